@@ -6,6 +6,7 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +15,8 @@ import (
 	"strings"
 
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/internal/event"
 	"golang.org/x/tools/internal/lsp/debug/tag"
 	"golang.org/x/tools/internal/lsp/protocol"
@@ -88,6 +91,7 @@ func (s *snapshot) ParseModHandle(ctx context.Context, modFH source.FileHandle)
 				parseErrors = append(parseErrors, *parseErr)
 			}
 			return &parseModData{
+				m:           m,
 				parseErrors: parseErrors,
 				err:         err,
 			}
@@ -131,6 +135,13 @@ func sumFilename(modURI span.URI) string {
 	return modURI.Filename()[:len(modURI.Filename())-len("mod")] + "sum"
 }
 
+// modURIForSum returns the URI of the go.mod file next to the go.sum file
+// sumURI.
+func modURIForSum(sumURI span.URI) span.URI {
+	filename := sumURI.Filename()
+	return span.URIFromPath(filename[:len(filename)-len("sum")] + "mod")
+}
+
 // extractModParseErrors processes the raw errors returned by modfile.Parse,
 // extracting the filenames and line numbers that correspond to the errors.
 func extractModParseErrors(uri span.URI, m *protocol.ColumnMapper, parseErr error, content []byte) (*source.Error, error) {
@@ -310,6 +321,11 @@ func (s *snapshot) ModUpgradeHandle(ctx context.Context) (source.ModUpgradeHandl
 	var (
 		cfg    = s.config(ctx)
 		tmpMod = s.view.tmpMod
+
+		// Running the go command with the -modfile flag modifies the
+		// config's build flags, so keep a separate config for the go
+		// commands that do not need it.
+		listCfg = s.config(ctx)
 	)
 	key := modKey{
 		sessionID: s.view.session.id,
@@ -358,6 +374,12 @@ func (s *snapshot) ModUpgradeHandle(ctx context.Context) (source.ModUpgradeHandl
 			latest = strings.TrimSuffix(latest, "]") // remove the "]"
 			upgrades[dep] = latest
 		}
+		// Only suggest upgrades that the module's go version can use.
+		if parsed.Go != nil {
+			if err := compatibleUpgrades(ctx, listCfg, pmh, parsed, upgrades); err != nil {
+				return &modUpgradeData{err: err}
+			}
+		}
 		return &modUpgradeData{
 			upgrades: upgrades,
 		}
@@ -371,6 +393,110 @@ func (s *snapshot) ModUpgradeHandle(ctx context.Context) (source.ModUpgradeHandl
 	return s.modUpgradeHandle, nil
 }
 
+// moduleGoVersion is the subset of the output of `go list -m -json` that
+// describes the go version required by a module version.
+type moduleGoVersion struct {
+	Path      string
+	Version   string
+	GoVersion string
+}
+
+// compatibleUpgrades replaces each of the given upgrades, which map module
+// paths to their latest versions, with the highest newer version whose go
+// directive is no higher than that of the parsed go.mod file. Upgrades
+// without such a version are removed.
+func compatibleUpgrades(ctx context.Context, cfg *packages.Config, pmh source.ParseModHandle, parsed *modfile.File, upgrades map[string]string) error {
+	if len(upgrades) == 0 {
+		return nil
+	}
+	current := make(map[string]string, len(parsed.Require))
+	for _, req := range parsed.Require {
+		current[req.Mod.Path] = req.Mod.Version
+	}
+	// Most latest versions are compatible, so check them all at once before
+	// inspecting the other versions of the incompatible ones.
+	var queries []string
+	for dep, latest := range upgrades {
+		queries = append(queries, dep+"@"+latest)
+	}
+	latest, err := listGoVersions(ctx, cfg, pmh, queries)
+	if err != nil {
+		return err
+	}
+	for _, mod := range latest {
+		if goVersionAllowed(mod.GoVersion, parsed.Go.Version) {
+			continue
+		}
+		_, stdout, err := runGoCommand(ctx, cfg, pmh, false, "list", []string{"-m", "-versions", mod.Path})
+		if err != nil {
+			return err
+		}
+		// The output is the module path, followed by its known versions.
+		queries = queries[:0]
+		versions := strings.Fields(stdout.String())
+		if len(versions) > 0 {
+			versions = versions[1:]
+		}
+		for _, v := range versions {
+			if semver.Compare(v, current[mod.Path]) > 0 && semver.Compare(v, mod.Version) < 0 {
+				queries = append(queries, mod.Path+"@"+v)
+			}
+		}
+		var candidates []moduleGoVersion
+		if len(queries) > 0 {
+			if candidates, err = listGoVersions(ctx, cfg, pmh, queries); err != nil {
+				return err
+			}
+		}
+		if v := latestCompatible(parsed.Go.Version, candidates); v != "" {
+			upgrades[mod.Path] = v
+		} else {
+			delete(upgrades, mod.Path)
+		}
+	}
+	return nil
+}
+
+// listGoVersions runs `go list -m -json` on the given module queries and
+// returns the go version required by each of the resulting modules.
+func listGoVersions(ctx context.Context, cfg *packages.Config, pmh source.ParseModHandle, queries []string) ([]moduleGoVersion, error) {
+	_, stdout, err := runGoCommand(ctx, cfg, pmh, false, "list", append([]string{"-e", "-m", "-json"}, queries...))
+	if err != nil {
+		return nil, err
+	}
+	var mods []moduleGoVersion
+	for dec := json.NewDecoder(stdout); dec.More(); {
+		var mod moduleGoVersion
+		if err := dec.Decode(&mod); err != nil {
+			return nil, err
+		}
+		mods = append(mods, mod)
+	}
+	return mods, nil
+}
+
+// latestCompatible returns the highest of the candidate versions that can
+// be used by a module with the given go version, or "" if there is none.
+func latestCompatible(goVersion string, candidates []moduleGoVersion) string {
+	var best string
+	for _, c := range candidates {
+		if !goVersionAllowed(c.GoVersion, goVersion) {
+			continue
+		}
+		if best == "" || semver.Compare(c.Version, best) > 0 {
+			best = c.Version
+		}
+	}
+	return best
+}
+
+// goVersionAllowed reports whether a dependency that requires the go version
+// depVersion can be used by a module that declares the go version modVersion.
+// Dependencies without a go directive can always be used.
+func goVersionAllowed(depVersion, modVersion string) bool {
+	return depVersion == "" || semver.Compare("v"+depVersion, "v"+modVersion) <= 0
+}
+
 // containsVendor reports whether the module has a vendor folder.
 func containsVendor(modURI span.URI) bool {
 	dir := filepath.Dir(modURI.Filename())
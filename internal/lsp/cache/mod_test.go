@@ -0,0 +1,112 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestLatestCompatible(t *testing.T) {
+	candidates := []moduleGoVersion{
+		{Path: "example.com/dep", Version: "v1.1.0", GoVersion: ""},
+		{Path: "example.com/dep", Version: "v1.3.0", GoVersion: "1.20"},
+		{Path: "example.com/dep", Version: "v1.2.0", GoVersion: "1.16"},
+		{Path: "example.com/dep", Version: "v1.4.0", GoVersion: "1.23"},
+	}
+	for _, tt := range []struct {
+		goVersion string
+		want      string
+	}{
+		{"1.14", "v1.1.0"},
+		{"1.16", "v1.2.0"},
+		{"1.20", "v1.3.0"},
+		{"1.23", "v1.4.0"},
+	} {
+		if got := latestCompatible(tt.goVersion, candidates); got != tt.want {
+			t.Errorf("latestCompatible(%q) = %q, want %q", tt.goVersion, got, tt.want)
+		}
+	}
+	if got := latestCompatible("1.14", candidates[1:]); got != "" {
+		t.Errorf("latestCompatible with no compatible candidates = %q, want none", got)
+	}
+}
+
+func TestSumChangeRefreshesParseModHandle(t *testing.T) {
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "sumchange")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	modURI := span.URIFromPath(filepath.Join(dir, "go.mod"))
+	sumURI := span.URIFromPath(filepath.Join(dir, "go.sum"))
+	if err := ioutil.WriteFile(modURI.Filename(), []byte("module example.com/m\n\ngo 1.14\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	session := New(ctx, nil).NewSession(ctx)
+	options := source.DefaultOptions()
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOPROXY=off")
+	_, snapshot, err := session.NewView(ctx, "sum_test", span.URIFromPath(dir), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := func(snapshot source.Snapshot) string {
+		t.Helper()
+		fh, err := snapshot.GetFile(ctx, modURI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pmh, err := snapshot.ParseModHandle(ctx, fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pmh.Sum() == nil {
+			return ""
+		}
+		content, err := pmh.Sum().Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+	if got := sum(snapshot); got != "" {
+		t.Fatalf("got go.sum %q before it exists", got)
+	}
+	// Both an unsaved edit and a change on disk are seen.
+	const edited = "example.com/a v1.0.0/go.mod h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n"
+	snapshots, err := session.DidModifyFiles(ctx, []source.FileModification{{
+		URI:        sumURI,
+		Action:     source.Open,
+		Version:    1,
+		Text:       []byte(edited),
+		LanguageID: "go.sum",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sum(snapshots[0]); got != edited {
+		t.Errorf("got go.sum %q after editing it, want %q", got, edited)
+	}
+	const downloaded = "example.com/b v1.0.0/go.mod h1:BBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBBB=\n"
+	if err := ioutil.WriteFile(sumURI.Filename(), []byte(downloaded), 0644); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err = session.DidModifyFiles(ctx, []source.FileModification{
+		{URI: sumURI, Action: source.Close, Version: -1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sum(snapshots[0]); got != downloaded {
+		t.Errorf("got go.sum %q after it changed on disk, want %q", got, downloaded)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"strings"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/internal/event"
@@ -22,6 +24,7 @@ import (
 type modTidyKey struct {
 	sessionID       string
 	cfg             string
+	compat          string
 	gomod           string
 	imports         string
 	unsavedOverlays string
@@ -31,7 +34,8 @@ type modTidyKey struct {
 type modTidyHandle struct {
 	handle *memoize.Handle
 
-	pmh source.ParseModHandle
+	pmh     source.ParseModHandle
+	options source.Options
 }
 
 type modTidyData struct {
@@ -45,6 +49,13 @@ type modTidyData struct {
 	// the go.mod file.
 	diagnostics []source.Error
 
+	// ideal is the go.mod file, as tidied by `go mod tidy`.
+	ideal *modfile.File
+
+	// idealSum is the contents of the go.sum file, as tidied by
+	// `go mod tidy`. It is nil if tidying produced no go.sum file.
+	idealSum []byte
+
 	err error
 }
 
@@ -57,6 +68,39 @@ func (mth *modTidyHandle) Tidy(ctx context.Context) (map[string]*modfile.Require
 	return data.missingDeps, data.diagnostics, data.err
 }
 
+func (mth *modTidyHandle) Ideal(ctx context.Context) (*modfile.File, error) {
+	v, err := mth.handle.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := v.(*modTidyData)
+	return data.ideal, data.err
+}
+
+func (mth *modTidyHandle) IdealSum(ctx context.Context) ([]byte, error) {
+	v, err := mth.handle.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data := v.(*modTidyData)
+	return data.idealSum, data.err
+}
+
+func (mth *modTidyHandle) TidyFile(ctx context.Context, file *modfile.File, m *protocol.ColumnMapper) (map[string]*modfile.Require, []source.Error, error) {
+	v, err := mth.handle.Get(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	data := v.(*modTidyData)
+	if data.err != nil {
+		return nil, nil, data.err
+	}
+	if data.ideal == nil {
+		return nil, data.diagnostics, nil
+	}
+	return tidyErrors(m.URI, m, file, data.ideal, mth.options)
+}
+
 func (s *snapshot) ModTidyHandle(ctx context.Context) (source.ModTidyHandle, error) {
 	if !s.view.tmpMod {
 		return nil, source.ErrTmpModfileUnsupported
@@ -101,6 +145,7 @@ func (s *snapshot) ModTidyHandle(ctx context.Context) (source.ModTidyHandle, err
 		unsavedOverlays: overlayHash,
 		gomod:           pmh.Mod().Identity().String(),
 		cfg:             hashConfig(cfg),
+		compat:          options.TidyCompat,
 	}
 	h := s.view.session.cache.store.Bind(key, func(ctx context.Context) interface{} {
 		ctx, done := event.Start(ctx, "cache.ModTidyHandle", tag.URI.Of(modURI))
@@ -113,15 +158,25 @@ func (s *snapshot) ModTidyHandle(ctx context.Context) (source.ModTidyHandle, err
 				err:         err,
 			}
 		}
-		tmpURI, inv, cleanup, err := goCommandInvocation(ctx, cfg, pmh, "mod", []string{"tidy"})
+		tmpURI, inv, cleanup, err := goCommandInvocation(ctx, cfg, pmh, "mod", tidyArgs(options))
 		if err != nil {
 			return &modTidyData{err: err}
 		}
-		// Keep the temporary go.mod file around long enough to parse it.
+		// Keep the temporary go.mod and go.sum files around long enough to
+		// read them.
 		defer cleanup()
 
-		if _, err := packagesinternal.GetGoCmdRunner(cfg).Run(ctx, *inv); err != nil {
-			return &modTidyData{err: err}
+		_, stderr, friendlyErr, err := packagesinternal.GetGoCmdRunner(cfg).RunRaw(ctx, *inv)
+		if friendlyErr != nil {
+			if ctx.Err() != nil {
+				return &modTidyData{err: friendlyErr}
+			}
+			// Keep the go command's stderr, which explains the failure.
+			return &modTidyData{err: &source.GoCommandError{
+				Command: "mod tidy",
+				Stderr:  strings.TrimSpace(stderr.String()),
+				Err:     err,
+			}}
 		}
 		// Go directly to disk to get the temporary mod file, since it is
 		// always on disk.
@@ -129,50 +184,77 @@ func (s *snapshot) ModTidyHandle(ctx context.Context) (source.ModTidyHandle, err
 		if err != nil {
 			return &modTidyData{err: err}
 		}
+		idealSum, err := ioutil.ReadFile(sumFilename(tmpURI))
+		if err != nil && !os.IsNotExist(err) {
+			return &modTidyData{err: err}
+		}
 		ideal, err := modfile.Parse(tmpURI.Filename(), tempContents, nil)
 		if err != nil {
 			// We do not need to worry about the temporary file's parse errors
 			// since it has been "tidied".
 			return &modTidyData{err: err}
 		}
-		// Get the dependencies that are different between the original and
-		// ideal go.mod files.
-		unusedDeps := make(map[string]*modfile.Require, len(original.Require))
-		missingDeps := make(map[string]*modfile.Require, len(ideal.Require))
-		for _, req := range original.Require {
-			unusedDeps[req.Mod.Path] = req
-		}
-		for _, req := range ideal.Require {
-			origDep := unusedDeps[req.Mod.Path]
-			if origDep != nil && origDep.Indirect == req.Indirect {
-				delete(unusedDeps, req.Mod.Path)
-			} else {
-				missingDeps[req.Mod.Path] = req
-			}
-		}
-		diagnostics, err := modRequireErrors(pmh.Mod().URI(), m, missingDeps, unusedDeps, options)
+		missingDeps, diagnostics, err := tidyErrors(pmh.Mod().URI(), m, original, ideal, options)
 		if err != nil {
 			return &modTidyData{err: err}
 		}
-		for _, req := range missingDeps {
-			if unusedDeps[req.Mod.Path] != nil {
-				delete(missingDeps, req.Mod.Path)
-			}
-		}
 		return &modTidyData{
 			missingDeps: missingDeps,
 			diagnostics: diagnostics,
+			ideal:       ideal,
+			idealSum:    idealSum,
 		}
 	})
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.modTidyHandle = &modTidyHandle{
-		handle: h,
-		pmh:    pmh,
+		handle:  h,
+		pmh:     pmh,
+		options: options,
 	}
 	return s.modTidyHandle, nil
 }
 
+// tidyArgs returns the arguments to `go mod` that tidy the go.mod file.
+func tidyArgs(options source.Options) []string {
+	args := []string{"tidy"}
+	if options.TidyCompat != "" {
+		args = append(args, "-compat="+options.TidyCompat)
+	}
+	return args
+}
+
+// tidyErrors compares the original go.mod file to its ideal, tidied form. It
+// returns the dependencies that are missing from the original file, along
+// with the errors for the require directives that differ between the two.
+func tidyErrors(uri span.URI, m *protocol.ColumnMapper, original, ideal *modfile.File, options source.Options) (map[string]*modfile.Require, []source.Error, error) {
+	// Get the dependencies that are different between the original and
+	// ideal go.mod files.
+	unusedDeps := make(map[string]*modfile.Require, len(original.Require))
+	missingDeps := make(map[string]*modfile.Require, len(ideal.Require))
+	for _, req := range original.Require {
+		unusedDeps[req.Mod.Path] = req
+	}
+	for _, req := range ideal.Require {
+		origDep := unusedDeps[req.Mod.Path]
+		if origDep != nil && origDep.Indirect == req.Indirect {
+			delete(unusedDeps, req.Mod.Path)
+		} else {
+			missingDeps[req.Mod.Path] = req
+		}
+	}
+	diagnostics, err := modRequireErrors(uri, m, missingDeps, unusedDeps, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, req := range missingDeps {
+		if unusedDeps[req.Mod.Path] != nil {
+			delete(missingDeps, req.Mod.Path)
+		}
+	}
+	return missingDeps, diagnostics, nil
+}
+
 // modRequireErrors extracts the errors that occur on the require directives.
 // It checks for directness issues and unused dependencies.
 func modRequireErrors(uri span.URI, m *protocol.ColumnMapper, missingDeps, unusedDeps map[string]*modfile.Require, options source.Options) ([]source.Error, error) {
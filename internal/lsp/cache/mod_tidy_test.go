@@ -0,0 +1,129 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"sort"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestTidyErrors(t *testing.T) {
+	const ideal = `module mod.com
+
+go 1.14
+
+require (
+	example.com/direct v1.0.0
+	example.com/indirect v1.0.0 // indirect
+)
+`
+	tests := []struct {
+		name, mod string
+		// wantMissing are the dependencies that tidy would add to the file.
+		wantMissing []string
+		// wantErrors are the messages of the errors reported for the file.
+		wantErrors []string
+	}{
+		{
+			name:       "tidy",
+			mod:        ideal,
+			wantErrors: nil,
+		},
+		{
+			name: "unused and missing",
+			mod: `module mod.com
+
+go 1.14
+
+require (
+	example.com/direct v1.0.0
+	example.com/unused v1.0.0
+)
+`,
+			wantMissing: []string{"example.com/indirect"},
+			wantErrors:  []string{"example.com/unused is not used in this module."},
+		},
+		{
+			name: "directness",
+			mod: `module mod.com
+
+go 1.14
+
+require (
+	example.com/direct v1.0.0 // indirect
+	example.com/indirect v1.0.0 // indirect
+)
+`,
+			wantErrors: []string{"example.com/direct should be a direct dependency."},
+		},
+	}
+	options := source.DefaultOptions()
+	idealFile, err := modfile.Parse("go.mod", []byte(ideal), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uri := span.URIFromPath("/tmp/go.mod")
+			m := &protocol.ColumnMapper{
+				URI:       uri,
+				Converter: span.NewContentConverter(uri.Filename(), []byte(tt.mod)),
+				Content:   []byte(tt.mod),
+			}
+			original, err := modfile.Parse(uri.Filename(), []byte(tt.mod), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			missing, errors, err := tidyErrors(uri, m, original, idealFile, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotMissing []string
+			for dep := range missing {
+				gotMissing = append(gotMissing, dep)
+			}
+			sort.Strings(gotMissing)
+			if !equalStrings(gotMissing, tt.wantMissing) {
+				t.Errorf("missing dependencies: got %v, want %v", gotMissing, tt.wantMissing)
+			}
+			var gotErrors []string
+			for _, e := range errors {
+				gotErrors = append(gotErrors, e.Message)
+			}
+			sort.Strings(gotErrors)
+			if !equalStrings(gotErrors, tt.wantErrors) {
+				t.Errorf("errors: got %v, want %v", gotErrors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestTidyArgs(t *testing.T) {
+	options := source.DefaultOptions()
+	if got := tidyArgs(options); len(got) != 1 || got[0] != "tidy" {
+		t.Errorf("got arguments %v by default, want [tidy]", got)
+	}
+	options.TidyCompat = "1.17"
+	if got := tidyArgs(options); len(got) != 2 || got[0] != "tidy" || got[1] != "-compat=1.17" {
+		t.Errorf("got arguments %v with a compat version, want [tidy -compat=1.17]", got)
+	}
+}
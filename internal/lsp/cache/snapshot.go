@@ -146,7 +146,13 @@ func (s *snapshot) RunGoCommandDirect(ctx context.Context, verb string, args []s
 }
 
 func (s *snapshot) RunGoCommand(ctx context.Context, verb string, args []string) (*bytes.Buffer, error) {
+	return s.RunGoCommandEnv(ctx, nil, verb, args)
+}
+
+func (s *snapshot) RunGoCommandEnv(ctx context.Context, env []string, verb string, args []string) (*bytes.Buffer, error) {
 	cfg := s.config(ctx)
+	// Later entries take precedence over the view's own environment.
+	cfg.Env = append(cfg.Env, env...)
 	var pmh source.ParseModHandle
 	if s.view.tmpMod {
 		modFH, err := s.GetFile(ctx, s.view.modURI)
@@ -860,6 +866,14 @@ func (s *snapshot) clone(ctx context.Context, withoutURIs map[span.URI]source.Fi
 			}
 			delete(result.parseModHandles, withoutURI)
 		}
+		if currentFH.Kind() == source.Sum {
+			// The parse handle of a go.mod file holds the go.sum file next
+			// to it, so it is rebuilt for the new go.sum contents. The
+			// go.mod file itself is not reparsed, as its parse is keyed by
+			// its contents, and the tidy handle is only invalidated above
+			// if the go.sum file was saved.
+			delete(result.parseModHandles, modURIForSum(withoutURI))
+		}
 
 		// If this is a file we don't yet know about,
 		// then we do not yet know what packages it should belong to.
@@ -998,6 +1012,11 @@ func (s *snapshot) shouldInvalidateMetadata(ctx context.Context, originalFH, cur
 	if kind := originalFH.Kind(); kind == source.Mod {
 		return originalFH.URI() == s.view.modURI
 	}
+	// The go.sum file only affects the go command's downloads, not package
+	// metadata.
+	if originalFH.Kind() == source.Sum {
+		return false
+	}
 	// Get the original and current parsed files in order to check package name and imports.
 	original, _, _, _, originalErr := s.view.session.cache.ParseGoHandle(ctx, originalFH, source.ParseHeader).Parse(ctx)
 	current, _, _, _, currentErr := s.view.session.cache.ParseGoHandle(ctx, currentFH, source.ParseHeader).Parse(ctx)
@@ -112,6 +112,10 @@ type View struct {
 	// Only possible with Go versions 1.14 and above.
 	tmpMod bool
 
+	// goversion is the minor version of the go command used by this view,
+	// e.g. 14 for go1.14. It is 0 if the view has no go.mod file.
+	goversion int
+
 	// goCommand indicates if the user is using the go command or some other
 	// build system.
 	goCommand bool
@@ -119,6 +123,11 @@ type View struct {
 	// `go env` variables that need to be tracked by gopls.
 	gocache, gomodcache, gopath, goprivate string
 
+	// gonosumdb is the effective GONOSUMDB, which defaults to GOPRIVATE, and
+	// gonosumcheck reports whether GONOSUMCHECK=1 disables go.sum checks.
+	gonosumdb    string
+	gonosumcheck bool
+
 	goEnv map[string]string
 }
 
@@ -175,6 +184,10 @@ func (v *View) ModFile() span.URI {
 	return v.modURI
 }
 
+func (v *View) GoVersion() int {
+	return v.goversion
+}
+
 // tempModFile creates a temporary go.mod file based on the contents of the
 // given go.mod file. It is the caller's responsibility to clean up the files
 // when they are done using them.
@@ -501,11 +514,22 @@ func (v *View) populateProcessEnv(ctx context.Context, modFH, sumFH source.FileH
 // envLocked returns the environment and build flags for the current view.
 // It assumes that the caller is holding the view's optionsMu.
 func (v *View) envLocked() ([]string, []string) {
-	env := append([]string{}, v.options.Env...)
+	env := goCommandEnv(v.options)
 	buildFlags := append([]string{}, v.options.BuildFlags...)
 	return env, buildFlags
 }
 
+// goCommandEnv returns the environment overrides for the go commands run
+// with the given options.
+func goCommandEnv(options source.Options) []string {
+	env := append([]string{}, options.Env...)
+	if options.ModCache != "" {
+		// Later values take precedence over earlier ones.
+		env = append(env, "GOMODCACHE="+options.ModCache)
+	}
+	return env
+}
+
 func (v *View) contains(uri span.URI) bool {
 	return strings.HasPrefix(string(uri), string(v.folder))
 }
@@ -774,13 +798,18 @@ func (v *View) setBuildInformation(ctx context.Context, folder span.URI, env []s
 	// check if the view has a valid build configuration.
 	v.setBuildConfiguration()
 
-	// The user has disabled the use of the -modfile flag or has no go.mod file.
-	if !modfileFlagEnabled || v.modURI == "" {
+	if v.modURI == "" {
 		return nil
 	}
-	if modfileFlag, err := v.modfileFlagExists(ctx, v.Options().Env); err != nil {
+	goversion, err := v.goVersion(ctx, goCommandEnv(v.Options()))
+	if err != nil {
 		return err
-	} else if modfileFlag {
+	}
+	v.goversion = goversion
+
+	// The -modfile flag is available in Go 1.14 and above, unless the user
+	// has disabled its use.
+	if modfileFlagEnabled && goversion >= 14 {
 		v.tmpMod = true
 	}
 	return nil
@@ -829,6 +858,7 @@ func (v *View) setGoEnv(ctx context.Context, configEnv []string) (string, error)
 		"GOCACHE":    &v.gocache,
 		"GOPATH":     &v.gopath,
 		"GOPRIVATE":  &v.goprivate,
+		"GONOSUMDB":  &v.gonosumdb,
 		"GOMODCACHE": &v.gomodcache,
 		"GOMOD":      &gomod,
 	}
@@ -863,6 +893,15 @@ func (v *View) setGoEnv(ctx context.Context, configEnv []string) (string, error)
 		v.gomodcache = filepath.Join(filepath.SplitList(v.gopath)[0], "pkg/mod")
 	}
 
+	// Nor is GONOSUMCHECK, which is not documented by `go help environment`.
+	// As in the go command, the last setting wins.
+	v.gonosumcheck = false
+	for _, kv := range configEnv {
+		if strings.HasPrefix(kv, "GONOSUMCHECK=") {
+			v.gonosumcheck = kv == "GONOSUMCHECK=1"
+		}
+	}
+
 	// The value of GOPACKAGESDRIVER is not returned through the go command.
 	gopackagesdriver := os.Getenv("GOPACKAGESDRIVER")
 	v.goCommand = gopackagesdriver == "" || gopackagesdriver == "off"
@@ -873,6 +912,18 @@ func (v *View) IsGoPrivatePath(target string) bool {
 	return globsMatchPath(v.goprivate, target)
 }
 
+func (v *View) SkipsSumCheck(target string) bool {
+	return v.gonosumcheck || globsMatchPath(v.gonosumdb, target)
+}
+
+func (v *View) GoFlags() []string {
+	return strings.Fields(v.goEnv["GOFLAGS"])
+}
+
+func (v *View) GOPATH() []string {
+	return filepath.SplitList(v.gopath)
+}
+
 // Copied from
 // https://cs.opensource.google/go/go/+/master:src/cmd/go/internal/str/path.go;l=58;drc=2910c5b4a01a573ebc97744890a07c1a3122c67a
 func globsMatchPath(globs, target string) bool {
@@ -915,12 +966,12 @@ func globsMatchPath(globs, target string) bool {
 	return false
 }
 
-// This function will return the main go.mod file for this folder if it exists and whether the -modfile
-// flag exists for this version of go.
-func (v *View) modfileFlagExists(ctx context.Context, env []string) (bool, error) {
+// goVersion returns the minor version of the go command for this folder,
+// e.g. 14 for go1.14, by inspecting its release tags.
+func (v *View) goVersion(ctx context.Context, env []string) (int, error) {
 	// Check the go version by running "go list" with modules off.
 	// Borrowed from internal/imports/mod.go:620.
-	const format = `{{range context.ReleaseTags}}{{if eq . "go1.14"}}{{.}}{{end}}{{end}}`
+	const format = `{{context.ReleaseTags}}`
 	folder := v.folder.Filename()
 	inv := gocommand.Invocation{
 		Verb:       "list",
@@ -930,13 +981,20 @@ func (v *View) modfileFlagExists(ctx context.Context, env []string) (bool, error
 	}
 	stdout, err := v.session.gocmdRunner.Run(ctx, inv)
 	if err != nil {
-		return false, err
-	}
-	// If the output is not go1.14 or an empty string, then it could be an error.
-	lines := strings.Split(stdout.String(), "\n")
-	if len(lines) < 2 && stdout.String() != "" {
-		event.Error(ctx, "unexpected stdout when checking for go1.14", errors.Errorf("%q", stdout), tag.Directory.Of(folder))
-		return false, nil
+		return 0, err
+	}
+	// The output is of the form "[go1.1 go1.2 ... go1.14]".
+	tags := strings.Fields(strings.Trim(strings.TrimSpace(stdout.String()), "[]"))
+	if len(tags) == 0 {
+		event.Error(ctx, "unexpected stdout when checking the go version", errors.Errorf("%q", stdout), tag.Directory.Of(folder))
+		return 0, nil
+	}
+	var goversion int
+	for _, t := range tags {
+		var minor int
+		if _, err := fmt.Sscanf(t, "go1.%d", &minor); err == nil && minor > goversion {
+			goversion = minor
+		}
 	}
-	return lines[0] == "go1.14", nil
+	return goversion, nil
 }
@@ -7,7 +7,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
 )
 
 func TestCaseInsensitiveFilesystem(t *testing.T) {
@@ -43,3 +46,20 @@ func TestCaseInsensitiveFilesystem(t *testing.T) {
 		}
 	}
 }
+
+func TestGoCommandEnv(t *testing.T) {
+	options := source.DefaultOptions()
+	options.Env = []string{"GOMODCACHE=/env", "GOFLAGS=-mod=mod"}
+	if got := goCommandEnv(options); strings.Join(got, " ") != "GOMODCACHE=/env GOFLAGS=-mod=mod" {
+		t.Errorf("got env %v without an override", got)
+	}
+	options.ModCache = "/override"
+	v := &View{options: options}
+	env, _ := v.envLocked()
+	if got := env[len(env)-1]; got != "GOMODCACHE=/override" {
+		t.Errorf("got last env entry %q, want the GOMODCACHE override", got)
+	}
+	if len(options.Env) != 2 {
+		t.Errorf("goCommandEnv modified the options' env: %v", options.Env)
+	}
+}
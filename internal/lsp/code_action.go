@@ -68,6 +68,132 @@ func (s *Server) codeAction(ctx context.Context, params *protocol.CodeActionPara
 				},
 			})
 		}
+		if wanted[protocol.SourceFixAll] {
+			edits, err := mod.Normalize(ctx, snapshot, fh)
+			if err != nil {
+				return nil, err
+			}
+			if len(edits) > 0 {
+				codeActions = append(codeActions, protocol.CodeAction{
+					Title: "Normalize go.mod",
+					Kind:  protocol.SourceFixAll,
+					Edit: protocol.WorkspaceEdit{
+						DocumentChanges: documentChanges(fh, edits),
+					},
+				})
+			}
+		}
+		if wanted[protocol.RefactorRewrite] {
+			edits, _, err := mod.CanonicalizeVersions(ctx, snapshot, fh)
+			if err != nil {
+				return nil, err
+			}
+			if len(edits) > 0 {
+				codeActions = append(codeActions, protocol.CodeAction{
+					Title: "Canonicalize versions",
+					Kind:  protocol.RefactorRewrite,
+					Edit: protocol.WorkspaceEdit{
+						DocumentChanges: documentChanges(fh, edits),
+					},
+				})
+			}
+			edits, err = mod.MergeRequireBlocks(ctx, snapshot, fh)
+			if err != nil {
+				return nil, err
+			}
+			if len(edits) > 0 {
+				codeActions = append(codeActions, protocol.CodeAction{
+					Title: "Merge require blocks",
+					Kind:  protocol.RefactorRewrite,
+					Edit: protocol.WorkspaceEdit{
+						DocumentChanges: documentChanges(fh, edits),
+					},
+				})
+			}
+			// Whether a versioned module path is published is only checked
+			// when the command runs, as that may need network access.
+			incompatible, err := mod.IncompatibleRequires(ctx, snapshot, fh, params.Range)
+			if err != nil {
+				return nil, err
+			}
+			for _, req := range incompatible {
+				cmd := mod.MigrateIncompatibleCommand(uri, req.Path, req.Version)
+				codeActions = append(codeActions, protocol.CodeAction{
+					Title:   cmd.Title,
+					Kind:    protocol.RefactorRewrite,
+					Command: cmd,
+				})
+			}
+			// The version to downgrade to is only listed when the command
+			// runs, as that may need network access.
+			downgrades, err := mod.Downgrades(ctx, snapshot, fh, params.Range)
+			if err != nil {
+				return nil, err
+			}
+			for _, downgrade := range downgrades {
+				cmd := mod.DowngradeDependencyCommand(uri, downgrade.Path, downgrade.From)
+				codeActions = append(codeActions, protocol.CodeAction{
+					Title:   cmd.Title,
+					Kind:    protocol.RefactorRewrite,
+					Command: cmd,
+				})
+			}
+			changes, err := mod.DirectnessChanges(ctx, snapshot, fh, params.Range)
+			if err != nil {
+				return nil, err
+			}
+			for _, change := range changes {
+				codeActions = append(codeActions, protocol.CodeAction{
+					Title: change.Title(),
+					Kind:  protocol.RefactorRewrite,
+					Edit: protocol.WorkspaceEdit{
+						DocumentChanges: documentChanges(fh, change.Edits),
+					},
+				})
+			}
+			exclusions, err := mod.Exclusions(ctx, snapshot, fh, params.Range)
+			if err != nil {
+				return nil, err
+			}
+			for _, exclusion := range exclusions {
+				codeActions = append(codeActions, protocol.CodeAction{
+					Title: fmt.Sprintf("Exclude %s %s", exclusion.Path, exclusion.Version),
+					Kind:  protocol.RefactorRewrite,
+					Edit: protocol.WorkspaceEdit{
+						DocumentChanges: documentChanges(fh, exclusion.Edits),
+					},
+				})
+			}
+			// Annotating indirect requirements runs `go mod why`, so it is
+			// left to the command.
+			annotate := mod.AnnotateIndirectCommand(uri)
+			codeActions = append(codeActions, protocol.CodeAction{
+				Title:   annotate.Title,
+				Kind:    protocol.RefactorRewrite,
+				Command: annotate,
+			})
+			edits, err = mod.StripViaComments(ctx, snapshot, fh)
+			if err != nil {
+				return nil, err
+			}
+			if len(edits) > 0 {
+				codeActions = append(codeActions, protocol.CodeAction{
+					Title: "Remove the annotations of indirect requires",
+					Kind:  protocol.RefactorRewrite,
+					Edit: protocol.WorkspaceEdit{
+						DocumentChanges: documentChanges(fh, edits),
+					},
+				})
+			}
+			// Computing the minimal go directive lists the whole module graph,
+			// so it is left to the command.
+			cmd := mod.MinimalGoDirectiveCommand(uri)
+			codeActions = append(codeActions, protocol.CodeAction{
+				Title:   cmd.Title,
+				Kind:    protocol.RefactorRewrite,
+				Command: cmd,
+			})
+		}
 	case source.Go:
 		// Don't suggest fixes for generated files, since they are generally
 		// not useful and some editors may apply them automatically on save.
@@ -206,7 +332,7 @@ func getModuleQuickFixes(ctx context.Context, snapshot source.Snapshot, diagnost
 			continue
 		}
 		codeActions = append(codeActions, protocol.CodeAction{
-			Title:       fmt.Sprintf("Add %s to go.mod", dep),
+			Title:       snapshot.View().Options().FixTitle(fmt.Sprintf("Add %s to go.mod", dep)),
 			Diagnostics: []protocol.Diagnostic{diagnostic},
 			Edit: protocol.WorkspaceEdit{
 				DocumentChanges: []protocol.TextDocumentEdit{edit},
@@ -10,6 +10,8 @@ import (
 	"io"
 	"strings"
 
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/lsp/mod"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/span"
@@ -87,10 +89,379 @@ func (s *Server) executeCommand(ctx context.Context, params *protocol.ExecuteCom
 		deps := params.Arguments[1].(string)
 		err := s.directGoModCommand(ctx, uri, "get", strings.Split(deps, " ")...)
 		return nil, err
+	case source.CommandSharedRequires:
+		var align bool
+		if len(params.Arguments) > 0 {
+			var ok bool
+			if align, ok = params.Arguments[0].(bool); !ok {
+				return nil, errors.Errorf("expected align to be a boolean but got %T", params.Arguments[0])
+			}
+		}
+		return s.sharedRequires(ctx, align)
+	case source.CommandAddDependency:
+		uri, path, version, err := mod.AddDependencyArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		err = s.directGoModCommand(ctx, protocol.URIFromSpanURI(uri), "get", path+"@"+version)
+		return nil, err
+	case source.CommandInitModFile:
+		if len(params.Arguments) != 1 {
+			return nil, errors.Errorf("expected 1 argument, got %v", params.Arguments)
+		}
+		dir, ok := params.Arguments[0].(string)
+		if !ok {
+			return nil, errors.Errorf("expected a directory URI but got %T", params.Arguments[0])
+		}
+		return nil, s.initModFile(ctx, protocol.DocumentURI(dir).SpanURI())
+	case source.CommandMigrateReplaces:
+		if len(params.Arguments) != 1 {
+			return nil, errors.Errorf("expected 1 argument, got %v", params.Arguments)
+		}
+		uri, ok := params.Arguments[0].(string)
+		if !ok {
+			return nil, errors.Errorf("expected a go.mod URI but got %T", params.Arguments[0])
+		}
+		return s.migrateReplaces(ctx, protocol.DocumentURI(uri).SpanURI())
+	case source.CommandChangelog:
+		path, from, to, err := mod.ChangelogArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return mod.FetchChangelog(ctx, s.session.Options(), path, from, to)
+	case source.CommandMinimalReproducer:
+		uri, path, err := mod.MinimalReproducerArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		view, err := s.session.ViewOf(uri)
+		if err != nil {
+			return nil, err
+		}
+		return mod.MinimalReproducer(ctx, view.Snapshot(), path)
+	case source.CommandRegenerateSum:
+		uri, err := mod.RegenerateSumArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.regenerateSum(ctx, uri)
+	case source.CommandRewriteImports:
+		uri, oldPath, err := mod.RewriteImportsArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.rewriteImports(ctx, uri, oldPath)
+	case source.CommandMinimalGoDirective:
+		uri, err := mod.MinimalGoDirectiveArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.setMinimalGoDirective(ctx, uri)
+	case source.CommandDowngradeDependency:
+		uri, path, from, err := mod.DowngradeDependencyArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		view, err := s.session.ViewOf(uri)
+		if err != nil {
+			return nil, err
+		}
+		query, err := mod.DowngradeQuery(ctx, view.Snapshot(), path, from)
+		if err != nil {
+			return nil, err
+		}
+		err = s.directGoModCommand(ctx, protocol.URIFromSpanURI(uri), "get", query)
+		return nil, err
+	case source.CommandMigrateIncompatible:
+		uri, path, version, err := mod.MigrateIncompatibleArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.migrateIncompatible(ctx, uri, module.Version{Path: path, Version: version})
+	case source.CommandAnnotateIndirect:
+		uri, err := mod.AnnotateIndirectArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.annotateIndirect(ctx, uri)
+	case source.CommandDownloadModule:
+		uri, path, version, err := mod.DownloadModuleArgs(params.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		err = s.directGoModCommand(ctx, protocol.URIFromSpanURI(uri), "mod", "download", path+"@"+version)
+		return nil, err
 	}
 	return nil, nil
 }
 
+// migrateReplaces asks the client to move the directory replacements of the
+// go.mod file uri to a go.work file, and reports the replacements that were
+// migrated and skipped.
+func (s *Server) migrateReplaces(ctx context.Context, uri span.URI) (*mod.WorkMigration, error) {
+	view, err := s.session.ViewOf(uri)
+	if err != nil {
+		return nil, err
+	}
+	migration, edits, err := mod.MigrateReplacesToWork(ctx, view.Snapshot())
+	if err != nil {
+		return nil, err
+	}
+	if len(edits) == 0 {
+		return migration, nil
+	}
+	var changes []protocol.TextDocumentEdit
+	for uri, fileEdits := range edits {
+		fh, err := s.session.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, documentChanges(fh, fileEdits)...)
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: "Migrate replacements to go.work",
+		Edit: protocol.WorkspaceEdit{
+			DocumentChanges: changes,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Applied {
+		return nil, errors.Errorf("failed to migrate replacements: %s", resp.FailureReason)
+	}
+	return migration, nil
+}
+
+// initModFile asks the client to create a go.mod file in dir.
+func (s *Server) initModFile(ctx context.Context, dir span.URI) error {
+	view, err := s.session.ViewOf(dir)
+	if err != nil {
+		return err
+	}
+	edit, err := mod.InitModFile(ctx, view.Snapshot(), dir)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: "Create go.mod",
+		Edit:  edit,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return errors.Errorf("failed to create go.mod: %s", resp.FailureReason)
+	}
+	return nil
+}
+
+// regenerateSum asks the client to replace the go.sum file of the go.mod file
+// uri with one rebuilt from scratch, then invalidates the go.sum file, so
+// that the following diagnostics use the new checksums.
+func (s *Server) regenerateSum(ctx context.Context, uri span.URI) error {
+	view, err := s.session.ViewOf(uri)
+	if err != nil {
+		return err
+	}
+	edit, err := mod.RegenerateSum(ctx, view.Snapshot())
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: "Regenerate go.sum",
+		Edit:  edit,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return errors.Errorf("failed to regenerate go.sum: %s", resp.FailureReason)
+	}
+	var mods []source.FileModification
+	for _, change := range edit.DocumentChanges {
+		mods = append(mods, source.FileModification{
+			URI:    change.TextDocument.URI.SpanURI(),
+			Action: source.InvalidateMetadata,
+		})
+	}
+	_, err = s.didModifyFiles(ctx, mods, FromRegenerateSum)
+	return err
+}
+
+// rewriteImports asks the client to rewrite the imports of oldPath, the
+// previous path of the module of the go.mod file uri, in all of its files.
+func (s *Server) rewriteImports(ctx context.Context, uri span.URI, oldPath string) error {
+	view, err := s.session.ViewOf(uri)
+	if err != nil {
+		return err
+	}
+	edit, err := mod.RewriteImports(ctx, view.Snapshot(), oldPath)
+	if err != nil {
+		return err
+	}
+	if len(edit.DocumentChanges) == 0 {
+		return nil
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: "Rewrite the imports of " + oldPath,
+		Edit:  edit,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return errors.Errorf("failed to rewrite the imports of %s: %s", oldPath, resp.FailureReason)
+	}
+	return nil
+}
+
+// migrateIncompatible asks the client to move the +incompatible requirement
+// old of the go.mod file uri, and the imports of its packages, to the
+// versioned module path.
+func (s *Server) migrateIncompatible(ctx context.Context, uri span.URI, old module.Version) error {
+	view, err := s.session.ViewOf(uri)
+	if err != nil {
+		return err
+	}
+	snapshot := view.Snapshot()
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return err
+	}
+	to, edit, err := mod.MigrateIncompatible(ctx, snapshot, fh, old)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: fmt.Sprintf("Migrate %s to %s %s", old.Path, to.Path, to.Version),
+		Edit:  edit,
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return errors.Errorf("failed to migrate %s: %s", old.Path, resp.FailureReason)
+	}
+	return nil
+}
+
+// setMinimalGoDirective asks the client to set the go directive of the go.mod
+// file uri to the lowest version under which its module still builds.
+func (s *Server) setMinimalGoDirective(ctx context.Context, uri span.URI) error {
+	view, err := s.session.ViewOf(uri)
+	if err != nil {
+		return err
+	}
+	snapshot := view.Snapshot()
+	version, err := mod.MinimalGoDirective(ctx, snapshot)
+	if err != nil {
+		return err
+	}
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return err
+	}
+	edits, err := mod.GoDirectiveEdits(ctx, snapshot, fh, version)
+	if err != nil {
+		return err
+	}
+	if len(edits) == 0 {
+		return s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+			Type:    protocol.Info,
+			Message: fmt.Sprintf("The go directive is already the minimal version, go %s.", version),
+		})
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: "Set the go directive to go " + version,
+		Edit: protocol.WorkspaceEdit{
+			DocumentChanges: documentChanges(fh, edits),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return errors.Errorf("failed to set the go directive: %s", resp.FailureReason)
+	}
+	return nil
+}
+
+// annotateIndirect asks the client to annotate the indirect requirements of
+// the go.mod file uri with the direct dependencies that need them.
+func (s *Server) annotateIndirect(ctx context.Context, uri span.URI) error {
+	view, err := s.session.ViewOf(uri)
+	if err != nil {
+		return err
+	}
+	snapshot := view.Snapshot()
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return err
+	}
+	edits, err := mod.ViaComments(ctx, snapshot, fh)
+	if err != nil {
+		return err
+	}
+	if len(edits) == 0 {
+		return s.client.ShowMessage(ctx, &protocol.ShowMessageParams{
+			Type:    protocol.Info,
+			Message: "There are no indirect requires to annotate.",
+		})
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: "Annotate indirect requires",
+		Edit: protocol.WorkspaceEdit{
+			DocumentChanges: documentChanges(fh, edits),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if !resp.Applied {
+		return errors.Errorf("failed to annotate indirect requires: %s", resp.FailureReason)
+	}
+	return nil
+}
+
+// sharedRequires reports the dependencies shared by the modules of all views
+// in the session. If align is true, it also asks the client to align the
+// versions of the shared dependencies.
+func (s *Server) sharedRequires(ctx context.Context, align bool) ([]*mod.SharedRequire, error) {
+	var snapshots []source.Snapshot
+	for _, view := range s.session.Views() {
+		snapshots = append(snapshots, view.Snapshot())
+	}
+	shared, edits, err := mod.SharedRequires(ctx, snapshots, align)
+	if err != nil {
+		return nil, err
+	}
+	if len(edits) == 0 {
+		return shared, nil
+	}
+	var changes []protocol.TextDocumentEdit
+	for uri, fileEdits := range edits {
+		fh, err := s.session.GetFile(ctx, uri)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, documentChanges(fh, fileEdits)...)
+	}
+	resp, err := s.client.ApplyEdit(ctx, &protocol.ApplyWorkspaceEditParams{
+		Label: "Align shared dependencies",
+		Edit: protocol.WorkspaceEdit{
+			DocumentChanges: changes,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Applied {
+		return nil, errors.Errorf("failed to align shared dependencies: %s", resp.FailureReason)
+	}
+	return shared, nil
+}
+
 func (s *Server) directGoModCommand(ctx context.Context, uri protocol.DocumentURI, verb string, args ...string) error {
 	view, err := s.session.ViewOf(uri.SpanURI())
 	if err != nil {
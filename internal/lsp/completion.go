@@ -11,6 +11,7 @@ import (
 
 	"golang.org/x/tools/internal/event"
 	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/mod"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 )
@@ -26,7 +27,14 @@ func (s *Server) completion(ctx context.Context, params *protocol.CompletionPara
 	case source.Go:
 		candidates, surrounding, err = source.Completion(ctx, snapshot, fh, params.Position)
 	case source.Mod:
-		candidates, surrounding = nil, nil
+		list, err := mod.Completion(ctx, snapshot, fh, params.Position)
+		if err != nil {
+			event.Error(ctx, "no completions found", err, tag.Position.Of(params.Position))
+			return &protocol.CompletionList{
+				Items: []protocol.CompletionItem{},
+			}, nil
+		}
+		return list, nil
 	}
 	if err != nil {
 		event.Error(ctx, "no completions found", err, tag.Position.Of(params.Position))
@@ -60,7 +60,7 @@ func (s *Server) diagnose(ctx context.Context, snapshot source.Snapshot, alwaysA
 	var wg sync.WaitGroup
 
 	// Diagnose the go.mod file.
-	reports, missingModules, err := mod.Diagnostics(ctx, snapshot)
+	reports, missingModules, err := mod.Diagnostics(ctx, snapshot, false)
 	if err != nil {
 		event.Error(ctx, "warning: diagnose go.mod", err, tag.Directory.Of(snapshot.View().Folder().Filename()))
 	}
@@ -86,6 +86,10 @@ func (s *Server) diagnose(ctx context.Context, snapshot source.Snapshot, alwaysA
 		}
 		allReports[key] = diags
 	}
+	s.diagnoseWorkspace(ctx, snapshot, allReports)
+	if ctx.Err() != nil {
+		return nil, nil
+	}
 
 	// Diagnose all of the packages in the workspace.
 	wsPackages, err := snapshot.WorkspacePackages(ctx)
@@ -152,6 +156,39 @@ See https://github.com/golang/go/issues/39164 for more detail on this issue.`,
 	return allReports, shows
 }
 
+// diagnoseWorkspace adds the diagnostics that compare the modules of all of
+// the session's views to reports, which hold the diagnostics of snapshot.
+// Only the diagnostics of the files that belong to snapshot's view are kept,
+// so that each file's diagnostics are published by a single view; those of
+// the view's go.mod file are merged into its report.
+func (s *Server) diagnoseWorkspace(ctx context.Context, snapshot source.Snapshot, reports map[diagnosticKey][]*source.Diagnostic) {
+	session := snapshot.View().Session()
+	snapshots := []source.Snapshot{snapshot}
+	for _, view := range session.Views() {
+		if view != snapshot.View() {
+			snapshots = append(snapshots, view.Snapshot())
+		}
+	}
+	workReports, err := mod.WorkDiagnostics(ctx, snapshots)
+	if err != nil {
+		event.Error(ctx, "warning: diagnose workspace", err, tag.Directory.Of(snapshot.View().Folder().Filename()))
+		return
+	}
+	for id, diags := range workReports {
+		if view, err := session.ViewOf(id.URI); err != nil || view != snapshot.View() {
+			continue
+		}
+		key := diagnosticKey{id: id}
+		for k := range reports {
+			if k.id.URI == id.URI {
+				key = k
+				break
+			}
+		}
+		reports[key] = append(reports[key], diags...)
+	}
+}
+
 func (s *Server) publishReports(ctx context.Context, snapshot source.Snapshot, reports map[diagnosticKey][]*source.Diagnostic) {
 	// Check for context cancellation before publishing diagnostics.
 	if ctx.Err() != nil {
@@ -243,14 +280,18 @@ func toProtocolDiagnostics(diagnostics []*source.Diagnostic) []protocol.Diagnost
 				Message: rel.Message,
 			})
 		}
-		reports = append(reports, protocol.Diagnostic{
+		report := protocol.Diagnostic{
 			Message:            strings.TrimSpace(diag.Message), // go list returns errors prefixed by newline
 			Range:              diag.Range,
 			Severity:           diag.Severity,
 			Source:             diag.Source,
 			Tags:               diag.Tags,
 			RelatedInformation: related,
-		})
+		}
+		if diag.CodeHref != "" {
+			report.Code = protocol.DiagnosticCode{Value: diag.Source, Target: diag.CodeHref}
+		}
+		reports = append(reports, report)
 	}
 	return reports
 }
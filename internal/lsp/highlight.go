@@ -9,16 +9,23 @@ import (
 
 	"golang.org/x/tools/internal/event"
 	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/mod"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
 )
 
 func (s *Server) documentHighlight(ctx context.Context, params *protocol.DocumentHighlightParams) ([]protocol.DocumentHighlight, error) {
-	snapshot, fh, ok, err := s.beginFileRequest(ctx, params.TextDocument.URI, source.Go)
+	snapshot, fh, ok, err := s.beginFileRequest(ctx, params.TextDocument.URI, source.UnknownKind)
 	if !ok {
 		return nil, err
 	}
-	rngs, err := source.Highlight(ctx, snapshot, fh, params.Position)
+	var rngs []protocol.Range
+	switch fh.Kind() {
+	case source.Mod:
+		rngs, err = mod.DocumentHighlight(ctx, snapshot, fh, params.Position)
+	case source.Go:
+		rngs, err = source.Highlight(ctx, snapshot, fh, params.Position)
+	}
 	if err != nil {
 		event.Error(ctx, "no highlight", err, tag.URI.Of(params.TextDocument.URI))
 	}
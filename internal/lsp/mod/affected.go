@@ -0,0 +1,60 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// AffectedModFiles returns the go.mod files of the workspace, sorted by URI,
+// that require the module path at a version other than version, and so would
+// change if every module were made to require that version. The workspace
+// consists of the modules used by a go.work file, if there is one, and
+// otherwise of the modules of the views of the snapshot's session.
+func AffectedModFiles(ctx context.Context, snapshot source.Snapshot, path, version string) ([]span.URI, error) {
+	ctx, done := event.Start(ctx, "mod.AffectedModFiles")
+	defer done()
+
+	// The given snapshot is used for its own view, as it may be newer than
+	// the view's current snapshot.
+	snapshots := []source.Snapshot{snapshot}
+	for _, view := range snapshot.View().Session().Views() {
+		if view != snapshot.View() {
+			snapshots = append(snapshots, view.Snapshot())
+		}
+	}
+	work, err := readParsedWork(ctx, snapshots)
+	if err != nil {
+		return nil, err
+	}
+	members, _, err := workMembers(ctx, snapshots, work)
+	if err != nil {
+		return nil, err
+	}
+	return affectedMembers(members, path, version), nil
+}
+
+// affectedMembers returns the URIs of the members that require path at a
+// version other than version, sorted.
+func affectedMembers(members []workMember, path, version string) []span.URI {
+	var uris []span.URI
+	for _, member := range members {
+		for _, req := range member.file.Require {
+			if req.Mod.Path == path && req.Mod.Version != version {
+				uris = append(uris, member.uri)
+				break
+			}
+		}
+	}
+	sort.Slice(uris, func(i, j int) bool {
+		return uris[i] < uris[j]
+	})
+	return uris
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const breakageCategory = "known breakage"
+
+// checkKnownBreakages reports the requirements on versions that the
+// KnownBreakages option lists as broken with the go version of the go
+// directive, on their version, with a fix that upgrades them to the first
+// fixed version. Files without a go directive are not checked, as the go
+// version they build with is not known.
+func checkKnownBreakages(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	if len(options.KnownBreakages) == 0 || file.Go == nil {
+		return nil, nil
+	}
+	minor := goMinor(file.Go.Version)
+	if minor == 0 {
+		return nil, nil
+	}
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Syntax == nil {
+			continue
+		}
+		for _, b := range options.KnownBreakages {
+			if b.Module != req.Mod.Path || semver.Compare(req.Mod.Version, b.Fixed) >= 0 || minor < goMinor(b.Go) {
+				continue
+			}
+			rng, err := tokenRange(uri, m, req.Syntax, len(req.Syntax.Token)-1)
+			if err != nil {
+				return nil, err
+			}
+			msg := fmt.Sprintf("%s %s is known not to build with go %s and later", req.Mod.Path, req.Mod.Version, b.Go)
+			if b.Reason != "" {
+				msg += ": " + strings.TrimSuffix(b.Reason, ".")
+			}
+			errors = append(errors, source.Error{
+				Category: breakageCategory,
+				Message:  fmt.Sprintf("%s. The go directive selects go %s; upgrade to %s or later.", msg, file.Go.Version, b.Fixed),
+				Range:    rng,
+				URI:      uri,
+				SuggestedFixes: []source.SuggestedFix{{
+					Title: fmt.Sprintf("Upgrade to %s %s", req.Mod.Path, b.Fixed),
+					Edits: map[span.URI][]protocol.TextEdit{
+						uri: {{Range: rng, NewText: b.Fixed}},
+					},
+				}},
+			})
+			break
+		}
+	}
+	return errors, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestKnownBreakages(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.15
+
+require (
+	example.com/old v1.0.0
+	example.com/fixed v1.4.2
+	example.com/later v1.0.0
+	example.com/unlisted v0.1.0
+)
+`
+	options := source.DefaultOptions()
+	for _, result := range source.SetOptions(&options, map[string]interface{}{
+		"knownBreakages": []interface{}{
+			map[string]interface{}{"module": "example.com/old", "fixed": "v1.4.2", "go": "1.14", "reason": "it uses a removed syscall."},
+			map[string]interface{}{"module": "example.com/fixed", "fixed": "v1.4.2", "go": "1.14"},
+			map[string]interface{}{"module": "example.com/later", "fixed": "v1.1.0", "go": "1.16"},
+		},
+	}) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkKnownBreakages(uri, m, file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if want := "example.com/old v1.0.0 is known not to build with go 1.14 and later: it uses a removed syscall. The go directive selects go 1.15; upgrade to v1.4.2 or later."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if got := rangeText(t, m, e.Range); got != "v1.0.0" {
+		t.Errorf("got range covering %q, want the version", got)
+	}
+	if fixes := e.SuggestedFixes; len(fixes) != 1 || len(fixes[0].Edits[uri]) != 1 || fixes[0].Edits[uri][0].NewText != "v1.4.2" {
+		t.Errorf("got fixes %v, want an upgrade to v1.4.2", e.SuggestedFixes)
+	}
+	if diag := toDiagnostic(e); diag.Source != breakageCategory {
+		t.Errorf("got source %q, want %q", diag.Source, breakageCategory)
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"module": "example.com/old", "fixed": "1.4.2", "go": "1.14"},
+		{"module": "example.com/old", "fixed": "v1.4.2"},
+		{"fixed": "v1.4.2", "go": "1.14"},
+	} {
+		options := source.DefaultOptions()
+		results := source.SetOptions(&options, map[string]interface{}{
+			"knownBreakages": []interface{}{bad},
+		})
+		if len(results) != 1 || results[0].Error == nil {
+			t.Errorf("got no error for the invalid entry %v", bad)
+		}
+		if len(options.KnownBreakages) != 0 {
+			t.Errorf("got breakages %v from the invalid entry %v", options.KnownBreakages, bad)
+		}
+	}
+}
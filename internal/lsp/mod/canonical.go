@@ -0,0 +1,208 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+const versionCategory = "version"
+
+// CanonicalizeVersions returns the edits that rewrite each version in the
+// require, replace, and exclude directives of the go.mod file to its
+// canonical form, such as v1.2.0 for v1.2. Build metadata is dropped, except
+// for +incompatible. Versions that cannot be canonicalized are reported as
+// errors, and no edits are returned in that case.
+func CanonicalizeVersions(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]protocol.TextEdit, []source.Error, error) {
+	ctx, done := event.Start(ctx, "mod.CanonicalizeVersions", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, m, _, _ := pmh.Parse(ctx)
+	if m == nil {
+		return nil, nil, nil
+	}
+	return canonicalizeVersions(fh.URI(), m, snapshot.View().Options())
+}
+
+func canonicalizeVersions(uri span.URI, m *protocol.ColumnMapper, options source.Options) ([]protocol.TextEdit, []source.Error, error) {
+	// The go.mod parser canonicalizes each version as it parses it, so a
+	// formatted copy of the file contains only canonical versions.
+	copied, err := modfile.Parse(uri.Filename(), m.Content, nil)
+	if err != nil {
+		errList, ok := err.(modfile.ErrorList)
+		if !ok {
+			return nil, nil, err
+		}
+		var versionErrors []source.Error
+		for _, e := range errList {
+			var verr *module.InvalidVersionError
+			if !errors.As(e.Err, &verr) {
+				// The go.mod lexer rejects some characters, such as
+				// invisible ones, before versions are parsed.
+				start, version, ok := versionTokenAt(m.Content, e.Pos.Byte)
+				if !ok {
+					continue
+				}
+				charErr, ok, err := invalidCharError(uri, m, start, version)
+				if err != nil {
+					return nil, nil, err
+				}
+				if ok {
+					versionErrors = append(versionErrors, charErr)
+				}
+				continue
+			}
+			if start := versionStart(m.Content, e.Pos.Byte, verr.Version); start >= 0 {
+				charErr, ok, err := invalidCharError(uri, m, start, verr.Version)
+				if err != nil {
+					return nil, nil, err
+				}
+				if ok {
+					versionErrors = append(versionErrors, charErr)
+					continue
+				}
+			}
+			rng, err := lineRange(uri, m, e.Pos)
+			if err != nil {
+				return nil, nil, err
+			}
+			versionErrors = append(versionErrors, source.Error{
+				Category: versionCategory,
+				Message:  e.Err.Error(),
+				Range:    rng,
+				URI:      uri,
+			})
+		}
+		return nil, versionErrors, nil
+	}
+	newContent, err := copied.Format()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Calculate the edits to be made due to the change.
+	diff := options.ComputeEdits(uri, string(m.Content), string(newContent))
+	edits, err := source.ToProtocolEdits(m, diff)
+	if err != nil {
+		return nil, nil, err
+	}
+	return edits, nil, nil
+}
+
+// lineRange returns the range of the line beginning at the given position,
+// excluding its line terminator.
+func lineRange(uri span.URI, m *protocol.ColumnMapper, start modfile.Position) (protocol.Range, error) {
+	end := start
+	if i := bytes.IndexByte(m.Content[start.Byte:], '\n'); i >= 0 {
+		end.Byte += i
+	} else {
+		end.Byte = len(m.Content)
+	}
+	return positionsToRange(uri, m, start, end)
+}
+
+// isVersionChar reports whether r may appear in a semantic version.
+func isVersionChar(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '.' || r == '-' || r == '+'
+}
+
+// versionStart returns the offset of version in the line of content that
+// begins at lineStart, or -1 if it is not there, as when it was quoted.
+func versionStart(content []byte, lineStart int, version string) int {
+	lineEnd := len(content)
+	if i := bytes.IndexByte(content[lineStart:], '\n'); i >= 0 {
+		lineEnd = lineStart + i
+	}
+	i := bytes.Index(content[lineStart:lineEnd], []byte(version))
+	if i < 0 {
+		return -1
+	}
+	return lineStart + i
+}
+
+// versionTokenAt returns the start and text of the version that contains the
+// given offset in content, if there is one. Tokens are separated by ASCII
+// whitespace, so that other whitespace characters count as part of a version.
+func versionTokenAt(content []byte, offset int) (int, string, bool) {
+	if offset < 0 || offset >= len(content) {
+		return 0, "", false
+	}
+	isSep := func(b byte) bool {
+		return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+	}
+	start, end := offset, offset
+	for start > 0 && !isSep(content[start-1]) {
+		start--
+	}
+	for end < len(content) && !isSep(content[end]) {
+		end++
+	}
+	token := string(content[start:end])
+	if len(token) < 2 || token[0] != 'v' || token[1] < '0' || token[1] > '9' {
+		return 0, "", false
+	}
+	return start, token, true
+}
+
+// invalidCharError returns an error pinpointing the first character of
+// version that cannot appear in a version, such as an invisible character
+// pasted along with it, if there is one. versionStart is the offset of the
+// version in the file. If removing the invalid characters leaves a valid
+// version, the error suggests doing so.
+func invalidCharError(uri span.URI, m *protocol.ColumnMapper, versionStart int, version string) (source.Error, bool, error) {
+	bad := strings.IndexFunc(version, func(r rune) bool { return !isVersionChar(r) })
+	if bad < 0 {
+		return source.Error{}, false, nil
+	}
+	r, size := utf8.DecodeRuneInString(version[bad:])
+	var start, end modfile.Position
+	start.Byte, end.Byte = versionStart+bad, versionStart+bad+size
+	rng, err := positionsToRange(uri, m, start, end)
+	if err != nil {
+		return source.Error{}, false, err
+	}
+	e := source.Error{
+		Category: versionCategory,
+		Message:  fmt.Sprintf("invalid character %U in version %q", r, version),
+		Range:    rng,
+		URI:      uri,
+	}
+	stripped := strings.Map(func(r rune) rune {
+		if !isVersionChar(r) {
+			return -1
+		}
+		return r
+	}, version)
+	if module.CanonicalVersion(stripped) != "" {
+		start.Byte, end.Byte = versionStart, versionStart+len(version)
+		versionRng, err := positionsToRange(uri, m, start, end)
+		if err != nil {
+			return source.Error{}, false, err
+		}
+		e.SuggestedFixes = []source.SuggestedFix{{
+			Title: fmt.Sprintf("Use version %s", stripped),
+			Edits: map[span.URI][]protocol.TextEdit{
+				uri: {{Range: versionRng, NewText: stripped}},
+			},
+		}}
+	}
+	return e, true, nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestCanonicalizeVersions(t *testing.T) {
+	const before = `module mod.com
+
+go 1.14
+
+require (
+	example.com/a v1.2
+	example.com/b v1.0.0+build
+	example.com/c v2.0.0+incompatible
+)
+
+replace example.com/d v1 => example.com/e v0.1
+
+exclude example.com/a v1.1
+`
+	const want = `module mod.com
+
+go 1.14
+
+require (
+	example.com/a v1.2.0
+	example.com/b v1.0.0
+	example.com/c v2.0.0+incompatible
+)
+
+replace example.com/d v1.0.0 => example.com/e v0.1.0
+
+exclude example.com/a v1.1.0
+`
+	uri, m := testMapper(before)
+	edits, errors, err := canonicalizeVersions(uri, m, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors: %v", errors)
+	}
+	diffEdits, err := source.FromProtocolEdits(m, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(before, diffEdits); got != want {
+		t.Errorf("canonicalized go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCanonicalizeInvalidVersions(t *testing.T) {
+	const mod = `module mod.com
+
+require (
+	example.com/a master
+	example.com/b v1.2
+	example.com/c latest
+)
+`
+	uri, m := testMapper(mod)
+	edits, errors, err := canonicalizeVersions(uri, m, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) > 0 {
+		t.Errorf("got edits for a file with invalid versions: %v", edits)
+	}
+	var got []string
+	for _, e := range errors {
+		got = append(got, rangeText(t, m, e.Range))
+	}
+	if want := "example.com/a master,example.com/c latest"; strings.Join(got, ",") != want {
+		t.Errorf("got errors on %q, want them on %q", got, want)
+	}
+}
+
+func TestInvalidVersionCharacters(t *testing.T) {
+	for _, test := range []struct {
+		name, mod string
+		char      string // the invalid character
+		column    float64
+		fixed     string // the fixed line, or "" if there is no fix
+	}{
+		{
+			// The go.mod lexer rejects the zero-width space itself.
+			name:   "invisible",
+			mod:    "module mod.com\n\nrequire (\n\texample.com/a v1.2.0\u200b\n)\n",
+			char:   "\u200b",
+			column: 21,
+			fixed:  "\texample.com/a v1.2.0\n",
+		},
+		{
+			// Without the invalid character, v1. is still not a valid
+			// version.
+			name:   "unfixable",
+			mod:    "module mod.com\n\nrequire (\n\texample.com/b v1.\u00e9\n)\n",
+			char:   "\u00e9",
+			column: 18,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			uri, m := testMapper(test.mod)
+			_, errors, err := canonicalizeVersions(uri, m, source.DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			e := errors[0]
+			if want := fmt.Sprintf("invalid character %U", []rune(test.char)[0]); !strings.HasPrefix(e.Message, want) {
+				t.Errorf("got message %q, want prefix %q", e.Message, want)
+			}
+			if got := rangeText(t, m, e.Range); got != test.char {
+				t.Errorf("got range covering %q, want %q", got, test.char)
+			}
+			if e.Range.Start.Character != test.column {
+				t.Errorf("got the invalid character at column %v, want %v", e.Range.Start.Character, test.column)
+			}
+			if test.fixed == "" {
+				if len(e.SuggestedFixes) > 0 {
+					t.Errorf("got fixes %v, want none", e.SuggestedFixes)
+				}
+				return
+			}
+			if len(e.SuggestedFixes) != 1 {
+				t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+			}
+			edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.ApplyEdits(test.mod, edits); !strings.Contains(got, test.fixed) {
+				t.Errorf("fixed go.mod does not contain %q:\n%s", test.fixed, got)
+			}
+		})
+	}
+}
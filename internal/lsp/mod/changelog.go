@@ -0,0 +1,94 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	errors "golang.org/x/xerrors"
+)
+
+// A Changelog is the changelog of a module between two versions. If it is
+// not Available, Reason says why.
+type Changelog struct {
+	Path     string
+	From, To string
+
+	Available bool
+	Text      string
+	Reason    string
+}
+
+// FetchChangelog returns the changelog of the module path from version from
+// to version to, as provided by options.ChangelogSource. An error is only
+// returned for invalid arguments; if there is no source, or it fails or has
+// no changelog, the result says that the changelog is unavailable.
+func FetchChangelog(ctx context.Context, options source.Options, path, from, to string) (*Changelog, error) {
+	ctx, done := event.Start(ctx, "mod.FetchChangelog")
+	defer done()
+
+	if err := module.CheckPath(path); err != nil {
+		return nil, err
+	}
+	for _, v := range []string{from, to} {
+		if !semver.IsValid(v) {
+			return nil, errors.Errorf("invalid version %q of %s", v, path)
+		}
+	}
+	changelog := &Changelog{Path: path, From: from, To: to}
+	if options.ChangelogSource == nil {
+		changelog.Reason = "Changelog unavailable: no changelog source is configured."
+		return changelog, nil
+	}
+	text, err := options.ChangelogSource.Changelog(ctx, path, from, to)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "fetching the changelog of "+path, err)
+		changelog.Reason = fmt.Sprintf("Changelog unavailable: %v", err)
+		return changelog, nil
+	}
+	if text == "" {
+		changelog.Reason = fmt.Sprintf("Changelog unavailable: no release notes were found for %s between %s and %s.", path, from, to)
+		return changelog, nil
+	}
+	changelog.Available = true
+	changelog.Text = text
+	return changelog, nil
+}
+
+// ChangelogCommand returns the command that fetches the changelog of the
+// module path between versions from and to, such as before an upgrade.
+func ChangelogCommand(title, path, from, to string) *protocol.Command {
+	return &protocol.Command{
+		Title:     title,
+		Command:   source.CommandChangelog,
+		Arguments: []interface{}{path, from, to},
+	}
+}
+
+// ChangelogArgs returns the module path and versions of a command returned
+// by ChangelogCommand, as sent back by the client.
+func ChangelogArgs(args []interface{}) (path, from, to string, err error) {
+	if len(args) != 3 {
+		return "", "", "", errors.Errorf("expected 3 arguments, got %v", args)
+	}
+	var strs [3]string
+	for i, arg := range args {
+		s, ok := arg.(string)
+		if !ok {
+			return "", "", "", errors.Errorf("expected argument %d to be a string but got %T", i, arg)
+		}
+		strs[i] = s
+	}
+	return strs[0], strs[1], strs[2], nil
+}
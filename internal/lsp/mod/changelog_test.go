@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// fakeChangelogs returns the changelogs it maps by module path, and fails
+// for the others.
+type fakeChangelogs map[string]string
+
+func (f fakeChangelogs) Changelog(ctx context.Context, path, from, to string) (string, error) {
+	text, ok := f[path]
+	if !ok {
+		return "", fmt.Errorf("no repository for %s", path)
+	}
+	return text, nil
+}
+
+func TestFetchChangelog(t *testing.T) {
+	ctx := context.Background()
+	options := source.DefaultOptions()
+	changelog, err := FetchChangelog(ctx, options, "example.com/a", "v1.0.0", "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changelog.Available || !strings.Contains(changelog.Reason, "no changelog source") {
+		t.Errorf("got changelog %+v without a source, want it unavailable", changelog)
+	}
+
+	options.ChangelogSource = fakeChangelogs{
+		"example.com/a":     "v1.1.0: add Frob\n",
+		"example.com/empty": "",
+	}
+	for _, test := range []struct {
+		path      string
+		available bool
+		want      string
+	}{
+		{"example.com/a", true, "v1.1.0: add Frob\n"},
+		{"example.com/empty", false, "no release notes were found for example.com/empty between v1.0.0 and v1.1.0"},
+		{"example.com/missing", false, "no repository for example.com/missing"},
+	} {
+		changelog, err := FetchChangelog(ctx, options, test.path, "v1.0.0", "v1.1.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changelog.Available != test.available {
+			t.Errorf("%s: got available %v, want %v", test.path, changelog.Available, test.available)
+		}
+		got := changelog.Reason
+		if test.available {
+			got = changelog.Text
+		}
+		if !strings.Contains(got, test.want) {
+			t.Errorf("%s: got %q, want it to contain %q", test.path, got, test.want)
+		}
+	}
+
+	if _, err := FetchChangelog(ctx, options, "example.com/a", "v1.0.0", "latest"); err == nil {
+		t.Error("got no error for an invalid version")
+	}
+	cmd := ChangelogCommand("Show changelog", "example.com/a", "v1.0.0", "v1.1.0")
+	path, from, to, err := ChangelogArgs(cmd.Arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "example.com/a" || from != "v1.0.0" || to != "v1.1.0" {
+		t.Errorf("got arguments %s %s %s, want the command's", path, from, to)
+	}
+}
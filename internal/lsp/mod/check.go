@@ -0,0 +1,1043 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// A check inspects a parsed go.mod file and reports any problems it finds.
+// Checks do not run the go command, so they are cheap enough to run on
+// every change to the file.
+type check func(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error)
+
+// checks are the checks run over the view's go.mod file, in addition to
+// `go mod tidy`.
+var checks = []check{
+	checkPaths,
+	checkExcludes,
+	checkDuplicateExcludes,
+	checkScatteredExcludes,
+	checkMainModuleExcludes,
+	checkReplacedRequires,
+	checkOverlappingReplaces,
+	checkSelfReplaces,
+	checkIndirectCount,
+	checkMajorVersionLayout,
+	checkHostConventions,
+	checkModulePolicy,
+	checkKnownBreakages,
+	checkModuleForks,
+}
+
+const (
+	invalidPathCategory      = "module path"
+	goDirectiveCategory      = "go directive"
+	goPatchCategory          = "go patch version"
+	toolchainCategory        = "toolchain"
+	missingToolchainCategory = "missing toolchain"
+	languageCategory         = "language version"
+	excludeCategory          = "ineffective exclude"
+	duplicateCategory        = "redundant exclude"
+	scatteredCategory        = "scattered excludes"
+	mainExcludeCategory      = "main module exclude"
+	replacedCategory         = "replaced require"
+	overlapCategory          = "ambiguous replace"
+	replaceDirCategory       = "replace directory"
+	selfReplaceCategory      = "self replace"
+	indirectCategory         = "indirect requires"
+	majorVersionCategory     = "major version"
+)
+
+// checkPaths reports module paths in the module, require, and replace
+// directives that are not valid. Paths that are replaced need only be valid
+// import paths, as they are never fetched.
+func checkPaths(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, _ source.Options) ([]source.Error, error) {
+	replaced := make(map[string]bool, len(file.Replace))
+	for _, r := range file.Replace {
+		replaced[r.Old.Path] = true
+	}
+	var errors []source.Error
+	report := func(line *modfile.Line, tok int, err error) error {
+		if err == nil {
+			return nil
+		}
+		rng, err2 := tokenRange(uri, m, line, tok)
+		if err2 != nil {
+			return err2
+		}
+		errors = append(errors, source.Error{
+			Category: invalidPathCategory,
+			Message:  err.Error(),
+			Range:    rng,
+			URI:      uri,
+		})
+		return nil
+	}
+	if file.Module != nil && file.Module.Syntax != nil {
+		line := file.Module.Syntax
+		if err := report(line, len(line.Token)-1, module.CheckImportPath(file.Module.Mod.Path)); err != nil {
+			return nil, err
+		}
+	}
+	for _, req := range file.Require {
+		if req.Syntax == nil {
+			continue
+		}
+		path := req.Mod.Path
+		check := module.CheckPath
+		if replaced[path] {
+			check = module.CheckImportPath
+		}
+		if err := report(req.Syntax, len(req.Syntax.Token)-2, check(path)); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range file.Replace {
+		if r.Syntax == nil {
+			continue
+		}
+		arrow := arrowIndex(r.Syntax)
+		if arrow < 0 {
+			continue
+		}
+		old := arrow - 1
+		if r.Old.Version != "" {
+			old--
+		}
+		if err := report(r.Syntax, old, module.CheckImportPath(r.Old.Path)); err != nil {
+			return nil, err
+		}
+		if modfile.IsDirectoryPath(r.New.Path) {
+			continue
+		}
+		if err := report(r.Syntax, arrow+1, module.CheckPath(r.New.Path)); err != nil {
+			return nil, err
+		}
+	}
+	return errors, nil
+}
+
+// checkExcludes reports exclude directives that name a version lower than
+// the required version of the same module. Such excludes never take effect,
+// since minimal version selection never selects a version lower than one
+// that is required.
+func checkExcludes(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	required := make(map[string]*modfile.Require, len(file.Require))
+	for _, req := range file.Require {
+		required[req.Mod.Path] = req
+	}
+	var errors []source.Error
+	for _, x := range file.Exclude {
+		if x.Syntax == nil {
+			continue
+		}
+		req, ok := required[x.Mod.Path]
+		if !ok || semver.Compare(x.Mod.Version, req.Mod.Version) >= 0 {
+			continue
+		}
+		version := req.Mod.Version
+		rng, err := positionsToRange(uri, m, x.Syntax.Start, x.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		edits, err := dropExcludeEdits(uri, m, x, options)
+		if err != nil {
+			return nil, err
+		}
+		related, err := relatedLine(uri, m, req.Syntax, fmt.Sprintf("%s %s is required here.", req.Mod.Path, version))
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: excludeCategory,
+			Message:  fmt.Sprintf("%s@%s is excluded, but %s is required, so the exclude has no effect.", x.Mod.Path, x.Mod.Version, version),
+			Range:    rng,
+			URI:      uri,
+			Related:  related,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Remove exclude %s %s", x.Mod.Path, x.Mod.Version),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: edits,
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// checkMainModuleExcludes reports exclude directives for the main module
+// itself, which cannot be excluded.
+func checkMainModuleExcludes(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	if file.Module == nil {
+		return nil, nil
+	}
+	var errors []source.Error
+	for _, x := range file.Exclude {
+		if x.Syntax == nil || x.Mod.Path != file.Module.Mod.Path {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, x.Syntax.Start, x.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		edits, err := dropExcludeEdits(uri, m, x, options)
+		if err != nil {
+			return nil, err
+		}
+		related, err := relatedLine(uri, m, file.Module.Syntax, "The main module is declared here.")
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: mainExcludeCategory,
+			Message:  fmt.Sprintf("%s is the main module, so it cannot be excluded.", x.Mod.Path),
+			Range:    rng,
+			URI:      uri,
+			Related:  related,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Remove exclude %s %s", x.Mod.Path, x.Mod.Version),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: edits,
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// checkDuplicateExcludes reports exclude directives that exclude a version
+// that an earlier directive already excludes, possibly spelled differently,
+// such as v1.2 and v1.2.0. Each exclude applies to a single version, so
+// excludes of different versions never overlap, even if they are adjacent;
+// checkScatteredExcludes reports those that can be consolidated.
+func checkDuplicateExcludes(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	first := make(map[module.Version]*modfile.Exclude)
+	var errors []source.Error
+	for _, x := range file.Exclude {
+		if x.Syntax == nil {
+			continue
+		}
+		prev, ok := first[x.Mod]
+		if !ok {
+			first[x.Mod] = x
+			continue
+		}
+		rng, err := positionsToRange(uri, m, x.Syntax.Start, x.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		// Remove the whole line, so that no blank line is left in its
+		// place.
+		var start, end modfile.Position
+		start.Byte = bytes.LastIndexByte(m.Content[:x.Syntax.Start.Byte], '\n') + 1
+		end.Byte = len(m.Content)
+		if i := bytes.IndexByte(m.Content[x.Syntax.End.Byte:], '\n'); i >= 0 {
+			end.Byte = x.Syntax.End.Byte + i + 1
+		}
+		lineRng, err := positionsToRange(uri, m, start, end)
+		if err != nil {
+			return nil, err
+		}
+		related, err := relatedLine(uri, m, prev.Syntax, fmt.Sprintf("%s %s is first excluded here.", x.Mod.Path, x.Mod.Version))
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: duplicateCategory,
+			Message:  fmt.Sprintf("%s %s is already excluded on line %d.", x.Mod.Path, x.Mod.Version, prev.Syntax.Start.Line),
+			Range:    rng,
+			URI:      uri,
+			Related:  related,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: "Remove the redundant exclude",
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: lineRng, NewText: ""}},
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// checkScatteredExcludes reports the excludes of a module that are spread
+// over several exclude directives or blocks, such as the excludes of two
+// adjacent versions added at different times. The error is reported on each
+// exclude after the first, and the fix consolidates all of the module's
+// excludes into a single block, in version order.
+func checkScatteredExcludes(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	// Number the exclude statements, so that excludes in the same block
+	// share a number.
+	stmts := make(map[*modfile.Line]int)
+	for i, stmt := range file.Syntax.Stmt {
+		switch stmt := stmt.(type) {
+		case *modfile.Line:
+			stmts[stmt] = i
+		case *modfile.LineBlock:
+			for _, line := range stmt.Line {
+				stmts[line] = i
+			}
+		}
+	}
+	// Repeated excludes of the same version are left to
+	// checkDuplicateExcludes.
+	byPath := make(map[string][]*modfile.Exclude)
+	seen := make(map[module.Version]bool)
+	var paths []string
+	for _, x := range file.Exclude {
+		if x.Syntax == nil || seen[x.Mod] {
+			continue
+		}
+		seen[x.Mod] = true
+		if _, ok := byPath[x.Mod.Path]; !ok {
+			paths = append(paths, x.Mod.Path)
+		}
+		byPath[x.Mod.Path] = append(byPath[x.Mod.Path], x)
+	}
+	var errors []source.Error
+	for _, path := range paths {
+		excludes := byPath[path]
+		first := excludes[0]
+		scattered := false
+		for _, x := range excludes[1:] {
+			if stmts[x.Syntax] != stmts[first.Syntax] {
+				scattered = true
+			}
+		}
+		if !scattered {
+			continue
+		}
+		var versions []string
+		for _, x := range excludes {
+			versions = append(versions, x.Mod.Version)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			return semver.Compare(versions[i], versions[j]) < 0
+		})
+		edits, err := rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+			for _, v := range versions {
+				if err := copied.DropExclude(path, v); err != nil {
+					return err
+				}
+			}
+			for _, v := range versions {
+				if err := copied.AddExclude(path, v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		related, err := relatedLine(uri, m, first.Syntax, fmt.Sprintf("%s is first excluded here.", path))
+		if err != nil {
+			return nil, err
+		}
+		for _, x := range excludes[1:] {
+			if stmts[x.Syntax] == stmts[first.Syntax] {
+				continue
+			}
+			rng, err := positionsToRange(uri, m, x.Syntax.Start, x.Syntax.End)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: scatteredCategory,
+				Message:  fmt.Sprintf("%s is also excluded on line %d; its excludes can be consolidated into one block.", path, first.Syntax.Start.Line),
+				Range:    rng,
+				URI:      uri,
+				Related:  related,
+				SuggestedFixes: []source.SuggestedFix{{
+					Title: fmt.Sprintf("Consolidate the excludes of %s", path),
+					Edits: map[span.URI][]protocol.TextEdit{
+						uri: edits,
+					},
+				}},
+			})
+		}
+	}
+	return errors, nil
+}
+
+// checkReplacedRequires reports requirements whose version is overridden by a
+// replace directive that replaces the module with another version of itself,
+// such as `replace example.com/foo => example.com/foo v1.5.0`, since the
+// required version is never used. The mismatch is only a matter of style, so
+// the fixes either align the requirement with the replacement or remove the
+// replace directive.
+func checkReplacedRequires(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Syntax == nil {
+			continue
+		}
+		var replace *modfile.Replace
+		for _, r := range file.Replace {
+			if r.Old.Path != req.Mod.Path || r.New.Path != req.Mod.Path {
+				continue
+			}
+			if r.Old.Version != "" && r.Old.Version != req.Mod.Version {
+				continue
+			}
+			replace = r
+		}
+		if replace == nil || replace.Syntax == nil || replace.New.Version == req.Mod.Version {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		versionRng, err := tokenRange(uri, m, req.Syntax, len(req.Syntax.Token)-1)
+		if err != nil {
+			return nil, err
+		}
+		dropEdits, err := rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+			return copied.DropReplace(replace.Old.Path, replace.Old.Version)
+		})
+		if err != nil {
+			return nil, err
+		}
+		related, err := relatedLine(uri, m, replace.Syntax, fmt.Sprintf("%s is replaced with %s here.", req.Mod.Path, replace.New.Version))
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: replacedCategory,
+			Message:  fmt.Sprintf("%s %s is required, but it is replaced with %s, so the required version has no effect.", req.Mod.Path, req.Mod.Version, replace.New.Version),
+			Range:    rng,
+			URI:      uri,
+			Related:  related,
+			SuggestedFixes: []source.SuggestedFix{
+				{
+					Title: fmt.Sprintf("Require %s %s", req.Mod.Path, replace.New.Version),
+					Edits: map[span.URI][]protocol.TextEdit{
+						uri: {{Range: versionRng, NewText: replace.New.Version}},
+					},
+				},
+				{
+					Title: fmt.Sprintf("Remove replace %s => %s %s", replace.Old.Path, replace.New.Path, replace.New.Version),
+					Edits: map[span.URI][]protocol.TextEdit{
+						uri: dropEdits,
+					},
+				},
+			},
+		})
+	}
+	return errors, nil
+}
+
+// checkOverlappingReplaces reports replace directives for a module whose path
+// is inside the path of another replaced module, such as example.com/a/sub and
+// example.com/a. Packages below the inner path could be provided by either
+// replacement, so imports of them are ambiguous. Each overlap is reported on
+// the later of the two directives.
+func checkOverlappingReplaces(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	var errors []source.Error
+	for i, later := range file.Replace {
+		if later.Syntax == nil {
+			continue
+		}
+		for _, earlier := range file.Replace[:i] {
+			if earlier.Syntax == nil {
+				continue
+			}
+			outer, inner := earlier.Old.Path, later.Old.Path
+			if strings.HasPrefix(outer, inner+"/") {
+				outer, inner = inner, outer
+			} else if !strings.HasPrefix(inner, outer+"/") {
+				continue
+			}
+			rng, err := positionsToRange(uri, m, later.Syntax.Start, later.Syntax.End)
+			if err != nil {
+				return nil, err
+			}
+			related, err := relatedLine(uri, m, earlier.Syntax, fmt.Sprintf("%s is replaced here.", earlier.Old.Path))
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: overlapCategory,
+				Message: fmt.Sprintf("%s and %s are both replaced, so imports of packages in %s are ambiguous (see the replace on line %d).",
+					inner, outer, inner, earlier.Syntax.Start.Line),
+				Range:   rng,
+				URI:     uri,
+				Related: related,
+			})
+			break
+		}
+	}
+	return errors, nil
+}
+
+// replaceDirErrors reports relative replacement directories that refer to
+// no directory in the workspace: those that climb above the root of the
+// filesystem, such as ../../../other in a go.mod file two directories deep,
+// and those that resolve outside each of roots, the directories of the
+// workspace, such as the view folder and the go.work file. A relative
+// directory outside the workspace only refers to the intended module if the
+// workspace is checked out next to it. Absolute directories name their
+// location explicitly, so they are not checked against roots.
+func replaceDirErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, roots []string) ([]source.Error, error) {
+	modDir := filepath.Dir(uri.Filename())
+	var errors []source.Error
+	for _, r := range file.Replace {
+		if r.Syntax == nil || !modfile.IsDirectoryPath(r.New.Path) || filepath.IsAbs(filepath.FromSlash(r.New.Path)) {
+			continue
+		}
+		var msg string
+		dir, err := resolveReplaceDir(modDir, r.New.Path)
+		switch {
+		case err == errEscapesRoot:
+			msg = fmt.Sprintf("%s escapes the root of the filesystem, so it does not refer to a directory relative to %s.", r.New.Path, modDir)
+		case err != nil || withinDirs(dir, roots):
+			continue
+		default:
+			msg = fmt.Sprintf("%s refers to %s, outside the workspace, so the replacement of %s depends on what is checked out next to the workspace.", r.New.Path, dir, r.Old.Path)
+		}
+		arrow := arrowIndex(r.Syntax)
+		if arrow < 0 || arrow+1 >= len(r.Syntax.Token) {
+			continue
+		}
+		rng, err := tokenRange(uri, m, r.Syntax, arrow+1)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: replaceDirCategory,
+			Message:  msg,
+			Range:    rng,
+			URI:      uri,
+		})
+	}
+	return errors, nil
+}
+
+// withinDirs reports whether dir is one of dirs or inside one of them.
+// Symbolic links in dirs are resolved, as they are in dir by
+// resolveReplaceDir.
+func withinDirs(dir string, dirs []string) bool {
+	for _, root := range dirs {
+		root = realDir(filepath.Clean(root))
+		if dir == root {
+			return true
+		}
+		// Only the root of the filesystem ends in a separator.
+		if !strings.HasSuffix(root, string(filepath.Separator)) {
+			root += string(filepath.Separator)
+		}
+		if strings.HasPrefix(dir, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSelfReplaces reports replace directives whose replacement is the main
+// module itself, by module path or by directory. The main module cannot
+// stand in for a dependency, so such replacements are mistakes, and the fix
+// removes them.
+func checkSelfReplaces(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	if file.Module == nil {
+		return nil, nil
+	}
+	mainPath := file.Module.Mod.Path
+	modDir := filepath.Dir(uri.Filename())
+	var errors []source.Error
+	for _, r := range file.Replace {
+		if r.Syntax == nil || r.Old.Path == mainPath {
+			continue
+		}
+		if modfile.IsDirectoryPath(r.New.Path) {
+			dir, err := resolveReplaceDir(modDir, r.New.Path)
+			if err != nil || dir != realDir(modDir) {
+				continue
+			}
+		} else if r.New.Path != mainPath {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, r.Syntax.Start, r.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		old := r.Old
+		edits, err := rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+			return copied.DropReplace(old.Path, old.Version)
+		})
+		if err != nil {
+			return nil, err
+		}
+		related, err := relatedLine(uri, m, file.Module.Syntax, "The main module is declared here.")
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: selfReplaceCategory,
+			Message:  fmt.Sprintf("%s is replaced with the main module, %s, which cannot provide it.", r.Old.Path, mainPath),
+			Range:    rng,
+			URI:      uri,
+			Related:  related,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Remove replace %s", r.Old.Path),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: edits,
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// checkIndirectCount reports go.mod files with more indirect requirements
+// than options.IndirectRequireThreshold, as long chains of indirect
+// dependencies can slow down builds. The diagnostic is only advisory, and
+// disabled unless a threshold is set.
+func checkIndirectCount(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	threshold := options.IndirectRequireThreshold
+	if threshold <= 0 || file.Module == nil || file.Module.Syntax == nil {
+		return nil, nil
+	}
+	var count int
+	for _, req := range file.Require {
+		if req.Indirect {
+			count++
+		}
+	}
+	if count <= threshold {
+		return nil, nil
+	}
+	rng, err := positionsToRange(uri, m, file.Module.Syntax.Start, file.Module.Syntax.End)
+	if err != nil {
+		return nil, err
+	}
+	return []source.Error{{
+		Category: indirectCategory,
+		Message:  fmt.Sprintf("%s has %d indirect requirements, more than the threshold of %d; consider reviewing its dependencies.", file.Module.Mod.Path, count, threshold),
+		Range:    rng,
+		URI:      uri,
+	}}, nil
+}
+
+// majorDirRe matches the name of a major version subdirectory, such as v2.
+var majorDirRe = regexp.MustCompile(`^v([2-9]|[1-9][0-9]+)$`)
+
+// checkMajorVersionLayout reports a module path with a major version suffix
+// in a go.mod file that is in a major version subdirectory for a different
+// major version, such as example.com/foo/v2 in foo/v3/go.mod. Modules that
+// are developed on a major version branch may be in any directory, so only
+// this obvious mismatch is reported, and only if options.MajorVersionLayout
+// is set.
+func checkMajorVersionLayout(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	if !options.MajorVersionLayout || file.Module == nil || file.Module.Syntax == nil {
+		return nil, nil
+	}
+	_, pathMajor, ok := module.SplitPathVersion(file.Module.Mod.Path)
+	if !ok || pathMajor == "" || strings.HasPrefix(pathMajor, ".") {
+		// Paths with no suffix, or with a gopkg.in suffix, are not
+		// checked.
+		return nil, nil
+	}
+	major := strings.TrimPrefix(pathMajor, "/")
+	dir := filepath.Base(filepath.Dir(uri.Filename()))
+	if !majorDirRe.MatchString(dir) || dir == major {
+		return nil, nil
+	}
+	line := file.Module.Syntax
+	rng, err := tokenRange(uri, m, line, len(line.Token)-1)
+	if err != nil {
+		return nil, err
+	}
+	return []source.Error{{
+		Category: majorVersionCategory,
+		Message: fmt.Sprintf("module path %s has major version %s, but go.mod is in the %s subdirectory. "+
+			"A module developed in a major version subdirectory must be in the directory for its own major version.", file.Module.Mod.Path, major, dir),
+		Range: rng,
+		URI:   uri,
+	}}, nil
+}
+
+// dropExcludeEdits returns the edits that remove the given exclude directive
+// from the go.mod file.
+func dropExcludeEdits(uri span.URI, m *protocol.ColumnMapper, x *modfile.Exclude, options source.Options) ([]protocol.TextEdit, error) {
+	return rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		return copied.DropExclude(x.Mod.Path, x.Mod.Version)
+	})
+}
+
+// rewriteEdits returns the edits that result from applying rewrite to a
+// parsed copy of the go.mod file and formatting it.
+func rewriteEdits(uri span.URI, m *protocol.ColumnMapper, options source.Options, rewrite func(*modfile.File) error) ([]protocol.TextEdit, error) {
+	// We need a private copy of the parsed go.mod file, since we're going to
+	// modify it.
+	copied, err := modfile.Parse("", m.Content, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := rewrite(copied); err != nil {
+		return nil, err
+	}
+	copied.Cleanup()
+	newContent, err := copied.Format()
+	if err != nil {
+		return nil, err
+	}
+	// Calculate the edits to be made due to the change.
+	diff := options.ComputeEdits(uri, string(m.Content), string(newContent))
+	return source.ToProtocolEdits(m, diff)
+}
+
+// relatedLine returns the related information that points at line, the
+// other directive involved in a problem, with the given message. It returns
+// nil if the line is unknown.
+func relatedLine(uri span.URI, m *protocol.ColumnMapper, line *modfile.Line, msg string) ([]source.RelatedInformation, error) {
+	if line == nil {
+		return nil, nil
+	}
+	rng, err := positionsToRange(uri, m, line.Start, line.End)
+	if err != nil {
+		return nil, err
+	}
+	return []source.RelatedInformation{{URI: uri, Range: rng, Message: msg}}, nil
+}
+
+// arrowIndex returns the index of the "=>" token in a replace directive, or
+// -1 if there is none.
+func arrowIndex(line *modfile.Line) int {
+	for i, tok := range line.Token {
+		if tok == "=>" {
+			return i
+		}
+	}
+	return -1
+}
+
+// tokenRange returns the range of the i'th token of the given line. If the
+// token cannot be found, it returns the range of the entire line.
+func tokenRange(uri span.URI, m *protocol.ColumnMapper, line *modfile.Line, i int) (protocol.Range, error) {
+	s, e := line.Start.Byte, line.End.Byte
+	if s < 0 || e > len(m.Content) || s > e {
+		return protocol.Range{}, fmt.Errorf("invalid line offsets %v-%v", s, e)
+	}
+	// Tokens are separated by whitespace, so find each of them in turn.
+	offset := s
+	for j, tok := range line.Token {
+		k := bytes.Index(m.Content[offset:e], []byte(tok))
+		if k == -1 {
+			break
+		}
+		offset += k
+		if j == i {
+			start, end := line.Start, line.Start
+			start.Byte, end.Byte = offset, offset+len(tok)
+			return positionsToRange(uri, m, start, end)
+		}
+		offset += len(tok)
+	}
+	return positionsToRange(uri, m, line.Start, line.End)
+}
+
+// goPatchVersionRe matches a go directive whose version has a patch
+// component, such as "go 1.21.0".
+var goPatchVersionRe = regexp.MustCompile(`^\s*go\s+(((?:0|[1-9][0-9]*)\.(?:0|[1-9][0-9]*))\.(?:0|[1-9][0-9]*))\s*(?://.*)?$`)
+
+// goDirectiveErrors reports go directives with a patch version, which the
+// go.mod parser rejects. Since such files cannot be parsed, it inspects the
+// raw contents of the file. goversion is the minor version of the go command
+// in use, which determines whether the go command itself would accept the
+// directive; it is 0 if the version is unknown. Go 1.21 and later accept
+// it, so only an informational note is reported for them, without the fix
+// that drops the patch version, as that would lower the version the module
+// requires.
+func goDirectiveErrors(uri span.URI, m *protocol.ColumnMapper, goversion int) ([]source.Error, error) {
+	if m == nil {
+		return nil, nil
+	}
+	var errors []source.Error
+	offset := 0
+	for _, line := range strings.SplitAfter(string(m.Content), "\n") {
+		lineStart := offset
+		offset += len(line)
+		match := goPatchVersionRe.FindStringSubmatchIndex(strings.TrimRight(line, "\r\n"))
+		if match == nil {
+			continue
+		}
+		version, prefix := line[match[2]:match[3]], line[match[4]:match[5]]
+		var start, end modfile.Position
+		start.Byte = lineStart + match[2]
+		end.Byte = start.Byte + len(version)
+		rng, err := positionsToRange(uri, m, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if goversion >= firstToolchainMinor {
+			errors = append(errors, source.Error{
+				Category: goPatchCategory,
+				Message:  fmt.Sprintf("go1.%d accepts go version %q, but gopls cannot parse a go directive with a patch version, so it does not check the rest of the file.", goversion, version),
+				Range:    rng,
+				URI:      uri,
+			})
+			continue
+		}
+		var msg string
+		switch {
+		case goversion > 0:
+			msg = fmt.Sprintf("invalid go version %q: go1.%d requires the form 1.N", version, goversion)
+		default:
+			msg = fmt.Sprintf("invalid go version %q: must match format 1.N", version)
+		}
+		errors = append(errors, source.Error{
+			Category: goDirectiveCategory,
+			Message:  msg,
+			Range:    rng,
+			URI:      uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Use go %s", prefix),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: rng, NewText: prefix}},
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+var (
+	// goLineRe matches a go directive, such as "go 1.21.0".
+	goLineRe = regexp.MustCompile(`^\s*go\s+(\S+)\s*(?://.*)?$`)
+
+	// toolchainLineRe matches a toolchain directive, such as
+	// "toolchain go1.21.0".
+	toolchainLineRe = regexp.MustCompile(`^\s*toolchain\s+(\S+)\s*(?://.*)?$`)
+)
+
+// A toolchainLine is a toolchain directive in the raw contents of a go.mod
+// file, as byte offsets.
+type toolchainLine struct {
+	start, end int // the line, including its terminator
+	name       string
+	nameStart  int
+}
+
+// scanToolchains returns the version of the last go directive of content, the
+// offset of that version, and its toolchain directives. The go.mod parser
+// does not know the toolchain directive, so files that contain one are only
+// available as raw contents.
+func scanToolchains(content []byte) (goVersion string, goStart int, toolchains []toolchainLine) {
+	offset := 0
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		lineStart := offset
+		offset += len(line)
+		text := strings.TrimRight(line, "\r\n")
+		if match := goLineRe.FindStringSubmatchIndex(text); match != nil {
+			goVersion, goStart = text[match[2]:match[3]], lineStart+match[2]
+		}
+		if match := toolchainLineRe.FindStringSubmatchIndex(text); match != nil {
+			toolchains = append(toolchains, toolchainLine{
+				start:     lineStart,
+				end:       offset,
+				name:      text[match[2]:match[3]],
+				nameStart: lineStart + match[2],
+			})
+		}
+	}
+	return goVersion, goStart, toolchains
+}
+
+// redundantToolchainErrors reports a toolchain directive that names the
+// toolchain the go directive already implies, such as toolchain go1.21.0
+// with go 1.21.0, along with a fix to remove it. The go.mod parser does not
+// know the toolchain directive, so it inspects the raw contents of the file.
+// If the go directive needs a newer go command than goversion, the minor
+// version of the one in use, the directive is the one that
+// missingToolchainErrors suggests, so it is not reported.
+func redundantToolchainErrors(uri span.URI, m *protocol.ColumnMapper, goversion int) ([]source.Error, error) {
+	if m == nil {
+		return nil, nil
+	}
+	goVersion, _, toolchains := scanToolchains(m.Content)
+	if goVersion == "" {
+		return nil, nil
+	}
+	if goversion >= firstToolchainMinor && languageMinorOf(goVersion) > goversion {
+		return nil, nil
+	}
+	var errors []source.Error
+	for _, t := range toolchains {
+		if t.name != "go"+goVersion {
+			continue
+		}
+		var start, end modfile.Position
+		start.Byte, end.Byte = t.nameStart, t.nameStart+len(t.name)
+		rng, err := positionsToRange(uri, m, start, end)
+		if err != nil {
+			return nil, err
+		}
+		start.Byte, end.Byte = t.start, t.end
+		lineRng, err := positionsToRange(uri, m, start, end)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: toolchainCategory,
+			Message:  fmt.Sprintf("toolchain %s is the default for go %s, so the toolchain directive is redundant.", t.name, goVersion),
+			Range:    rng,
+			URI:      uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: "Remove the toolchain directive",
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: lineRng, NewText: ""}},
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// firstToolchainMinor is the minor version of the first go command that
+// understands toolchain directives and downloads the toolchains they name.
+const firstToolchainMinor = 21
+
+// missingToolchainErrors reports a go directive of the form 1.N or 1.N.P
+// that needs a newer go command than goversion, the minor version of the one
+// in use, when there is no toolchain directive. The fix adds a toolchain
+// directive for the release that the go directive names, go1.N.P, or go1.N.0,
+// the first release of 1.N, which the go command in use downloads to build
+// the module. It inspects the raw contents of the file,
+// as the go.mod parser does not know the toolchain directive; gopls reports
+// the directive it adds as a syntax error, but the go command accepts it.
+// Go commands older than go1.21 cannot download toolchains, so nothing is
+// reported for them, nor if goversion is 0, meaning unknown.
+func missingToolchainErrors(uri span.URI, m *protocol.ColumnMapper, goversion int) ([]source.Error, error) {
+	if m == nil || goversion < firstToolchainMinor {
+		return nil, nil
+	}
+	goVersion, goStart, toolchains := scanToolchains(m.Content)
+	minor, toolchain := goMinor(goVersion), "go"+goVersion+".0"
+	if match := goPatchVersionRe.FindStringSubmatch("go " + goVersion); match != nil {
+		minor, toolchain = goMinor(match[2]), "go"+goVersion
+	}
+	if minor <= goversion || len(toolchains) > 0 {
+		return nil, nil
+	}
+	var start, end modfile.Position
+	start.Byte, end.Byte = goStart, goStart+len(goVersion)
+	rng, err := positionsToRange(uri, m, start, end)
+	if err != nil {
+		return nil, err
+	}
+	// Add the directive after the go directive, separated by a blank line.
+	lineEnd := len(m.Content)
+	if i := bytes.IndexByte(m.Content[goStart:], '\n'); i >= 0 {
+		lineEnd = goStart + i + 1
+	}
+	start.Byte, end.Byte = lineEnd, lineEnd
+	insertRng, err := positionsToRange(uri, m, start, end)
+	if err != nil {
+		return nil, err
+	}
+	newText := fmt.Sprintf("\ntoolchain %s\n", toolchain)
+	if lineEnd == len(m.Content) && !bytes.HasSuffix(m.Content, []byte("\n")) {
+		newText = "\n" + newText
+	}
+	return []source.Error{{
+		Category: missingToolchainCategory,
+		Message:  fmt.Sprintf("go %s requires a newer go command than go1.%d, and there is no toolchain directive naming the release to download.", goVersion, goversion),
+		Range:    rng,
+		URI:      uri,
+		SuggestedFixes: []source.SuggestedFix{{
+			Title: "Add toolchain " + toolchain,
+			Edits: map[span.URI][]protocol.TextEdit{
+				uri: {{Range: insertRng, NewText: newText}},
+			},
+		}},
+	}}, nil
+}
+
+// languageMinorOf returns the minor version of the language version of a go
+// version, such as 21 for 1.21, 1.21rc1, and 1.21.3, or 0 if v is not a go
+// version.
+func languageMinorOf(v string) int {
+	if !strings.HasPrefix(v, "1.") {
+		return 0
+	}
+	v = strings.TrimPrefix(v, "1.")
+	i := 0
+	for i < len(v) && '0' <= v[i] && v[i] <= '9' {
+		i++
+	}
+	minor, err := strconv.Atoi(v[:i])
+	if err != nil {
+		return 0
+	}
+	return minor
+}
+
+// divergentToolchainErrors explains the language version of a go.mod file
+// whose toolchain directive names a go release of another minor version than
+// its go directive. A newer toolchain only selects the go command that builds
+// the module: the language version, which determines the features the
+// module's packages may use, is still that of the go directive. An older
+// toolchain than the go directive requires is ignored. Like
+// redundantToolchainErrors, it inspects the raw contents of the file.
+func divergentToolchainErrors(uri span.URI, m *protocol.ColumnMapper) ([]source.Error, error) {
+	if m == nil {
+		return nil, nil
+	}
+	goVersion, goStart, toolchains := scanToolchains(m.Content)
+	goLang := languageMinorOf(goVersion)
+	if goLang == 0 {
+		return nil, nil
+	}
+	var start, end modfile.Position
+	start.Byte, end.Byte = goStart, goStart+len(goVersion)
+	goRng, err := positionsToRange(uri, m, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var errors []source.Error
+	for _, t := range toolchains {
+		toolchainLang := languageMinorOf(strings.TrimPrefix(t.name, "go"))
+		if !strings.HasPrefix(t.name, "go") || toolchainLang == 0 || toolchainLang == goLang {
+			continue
+		}
+		var msg string
+		if toolchainLang > goLang {
+			msg = fmt.Sprintf("The language version is go 1.%d, set by the go directive: toolchain %s only selects the go command that builds the module, so language features added after go 1.%d remain unavailable.", goLang, t.name, goLang)
+		} else {
+			msg = fmt.Sprintf("toolchain %s is older than go %s, so the go command ignores it; the language version is go 1.%d, set by the go directive.", t.name, goVersion, goLang)
+		}
+		start.Byte, end.Byte = t.nameStart, t.nameStart+len(t.name)
+		rng, err := positionsToRange(uri, m, start, end)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: languageCategory,
+			Message:  msg,
+			Range:    rng,
+			URI:      uri,
+			Related: []source.RelatedInformation{{
+				URI:     uri,
+				Range:   goRng,
+				Message: fmt.Sprintf("The go directive sets the language version to go 1.%d.", goLang),
+			}},
+		})
+	}
+	return errors, nil
+}
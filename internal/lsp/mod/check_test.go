@@ -0,0 +1,1057 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// testMapper returns a column mapper for a go.mod file with the given
+// contents.
+func testMapper(contents string) (span.URI, *protocol.ColumnMapper) {
+	uri := span.URIFromPath("/tmp/go.mod")
+	return uri, &protocol.ColumnMapper{
+		URI:       uri,
+		Converter: span.NewContentConverter(uri.Filename(), []byte(contents)),
+		Content:   []byte(contents),
+	}
+}
+
+// parseTestMod parses the given go.mod contents, as the ParseModHandle would.
+func parseTestMod(t *testing.T, contents string) (span.URI, *protocol.ColumnMapper, *modfile.File) {
+	t.Helper()
+	uri, m := testMapper(contents)
+	file, err := modfile.Parse(uri.Filename(), []byte(contents), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uri, m, file
+}
+
+// rangeText returns the text of the go.mod file covered by rng.
+func rangeText(t *testing.T, m *protocol.ColumnMapper, rng protocol.Range) string {
+	t.Helper()
+	spn, err := m.RangeSpan(rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(m.Content[spn.Start().Offset():spn.End().Offset()])
+}
+
+func TestCheckPaths(t *testing.T) {
+	for _, tt := range []struct {
+		name, mod string
+		// want maps the text of each diagnostic's range to a substring of its
+		// message.
+		want map[string]string
+	}{
+		{
+			name: "valid",
+			mod: `module mod.com
+
+require example.com/foo v1.0.0
+
+replace local => ./local
+`,
+		},
+		{
+			name: "uppercase",
+			mod: `module mod.com
+
+require Example.com/foo v1.0.0
+`,
+			want: map[string]string{"Example.com/foo": `invalid char 'E' in first path element`},
+		},
+		{
+			name: "trailing slash",
+			mod: `module mod.com/
+
+require (
+	example.com/foo/ v1.0.0
+)
+`,
+			want: map[string]string{
+				"mod.com/":         "trailing slash",
+				"example.com/foo/": "trailing slash",
+			},
+		},
+		{
+			name: "reserved name",
+			mod: `module mod.com
+
+require example.com/con v1.0.0
+`,
+			want: map[string]string{"example.com/con": `"con" disallowed as path element component on Windows`},
+		},
+		{
+			name: "replaced without dot",
+			mod: `module mod.com
+
+require local v1.0.0
+
+replace local => ./local
+`,
+		},
+		{
+			name: "replacement",
+			mod: `module mod.com
+
+replace example.com/foo v1.0.0 => foo v1.2.0
+`,
+			want: map[string]string{"foo": "missing dot in first path element"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, m, file := parseTestMod(t, tt.mod)
+			errors, err := checkPaths(uri, m, file, source.DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(errors) != len(tt.want) {
+				t.Fatalf("got %d errors, want %d: %v", len(errors), len(tt.want), errors)
+			}
+			for _, e := range errors {
+				text := rangeText(t, m, e.Range)
+				msg, ok := tt.want[text]
+				if !ok {
+					t.Errorf("unexpected error at %q: %s", text, e.Message)
+					continue
+				}
+				if !strings.Contains(e.Message, msg) {
+					t.Errorf("error at %q: got message %q, want it to contain %q", text, e.Message, msg)
+				}
+			}
+		})
+	}
+}
+
+func TestGoDirectiveErrors(t *testing.T) {
+	for _, tt := range []struct {
+		version   string
+		goversion int
+		wantMsg   string // empty if no error is expected
+	}{
+		{"1.21", 20, ""},
+		{"1.21", 27, ""},
+		{"1.21.0", 20, `invalid go version "1.21.0": go1.20 requires the form 1.N`},
+		{"1.21.0", 27, `go1.27 accepts go version "1.21.0", but gopls cannot parse a go directive with a patch version, so it does not check the rest of the file.`},
+		{"1.21.0", 0, `invalid go version "1.21.0": must match format 1.N`},
+	} {
+		t.Run(fmt.Sprintf("%s/go1.%d", tt.version, tt.goversion), func(t *testing.T) {
+			contents := fmt.Sprintf("module mod.com\n\ngo %s\n", tt.version)
+			uri, m := testMapper(contents)
+			errors, err := goDirectiveErrors(uri, m, tt.goversion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantMsg == "" {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			e := errors[0]
+			if e.Message != tt.wantMsg {
+				t.Errorf("got message %q, want %q", e.Message, tt.wantMsg)
+			}
+			if got := rangeText(t, m, e.Range); got != tt.version {
+				t.Errorf("got range covering %q, want %q", got, tt.version)
+			}
+			if tt.goversion >= 21 {
+				// The go command accepts the version, so it is not lowered.
+				if diag := toDiagnostic(e); diag.Severity != protocol.SeverityInformation {
+					t.Errorf("got severity %v, want information", diag.Severity)
+				}
+				if len(e.SuggestedFixes) != 0 {
+					t.Errorf("got fixes %v, want none", e.SuggestedFixes)
+				}
+				return
+			}
+			if len(e.SuggestedFixes) != 1 {
+				t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+			}
+			edits := e.SuggestedFixes[0].Edits[uri]
+			if len(edits) != 1 || edits[0].NewText != "1.21" {
+				t.Errorf("got edits %v, want the version replaced by 1.21", edits)
+			}
+		})
+	}
+}
+
+func TestRedundantToolchainErrors(t *testing.T) {
+	for _, tt := range []struct {
+		toolchain string
+		redundant bool
+	}{
+		{"go1.21.0", true},
+		{"go1.21.3", false},
+	} {
+		t.Run(tt.toolchain, func(t *testing.T) {
+			mod := fmt.Sprintf("module mod.com\n\ngo 1.21.0\n\ntoolchain %s\n", tt.toolchain)
+			uri, m := testMapper(mod)
+			errors, err := redundantToolchainErrors(uri, m, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.redundant {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			e := errors[0]
+			if got := rangeText(t, m, e.Range); got != tt.toolchain {
+				t.Errorf("got range covering %q, want the toolchain", got)
+			}
+			if diag := toDiagnostic(e); diag.Severity != protocol.SeverityHint {
+				t.Errorf("got severity %v, want a hint", diag.Severity)
+			}
+			if len(e.SuggestedFixes) != 1 {
+				t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+			}
+			edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := diff.ApplyEdits(mod, edits), "module mod.com\n\ngo 1.21.0\n\n"; got != want {
+				t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+
+	// The go command in use needs the directive to download go1.22.3.
+	uri, m := testMapper("module mod.com\n\ngo 1.22.3\n\ntoolchain go1.22.3\n")
+	if errors, err := redundantToolchainErrors(uri, m, 21); err != nil || len(errors) > 0 {
+		t.Errorf("got errors %v, %v for a toolchain that go1.21 needs, want none", errors, err)
+	}
+}
+
+func TestDivergentToolchainErrors(t *testing.T) {
+	for _, tt := range []struct {
+		goVersion, toolchain string
+		want                 string
+	}{
+		{"1.20", "go1.22.1", "The language version is go 1.20, set by the go directive: toolchain go1.22.1 only selects the go command that builds the module, so language features added after go 1.20 remain unavailable."},
+		{"1.21.0", "go1.20.3", "toolchain go1.20.3 is older than go 1.21.0, so the go command ignores it; the language version is go 1.21, set by the go directive."},
+		{"1.21.0", "go1.21.3", ""},
+		{"1.21.0", "go1.21rc2", ""},
+		{"1.21.0", "default", ""},
+	} {
+		t.Run(tt.goVersion+"/"+tt.toolchain, func(t *testing.T) {
+			mod := fmt.Sprintf("module mod.com\n\ngo %s\n\ntoolchain %s\n", tt.goVersion, tt.toolchain)
+			uri, m := testMapper(mod)
+			errors, err := divergentToolchainErrors(uri, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			e := errors[0]
+			if e.Message != tt.want {
+				t.Errorf("got message %q, want %q", e.Message, tt.want)
+			}
+			if got := rangeText(t, m, e.Range); got != tt.toolchain {
+				t.Errorf("got range covering %q, want the toolchain", got)
+			}
+			if len(e.Related) != 1 || rangeText(t, m, e.Related[0].Range) != tt.goVersion {
+				t.Errorf("got related information %v, want the go version", e.Related)
+			}
+			if diag := toDiagnostic(e); diag.Severity != protocol.SeverityInformation {
+				t.Errorf("got severity %v, want information", diag.Severity)
+			}
+			if len(e.SuggestedFixes) != 0 {
+				t.Errorf("got %d fixes, want none", len(e.SuggestedFixes))
+			}
+		})
+	}
+}
+
+func TestMissingToolchainErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		mod       string
+		goversion int
+		want      string
+	}{
+		{"older", "module mod.com\n\ngo 1.22\n\nrequire example.com/a v1.0.0\n", 21, "module mod.com\n\ngo 1.22\n\ntoolchain go1.22.0\n\nrequire example.com/a v1.0.0\n"},
+		{"last line", "module mod.com\n\ngo 1.22", 21, "module mod.com\n\ngo 1.22\n\ntoolchain go1.22.0\n"},
+		{"same", "module mod.com\n\ngo 1.22\n", 22, ""},
+		{"toolchain", "module mod.com\n\ngo 1.22\n\ntoolchain go1.22.1\n", 21, ""},
+		{"unknown", "module mod.com\n\ngo 1.22\n", 0, ""},
+		{"no switching", "module mod.com\n\ngo 1.22\n", 20, ""},
+		{"patch", "module mod.com\n\ngo 1.22.3\n", 21, "module mod.com\n\ngo 1.22.3\n\ntoolchain go1.22.3\n"},
+		{"patch, same", "module mod.com\n\ngo 1.22.3\n", 22, ""},
+		{"invalid", "module mod.com\n\ngo 1.22.x\n", 21, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, m := testMapper(tt.mod)
+			errors, err := missingToolchainErrors(uri, m, tt.goversion)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == "" {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			e := errors[0]
+			version, _, _ := scanToolchains([]byte(tt.mod))
+			if got := rangeText(t, m, e.Range); got != version {
+				t.Errorf("got range covering %q, want the go version", got)
+			}
+			if len(e.SuggestedFixes) != 1 {
+				t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+			}
+			edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.ApplyEdits(tt.mod, edits); got != tt.want {
+				t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, tt.want)
+			}
+			fixed, m := testMapper(tt.want)
+			if errors, _ := redundantToolchainErrors(fixed, m, tt.goversion); len(errors) > 0 {
+				t.Errorf("added toolchain is reported as redundant: %v", errors)
+			}
+		})
+	}
+}
+
+func TestCheckExcludes(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/foo v1.2.0
+
+exclude (
+	example.com/foo v1.1.0
+	example.com/foo v1.3.0
+)
+`
+	const want = `module mod.com
+
+go 1.14
+
+require example.com/foo v1.2.0
+
+exclude example.com/foo v1.3.0
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkExcludes(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if got := rangeText(t, m, e.Range); got != "example.com/foo v1.1.0" {
+		t.Errorf("got range covering %q, want the exclude of v1.1.0", got)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCheckMainModuleExcludes(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+exclude (
+	mod.com v1.0.0
+	mod.com/other v1.0.0
+)
+`
+	const want = `module mod.com
+
+go 1.14
+
+exclude mod.com/other v1.0.0
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkMainModuleExcludes(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if got := rangeText(t, m, e.Range); got != "mod.com v1.0.0" {
+		t.Errorf("got range covering %q, want the exclude of the main module", got)
+	}
+	if diag := toDiagnostic(e); diag.Severity != protocol.SeverityError {
+		t.Errorf("got severity %v, want an error", diag.Severity)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCheckDuplicateExcludes(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+exclude (
+	example.com/foo v1.2.0
+	example.com/foo v1.2.1
+	example.com/foo v1.2
+)
+`
+	const want = `module mod.com
+
+go 1.14
+
+exclude (
+	example.com/foo v1.2.0
+	example.com/foo v1.2.1
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkDuplicateExcludes(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The adjacent versions v1.2.0 and v1.2.1 are excluded separately, but
+	// v1.2 is another spelling of v1.2.0.
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if got := rangeText(t, m, e.Range); got != "example.com/foo v1.2" {
+		t.Errorf("got range covering %q, want the redundant exclude", got)
+	}
+	if want := "example.com/foo v1.2.0 is already excluded on line 6."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCheckScatteredExcludes(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+exclude example.com/foo v1.2.1
+
+require example.com/bar v1.0.0
+
+exclude (
+	example.com/bar v1.0.1
+	example.com/bar v1.0.2
+)
+
+exclude example.com/foo v1.2.0
+`
+	const want = `module mod.com
+
+go 1.14
+
+require example.com/bar v1.0.0
+
+exclude (
+	example.com/bar v1.0.1
+	example.com/bar v1.0.2
+)
+
+exclude (
+	example.com/foo v1.2.0
+	example.com/foo v1.2.1
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkScatteredExcludes(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The excludes of example.com/bar are already in one block.
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if got := rangeText(t, m, e.Range); got != "exclude example.com/foo v1.2.0" {
+		t.Errorf("got range covering %q, want the second exclude", got)
+	}
+	if want := "example.com/foo is also excluded on line 5; its excludes can be consolidated into one block."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if diag := toDiagnostic(e); diag.Severity != protocol.SeverityHint {
+		t.Errorf("got severity %v, want a hint", diag.Severity)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCheckReplacedRequires(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/foo v1.0.0
+	example.com/bar v1.0.0
+	example.com/baz v1.0.0
+)
+
+replace (
+	example.com/foo => example.com/foo v1.5.0
+	example.com/bar v1.1.0 => example.com/bar v1.5.0
+	example.com/baz => example.com/other v1.5.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkReplacedRequires(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only foo's requirement is overridden: bar's replace applies to
+	// another version, and baz is replaced with a different module.
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if got := rangeText(t, m, e.Range); got != "example.com/foo v1.0.0" {
+		t.Errorf("got range covering %q, want the requirement on foo", got)
+	}
+	if diag := toDiagnostic(e); diag.Severity != protocol.SeverityHint {
+		t.Errorf("got severity %v, want a hint", diag.Severity)
+	}
+	if len(e.SuggestedFixes) != 2 {
+		t.Fatalf("got %d fixes, want 2", len(e.SuggestedFixes))
+	}
+	for i, want := range []string{
+		"\texample.com/foo v1.5.0\n",
+		"replace (\n\texample.com/bar v1.1.0",
+	} {
+		edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[i].Edits[uri])
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := diff.ApplyEdits(mod, edits)
+		if !strings.Contains(got, want) {
+			t.Errorf("%s: fixed go.mod does not contain %q:\n%s", e.SuggestedFixes[i].Title, want, got)
+		}
+		if i == 1 && strings.Contains(got, "example.com/foo =>") {
+			t.Errorf("%s: fixed go.mod still replaces foo:\n%s", e.SuggestedFixes[i].Title, got)
+		}
+	}
+}
+
+func TestCheckOverlappingReplaces(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/a/sub v1.0.0
+	example.com/ab v1.0.0
+)
+
+replace example.com/a => ../a
+
+replace example.com/ab => ../ab
+
+replace example.com/a/sub => ../sub
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkOverlappingReplaces(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// example.com/ab shares a prefix with example.com/a, but not a path
+	// element, so it does not overlap.
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if got := rangeText(t, m, e.Range); got != "replace example.com/a/sub => ../sub" {
+		t.Errorf("got range covering %q, want the later replace", got)
+	}
+	if want := "example.com/a/sub and example.com/a are both replaced, so imports of packages in example.com/a/sub are ambiguous (see the replace on line 11)."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+}
+
+func TestReplaceDirErrors(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+replace (
+	example.com/a => ../a
+	example.com/b => ../../b
+	example.com/c => ./c/../../../c
+	example.com/d => /d
+	example.com/e => ./e
+	example.com/f => ../tmp/f
+	example.com/g => ../tmpg
+)
+`
+	for _, tt := range []struct {
+		name  string
+		roots []string
+		want  []string
+	}{
+		{"folder", []string{"/tmp"}, []string{"../a", "../../b", "./c/../../../c", "../tmpg"}},
+		{"go.work", []string{"/tmp", "/"}, []string{"../../b", "./c/../../../c"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, m, file := parseTestMod(t, mod)
+			errors, err := replaceDirErrors(uri, m, file, tt.roots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range errors {
+				got = append(got, rangeText(t, m, e.Range))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got errors for %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceDirOutside(t *testing.T) {
+	const mod = "module mod.com\n\ngo 1.14\n\nreplace example.com/outside => ../outside\n"
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := replaceDirErrors(uri, m, file, []string{"/tmp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	const want = "../outside refers to /outside, outside the workspace, so the replacement of example.com/outside depends on what is checked out next to the workspace."
+	if errors[0].Message != want {
+		t.Errorf("got message %q, want %q", errors[0].Message, want)
+	}
+	if diag := toDiagnostic(errors[0]); diag.Severity != protocol.SeverityWarning {
+		t.Errorf("got severity %v, want a warning", diag.Severity)
+	}
+}
+
+func TestCheckIndirectCount(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/direct v1.0.0
+	example.com/a v1.0.0 // indirect
+	example.com/b v1.0.0 // indirect
+	example.com/c v1.0.0 // indirect
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	for _, tt := range []struct {
+		threshold int
+		wantMsg   string // empty if no error is expected
+	}{
+		{0, ""},
+		{3, ""},
+		{2, "mod.com has 3 indirect requirements, more than the threshold of 2; consider reviewing its dependencies."},
+	} {
+		t.Run(fmt.Sprint(tt.threshold), func(t *testing.T) {
+			options := source.DefaultOptions()
+			options.IndirectRequireThreshold = tt.threshold
+			errors, err := checkIndirectCount(uri, m, file, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantMsg == "" {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			if errors[0].Message != tt.wantMsg {
+				t.Errorf("got message %q, want %q", errors[0].Message, tt.wantMsg)
+			}
+			if got := rangeText(t, m, errors[0].Range); got != "module mod.com" {
+				t.Errorf("got range covering %q, want the module directive", got)
+			}
+		})
+	}
+}
+
+func TestCheckMajorVersionLayout(t *testing.T) {
+	for _, tt := range []struct {
+		dir, path string
+		want      bool
+	}{
+		{"/foo/v3", "example.com/foo/v2", true},
+		{"/foo/v2", "example.com/foo/v2", false},
+		{"/foo", "example.com/foo/v2", false},
+		{"/foo/v3", "example.com/foo", false},
+		{"/foo/v3", "gopkg.in/foo.v2", false},
+	} {
+		t.Run(tt.dir+":"+tt.path, func(t *testing.T) {
+			contents := fmt.Sprintf("module %s\n", tt.path)
+			uri := span.URIFromPath(tt.dir + "/go.mod")
+			m := &protocol.ColumnMapper{
+				URI:       uri,
+				Converter: span.NewContentConverter(uri.Filename(), []byte(contents)),
+				Content:   []byte(contents),
+			}
+			file, err := modfile.Parse(uri.Filename(), []byte(contents), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			options := source.DefaultOptions()
+			options.MajorVersionLayout = true
+			errors, err := checkMajorVersionLayout(uri, m, file, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			if got := rangeText(t, m, errors[0].Range); got != tt.path {
+				t.Errorf("got range covering %q, want the module path", got)
+			}
+			// The check is opt-in.
+			options.MajorVersionLayout = false
+			if errors, _ := checkMajorVersionLayout(uri, m, file, options); len(errors) > 0 {
+				t.Errorf("got errors with the check disabled: %v", errors)
+			}
+		})
+	}
+}
+
+func TestCheckHostConventions(t *testing.T) {
+	const mod = `module example.com/m
+
+require (
+	bitbucket.org/foo/bar v1.0.0
+	github.com/foo v1.0.0
+	github.com/foo/bar.git v1.0.0
+	github.com/foo/baz v1.0.0
+	gopkg.in/a/b/c.v1 v1.0.0
+	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/vendor/check.v1 v0.0.0-20161208181325-20d25e280405
+	gopkg.in/user/tomb.v2 v2.0.0+incompatible
+	gopkg.in/replaced/a/b.v1 v1.0.0
+)
+
+replace gopkg.in/replaced/a/b.v1 => ./b
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkHostConventions(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range errors {
+		if e.Category != hostConventionCategory {
+			t.Errorf("got category %q, want %q", e.Category, hostConventionCategory)
+		}
+		got = append(got, rangeText(t, m, e.Range))
+	}
+	want := []string{
+		"github.com/foo v1.0.0",
+		"github.com/foo/bar.git v1.0.0",
+		"gopkg.in/a/b/c.v1 v1.0.0",
+		"gopkg.in/user/tomb.v2 v2.0.0+incompatible",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics on %q, want %q", got, want)
+	}
+}
+
+func TestRelatedInformation(t *testing.T) {
+	const mod = `module example.com/m
+
+require (
+	example.com/a v1.2.0
+	example.com/b v1.0.0
+)
+
+exclude (
+	example.com/a v1.1.0
+	example.com/m v1.0.0
+	example.com/c v1.0.0
+	example.com/c v1.0.0
+)
+
+replace (
+	example.com/b => example.com/b v1.1.0
+	example.com/d => ./d
+	example.com/d/sub => ./sub
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := runChecks(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		excludeCategory:     "example.com/a v1.2.0",
+		mainExcludeCategory: "module example.com/m",
+		duplicateCategory:   "example.com/c v1.0.0",
+		replacedCategory:    "example.com/b => example.com/b v1.1.0",
+		overlapCategory:     "example.com/d => ./d",
+	}
+	got := make(map[string]string)
+	for _, e := range errors {
+		if _, ok := want[e.Category]; !ok {
+			continue
+		}
+		if len(e.Related) != 1 {
+			t.Errorf("got %d related locations for %q, want 1", len(e.Related), e.Message)
+			continue
+		}
+		if e.Related[0].URI != uri || e.Related[0].Message == "" {
+			t.Errorf("got related information %+v for %q, want a message in the same file", e.Related[0], e.Message)
+		}
+		got[e.Category] = rangeText(t, m, e.Related[0].Range)
+		if diag := toDiagnostic(e); len(diag.Related) != 1 {
+			t.Errorf("the diagnostic for %q lost its related information", e.Message)
+		}
+	}
+	for category, text := range want {
+		if got[category] != text {
+			t.Errorf("got %s related to %q, want %q", category, got[category], text)
+		}
+	}
+}
+
+func TestCheckModulePolicy(t *testing.T) {
+	const mod = `module example.com/m
+
+require (
+	corp.example.com/lib v1.0.0
+	corp.example.com/libx v1.0.0
+	github.com/bad/pkg v1.0.0
+	github.com/good/pkg v1.0.0
+)
+
+replace (
+	github.com/good/pkg => github.com/bad/pkg v1.1.0
+	corp.example.com/lib => ../lib
+)
+`
+	for _, tt := range []struct {
+		name            string
+		allowed, denied []string
+		// want are the texts of the ranges of the reported paths, in order.
+		want []string
+	}{
+		{
+			name: "no policy",
+		},
+		{
+			name:   "deny",
+			denied: []string{"github.com/bad", "corp.example.com/lib/"},
+			want:   []string{"corp.example.com/lib", "github.com/bad/pkg", "github.com/bad/pkg", "corp.example.com/lib"},
+		},
+		{
+			name:    "allow",
+			allowed: []string{"corp.example.com/lib", "github.com/good"},
+			want:    []string{"corp.example.com/libx", "github.com/bad/pkg", "github.com/bad/pkg"},
+		},
+		{
+			name:    "deny within allow",
+			allowed: []string{"github.com"},
+			denied:  []string{"github.com/bad"},
+			want:    []string{"corp.example.com/lib", "corp.example.com/libx", "github.com/bad/pkg", "github.com/bad/pkg", "corp.example.com/lib"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, m, file := parseTestMod(t, mod)
+			options := source.DefaultOptions()
+			options.AllowedModules, options.DeniedModules = tt.allowed, tt.denied
+			errors, err := checkModulePolicy(uri, m, file, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range errors {
+				if e.Category != policyCategory || len(e.SuggestedFixes) > 0 {
+					t.Errorf("got %q in category %q with %d fixes, want a policy error without fixes", e.Message, e.Category, len(e.SuggestedFixes))
+				}
+				got = append(got, rangeText(t, m, e.Range))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got errors on %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSelfReplaces(t *testing.T) {
+	const mod = `module example.com/m
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+)
+
+replace (
+	example.com/a => example.com/m v1.0.0
+	example.com/b => ./
+	example.com/c => ../c
+	example.com/m/sub => ./sub
+)
+`
+	const want = `module example.com/m
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+)
+
+replace (
+	example.com/b => ./
+	example.com/c => ../c
+	example.com/m/sub => ./sub
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkSelfReplaces(uri, m, file, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 2 {
+		t.Fatalf("got %d errors, want 2: %v", len(errors), errors)
+	}
+	for i, text := range []string{"example.com/a => example.com/m v1.0.0", "example.com/b => ./"} {
+		if got := rangeText(t, m, errors[i].Range); got != text {
+			t.Errorf("got error %d on %q, want %q", i, got, text)
+		}
+	}
+	if len(errors[0].SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(errors[0].SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(m, errors[0].SuggestedFixes[0].Edits[uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("got fixed file\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestModValidators(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/a v1.0.0
+
+replace example.com/a => ../a
+`
+	uri, m, file := parseTestMod(t, mod)
+	noReplaces := func(uri span.URI, m *protocol.ColumnMapper, file *modfile.File) ([]source.Error, error) {
+		var errors []source.Error
+		for _, r := range file.Replace {
+			rng, err := positionsToRange(uri, m, r.Syntax.Start, r.Syntax.End)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: "policy",
+				Message:  "replace directives are not allowed",
+				Range:    rng,
+				URI:      uri,
+			})
+		}
+		return errors, nil
+	}
+	options := source.DefaultOptions()
+	options.ModValidators = []source.ModValidator{noReplaces}
+	errors, err := runChecks(uri, m, file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	if got := rangeText(t, m, errors[0].Range); got != "replace example.com/a => ../a" {
+		t.Errorf("got range covering %q, want the replace directive", got)
+	}
+	if diag := toDiagnostic(errors[0]); diag.Source != "policy" || diag.Severity != protocol.SeverityWarning {
+		t.Errorf("got source %q and severity %v, want a policy warning", diag.Source, diag.Severity)
+	}
+
+	failing := func(span.URI, *protocol.ColumnMapper, *modfile.File) ([]source.Error, error) {
+		return nil, fmt.Errorf("policy unavailable")
+	}
+	options.ModValidators = append(options.ModValidators, failing)
+	if _, err := runChecks(uri, m, file, options); err == nil {
+		t.Error("got no error from a failing validator")
+	}
+}
@@ -15,6 +15,18 @@ import (
 
 // CodeLens computes code lens for a go.mod file.
 func CodeLens(ctx context.Context, snapshot source.Snapshot, uri span.URI) ([]protocol.CodeLens, error) {
+	upgradeLenses, err := upgradeCodeLens(ctx, snapshot, uri)
+	if err != nil {
+		return nil, err
+	}
+	tidyLenses, err := tidyCodeLens(ctx, snapshot, uri)
+	if err != nil {
+		return nil, err
+	}
+	return append(upgradeLenses, tidyLenses...), nil
+}
+
+func upgradeCodeLens(ctx context.Context, snapshot source.Snapshot, uri span.URI) ([]protocol.CodeLens, error) {
 	if !snapshot.View().Options().EnabledCodeLens[source.CommandUpgradeDependency] {
 		return nil, nil
 	}
@@ -89,6 +101,93 @@ func CodeLens(ctx context.Context, snapshot source.Snapshot, uri span.URI) ([]pr
 	return codelens, err
 }
 
+// tidyCodeLens returns a code lens on the module directive that runs `go mod
+// tidy`, summarizing the changes it would make. The summary comes from the
+// same memoized result of `go mod tidy` as the go.mod diagnostics.
+func tidyCodeLens(ctx context.Context, snapshot source.Snapshot, uri span.URI) ([]protocol.CodeLens, error) {
+	if !snapshot.View().Options().EnabledCodeLens[source.CommandTidy] {
+		return nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.tidyCodeLens", tag.URI.Of(uri))
+	defer done()
+
+	if modURI := snapshot.View().ModFile(); modURI == "" || modURI != uri {
+		return nil, nil
+	}
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if file.Module == nil || file.Module.Syntax == nil {
+		return nil, nil
+	}
+	mth, err := snapshot.ModTidyHandle(ctx)
+	if err == source.ErrTmpModfileUnsupported {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ideal, err := mth.Ideal(ctx)
+	if err != nil || ideal == nil {
+		// Errors from `go mod tidy` are reported as diagnostics.
+		return nil, nil
+	}
+	added, removed := tidySummary(file, ideal)
+	if added == 0 && removed == 0 {
+		return nil, nil
+	}
+	rng, err := positionsToRange(uri, m, file.Module.Syntax.Start, file.Module.Syntax.End)
+	if err != nil {
+		return nil, err
+	}
+	return []protocol.CodeLens{{
+		Range: rng,
+		Command: protocol.Command{
+			Title:     fmt.Sprintf("Tidy: %s added, %s removed", pluralRequires(added), pluralRequires(removed)),
+			Command:   source.CommandTidy,
+			Arguments: []interface{}{uri},
+		},
+	}}, nil
+}
+
+// tidySummary returns the number of requirements that tidying the original
+// go.mod file into the ideal one adds and removes.
+func tidySummary(original, ideal *modfile.File) (added, removed int) {
+	originalReqs := make(map[string]bool, len(original.Require))
+	for _, req := range original.Require {
+		originalReqs[req.Mod.Path] = true
+	}
+	idealReqs := make(map[string]bool, len(ideal.Require))
+	for _, req := range ideal.Require {
+		idealReqs[req.Mod.Path] = true
+		if !originalReqs[req.Mod.Path] {
+			added++
+		}
+	}
+	for path := range originalReqs {
+		if !idealReqs[path] {
+			removed++
+		}
+	}
+	return added, removed
+}
+
+func pluralRequires(n int) string {
+	if n == 1 {
+		return "1 require"
+	}
+	return fmt.Sprintf("%d requires", n)
+}
+
 func positionsToRange(uri span.URI, m *protocol.ColumnMapper, s, e modfile.Position) (protocol.Range, error) {
 	line, col, err := m.Converter.ToPosition(s.Byte)
 	if err != nil {
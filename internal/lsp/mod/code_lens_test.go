@@ -0,0 +1,32 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import "testing"
+
+func TestTidySummary(t *testing.T) {
+	_, _, original := parseTestMod(t, `module mod.com
+
+require (
+	example.com/kept v1.0.0
+	example.com/unused v1.0.0
+	example.com/unused2 v1.0.0
+)
+`)
+	_, _, ideal := parseTestMod(t, `module mod.com
+
+require (
+	example.com/kept v1.1.0
+	example.com/missing v1.0.0
+)
+`)
+	added, removed := tidySummary(original, ideal)
+	if added != 1 || removed != 2 {
+		t.Errorf("tidySummary() = %d added, %d removed, want 1 added, 2 removed", added, removed)
+	}
+	if got := pluralRequires(1) + ", " + pluralRequires(2); got != "1 require, 2 requires" {
+		t.Errorf("got %q", got)
+	}
+}
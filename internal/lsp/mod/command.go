@@ -0,0 +1,159 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// AddDependencyCommand returns the command that adds the module path at the
+// given version to the go.mod file uri. Unlike a text edit, the command also
+// updates go.sum and downloads the module if needed.
+func AddDependencyCommand(title string, uri span.URI, path, version string) *protocol.Command {
+	return &protocol.Command{
+		Title:     title,
+		Command:   source.CommandAddDependency,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri), path, version},
+	}
+}
+
+// AddDependencyArgs returns the go.mod file, module path, and version of a
+// command returned by AddDependencyCommand, as sent back by the client.
+func AddDependencyArgs(args []interface{}) (span.URI, string, string, error) {
+	return moduleVersionArgs(args)
+}
+
+// DownloadModuleCommand returns the command that downloads the module path at
+// the given version with `go mod download`, which records its checksums in
+// the go.sum file of the go.mod file uri without changing the requirements.
+func DownloadModuleCommand(uri span.URI, path, version string) *protocol.Command {
+	return &protocol.Command{
+		Title:     fmt.Sprintf("Download %s %s", path, version),
+		Command:   source.CommandDownloadModule,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri), path, version},
+	}
+}
+
+// DownloadModuleArgs returns the go.mod file, module path, and version of a
+// command returned by DownloadModuleCommand, as sent back by the client.
+func DownloadModuleArgs(args []interface{}) (span.URI, string, string, error) {
+	return moduleVersionArgs(args)
+}
+
+// DowngradeDependencyCommand returns the command that downgrades the
+// requirement of the go.mod file uri on the module path from version from to
+// the previous published version, as found by DowngradeQuery.
+func DowngradeDependencyCommand(uri span.URI, path, from string) *protocol.Command {
+	return &protocol.Command{
+		Title:     fmt.Sprintf("Downgrade %s from %s to the previous version", path, from),
+		Command:   source.CommandDowngradeDependency,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri), path, from},
+	}
+}
+
+// DowngradeDependencyArgs returns the go.mod file, module path, and current
+// version of a command returned by DowngradeDependencyCommand, as sent back
+// by the client.
+func DowngradeDependencyArgs(args []interface{}) (span.URI, string, string, error) {
+	return moduleVersionArgs(args)
+}
+
+// MigrateIncompatibleCommand returns the command that migrates the
+// +incompatible requirement of the go.mod file uri on the module path at
+// version to the versioned module path, as MigrateIncompatible does. That
+// lists the published versions of the versioned path, so it is only done
+// when the command runs.
+func MigrateIncompatibleCommand(uri span.URI, path, version string) *protocol.Command {
+	return &protocol.Command{
+		Title:     fmt.Sprintf("Migrate %s %s to a versioned module path", path, version),
+		Command:   source.CommandMigrateIncompatible,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri), path, version},
+	}
+}
+
+// MigrateIncompatibleArgs returns the go.mod file, module path, and version
+// of a command returned by MigrateIncompatibleCommand, as sent back by the
+// client.
+func MigrateIncompatibleArgs(args []interface{}) (span.URI, string, string, error) {
+	return moduleVersionArgs(args)
+}
+
+// moduleVersionArgs decodes the go.mod file URI, module path, and version
+// arguments of a command.
+func moduleVersionArgs(args []interface{}) (span.URI, string, string, error) {
+	if len(args) != 3 {
+		return "", "", "", errors.Errorf("expected 3 arguments, got %v", args)
+	}
+	var strs [3]string
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			strs[i] = arg
+		case protocol.DocumentURI:
+			strs[i] = string(arg)
+		default:
+			return "", "", "", errors.Errorf("expected argument %d to be a string but got %T", i, arg)
+		}
+	}
+	uri, path, version := protocol.DocumentURI(strs[0]).SpanURI(), strs[1], strs[2]
+	if path == "" || version == "" {
+		return "", "", "", errors.Errorf("missing module path or version in %v", args)
+	}
+	return uri, path, version, nil
+}
+
+// MinimalGoDirectiveCommand returns the command that sets the go directive of
+// the go.mod file uri to the version computed by MinimalGoDirective. That
+// lists the whole module graph, so it is only done when the command runs.
+func MinimalGoDirectiveCommand(uri span.URI) *protocol.Command {
+	return &protocol.Command{
+		Title:     "Set the go directive to the minimal version",
+		Command:   source.CommandMinimalGoDirective,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri)},
+	}
+}
+
+// MinimalGoDirectiveArgs returns the go.mod file of a command returned by
+// MinimalGoDirectiveCommand, as sent back by the client.
+func MinimalGoDirectiveArgs(args []interface{}) (span.URI, error) {
+	return uriArgs(args)
+}
+
+// AnnotateIndirectCommand returns the command that annotates the indirect
+// requirements of the go.mod file uri as ViaComments does. That runs
+// `go mod why`, so it is only done when the command runs.
+func AnnotateIndirectCommand(uri span.URI) *protocol.Command {
+	return &protocol.Command{
+		Title:     "Annotate indirect requires with the modules that need them",
+		Command:   source.CommandAnnotateIndirect,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri)},
+	}
+}
+
+// AnnotateIndirectArgs returns the go.mod file of a command returned by
+// AnnotateIndirectCommand, as sent back by the client.
+func AnnotateIndirectArgs(args []interface{}) (span.URI, error) {
+	return uriArgs(args)
+}
+
+// uriArgs decodes the go.mod file URI argument of a command.
+func uriArgs(args []interface{}) (span.URI, error) {
+	if len(args) != 1 {
+		return "", errors.Errorf("expected 1 argument, got %v", args)
+	}
+	switch arg := args[0].(type) {
+	case string:
+		return protocol.DocumentURI(arg).SpanURI(), nil
+	case protocol.DocumentURI:
+		return arg.SpanURI(), nil
+	default:
+		return "", errors.Errorf("expected a go.mod URI but got %T", args[0])
+	}
+}
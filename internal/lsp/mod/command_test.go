@@ -0,0 +1,87 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestAddDependencyArgs(t *testing.T) {
+	uri := span.URIFromPath("/a/go.mod")
+	cmd := AddDependencyCommand("Add example.com/foo", uri, "example.com/foo", "v1.2.0")
+	if cmd.Command != source.CommandAddDependency {
+		t.Fatalf("got command %q, want %q", cmd.Command, source.CommandAddDependency)
+	}
+	// The arguments reach the server after a round trip through the client.
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded protocol.Command
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]interface{}{cmd.Arguments, decoded.Arguments} {
+		gotURI, path, version, err := AddDependencyArgs(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotURI != uri || path != "example.com/foo" || version != "v1.2.0" {
+			t.Errorf("AddDependencyArgs(%v) = %s, %s, %s", args, gotURI, path, version)
+		}
+	}
+	for _, args := range [][]interface{}{
+		nil,
+		{string(protocol.URIFromSpanURI(uri)), "example.com/foo"},
+		{string(protocol.URIFromSpanURI(uri)), "example.com/foo", 1},
+		{string(protocol.URIFromSpanURI(uri)), "", "v1.2.0"},
+	} {
+		if _, _, _, err := AddDependencyArgs(args); err == nil {
+			t.Errorf("AddDependencyArgs(%v) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestMinimalGoDirectiveArgs(t *testing.T) {
+	uri := span.URIFromPath("/a/go.mod")
+	cmd := MinimalGoDirectiveCommand(uri)
+	if cmd.Command != source.CommandMinimalGoDirective {
+		t.Fatalf("got command %q, want %q", cmd.Command, source.CommandMinimalGoDirective)
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded protocol.Command
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]interface{}{cmd.Arguments, decoded.Arguments} {
+		if gotURI, err := MinimalGoDirectiveArgs(args); err != nil || gotURI != uri {
+			t.Errorf("MinimalGoDirectiveArgs(%v) = %s, %v", args, gotURI, err)
+		}
+	}
+	for _, args := range [][]interface{}{nil, {1}, {string(protocol.URIFromSpanURI(uri)), "extra"}} {
+		if _, err := MinimalGoDirectiveArgs(args); err == nil {
+			t.Errorf("MinimalGoDirectiveArgs(%v) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestAnnotateIndirectArgs(t *testing.T) {
+	uri := span.URIFromPath("/a/go.mod")
+	cmd := AnnotateIndirectCommand(uri)
+	if cmd.Command != source.CommandAnnotateIndirect {
+		t.Fatalf("got command %q, want %q", cmd.Command, source.CommandAnnotateIndirect)
+	}
+	if gotURI, err := AnnotateIndirectArgs([]interface{}{string(protocol.URIFromSpanURI(uri))}); err != nil || gotURI != uri {
+		t.Errorf("AnnotateIndirectArgs = %s, %v, want %s", gotURI, err, uri)
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// Completion returns completions for the go.mod file at the given position.
+// For now, it only completes the directories of filesystem replacements,
+// such as the ../foo in `replace example.com/foo => ../foo`.
+func Completion(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, position protocol.Position) (*protocol.CompletionList, error) {
+	ctx, done := event.Start(ctx, "mod.Completion", tag.URI.Of(fh.URI()))
+	defer done()
+
+	content, err := fh.Read()
+	if err != nil {
+		return nil, err
+	}
+	m := &protocol.ColumnMapper{
+		URI:       fh.URI(),
+		Converter: span.NewContentConverter(fh.URI().Filename(), content),
+		Content:   content,
+	}
+	spn, err := m.PointSpan(position)
+	if err != nil {
+		return nil, err
+	}
+	offset := spn.Start().Offset()
+	prefix, ok := replaceTargetPrefix(content, offset)
+	if !ok {
+		return &protocol.CompletionList{Items: []protocol.CompletionItem{}}, nil
+	}
+	// Only the last element of the target is replaced by a completion.
+	partial := prefix[strings.LastIndex(prefix, "/")+1:]
+	var start, end modfile.Position
+	start.Byte, end.Byte = offset-len(partial), offset
+	rng, err := positionsToRange(fh.URI(), m, start, end)
+	if err != nil {
+		return nil, err
+	}
+	hasModFile := func(dir string) bool {
+		fh, err := snapshot.GetFile(ctx, span.URIFromPath(filepath.Join(dir, "go.mod")))
+		if err != nil {
+			return false
+		}
+		_, err = fh.Read()
+		return err == nil
+	}
+	dirs, err := replaceDirCompletions(filepath.Dir(fh.URI().Filename()), prefix, hasModFile)
+	if err != nil {
+		return nil, err
+	}
+	items := []protocol.CompletionItem{}
+	for i, dir := range dirs {
+		item := protocol.CompletionItem{
+			Label: dir.name,
+			Kind:  protocol.FolderCompletion,
+			TextEdit: &protocol.TextEdit{
+				NewText: dir.name,
+				Range:   rng,
+			},
+			// See the corresponding comment in the Go completion code:
+			// this makes clients keep our ordering.
+			SortText: fmt.Sprintf("%05d", i),
+		}
+		if dir.module {
+			item.Detail = "module"
+		}
+		items = append(items, item)
+	}
+	return &protocol.CompletionList{Items: items}, nil
+}
+
+// replaceTargetRe matches the text of a replace directive up to a cursor in
+// its replacement path. The "replace" verb is optional, since the directive
+// may be in a block.
+var replaceTargetRe = regexp.MustCompile(`^\s*(replace\s+)?\S+(\s+\S+)?\s+=>\s+(\S*)$`)
+
+// replaceTargetPrefix reports whether offset is in the path of a
+// filesystem replacement in the given go.mod contents, and if so returns the
+// part of the path before offset.
+func replaceTargetPrefix(content []byte, offset int) (string, bool) {
+	if offset < 0 || offset > len(content) {
+		return "", false
+	}
+	before := string(content[:offset])
+	lineStart := strings.LastIndex(before, "\n") + 1
+	match := replaceTargetRe.FindStringSubmatch(before[lineStart:])
+	if match == nil {
+		return "", false
+	}
+	if match[1] == "" && !inReplaceBlock(before[:lineStart]) {
+		return "", false
+	}
+	prefix := match[3]
+	if !strings.HasPrefix(prefix, ".") && !strings.HasPrefix(prefix, "/") {
+		// Not a filesystem path.
+		return "", false
+	}
+	return prefix, true
+}
+
+// inReplaceBlock reports whether the end of the given text is in a replace
+// block.
+func inReplaceBlock(text string) bool {
+	lines := strings.Split(text, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		switch {
+		case line == ")":
+			return false
+		case strings.HasSuffix(line, "("):
+			return strings.TrimSpace(strings.TrimSuffix(line, "(")) == "replace"
+		}
+	}
+	return false
+}
+
+type dirCompletion struct {
+	name   string
+	module bool // whether the directory contains a go.mod file
+}
+
+// replaceDirCompletions returns the subdirectories that complete the
+// replacement path prefix, relative to modDir, the directory of the go.mod
+// file. Directories with a go.mod file are listed first, and modDir itself is
+// never listed.
+func replaceDirCompletions(modDir, prefix string, hasModFile func(dir string) bool) ([]dirCompletion, error) {
+	i := strings.LastIndex(prefix, "/")
+	if i < 0 {
+		// Wait for "." or ".." to be completed to a directory.
+		return nil, nil
+	}
+	parent, err := resolveReplaceDir(modDir, prefix[:i+1])
+	if err == errEscapesRoot {
+		// There is nothing above the root to complete.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	partial := prefix[i+1:]
+	infos, err := ioutil.ReadDir(parent)
+	if os.IsNotExist(err) {
+		// The user may still be typing the path.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dirs []dirCompletion
+	for _, info := range infos {
+		name := info.Name()
+		if !info.IsDir() || !strings.HasPrefix(name, partial) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(partial, ".") {
+			continue
+		}
+		dir := filepath.Join(parent, name)
+		if realDir(dir) == realDir(modDir) {
+			continue
+		}
+		dirs = append(dirs, dirCompletion{name: name, module: hasModFile(dir)})
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return dirs[i].module && !dirs[j].module
+	})
+	return dirs, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReplaceTargetPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		content string // the cursor is at the "|"
+		want    string
+		wantOK  bool
+	}{
+		{"module mod.com\n\nreplace example.com/a => ../|", "../", true},
+		{"module mod.com\n\nreplace example.com/a v1.0.0 => ./sub/fo|", "./sub/fo", true},
+		{"module mod.com\n\nreplace (\n\texample.com/a => ../|\n)\n", "../", true},
+		{"module mod.com\n\nrequire (\n\texample.com/a => ../|\n)\n", "", false},
+		{"module mod.com\n\nreplace example.com/a => example.com/|", "", false},
+		{"module mod.com\n\nreplace example.com/a => ../b v1.0.0|", "", false},
+		{"module mod.com\n\nrequire example.com/a ../|", "", false},
+	} {
+		offset := strings.Index(tt.content, "|")
+		content := strings.Replace(tt.content, "|", "", 1)
+		got, ok := replaceTargetPrefix([]byte(content), offset)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("replaceTargetPrefix(%q) = %q, %v, want %q, %v", tt.content, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestReplaceDirCompletions(t *testing.T) {
+	root, err := ioutil.TempDir("", "modcompletion")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"main", "lib", "module", ".hidden"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	modDir := filepath.Join(root, "main")
+	hasModFile := func(dir string) bool {
+		return filepath.Base(dir) == "module"
+	}
+	for _, tt := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"../", []string{"module", "lib"}},
+		{"../m", []string{"module"}},
+		{"../.", []string{".hidden"}},
+		{"./", nil},
+		{"..", nil},
+		{"../missing/", nil},
+	} {
+		dirs, err := replaceDirCompletions(modDir, tt.prefix, hasModFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, dir := range dirs {
+			got = append(got, dir.name)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("replaceDirCompletions(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+}
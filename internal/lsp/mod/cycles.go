@@ -0,0 +1,225 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const moduleCycleCategory = "module cycle"
+
+// moduleCycleErrors reports the cycles in the module graph rooted at root,
+// as printed by `go mod graph`, on the requirements of file that are part of
+// them, or, for cycles deeper in the graph, on the requirements that lead to
+// them. Any version of the main module stands for the main module itself, so
+// a dependency that requires the main module back, for example through
+// mutual directory replacements, closes a cycle.
+func moduleCycleErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, root *DepNode) ([]source.Error, error) {
+	canonical := func(n *DepNode) *DepNode {
+		if n.Path == root.Path {
+			return root
+		}
+		return n
+	}
+	successors := func(n *DepNode) []*DepNode {
+		var succ []*DepNode
+		for _, req := range n.Requires {
+			succ = append(succ, canonical(req))
+		}
+		return succ
+	}
+	cycles := stronglyConnected(root, successors)
+	// Every module leads back to the main module if one requires it, so
+	// paths through the main module do not count as leading to a cycle.
+	below := func(n *DepNode) []*DepNode {
+		var succ []*DepNode
+		for _, w := range successors(n) {
+			if w != root {
+				succ = append(succ, w)
+			}
+		}
+		return succ
+	}
+
+	byID := make(map[string]*DepNode)
+	walkGraph([]*DepNode{root}, func(n *DepNode) {
+		byID[n.Path+"@"+n.Version] = canonical(n)
+	})
+	var errors []source.Error
+	for _, cycle := range cycles {
+		inCycle := make(map[*DepNode]bool)
+		for _, n := range cycle {
+			inCycle[n] = true
+		}
+		var on, leading []*modfile.Require
+		for _, req := range file.Require {
+			n := byID[req.Mod.Path+"@"+req.Mod.Version]
+			if req.Syntax == nil || n == nil {
+				continue
+			}
+			if inCycle[n] {
+				on = append(on, req)
+			} else if reaches(n, inCycle, below) {
+				leading = append(leading, req)
+			}
+		}
+		for _, req := range on {
+			path := cyclePath(byID[req.Mod.Path+"@"+req.Mod.Version], inCycle, successors)
+			e, err := moduleCycleError(uri, m, req, fmt.Sprintf("%s is in a module dependency cycle: %s.", req.Mod.Path, path))
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, e)
+		}
+		if len(on) > 0 || len(leading) == 0 {
+			continue
+		}
+		path := cyclePath(cycle[0], inCycle, successors)
+		for _, req := range leading {
+			e, err := moduleCycleError(uri, m, req, fmt.Sprintf("%s leads to a module dependency cycle: %s.", req.Mod.Path, path))
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, e)
+		}
+	}
+	return errors, nil
+}
+
+// moduleCycleError reports msg on the requirement req.
+func moduleCycleError(uri span.URI, m *protocol.ColumnMapper, req *modfile.Require, msg string) (source.Error, error) {
+	rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+	if err != nil {
+		return source.Error{}, err
+	}
+	return source.Error{
+		Category: moduleCycleCategory,
+		Message:  msg,
+		Range:    rng,
+		URI:      uri,
+	}, nil
+}
+
+// stronglyConnected returns the cycles of the graph reachable from root: its
+// strongly connected components with more than one node, or with a node that
+// requires itself.
+func stronglyConnected(root *DepNode, successors func(*DepNode) []*DepNode) [][]*DepNode {
+	index := make(map[*DepNode]int)
+	low := make(map[*DepNode]int)
+	onStack := make(map[*DepNode]bool)
+	var stack []*DepNode
+	var cycles [][]*DepNode
+	var connect func(v *DepNode)
+	connect = func(v *DepNode) {
+		index[v] = len(index)
+		low[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		selfLoop := false
+		for _, w := range successors(v) {
+			if w == v {
+				selfLoop = true
+			}
+			if _, ok := index[w]; !ok {
+				connect(w)
+				if low[w] < low[v] {
+					low[v] = low[w]
+				}
+			} else if onStack[w] && index[w] < low[v] {
+				low[v] = index[w]
+			}
+		}
+		if low[v] != index[v] {
+			return
+		}
+		var component []*DepNode
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoop {
+			// The component was popped in reverse order of discovery.
+			for i, j := 0, len(component)-1; i < j; i, j = i+1, j-1 {
+				component[i], component[j] = component[j], component[i]
+			}
+			cycles = append(cycles, component)
+		}
+	}
+	connect(root)
+	return cycles
+}
+
+// reaches reports whether a node of the cycle can be reached from n.
+func reaches(n *DepNode, cycle map[*DepNode]bool, successors func(*DepNode) []*DepNode) bool {
+	seen := make(map[*DepNode]bool)
+	var visit func(n *DepNode) bool
+	visit = func(n *DepNode) bool {
+		if cycle[n] {
+			return true
+		}
+		if seen[n] {
+			return false
+		}
+		seen[n] = true
+		for _, w := range successors(n) {
+			if visit(w) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(n)
+}
+
+// cyclePath describes the shortest path from start back to itself through
+// the nodes of cycle, such as "a@v1.0.0 -> b@v1.0.0 -> a@v1.0.0".
+func cyclePath(start *DepNode, cycle map[*DepNode]bool, successors func(*DepNode) []*DepNode) string {
+	prev := make(map[*DepNode]*DepNode)
+	queue := []*DepNode{start}
+	var last *DepNode
+	for len(queue) > 0 && last == nil {
+		n := queue[0]
+		queue = queue[1:]
+		for _, w := range successors(n) {
+			if w == start {
+				last = n
+				break
+			}
+			if _, ok := prev[w]; !ok && cycle[w] {
+				prev[w] = n
+				queue = append(queue, w)
+			}
+		}
+	}
+	nodes := []*DepNode{start}
+	for n := last; n != nil && n != start; n = prev[n] {
+		nodes = append(nodes, n)
+	}
+	nodes = append(nodes, start)
+	// The nodes between the two starts were collected backwards.
+	for i, j := 1, len(nodes)-2; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	var ids []string
+	for _, n := range nodes {
+		id := n.Path
+		if n.Version != "" {
+			id += "@" + n.Version
+		}
+		ids = append(ids, id)
+	}
+	return strings.Join(ids, " -> ")
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestModuleCycleErrors(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.14
+
+require (
+	example.com/a v0.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+	example.com/leaf v1.0.0
+)
+
+replace example.com/a => ../a
+`
+	// example.com/a replaces the main module with a directory too, so they
+	// require each other, and example.com/c leads to a cycle between x and
+	// y.
+	const graph = `example.com/m example.com/a@v0.0.0
+example.com/m example.com/b@v1.0.0
+example.com/m example.com/c@v1.0.0
+example.com/m example.com/leaf@v1.0.0
+example.com/a@v0.0.0 example.com/m@v0.0.0
+example.com/a@v0.0.0 example.com/leaf@v1.0.0
+example.com/b@v1.0.0 example.com/leaf@v1.0.0
+example.com/c@v1.0.0 example.com/x@v1.0.0
+example.com/x@v1.0.0 example.com/y@v1.0.0
+example.com/y@v1.0.0 example.com/x@v1.0.0
+`
+	uri, m, file := parseTestMod(t, mod)
+	root, err := parseModGraph(strings.NewReader(graph), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errors, err := moduleCycleErrors(uri, m, file, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		text, msg string
+	}
+	var got []result
+	for _, e := range errors {
+		got = append(got, result{rangeText(t, m, e.Range), e.Message})
+	}
+	want := []result{
+		{"example.com/c v1.0.0", "example.com/c leads to a module dependency cycle: example.com/x@v1.0.0 -> example.com/y@v1.0.0 -> example.com/x@v1.0.0."},
+		{"example.com/a v0.0.0", "example.com/a is in a module dependency cycle: example.com/a@v0.0.0 -> example.com/m -> example.com/a@v0.0.0."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors:\n%v\nwant:\n%v", got, want)
+	}
+
+	// Without cycles, nothing is reported.
+	const acyclic = `example.com/m example.com/b@v1.0.0
+example.com/m example.com/leaf@v1.0.0
+example.com/b@v1.0.0 example.com/leaf@v1.0.0
+`
+	root, err = parseModGraph(strings.NewReader(acyclic), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errors, err := moduleCycleErrors(uri, m, file, root); err != nil || len(errors) > 0 {
+		t.Errorf("got errors %v, %v for an acyclic graph, want none", errors, err)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"sort"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// A ChangeKind classifies a change between two go.mod files.
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+	Upgraded
+	Downgraded
+	// Changed is used for changes that are neither upgrades nor
+	// downgrades, such as a different replacement or indirect comment.
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Upgraded:
+		return "upgraded"
+	case Downgraded:
+		return "downgraded"
+	case Changed:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// A ModDelta describes the differences between two versions of a go.mod
+// file. Changes are sorted by module path, then version.
+type ModDelta struct {
+	// Module and Go are the changes to the module and go directives, or nil
+	// if they are unchanged.
+	Module, Go *DirectiveChange
+
+	Requires []RequireChange
+	Replaces []ReplaceChange
+	Excludes []ExcludeChange
+}
+
+// A DirectiveChange is a change to the value of a directive that appears at
+// most once, with "" for a missing directive.
+type DirectiveChange struct {
+	Old, New string
+}
+
+// A RequireChange is a change to the requirement of a module. The old or new
+// version is "" if the module is added or removed.
+type RequireChange struct {
+	Kind                     ChangeKind
+	Path                     string
+	OldVersion, NewVersion   string
+	OldIndirect, NewIndirect bool
+}
+
+// A ReplaceChange is a change to the replacement of Old, which is the zero
+// value on the side where there is no replacement.
+type ReplaceChange struct {
+	Kind     ChangeKind
+	Old      module.Version
+	From, To module.Version
+}
+
+// An ExcludeChange is an exclude directive that was added or removed.
+type ExcludeChange struct {
+	Kind ChangeKind
+	Mod  module.Version
+}
+
+// Empty reports whether the go.mod files have the same dependencies and
+// directives.
+func (d *ModDelta) Empty() bool {
+	return d.Module == nil && d.Go == nil && len(d.Requires) == 0 && len(d.Replaces) == 0 && len(d.Excludes) == 0
+}
+
+// DiffModFiles returns the differences between the old and new contents of
+// a go.mod file. Formatting, comments other than indirect comments, and the
+// order of directives are ignored. Version changes are classified as
+// upgrades or downgrades by semantic version order.
+func DiffModFiles(old, new []byte) (*ModDelta, error) {
+	oldFile, err := modfile.Parse("old/go.mod", old, nil)
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	newFile, err := modfile.Parse("new/go.mod", new, nil)
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	delta := &ModDelta{
+		Module:   directiveChange(modulePath(oldFile), modulePath(newFile)),
+		Go:       directiveChange(goVersion(oldFile), goVersion(newFile)),
+		Requires: requireChanges(oldFile.Require, newFile.Require),
+		Replaces: replaceChanges(oldFile.Replace, newFile.Replace),
+		Excludes: excludeChanges(oldFile.Exclude, newFile.Exclude),
+	}
+	return delta, nil
+}
+
+func modulePath(file *modfile.File) string {
+	if file.Module == nil {
+		return ""
+	}
+	return file.Module.Mod.Path
+}
+
+func goVersion(file *modfile.File) string {
+	if file.Go == nil {
+		return ""
+	}
+	return file.Go.Version
+}
+
+func directiveChange(old, new string) *DirectiveChange {
+	if old == new {
+		return nil
+	}
+	return &DirectiveChange{Old: old, New: new}
+}
+
+// versionChange classifies the change of a module from version old to new,
+// both of which are set.
+func versionChange(old, new string) ChangeKind {
+	switch c := semver.Compare(old, new); {
+	case c < 0:
+		return Upgraded
+	case c > 0:
+		return Downgraded
+	}
+	return Changed
+}
+
+func requireChanges(old, new []*modfile.Require) []RequireChange {
+	// A module required more than once is treated as required at its
+	// highest version, as it is by the go command.
+	highest := func(reqs []*modfile.Require) map[string]*modfile.Require {
+		m := make(map[string]*modfile.Require, len(reqs))
+		for _, req := range reqs {
+			if prev, ok := m[req.Mod.Path]; !ok || semver.Compare(prev.Mod.Version, req.Mod.Version) < 0 {
+				m[req.Mod.Path] = req
+			}
+		}
+		return m
+	}
+	oldReqs, newReqs := highest(old), highest(new)
+	var changes []RequireChange
+	for path, o := range oldReqs {
+		n, ok := newReqs[path]
+		if !ok {
+			changes = append(changes, RequireChange{Kind: Removed, Path: path, OldVersion: o.Mod.Version, OldIndirect: o.Indirect})
+			continue
+		}
+		if o.Mod.Version == n.Mod.Version && o.Indirect == n.Indirect {
+			continue
+		}
+		kind := Changed
+		if o.Mod.Version != n.Mod.Version {
+			kind = versionChange(o.Mod.Version, n.Mod.Version)
+		}
+		changes = append(changes, RequireChange{
+			Kind:        kind,
+			Path:        path,
+			OldVersion:  o.Mod.Version,
+			NewVersion:  n.Mod.Version,
+			OldIndirect: o.Indirect,
+			NewIndirect: n.Indirect,
+		})
+	}
+	for path, n := range newReqs {
+		if _, ok := oldReqs[path]; !ok {
+			changes = append(changes, RequireChange{Kind: Added, Path: path, NewVersion: n.Mod.Version, NewIndirect: n.Indirect})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+func replaceChanges(old, new []*modfile.Replace) []ReplaceChange {
+	// Later replacements of the same module override earlier ones.
+	targets := func(reps []*modfile.Replace) map[module.Version]module.Version {
+		m := make(map[module.Version]module.Version, len(reps))
+		for _, r := range reps {
+			m[r.Old] = r.New
+		}
+		return m
+	}
+	oldReps, newReps := targets(old), targets(new)
+	var changes []ReplaceChange
+	for mod, from := range oldReps {
+		to, ok := newReps[mod]
+		switch {
+		case !ok:
+			changes = append(changes, ReplaceChange{Kind: Removed, Old: mod, From: from})
+		case from != to:
+			kind := Changed
+			if from.Path == to.Path && from.Version != "" && to.Version != "" {
+				kind = versionChange(from.Version, to.Version)
+			}
+			changes = append(changes, ReplaceChange{Kind: kind, Old: mod, From: from, To: to})
+		}
+	}
+	for mod, to := range newReps {
+		if _, ok := oldReps[mod]; !ok {
+			changes = append(changes, ReplaceChange{Kind: Added, Old: mod, To: to})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return lessVersion(changes[i].Old, changes[j].Old)
+	})
+	return changes
+}
+
+func excludeChanges(old, new []*modfile.Exclude) []ExcludeChange {
+	set := func(xs []*modfile.Exclude) map[module.Version]bool {
+		m := make(map[module.Version]bool, len(xs))
+		for _, x := range xs {
+			m[x.Mod] = true
+		}
+		return m
+	}
+	oldXs, newXs := set(old), set(new)
+	var changes []ExcludeChange
+	for mod := range oldXs {
+		if !newXs[mod] {
+			changes = append(changes, ExcludeChange{Kind: Removed, Mod: mod})
+		}
+	}
+	for mod := range newXs {
+		if !oldXs[mod] {
+			changes = append(changes, ExcludeChange{Kind: Added, Mod: mod})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return lessVersion(changes[i].Mod, changes[j].Mod)
+	})
+	return changes
+}
+
+func lessVersion(a, b module.Version) bool {
+	if a.Path != b.Path {
+		return a.Path < b.Path
+	}
+	return semver.Compare(a.Version, b.Version) < 0
+}
@@ -0,0 +1,110 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+	errors "golang.org/x/xerrors"
+)
+
+func TestDiffModFiles(t *testing.T) {
+	const old = `module example.com/m
+
+go 1.13
+
+require (
+	example.com/down v1.2.0
+	example.com/indirect v1.0.0 // indirect
+	example.com/removed v1.0.0
+	example.com/same v1.0.0
+	example.com/up v1.0.0
+)
+
+replace (
+	example.com/dir => ./dir
+	example.com/gone => example.com/fork v1.0.0
+	example.com/same => example.com/fork v1.0.0
+	example.com/up => example.com/fork v1.0.0
+)
+
+exclude example.com/x v1.0.0
+`
+	const new = `module example.com/m
+
+go 1.14
+
+require (
+	example.com/added v0.1.0
+	example.com/down v1.1.0
+	example.com/indirect v1.0.0
+	example.com/same v1.0.0
+	example.com/up v1.3.0 // a comment
+)
+
+replace (
+	example.com/dir => ../dir
+	example.com/new v1.0.0 => ./new
+	example.com/same => example.com/fork v1.0.0
+	example.com/up => example.com/fork v1.1.0
+)
+
+exclude example.com/y v1.0.0
+`
+	delta, err := DiffModFiles([]byte(old), []byte(new))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delta.Module != nil {
+		t.Errorf("got module change %v, want none", delta.Module)
+	}
+	if want := (&DirectiveChange{Old: "1.13", New: "1.14"}); !reflect.DeepEqual(delta.Go, want) {
+		t.Errorf("got go change %v, want %v", delta.Go, want)
+	}
+	wantRequires := []RequireChange{
+		{Kind: Added, Path: "example.com/added", NewVersion: "v0.1.0"},
+		{Kind: Downgraded, Path: "example.com/down", OldVersion: "v1.2.0", NewVersion: "v1.1.0"},
+		{Kind: Changed, Path: "example.com/indirect", OldVersion: "v1.0.0", NewVersion: "v1.0.0", OldIndirect: true},
+		{Kind: Removed, Path: "example.com/removed", OldVersion: "v1.0.0"},
+		{Kind: Upgraded, Path: "example.com/up", OldVersion: "v1.0.0", NewVersion: "v1.3.0"},
+	}
+	if !reflect.DeepEqual(delta.Requires, wantRequires) {
+		t.Errorf("got require changes\n%+v\nwant\n%+v", delta.Requires, wantRequires)
+	}
+	fork := func(version string) module.Version {
+		return module.Version{Path: "example.com/fork", Version: version}
+	}
+	wantReplaces := []ReplaceChange{
+		{Kind: Changed, Old: module.Version{Path: "example.com/dir"}, From: module.Version{Path: "./dir"}, To: module.Version{Path: "../dir"}},
+		{Kind: Removed, Old: module.Version{Path: "example.com/gone"}, From: fork("v1.0.0")},
+		{Kind: Added, Old: module.Version{Path: "example.com/new", Version: "v1.0.0"}, To: module.Version{Path: "./new"}},
+		{Kind: Upgraded, Old: module.Version{Path: "example.com/up"}, From: fork("v1.0.0"), To: fork("v1.1.0")},
+	}
+	if !reflect.DeepEqual(delta.Replaces, wantReplaces) {
+		t.Errorf("got replace changes\n%+v\nwant\n%+v", delta.Replaces, wantReplaces)
+	}
+	wantExcludes := []ExcludeChange{
+		{Kind: Removed, Mod: module.Version{Path: "example.com/x", Version: "v1.0.0"}},
+		{Kind: Added, Mod: module.Version{Path: "example.com/y", Version: "v1.0.0"}},
+	}
+	if !reflect.DeepEqual(delta.Excludes, wantExcludes) {
+		t.Errorf("got exclude changes\n%+v\nwant\n%+v", delta.Excludes, wantExcludes)
+	}
+
+	// Reformatting the file changes nothing.
+	same, err := DiffModFiles([]byte(old), []byte("module example.com/m\ngo 1.13\nrequire example.com/up v1.0.0\n"+old[len("module example.com/m\n\ngo 1.13\n"):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !same.Empty() {
+		t.Errorf("got changes for an equivalent file: %+v", same)
+	}
+
+	if _, err := DiffModFiles([]byte(old), []byte("module\n")); !errors.Is(err, ErrParseMod) {
+		t.Errorf("got error %v for an invalid file, want ErrParseMod", err)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const depReplaceCategory = "dependency replace"
+
+// listedGoMod is the subset of the output of `go list -m -json` that locates
+// the go.mod file of a module.
+type listedGoMod struct {
+	Path  string
+	GoMod string
+	Error *struct{ Err string }
+}
+
+// dependencyReplaceErrors reports the direct requirements in file whose own
+// go.mod file has replace directives, which only apply when that module is
+// the main module. The go.mod files are read from the module cache, as
+// located by the go command; modules that have not been downloaded are not
+// checked, and if the go command fails, no errors are reported.
+func dependencyReplaceErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File) ([]source.Error, error) {
+	var paths []string
+	for _, req := range file.Require {
+		if !req.Indirect {
+			paths = append(paths, req.Mod.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	args := append([]string{"-e", "-m", "-json"}, paths...)
+	stdout, err := snapshot.RunGoCommand(ctx, "list", args)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "locating dependency go.mod files", err)
+		return nil, nil
+	}
+	replaces := make(map[string][]*modfile.Replace)
+	for dec := json.NewDecoder(stdout); dec.More(); {
+		var mod listedGoMod
+		if err := dec.Decode(&mod); err != nil {
+			return nil, err
+		}
+		if mod.Error != nil || mod.GoMod == "" {
+			continue
+		}
+		content, err := ioutil.ReadFile(mod.GoMod)
+		if err != nil {
+			continue
+		}
+		// A dependency's go.mod file that cannot be parsed is reported
+		// by the go command, if it matters.
+		depFile, err := modfile.Parse(mod.GoMod, content, nil)
+		if err != nil {
+			continue
+		}
+		replaces[mod.Path] = depFile.Replace
+	}
+	return depReplaceErrors(uri, m, file, replaces)
+}
+
+// depReplaceErrors reports the direct requirements in file whose module's
+// go.mod file has the replace directives in replaces, keyed by module path.
+// Replacements of modules that the main module replaces itself are not
+// mentioned.
+func depReplaceErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, replaces map[string][]*modfile.Replace) ([]source.Error, error) {
+	replaced := make(map[string]bool, len(file.Replace))
+	for _, r := range file.Replace {
+		replaced[r.Old.Path] = true
+	}
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Indirect || req.Syntax == nil {
+			continue
+		}
+		var ignored []string
+		for _, r := range replaces[req.Mod.Path] {
+			if !replaced[r.Old.Path] {
+				ignored = append(ignored, formatReplace(r))
+			}
+		}
+		if len(ignored) == 0 {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		applies, what := "applies", "it"
+		if len(ignored) > 1 {
+			applies, what = "apply", "them"
+		}
+		errors = append(errors, source.Error{
+			Category: depReplaceCategory,
+			Message: fmt.Sprintf("The go.mod file of %s replaces %s, which only %s when it is the main module. Add %s here if this module needs %s.",
+				req.Mod.Path, strings.Join(ignored, ", "), applies, what, what),
+			Range: rng,
+			URI:   uri,
+		})
+	}
+	return errors, nil
+}
+
+// formatReplace returns the replace directive r as it is written after the
+// replace keyword.
+func formatReplace(r *modfile.Replace) string {
+	s := r.Old.Path
+	if r.Old.Version != "" {
+		s += " " + r.Old.Version
+	}
+	s += " => " + r.New.Path
+	if r.New.Version != "" {
+		s += " " + r.New.Version
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/mod/modfile"
+)
+
+func TestDepReplaceErrors(t *testing.T) {
+	const mod = `module example.com/m
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0 // indirect
+	example.com/d v1.0.0
+)
+
+replace example.com/shared => ../shared
+`
+	uri, m, file := parseTestMod(t, mod)
+	parseReplaces := func(contents string) []*modfile.Replace {
+		_, _, file := parseTestMod(t, contents)
+		return file.Replace
+	}
+	replaces := map[string][]*modfile.Replace{
+		"example.com/a": parseReplaces("module example.com/a\nreplace example.com/x v1.0.0 => ../x\n"),
+		// Only the replacement that the main module lacks is mentioned.
+		"example.com/b": parseReplaces("module example.com/b\nreplace (\n\texample.com/shared => ../shared\n\texample.com/y => example.com/fork v1.2.0\n)\n"),
+		// Indirect requirements are not reported.
+		"example.com/c": parseReplaces("module example.com/c\nreplace example.com/x => ../x\n"),
+		"example.com/d": parseReplaces("module example.com/d\nreplace example.com/shared => ./shared\n"),
+	}
+	errors, err := depReplaceErrors(uri, m, file, replaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"example.com/a v1.0.0": "The go.mod file of example.com/a replaces example.com/x v1.0.0 => ../x, which only applies when it is the main module. Add it here if this module needs it.",
+		"example.com/b v1.0.0": "The go.mod file of example.com/b replaces example.com/y => example.com/fork v1.2.0, which only applies when it is the main module. Add it here if this module needs it.",
+	}
+	if len(errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errors), len(want), errors)
+	}
+	for _, e := range errors {
+		text := rangeText(t, m, e.Range)
+		if e.Message != want[text] {
+			t.Errorf("got message %q on %q, want %q", e.Message, text, want[text])
+		}
+	}
+
+	replaces["example.com/a"] = parseReplaces("module example.com/a\nreplace (\n\texample.com/x => ../x\n\texample.com/y => ../y\n)\n")
+	errors, err = depReplaceErrors(uri, m, file, replaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const wantMsg = "The go.mod file of example.com/a replaces example.com/x => ../x, example.com/y => ../y, which only apply when it is the main module. Add them here if this module needs them."
+	if len(errors) == 0 || errors[0].Message != wantMsg {
+		t.Errorf("got errors %v, want the first to be %q", errors, wantMsg)
+	}
+}
@@ -8,18 +8,78 @@ package mod
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
 
 	"golang.org/x/mod/modfile"
 	"golang.org/x/tools/internal/event"
 	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/diff"
 	"golang.org/x/tools/internal/lsp/protocol"
 	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
 )
 
-func Diagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.FileIdentity][]*source.Diagnostic, map[string]*modfile.Require, error) {
+// Diagnostics returns the diagnostics for the view's go.mod file, along with
+// the requirements that are missing from it. If fixableOnly is set, only the
+// diagnostics that come with a suggested fix are returned, for callers that
+// fix every diagnostic automatically.
+func Diagnostics(ctx context.Context, snapshot source.Snapshot, fixableOnly bool) (map[source.FileIdentity][]*source.Diagnostic, map[string]*modfile.Require, error) {
+	return collectDiagnostics(ctx, snapshot, fixableOnly, func(*source.Diagnostic) bool { return true })
+}
+
+// RangeDiagnostics is like Diagnostics, but only returns the diagnostics
+// that intersect rng, for editors that only display part of a large go.mod
+// file. The diagnostics derived from `go mod tidy`, whose result is computed
+// and cached for the whole file, are returned wherever they are.
+func RangeDiagnostics(ctx context.Context, snapshot source.Snapshot, rng protocol.Range) (map[source.FileIdentity][]*source.Diagnostic, map[string]*modfile.Require, error) {
+	return collectDiagnostics(ctx, snapshot, false, func(diag *source.Diagnostic) bool {
+		return categories[diag.Source].tidy || intersects(diag.Range, rng)
+	})
+}
+
+// collectDiagnostics returns the diagnostics for the view's go.mod file for
+// which keep returns true, as described for Diagnostics.
+func collectDiagnostics(ctx context.Context, snapshot source.Snapshot, fixableOnly bool, keep func(*source.Diagnostic) bool) (map[source.FileIdentity][]*source.Diagnostic, map[string]*modfile.Require, error) {
+	diagnostics := []*source.Diagnostic{}
+	id, missingDeps, err := diagnosticsSeq(ctx, snapshot, fixableOnly, func(diag *source.Diagnostic) bool {
+		if keep(diag) {
+			diagnostics = append(diagnostics, diag)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if id.URI == "" {
+		return nil, nil, nil
+	}
+	return map[source.FileIdentity][]*source.Diagnostic{id: diagnostics}, missingDeps, nil
+}
+
+// DiagnosticsSeq is like Diagnostics, but passes each diagnostic for the
+// view's go.mod file to yield as it is converted, rather than collecting them,
+// so that callers can stream the diagnostics of go.mod files with many
+// requirements. If yield returns false, no more diagnostics are passed to it.
+// DiagnosticsSeq returns the identity of the go.mod file, which is the zero
+// value if the view has no go.mod file or it cannot be diagnosed. go.mod
+// files in the IgnoredModDirs of another folder of the session are not
+// diagnosed.
+func DiagnosticsSeq(ctx context.Context, snapshot source.Snapshot, yield func(*source.Diagnostic) bool) (source.FileIdentity, map[string]*modfile.Require, error) {
+	return diagnosticsSeq(ctx, snapshot, false, yield)
+}
+
+func diagnosticsSeq(ctx context.Context, snapshot source.Snapshot, fixableOnly bool, yield func(*source.Diagnostic) bool) (source.FileIdentity, map[string]*modfile.Require, error) {
 	uri := snapshot.View().ModFile()
 	if uri == "" {
-		return nil, nil, nil
+		return source.FileIdentity{}, nil, nil
+	}
+	if ignoredModFile(uri.Filename(), sessionFolders(snapshot), snapshot.View().Options().IgnoredModDirs) {
+		return source.FileIdentity{}, nil, nil
 	}
 
 	ctx, done := event.Start(ctx, "mod.Diagnostics", tag.URI.Of(uri))
@@ -27,50 +87,703 @@ func Diagnostics(ctx context.Context, snapshot source.Snapshot) (map[source.File
 
 	fh, err := snapshot.GetFile(ctx, uri)
 	if err != nil {
-		return nil, nil, err
+		return source.FileIdentity{}, nil, err
 	}
-	mth, err := snapshot.ModTidyHandle(ctx)
+	missingDeps, diagnostics, err := modErrors(ctx, snapshot, fh)
 	if err == source.ErrTmpModfileUnsupported {
-		return nil, nil, nil
+		return source.FileIdentity{}, nil, nil
+	}
+	if gcErr := (*source.GoCommandError)(nil); errors.As(err, &gcErr) {
+		// Show the go command's own explanation of its failure.
+		failureErr, err := goCommandFailureError(ctx, snapshot, fh, gcErr)
+		if err != nil {
+			return source.FileIdentity{}, nil, err
+		}
+		missingDeps, diagnostics = nil, []source.Error{failureErr}
+	} else if err != nil {
+		return source.FileIdentity{}, nil, err
 	}
+	storeDiagnosed(fh.Identity(), diagnostics)
+	docs := snapshot.View().Options().ModDiagnosticDocs
+	for _, e := range diagnostics {
+		if fixableOnly && !fixable(e) {
+			continue
+		}
+		diag := toDiagnostic(e)
+		if docs != "" {
+			diag.CodeHref = categoryDocURL(docs, e.Category)
+		}
+		if !yield(diag) {
+			break
+		}
+	}
+	return fh.Identity(), missingDeps, nil
+}
+
+// diagnosed caches the errors last reported for each go.mod file, along with
+// the identity of the file they were found in, so that SuggestedFixes can
+// offer the fixes of the diagnostics that the client shows without running
+// the go command again.
+var diagnosed = struct {
+	mu     sync.Mutex
+	errors map[span.URI]diagnosedErrors
+}{errors: make(map[span.URI]diagnosedErrors)}
+
+type diagnosedErrors struct {
+	id     source.FileIdentity
+	errors []source.Error
+}
+
+// storeDiagnosed records errors as those reported for the go.mod file id.
+func storeDiagnosed(id source.FileIdentity, errors []source.Error) {
+	diagnosed.mu.Lock()
+	defer diagnosed.mu.Unlock()
+	diagnosed.errors[id.URI] = diagnosedErrors{id: id, errors: errors}
+}
+
+// loadDiagnosed returns the errors reported for the go.mod file id, and
+// whether this version of the file was diagnosed.
+func loadDiagnosed(id source.FileIdentity) ([]source.Error, bool) {
+	diagnosed.mu.Lock()
+	defer diagnosed.mu.Unlock()
+	d, ok := diagnosed.errors[id.URI]
+	if !ok || d.id != id {
+		return nil, false
+	}
+	return d.errors, true
+}
+
+// toDiagnostic converts e to a diagnostic. The related information of e,
+// which points at the other directives involved in a conflict, is kept, so
+// that it is sent to the client along with the diagnostic.
+func toDiagnostic(e source.Error) *source.Diagnostic {
+	diag := &source.Diagnostic{
+		Message: e.Message,
+		Range:   e.Range,
+		Source:  e.Category,
+		Related: e.Related,
+	}
+	if c, ok := categories[e.Category]; ok {
+		diag.Severity = c.severity
+	} else {
+		diag.Severity = protocol.SeverityWarning
+	}
+	return diag
+}
+
+const (
+	tidyTimeoutCategory = "go mod tidy timeout"
+	goCommandCategory   = "go-command"
+)
+
+// tidyTimeoutError returns the error reported on the module directive of the
+// go.mod file when `go mod tidy` takes longer than the configured timeout.
+func tidyTimeoutError(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) (source.Error, error) {
+	return moduleDirectiveError(ctx, snapshot, fh, source.Error{
+		Category: tidyTimeoutCategory,
+		Message:  "go mod tidy timed out",
+		URI:      fh.URI(),
+	})
+}
+
+// goCommandFailureError returns the error reported on the module directive of
+// the go.mod file when the go command fails to tidy it, with the command's
+// stderr as its message.
+func goCommandFailureError(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, gcErr *source.GoCommandError) (source.Error, error) {
+	return moduleDirectiveError(ctx, snapshot, fh, source.Error{
+		Category: goCommandCategory,
+		Message:  goCommandMessage(gcErr),
+		URI:      fh.URI(),
+	})
+}
+
+// goCommandMessage returns the message of the error reported for gcErr: the
+// go command's stderr, or if it wrote nothing, the error it exited with.
+func goCommandMessage(gcErr *source.GoCommandError) string {
+	if gcErr.Stderr == "" {
+		return fmt.Sprintf("go %s failed: %v", gcErr.Command, gcErr.Err)
+	}
+	return fmt.Sprintf("go %s failed:\n%s", gcErr.Command, gcErr.Stderr)
+}
+
+// moduleDirectiveError returns e, placed on the module directive of the
+// go.mod file fh, or at its start if it has none.
+func moduleDirectiveError(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, e source.Error) (source.Error, error) {
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
 	if err != nil {
-		return nil, nil, err
+		return source.Error{}, err
+	}
+	file, m, _, err := pmh.Parse(ctx)
+	if err != nil || file.Module == nil || file.Module.Syntax == nil {
+		// Without a module directive, report the error at the start of the
+		// file.
+		return e, nil
 	}
-	missingDeps, diagnostics, err := mth.Tidy(ctx)
+	e.Range, err = positionsToRange(fh.URI(), m, file.Module.Syntax.Start, file.Module.Syntax.End)
+	if err != nil {
+		return source.Error{}, err
+	}
+	return e, nil
+}
+
+// intersects reports whether the ranges a and b overlap or touch.
+func intersects(a, b protocol.Range) bool {
+	return protocol.ComparePosition(a.Start, b.End) <= 0 && protocol.ComparePosition(b.Start, a.End) <= 0
+}
+
+// replaceLineErrors returns errors, with any error that starts on the same
+// line as one of the given replacements replaced by it.
+func replaceLineErrors(errors, replacements []source.Error) []source.Error {
+	if len(replacements) == 0 {
+		return errors
+	}
+	replaced := make(map[float64]bool)
+	for _, r := range replacements {
+		replaced[r.Range.Start.Line] = true
+	}
+	result := replacements
+	for _, e := range errors {
+		if !replaced[e.Range.Start.Line] {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// A category describes the errors reported by this package with a given
+// category.
+type category struct {
+	// severity is the severity of their diagnostics.
+	severity protocol.DiagnosticSeverity
+
+	// fixable reports whether they may come with a suggested fix. Errors in
+	// other categories never have one.
+	fixable bool
+
+	// tidy reports whether they are derived from the result of
+	// `go mod tidy`, rather than from the contents of the go.mod file.
+	tidy bool
+}
+
+// categories registers every category of the errors reported by this
+// package. A new check must add its category here, so that it is reported
+// with the right severity and listed by DiagnosticCategories. Errors in
+// other categories, such as those of the user's ModValidators, are reported
+// as warnings.
+var categories = map[string]category{
+	syntaxCategory:              {severity: protocol.SeverityError},
+	"go mod tidy":               {severity: protocol.SeverityWarning, fixable: true, tidy: true},
+	tidyTimeoutCategory:         {severity: protocol.SeverityInformation, tidy: true},
+	goCommandCategory:           {severity: protocol.SeverityError, tidy: true},
+	invalidPathCategory:         {severity: protocol.SeverityError},
+	goDirectiveCategory:         {severity: protocol.SeverityError, fixable: true},
+	goPatchCategory:             {severity: protocol.SeverityInformation},
+	toolchainCategory:           {severity: protocol.SeverityHint, fixable: true},
+	missingToolchainCategory:    {severity: protocol.SeverityInformation, fixable: true},
+	languageCategory:            {severity: protocol.SeverityInformation},
+	excludeCategory:             {severity: protocol.SeverityHint, fixable: true},
+	duplicateCategory:           {severity: protocol.SeverityWarning, fixable: true},
+	scatteredCategory:           {severity: protocol.SeverityHint, fixable: true},
+	mainExcludeCategory:         {severity: protocol.SeverityError, fixable: true},
+	unusedExcludeCategory:       {severity: protocol.SeverityWarning, fixable: true},
+	moduleCycleCategory:         {severity: protocol.SeverityInformation},
+	gopathLayoutCategory:        {severity: protocol.SeverityInformation},
+	staleImportCategory:         {severity: protocol.SeverityWarning, fixable: true},
+	replacedCategory:            {severity: protocol.SeverityHint, fixable: true},
+	overlapCategory:             {severity: protocol.SeverityWarning},
+	replaceDirCategory:          {severity: protocol.SeverityWarning},
+	replaceTargetCategory:       {severity: protocol.SeverityError, fixable: true},
+	selfReplaceCategory:         {severity: protocol.SeverityError, fixable: true},
+	depReplaceCategory:          {severity: protocol.SeverityInformation},
+	mainOnlyCategory:            {severity: protocol.SeverityInformation},
+	indirectCategory:            {severity: protocol.SeverityInformation},
+	majorVersionCategory:        {severity: protocol.SeverityWarning},
+	versionCategory:             {severity: protocol.SeverityError, fixable: true},
+	earliestVersionCategory:     {severity: protocol.SeverityError, fixable: true},
+	goneVersionCategory:         {severity: protocol.SeverityError, fixable: true},
+	stalePseudoCategory:         {severity: protocol.SeverityInformation, fixable: true},
+	upgradeCategory:             {severity: protocol.SeverityInformation, fixable: true},
+	singleImporterCategory:      {severity: protocol.SeverityInformation},
+	platformCategory:            {severity: protocol.SeverityInformation},
+	constrainedCategory:         {severity: protocol.SeverityInformation},
+	hostConventionCategory:      {severity: protocol.SeverityHint},
+	policyCategory:              {severity: protocol.SeverityError},
+	licenseCategory:             {severity: protocol.SeverityWarning},
+	breakageCategory:            {severity: protocol.SeverityWarning, fixable: true},
+	forkCategory:                {severity: protocol.SeverityInformation, fixable: true},
+	syncCategory:                {severity: protocol.SeverityWarning, fixable: true},
+	lineEndingCategory:          {severity: protocol.SeverityWarning, fixable: true},
+	indentCategory:              {severity: protocol.SeverityHint, fixable: true},
+	encodingCategory:            {severity: protocol.SeverityError, fixable: true},
+	missingSumCategory:          {severity: protocol.SeverityWarning},
+	goFlagsCategory:             {severity: protocol.SeverityError},
+	workGoDirectiveCategory:     {severity: protocol.SeverityWarning},
+	workReplaceCycleCategory:    {severity: protocol.SeverityWarning},
+	workReplaceOverrideCategory: {severity: protocol.SeverityWarning},
+	workSecurityReplaceCategory: {severity: protocol.SeverityWarning, fixable: true},
+	workMissingGoCategory:       {severity: protocol.SeverityWarning, fixable: true},
+	workGoVersionCategory:       {severity: protocol.SeverityError, fixable: true},
+}
+
+// DiagnosticCategories returns the categories of the diagnostics reported
+// for go.mod and go.work files, which are used as their source, sorted.
+func DiagnosticCategories() []string {
+	names := make([]string, 0, len(categories))
+	for name := range categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// categoryDocURL returns the URL of the documentation of category, the
+// anchor of its heading in the page at base: its words joined by hyphens.
+func categoryDocURL(base, category string) string {
+	anchor := strings.Map(func(r rune) rune {
+		switch {
+		case r == ' ':
+			return '-'
+		case r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, category)
+	return strings.TrimSuffix(base, "#") + "#" + anchor
+}
+
+// fixable reports whether e comes with a suggested fix. Not every error in a
+// fixable category has one; for example, a noncanonical version is only
+// fixed if its canonical form can be inferred.
+func fixable(e source.Error) bool {
+	return categories[e.Category].fixable && len(e.SuggestedFixes) > 0
+}
+
+// errTidyTimeout is returned by tidyWithTimeout when `go mod tidy` takes
+// longer than the TidyTimeout option allows.
+var errTidyTimeout = errors.New("go mod tidy timed out")
+
+// tidyWithTimeout returns the result of mth, computed under the deadline set
+// by the TidyTimeout option, if any. Only `go mod tidy` is subject to the
+// deadline; if it is exceeded, errTidyTimeout is returned.
+func tidyWithTimeout(ctx context.Context, snapshot source.Snapshot, mth source.ModTidyHandle) (map[string]*modfile.Require, []source.Error, error) {
+	timeout := snapshot.View().Options().TidyTimeout
+	if timeout <= 0 {
+		return mth.Tidy(ctx)
+	}
+	tidyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	missingDeps, errors, err := mth.Tidy(tidyCtx)
+	if err != nil && tidyCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		return nil, nil, errTidyTimeout
+	}
+	return missingDeps, errors, err
+}
+
+// modErrors returns the errors reported by `go mod tidy` for the given go.mod
+// file, along with the errors found by inspecting the parsed file directly.
+func modErrors(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) (map[string]*modfile.Require, []source.Error, error) {
+	mth, err := snapshot.ModTidyHandle(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
-	reports := map[source.FileIdentity][]*source.Diagnostic{
-		fh.Identity(): {},
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, nil, parseModError(err)
 	}
-	for _, e := range diagnostics {
-		diag := &source.Diagnostic{
-			Message: e.Message,
-			Range:   e.Range,
-			Source:  e.Category,
+	file, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		// A go.mod file that cannot be parsed cannot be tidied either, so
+		// only report the reasons that it could not be parsed.
+		errors, err := unparsedErrors(snapshot, fh, m, parseErrors)
+		return nil, errors, err
+	}
+	if err != nil {
+		return nil, nil, parseModError(err)
+	}
+	parsed := file
+	if snapshot.View().Options().ModDirectOnly {
+		file = directOnly(file)
+	}
+	missingDeps, errors, err := tidyWithTimeout(ctx, snapshot, mth)
+	if err != nil {
+		if err != errTidyTimeout {
+			return nil, nil, tidyError(err)
+		}
+		// Report the timeout, rather than the cancellation it caused, and
+		// carry on with the checks that do not depend on `go mod tidy`.
+		timeoutErr, err := tidyTimeoutError(ctx, snapshot, fh)
+		if err != nil {
+			return nil, nil, err
 		}
-		if e.Category == "syntax" {
-			diag.Severity = protocol.SeverityError
+		missingDeps, errors = nil, []source.Error{timeoutErr}
+	}
+	localErrors, err := parsedErrors(ctx, snapshot, fh, pmh, m, file)
+	if err != nil {
+		return nil, nil, err
+	}
+	errors = append(errors, localErrors...)
+	// Type-checking the workspace packages fails for reasons that are
+	// reported in the Go files themselves, so only log the failure.
+	if oldPaths := snapshot.View().Options().PreviousModulePaths; file.Module != nil && len(oldPaths) > 0 {
+		if stale, err := moduleStaleImports(ctx, snapshot, file, oldPaths); err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			event.Error(ctx, "finding stale imports", err)
 		} else {
-			diag.Severity = protocol.SeverityWarning
+			for _, oldPath := range oldPaths {
+				importErrors, err := staleImportErrors(fh.URI(), m, file, oldPath, stale[oldPath])
+				if err != nil {
+					return nil, nil, err
+				}
+				errors = append(errors, importErrors...)
+			}
+		}
+	}
+	if snapshot.View().Options().CheckGopathLayout {
+		layoutErrors, err := gopathLayoutErrors(fh.URI(), m, file, snapshot.View().GOPATH())
+		if err != nil {
+			return nil, nil, err
 		}
-		reports[fh.Identity()] = append(reports[fh.Identity()], diag)
+		errors = append(errors, layoutErrors...)
 	}
-	return reports, missingDeps, nil
+	if snapshot.View().Options().CheckEarliestVersions {
+		versionErrors, err := earliestVersionErrors(ctx, snapshot, fh.URI(), m, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, versionErrors...)
+	}
+	if snapshot.View().Options().CheckGoneVersions {
+		goneErrors, err := goneVersionErrors(ctx, snapshot, fh.URI(), m, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, goneErrors...)
+	}
+	if snapshot.View().Options().CheckReplaceTargets {
+		targetErrors, err := replaceTargetErrors(ctx, snapshot, fh.URI(), m, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, targetErrors...)
+	}
+	if snapshot.View().Options().SingleImporterRequires {
+		importers, err := moduleImporters(ctx, snapshot, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		importerErrors, err := singleImporterErrors(fh.URI(), m, file, importers)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, importerErrors...)
+	}
+	if cycles := snapshot.View().Options().ModuleCycles; len(file.Exclude) > 0 || cycles {
+		// Listing the module graph may fail for reasons that are reported
+		// by `go mod tidy`, so only log the failure.
+		root, err := DependencyTree(ctx, snapshot)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			event.Error(ctx, "listing the module graph", err)
+		} else if root != nil {
+			excludeErrors, err := unusedExcludeErrors(fh.URI(), m, file, root, snapshot.View().Options())
+			if err != nil {
+				return nil, nil, err
+			}
+			errors = append(errors, excludeErrors...)
+			if cycles {
+				cycleErrors, err := moduleCycleErrors(fh.URI(), m, file, root)
+				if err != nil {
+					return nil, nil, err
+				}
+				errors = append(errors, cycleErrors...)
+			}
+		}
+	}
+	if platform := snapshot.View().Options().TargetPlatform; platform != "" {
+		platformErrors, err := platformErrors(ctx, snapshot, fh.URI(), m, file, platform)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, platformErrors...)
+	}
+	if age := snapshot.View().Options().StalePseudoVersionAge; age > 0 {
+		staleErrors, err := stalePseudoVersionErrors(ctx, snapshot, fh.URI(), m, file, age)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, staleErrors...)
+	}
+	if snapshot.View().Options().DependencyReplaces {
+		depErrors, err := dependencyReplaceErrors(ctx, snapshot, fh.URI(), m, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, depErrors...)
+	}
+	if snapshot.View().Options().MainOnlyRequires {
+		mainErrors, err := mainOnlyRequireErrors(ctx, snapshot, fh.URI(), m, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, mainErrors...)
+	}
+	if snapshot.View().Options().ResolveVanityPaths {
+		policyErrors, err := vanityPolicyErrors(ctx, snapshot, fh.URI(), m, file)
+		if err != nil {
+			return nil, nil, err
+		}
+		errors = append(errors, policyErrors...)
+	}
+	licenseErrors, err := licenseErrors(ctx, fh.URI(), m, file, snapshot.View().Options())
+	if err != nil {
+		return nil, nil, err
+	}
+	errors = append(errors, licenseErrors...)
+	syncErrors, err := syncErrors(ctx, snapshot, fh.URI(), m, file, snapshot.View().Options().ModSynchronizers)
+	if err != nil {
+		return nil, nil, err
+	}
+	errors = append(errors, syncErrors...)
+	if snapshot.View().Options().ModDirectOnly {
+		errors = dropIndirectErrors(parsed, errors)
+	}
+	return missingDeps, errors, nil
 }
 
-func SuggestedFixes(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, diags []protocol.Diagnostic) ([]protocol.CodeAction, error) {
-	mth, err := snapshot.ModTidyHandle(ctx)
-	if err == source.ErrTmpModfileUnsupported {
-		return nil, nil
+// unparsedErrors returns the errors of the go.mod file fh that cannot be
+// parsed: the parse errors, refined where the raw contents of the file tell
+// more, and the problems that the raw contents show.
+func unparsedErrors(snapshot source.Snapshot, fh source.FileHandle, m *protocol.ColumnMapper, parseErrors []source.Error) ([]source.Error, error) {
+	goErrors, err := goDirectiveErrors(fh.URI(), m, snapshot.View().GoVersion())
+	if err != nil {
+		return nil, err
+	}
+	// The parse errors only describe the first invalid version, so report
+	// each one separately.
+	_, versionErrors, err := canonicalizeVersions(fh.URI(), m, snapshot.View().Options())
+	if err != nil {
+		return nil, err
+	}
+	// The go.mod parser rejects toolchain directives, so the syntax error
+	// on a redundant one is kept alongside the hint.
+	toolchainErrors, err := redundantToolchainErrors(fh.URI(), m, snapshot.View().GoVersion())
+	if err != nil {
+		return nil, err
+	}
+	languageErrors, err := divergentToolchainErrors(fh.URI(), m)
+	if err != nil {
+		return nil, err
+	}
+	missingErrors, err := missingToolchainErrors(fh.URI(), m, snapshot.View().GoVersion())
+	if err != nil {
+		return nil, err
+	}
+	endingErrors, err := lineEndingErrors(fh.URI(), m)
+	if err != nil {
+		return nil, err
+	}
+	spaceErrors, err := indentErrors(fh.URI(), m)
+	if err != nil {
+		return nil, err
+	}
+	encErrors, err := encodingErrors(fh.URI(), m)
+	if err != nil {
+		return nil, err
+	}
+	errors := replaceLineErrors(parseErrors, append(goErrors, versionErrors...))
+	errors = append(errors, toolchainErrors...)
+	errors = append(errors, languageErrors...)
+	errors = append(errors, missingErrors...)
+	errors = append(errors, endingErrors...)
+	errors = append(errors, spaceErrors...)
+	return append(errors, encErrors...), nil
+}
+
+// parsedErrors returns the errors found by inspecting the parsed go.mod file
+// fh and the files next to it. They do not need the go command or the
+// network, so they are cheap to compute.
+func parsedErrors(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, pmh source.ParseModHandle, m *protocol.ColumnMapper, file *modfile.File) ([]source.Error, error) {
+	errors, err := runChecks(fh.URI(), m, file, snapshot.View().Options())
+	if err != nil {
+		return nil, err
+	}
+	toolchainErrors, err := missingToolchainErrors(fh.URI(), m, snapshot.View().GoVersion())
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, toolchainErrors...)
+	roots, err := workspaceRoots(ctx, snapshot, fh)
+	if err != nil {
+		return nil, err
+	}
+	dirErrors, err := replaceDirErrors(fh.URI(), m, file, roots)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, dirErrors...)
+	endingErrors, err := lineEndingErrors(fh.URI(), m)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, endingErrors...)
+	spaceErrors, err := indentErrors(fh.URI(), m)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, spaceErrors...)
+	encErrors, err := encodingErrors(fh.URI(), m)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, encErrors...)
+	flagErrors, err := goFlagsErrors(fh.URI(), m, file, snapshot.View().GoFlags(), snapshot.View().Options().BuildFlags, fileExists)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, flagErrors...)
+	if sumFH := pmh.Sum(); sumFH != nil {
+		sum, err := sumFH.Read()
+		if err != nil {
+			return nil, err
+		}
+		sumErrors, err := missingSumErrors(fh.URI(), m, file, sum, snapshot.View().SkipsSumCheck)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, sumErrors...)
+	}
+	return errors, nil
+}
+
+// offlineErrors returns the errors of the go.mod file fh that can be found
+// without running the go command, as modErrors would report them.
+func offlineErrors(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]source.Error, error) {
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	file, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return unparsedErrors(snapshot, fh, m, parseErrors)
+	}
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	parsed := file
+	if snapshot.View().Options().ModDirectOnly {
+		file = directOnly(file)
+	}
+	errors, err := parsedErrors(ctx, snapshot, fh, pmh, m, file)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot.View().Options().ModDirectOnly {
+		errors = dropIndirectErrors(parsed, errors)
+	}
+	return errors, nil
+}
+
+// runChecks runs the built-in checks over the parsed go.mod file, followed by
+// any custom validators registered in options.
+func runChecks(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	var errors []source.Error
+	for _, check := range checks {
+		checkErrors, err := check(uri, m, file, options)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, checkErrors...)
+	}
+	for _, validate := range options.ModValidators {
+		validatorErrors, err := validate(uri, m, file)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, validatorErrors...)
+	}
+	return errors, nil
+}
+
+// ApplyFix applies the edits of the given code action to the go.mod file in
+// memory, and returns the diagnostics that would remain for the resulting
+// contents. Neither the file on disk nor the snapshot is modified.
+func ApplyFix(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, action protocol.CodeAction) ([]*source.Diagnostic, error) {
+	ctx, done := event.Start(ctx, "mod.ApplyFix", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, parseModError(err)
 	}
+	_, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	if action.Command != nil {
+		return nil, fmt.Errorf("cannot apply %q in memory, as it runs the %s command", action.Title, action.Command.Command)
+	}
+	var edits []protocol.TextEdit
+	for _, change := range action.Edit.DocumentChanges {
+		if change.TextDocument.URI.SpanURI() == fh.URI() {
+			edits = append(edits, change.Edits...)
+		}
+	}
+	diffEdits, err := source.FromProtocolEdits(m, edits)
 	if err != nil {
 		return nil, err
 	}
-	_, diagnostics, err := mth.Tidy(ctx)
+	contents := []byte(diff.ApplyEdits(string(m.Content), diffEdits))
+	file, err := modfile.Parse(fh.URI().Filename(), contents, nil)
 	if err != nil {
+		return nil, fmt.Errorf("applying %q: %w", action.Title, err)
+	}
+	newMapper := &protocol.ColumnMapper{
+		URI:       fh.URI(),
+		Converter: span.NewContentConverter(fh.URI().Filename(), contents),
+		Content:   contents,
+	}
+	mth, err := snapshot.ModTidyHandle(ctx)
+	if err != nil && err != source.ErrTmpModfileUnsupported {
 		return nil, err
 	}
+	var errors []source.Error
+	if mth != nil {
+		_, tidyErrors, err := mth.TidyFile(ctx, file, newMapper)
+		if err != nil {
+			return nil, tidyError(err)
+		}
+		errors = tidyErrors
+	}
+	checkErrors, err := runChecks(fh.URI(), newMapper, file, snapshot.View().Options())
+	if err != nil {
+		return nil, err
+	}
+	var diagnostics []*source.Diagnostic
+	for _, e := range append(errors, checkErrors...) {
+		diagnostics = append(diagnostics, toDiagnostic(e))
+	}
+	return diagnostics, nil
+}
+
+// SuggestedFixes returns the quick fixes for diags, the diagnostics of the
+// go.mod file fh that the client shows. The fixes are those of the errors
+// found when fh was last diagnosed; if this version of the file was not
+// diagnosed, only the errors that can be found without running the go
+// command are checked, so that a code action request never waits for the
+// go command or the network.
+func SuggestedFixes(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, diags []protocol.Diagnostic) ([]protocol.CodeAction, error) {
+	diagnostics, ok := loadDiagnosed(fh.Identity())
+	if !ok {
+		var err error
+		diagnostics, err = offlineErrors(ctx, snapshot, fh)
+		if err != nil {
+			return nil, err
+		}
+	}
 	errorsMap := make(map[string][]source.Error)
 	for _, e := range diagnostics {
 		if errorsMap[e.Message] == nil {
@@ -86,11 +799,16 @@ func SuggestedFixes(ctx context.Context, snapshot source.Snapshot, fh source.Fil
 			}
 			for _, fix := range e.SuggestedFixes {
 				action := protocol.CodeAction{
-					Title:       fix.Title,
+					Title:       snapshot.View().Options().FixTitle(fix.Title),
 					Kind:        protocol.QuickFix,
 					Diagnostics: []protocol.Diagnostic{diag},
 					Edit:        protocol.WorkspaceEdit{},
 				}
+				if fix.Command != nil {
+					action.Command = fix.Command
+					actions = append(actions, action)
+					continue
+				}
 				for uri, edits := range fix.Edits {
 					fh, err := snapshot.GetFile(ctx, uri)
 					if err != nil {
@@ -134,18 +852,18 @@ func SuggestedGoFixes(ctx context.Context, snapshot source.Snapshot) (map[string
 	}
 	missingDeps, _, err := mth.Tidy(ctx)
 	if err != nil {
-		return nil, err
+		return nil, tidyError(err)
 	}
 	if len(missingDeps) == 0 {
 		return nil, nil
 	}
 	pmh, err := snapshot.ParseModHandle(ctx, fh)
 	if err != nil {
-		return nil, err
+		return nil, parseModError(err)
 	}
 	_, m, _, err := pmh.Parse(ctx)
 	if err != nil {
-		return nil, err
+		return nil, parseModError(err)
 	}
 	// Get the contents of the go.mod file before we make any changes.
 	oldContents, err := fh.Read()
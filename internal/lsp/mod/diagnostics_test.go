@@ -0,0 +1,93 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cache"
+)
+
+func TestDiagnosticCategories(t *testing.T) {
+	names := DiagnosticCategories()
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("categories are not sorted: %v", names)
+	}
+	registered := make(map[string]bool)
+	for _, name := range names {
+		registered[name] = true
+	}
+	// The errors of the cache are reported under its own categories.
+	for _, name := range []string{cache.SyntaxError, cache.ModTidyError} {
+		if !registered[name] {
+			t.Errorf("category %q of the cache is not registered", name)
+		}
+	}
+	// Every category constant of the package must be registered.
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range pkgs["mod"].Files {
+		for _, decl := range f.Decls {
+			decl, ok := decl.(*ast.GenDecl)
+			if !ok || decl.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				spec := spec.(*ast.ValueSpec)
+				for i, ident := range spec.Names {
+					if !strings.HasSuffix(ident.Name, "Category") || i >= len(spec.Values) {
+						continue
+					}
+					lit, ok := spec.Values[i].(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						continue
+					}
+					name, err := strconv.Unquote(lit.Value)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !registered[name] {
+						t.Errorf("%s: category %s (%q) is not registered", fset.Position(ident.Pos()), ident.Name, name)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestCategoryDocURL(t *testing.T) {
+	for _, test := range []struct {
+		base, category, want string
+	}{
+		{"https://example.com/docs", missingSumCategory, "https://example.com/docs#missing-gosum-entry"},
+		{"https://example.com/docs#", upgradeCategory, "https://example.com/docs#upgrade-available"},
+		{"https://example.com/docs", cache.ModTidyError, "https://example.com/docs#go-mod-tidy"},
+	} {
+		if got := categoryDocURL(test.base, test.category); got != test.want {
+			t.Errorf("categoryDocURL(%q, %q) = %q, want %q", test.base, test.category, got, test.want)
+		}
+	}
+	// Each category must link to its own section.
+	seen := make(map[string]string)
+	for _, name := range DiagnosticCategories() {
+		url := categoryDocURL("https://example.com/docs", name)
+		if other, ok := seen[url]; ok {
+			t.Errorf("categories %q and %q both link to %s", other, name, url)
+		}
+		seen[url] = name
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// directOnly returns a copy of file without its indirect requirements, so
+// that the checks run over it, including those that query the module proxy,
+// skip them. The syntax of the file is shared with the original.
+func directOnly(file *modfile.File) *modfile.File {
+	direct := *file
+	direct.Require = nil
+	for _, req := range file.Require {
+		if !req.Indirect {
+			direct.Require = append(direct.Require, req)
+		}
+	}
+	return &direct
+}
+
+// dropIndirectErrors removes the errors reported on the indirect
+// requirements of file, such as those of `go mod tidy`, which are computed
+// for the whole file.
+func dropIndirectErrors(file *modfile.File, errors []source.Error) []source.Error {
+	// The lines of the syntax are 1-based, and those of the ranges 0-based.
+	indirect := make(map[int]bool)
+	for _, req := range file.Require {
+		if !req.Indirect || req.Syntax == nil {
+			continue
+		}
+		for line := req.Syntax.Start.Line; line <= req.Syntax.End.Line; line++ {
+			indirect[line-1] = true
+		}
+	}
+	if len(indirect) == 0 {
+		return errors
+	}
+	var kept []source.Error
+	for _, e := range errors {
+		if !indirect[int(e.Range.Start.Line)] {
+			kept = append(kept, e)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestDirectOnly(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.14
+
+require (
+	example.com/direct v1.0.0
+	example.com/indirect v1.0.0 // indirect
+)
+
+require example.com/other v1.0.0
+`
+	options := source.DefaultOptions()
+	for _, result := range source.SetOptions(&options, map[string]interface{}{"modDirectOnly": true}) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+	if !options.ModDirectOnly {
+		t.Fatal("modDirectOnly was not set")
+	}
+
+	_, _, file := parseTestMod(t, mod)
+	direct := directOnly(file)
+	var paths []string
+	for _, req := range direct.Require {
+		paths = append(paths, req.Mod.Path)
+	}
+	if len(paths) != 2 || paths[0] != "example.com/direct" || paths[1] != "example.com/other" {
+		t.Errorf("got requirements %v, want the direct ones", paths)
+	}
+	if len(file.Require) != 3 {
+		t.Errorf("directOnly modified the original file")
+	}
+
+	// Only the error on line 6, 0-based, is on the indirect requirement.
+	var errors []source.Error
+	for _, line := range []float64{0, 5, 6, 9} {
+		errors = append(errors, source.Error{Range: protocol.Range{Start: protocol.Position{Line: line}}})
+	}
+	kept := dropIndirectErrors(file, errors)
+	var lines []float64
+	for _, e := range kept {
+		lines = append(lines, e.Range.Start.Line)
+	}
+	if len(lines) != 3 || lines[0] != 0 || lines[1] != 5 || lines[2] != 9 {
+		t.Errorf("kept the errors on lines %v, want 0, 5, and 9", lines)
+	}
+}
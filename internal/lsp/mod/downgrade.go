@@ -0,0 +1,96 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	errors "golang.org/x/xerrors"
+)
+
+// A Downgrade is a move of a requirement from its version in the go.mod file
+// to the previous published version of the module.
+type Downgrade struct {
+	Path, From string
+}
+
+// Downgrades returns the downgrade of each requirement of the go.mod file fh
+// on a line within rng. Finding the version to downgrade to requires listing
+// the published versions of the module, which may need network access, so it
+// is left to DowngradeQuery, when the downgrade is made.
+func Downgrades(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, rng protocol.Range) ([]Downgrade, error) {
+	ctx, done := event.Start(ctx, "mod.Downgrades", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, _, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var downgrades []Downgrade
+	for _, req := range requiresInRange(file, rng) {
+		downgrades = append(downgrades, Downgrade{Path: req.Mod.Path, From: req.Mod.Version})
+	}
+	return downgrades, nil
+}
+
+// DowngradeQuery returns the argument to `go get` that moves the module path
+// from version from to the highest published version below it. It fails if
+// there is no such version, or if the published versions cannot be listed.
+func DowngradeQuery(ctx context.Context, snapshot source.Snapshot, path, from string) (string, error) {
+	ctx, done := event.Start(ctx, "mod.DowngradeQuery")
+	defer done()
+
+	versions, err := listPublishedVersions(ctx, snapshot, []string{path})
+	if err != nil {
+		return "", err
+	}
+	to := previousVersion(from, versions[path])
+	if to == "" {
+		return "", errors.Errorf("found no published version of %s below %s", path, from)
+	}
+	return module.Version{Path: path, Version: to}.String(), nil
+}
+
+// requiresInRange returns the requirements of file whose lines overlap rng.
+func requiresInRange(file *modfile.File, rng protocol.Range) []*modfile.Require {
+	var reqs []*modfile.Require
+	for _, req := range file.Require {
+		if req.Syntax == nil {
+			continue
+		}
+		// Positions in the go.mod file count lines from 1.
+		start, end := float64(req.Syntax.Start.Line-1), float64(req.Syntax.End.Line-1)
+		if start <= rng.End.Line && rng.Start.Line <= end {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// previousVersion returns the highest of the published versions strictly
+// below v, or "" if there is none.
+func previousVersion(v string, published []string) string {
+	var prev string
+	for _, p := range published {
+		if semver.Compare(p, v) < 0 && (prev == "" || semver.Compare(p, prev) > 0) {
+			prev = p
+		}
+	}
+	return prev
+}
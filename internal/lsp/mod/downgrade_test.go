@@ -0,0 +1,87 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestPreviousVersion(t *testing.T) {
+	published := []string{"v1.0.0", "v1.1.0", "v1.2.0", "v1.3.0-pre"}
+	for _, test := range []struct {
+		version, want string
+	}{
+		{"v1.2.0", "v1.1.0"},
+		{"v1.3.0", "v1.3.0-pre"},
+		{"v1.1.5", "v1.1.0"},
+		{"v1.0.0", ""},
+		{"v1.2.1-0.20200101000000-abcdefabcdef", "v1.2.0"},
+	} {
+		if got := previousVersion(test.version, published); got != test.want {
+			t.Errorf("previousVersion(%q) = %q, want %q", test.version, got, test.want)
+		}
+	}
+}
+
+func TestRequiresInRange(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/a v1.0.0
+
+require (
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+)
+`
+	_, _, file := parseTestMod(t, mod)
+	lines := func(start, end float64) protocol.Range {
+		return protocol.Range{Start: protocol.Position{Line: start}, End: protocol.Position{Line: end}}
+	}
+	for _, test := range []struct {
+		rng  protocol.Range
+		want []string
+	}{
+		{lines(4, 4), []string{"example.com/a"}},
+		{lines(7, 7), []string{"example.com/b"}},
+		{lines(4, 8), []string{"example.com/a", "example.com/b", "example.com/c"}},
+		{lines(0, 2), nil},
+	} {
+		var got []string
+		for _, req := range requiresInRange(file, test.rng) {
+			got = append(got, req.Mod.Path)
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("requiresInRange(%v) = %v, want %v", test.rng, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("requiresInRange(%v) = %v, want %v", test.rng, got, test.want)
+				break
+			}
+		}
+	}
+}
+
+func TestDowngradeDependencyArgs(t *testing.T) {
+	uri := span.URIFromPath("/a/go.mod")
+	cmd := DowngradeDependencyCommand(uri, "example.com/a", "v1.1.0")
+	if cmd.Command != source.CommandDowngradeDependency {
+		t.Fatalf("got command %q, want %q", cmd.Command, source.CommandDowngradeDependency)
+	}
+	gotURI, path, from, err := DowngradeDependencyArgs(cmd.Arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotURI != uri || path != "example.com/a" || from != "v1.1.0" {
+		t.Errorf("DowngradeDependencyArgs(%v) = %s, %s, %s", cmd.Arguments, gotURI, path, from)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const encodingCategory = "encoding"
+
+var byteOrderMark = []byte("\xef\xbb\xbf")
+
+// encodingErrors reports a go.mod file that starts with a UTF-8 byte order
+// mark, or that is not valid UTF-8, neither of which the go command accepts.
+// The fix for a byte order mark deletes it. The fix for invalid UTF-8
+// assumes that the file was saved as Latin-1, the most common culprit, and
+// replaces each byte that is not part of a valid UTF-8 sequence with the
+// character it encodes in Latin-1. Like lineEndingErrors, the check only
+// looks at the raw contents, so it applies to files that cannot be parsed.
+func encodingErrors(uri span.URI, m *protocol.ColumnMapper) ([]source.Error, error) {
+	content := m.Content
+	var errors []source.Error
+	if bytes.HasPrefix(content, byteOrderMark) {
+		rng, err := positionsToRange(uri, m, modfile.Position{Byte: 0}, modfile.Position{Byte: len(byteOrderMark)})
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: encodingCategory,
+			Message:  "go.mod starts with a UTF-8 byte order mark, which the go command cannot parse.",
+			Range:    rng,
+			URI:      uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: "Remove the byte order mark",
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: rng}},
+				},
+			}},
+		})
+	}
+	if utf8.Valid(content) {
+		return errors, nil
+	}
+	var (
+		edits []protocol.TextEdit
+		first = -1
+	)
+	for offset := 0; offset < len(content); {
+		r, size := utf8.DecodeRune(content[offset:])
+		if r != utf8.RuneError || size != 1 {
+			offset += size
+			continue
+		}
+		if first < 0 {
+			first = offset
+		}
+		rng, err := positionsToRange(uri, m, modfile.Position{Byte: offset}, modfile.Position{Byte: offset + 1})
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, protocol.TextEdit{Range: rng, NewText: string(rune(content[offset]))})
+		offset++
+	}
+	start := bytes.LastIndexByte(content[:first], '\n') + 1
+	end := len(content)
+	if i := bytes.IndexByte(content[first:], '\n'); i >= 0 {
+		end = first + i
+	}
+	rng, err := positionsToRange(uri, m, modfile.Position{Byte: start}, modfile.Position{Byte: end})
+	if err != nil {
+		return nil, err
+	}
+	return append(errors, source.Error{
+		Category: encodingCategory,
+		Message:  "go.mod is not valid UTF-8, which the go command requires.",
+		Range:    rng,
+		URI:      uri,
+		SuggestedFixes: []source.SuggestedFix{{
+			Title: "Re-encode from Latin-1 to UTF-8",
+			Edits: map[span.URI][]protocol.TextEdit{
+				uri: edits,
+			},
+		}},
+	}), nil
+}
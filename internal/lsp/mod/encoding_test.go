@@ -0,0 +1,64 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestEncodingErrors(t *testing.T) {
+	const valid = "module example.com/m\n\ngo 1.14\n"
+	if errors, err := encodingErrors(testMapper(valid)); err != nil || len(errors) > 0 {
+		t.Errorf("got errors %v, %v for a valid UTF-8 file", errors, err)
+	}
+	for _, test := range []struct {
+		name, mod, text, fixed string
+	}{
+		{
+			name:  "byte order mark",
+			mod:   "\xef\xbb\xbf" + valid,
+			text:  "\ufeff",
+			fixed: valid,
+		},
+		{
+			name:  "Latin-1",
+			mod:   "module example.com/m\n\ngo 1.14\n\n// Owner: José Müller\n// Ren\xe9 M\xfcller\n",
+			text:  "// Ren\xe9 M\xfcller",
+			fixed: "module example.com/m\n\ngo 1.14\n\n// Owner: José Müller\n// René Müller\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			uri, m := testMapper(test.mod)
+			errors, err := encodingErrors(uri, m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			if got := rangeText(t, m, errors[0].Range); got != test.text {
+				t.Errorf("got range covering %q, want %q", got, test.text)
+			}
+			if len(errors[0].SuggestedFixes) != 1 {
+				t.Fatalf("got %d fixes, want 1", len(errors[0].SuggestedFixes))
+			}
+			edits, err := source.FromProtocolEdits(m, errors[0].SuggestedFixes[0].Edits[uri])
+			if err != nil {
+				t.Fatal(err)
+			}
+			fixed := diff.ApplyEdits(test.mod, edits)
+			if fixed != test.fixed {
+				t.Fatalf("fixed file is %q, want %q", fixed, test.fixed)
+			}
+			if _, err := modfile.Parse("go.mod", []byte(fixed), nil); err != nil {
+				t.Errorf("fixed file does not parse: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const lineEndingCategory = "line endings"
+
+// lineEndingErrors reports a go.mod file that mixes CRLF and LF line endings,
+// on its first CRLF line, with a fix that converts every line ending to LF.
+// The go.mod formatter writes LF line endings, so until the file is
+// converted, formatting it or computing fixes from a formatted copy edits
+// every CRLF line. The check only looks at the raw contents, so it applies
+// to files that cannot be parsed too. Files that consistently use CRLF line
+// endings are left alone.
+func lineEndingErrors(uri span.URI, m *protocol.ColumnMapper) ([]source.Error, error) {
+	content := m.Content
+	crlf := bytes.Count(content, []byte("\r\n"))
+	if crlf == 0 || crlf == bytes.Count(content, []byte("\n")) {
+		return nil, nil
+	}
+	var edits []protocol.TextEdit
+	for offset := 0; ; {
+		i := bytes.Index(content[offset:], []byte("\r\n"))
+		if i < 0 {
+			break
+		}
+		offset += i
+		rng, err := positionsToRange(uri, m, modfile.Position{Byte: offset}, modfile.Position{Byte: offset + 1})
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, protocol.TextEdit{Range: rng})
+		offset += 2
+	}
+	first := bytes.Index(content, []byte("\r\n"))
+	start := bytes.LastIndexByte(content[:first], '\n') + 1
+	rng, err := positionsToRange(uri, m, modfile.Position{Byte: start}, modfile.Position{Byte: first})
+	if err != nil {
+		return nil, err
+	}
+	return []source.Error{{
+		Category: lineEndingCategory,
+		Message:  "go.mod mixes CRLF and LF line endings.",
+		Range:    rng,
+		URI:      uri,
+		SuggestedFixes: []source.SuggestedFix{{
+			Title: "Use LF line endings",
+			Edits: map[span.URI][]protocol.TextEdit{
+				uri: edits,
+			},
+		}},
+	}}, nil
+}
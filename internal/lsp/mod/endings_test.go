@@ -0,0 +1,71 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestLineEndingErrors(t *testing.T) {
+	for _, mod := range []string{
+		"module example.com/m\n\ngo 1.14\n",
+		"module example.com/m\r\n\r\ngo 1.14\r\n",
+	} {
+		uri, m := testMapper(mod)
+		errors, err := lineEndingErrors(uri, m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(errors) > 0 {
+			t.Errorf("got errors for consistent line endings in %q: %v", mod, errors)
+		}
+	}
+
+	const mixed = "module example.com/m\n\ngo 1.14\r\n\r\nrequire (\n\texample.com/a v1.0.0\r\n\texample.com/b v1.0.0\n)\n"
+	uri, m := testMapper(mixed)
+	errors, err := lineEndingErrors(uri, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	if got := rangeText(t, m, errors[0].Range); got != "go 1.14" {
+		t.Errorf("got range covering %q, want the first CRLF line", got)
+	}
+	if len(errors[0].SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(errors[0].SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(m, errors[0].SuggestedFixes[0].Edits[uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := diff.ApplyEdits(mixed, edits)
+	if want := strings.Replace(mixed, "\r\n", "\n", -1); fixed != want {
+		t.Fatalf("fixed file is %q, want %q", fixed, want)
+	}
+
+	// Once the line endings are consistent, formatting the file only
+	// changes what it should.
+	file, err := modfile.Parse("go.mod", []byte(fixed), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := file.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(formatted) != fixed {
+		t.Errorf("formatting the fixed file changed it to %q", formatted)
+	}
+	if errors, _ := lineEndingErrors(testMapper(fixed)); len(errors) > 0 {
+		t.Errorf("got errors after the fix: %v", errors)
+	}
+}
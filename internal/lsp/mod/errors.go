@@ -0,0 +1,49 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrParseMod is matched, using errors.Is, by the errors returned when
+	// the go.mod file cannot be read or parsed at all. Syntax errors in the
+	// file are reported as diagnostics instead.
+	ErrParseMod = errors.New("parsing go.mod")
+
+	// ErrTidyFailed is matched, using errors.Is, by the errors returned
+	// when `go mod tidy` fails, for example because the module proxy cannot
+	// be reached.
+	ErrTidyFailed = errors.New("go mod tidy failed")
+)
+
+// A modError is an error of one of the kinds above. It unwraps to its
+// cause, so that callers can inspect both.
+type modError struct {
+	kind  error
+	cause error
+}
+
+func (e *modError) Error() string {
+	return fmt.Sprintf("%v: %v", e.kind, e.cause)
+}
+
+func (e *modError) Unwrap() error {
+	return e.cause
+}
+
+func (e *modError) Is(target error) bool {
+	return target == e.kind
+}
+
+func parseModError(err error) error {
+	return &modError{kind: ErrParseMod, cause: err}
+}
+
+func tidyError(err error) error {
+	return &modError{kind: ErrTidyFailed, cause: err}
+}
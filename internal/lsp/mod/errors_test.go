@@ -0,0 +1,31 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"testing"
+
+	errors "golang.org/x/xerrors"
+)
+
+func TestModErrors(t *testing.T) {
+	err := tidyError(context.DeadlineExceeded)
+	if !errors.Is(err, ErrTidyFailed) {
+		t.Errorf("%v is not ErrTidyFailed", err)
+	}
+	if errors.Is(err, ErrParseMod) {
+		t.Errorf("%v is ErrParseMod", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v does not wrap its cause", err)
+	}
+	if want := "go mod tidy failed: context deadline exceeded"; err.Error() != want {
+		t.Errorf("got message %q, want %q", err.Error(), want)
+	}
+	if err := parseModError(context.Canceled); !errors.Is(err, ErrParseMod) || errors.Is(err, ErrTidyFailed) {
+		t.Errorf("%v is not only ErrParseMod", err)
+	}
+}
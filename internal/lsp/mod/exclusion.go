@@ -0,0 +1,82 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// An Exclusion adds an exclude directive for a known-bad version of a
+// module, so that minimal version selection skips it.
+type Exclusion struct {
+	Path, Version string
+	Edits         []protocol.TextEdit
+}
+
+// Exclusions returns the exclusion of the required version of each
+// requirement of the go.mod file fh on a line within rng. Requirements whose
+// version is already excluded are omitted.
+func Exclusions(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, rng protocol.Range) ([]Exclusion, error) {
+	ctx, done := event.Start(ctx, "mod.Exclusions", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var exclusions []Exclusion
+	for _, req := range requiresInRange(file, rng) {
+		edits, err := excludeEdits(fh.URI(), m, file, req.Mod.Path, req.Mod.Version, snapshot.View().Options())
+		if err != nil || len(edits) == 0 {
+			// A requirement on an invalid version is diagnosed elsewhere.
+			continue
+		}
+		exclusions = append(exclusions, Exclusion{
+			Path:    req.Mod.Path,
+			Version: req.Mod.Version,
+			Edits:   edits,
+		})
+	}
+	return exclusions, nil
+}
+
+// excludeEdits returns the edits that add an exclude directive for path at
+// version to file, or no edits if that version is already excluded. The
+// version must be canonical, and the main module cannot be excluded.
+func excludeEdits(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, path, version string, options source.Options) ([]protocol.TextEdit, error) {
+	if err := module.Check(path, version); err != nil {
+		return nil, err
+	}
+	if module.CanonicalVersion(version) != version {
+		return nil, errors.Errorf("%s is not a canonical version of %s", version, path)
+	}
+	if file.Module != nil && file.Module.Mod.Path == path {
+		return nil, errors.Errorf("cannot exclude the main module %s", path)
+	}
+	for _, x := range file.Exclude {
+		if x.Mod.Path == path && x.Mod.Version == version {
+			return nil, nil
+		}
+	}
+	return rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		return copied.AddExclude(path, version)
+	})
+}
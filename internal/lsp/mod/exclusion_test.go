@@ -0,0 +1,67 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestExcludeEdits(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/foo v1.2.0
+
+exclude example.com/foo v1.1.0
+`
+	const want = `module mod.com
+
+go 1.14
+
+require example.com/foo v1.2.0
+
+exclude (
+	example.com/foo v1.1.0
+	example.com/foo v1.2.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	options := source.DefaultOptions()
+	protocolEdits, err := excludeEdits(uri, m, file, "example.com/foo", "v1.2.0", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err := source.FromProtocolEdits(m, protocolEdits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("excluded go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// An existing exclude is not duplicated.
+	edits2, err := excludeEdits(uri, m, file, "example.com/foo", "v1.1.0", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits2) != 0 {
+		t.Errorf("got edits %v for an excluded version, want none", edits2)
+	}
+
+	for _, test := range []struct{ path, version string }{
+		{"example.com/foo", "v1.2"},
+		{"example.com/foo", "latest"},
+		{"example.com/foo", ""},
+		{"mod.com", "v1.0.0"},
+	} {
+		if _, err := excludeEdits(uri, m, file, test.path, test.version, options); err == nil {
+			t.Errorf("excluding %s %s succeeded, want an error", test.path, test.version)
+		}
+	}
+}
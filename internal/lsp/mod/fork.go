@@ -0,0 +1,59 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const forkCategory = "maintained fork"
+
+// checkModuleForks reports the requirements on modules that the ModuleForks
+// option maps to a maintained fork, on their module path, with a fix that
+// adds a replace directive for the fork. Modules that the go.mod file
+// already replaces, in any version, are not reported.
+func checkModuleForks(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	if len(options.ModuleForks) == 0 {
+		return nil, nil
+	}
+	replaced := make(map[string]bool, len(file.Replace))
+	for _, r := range file.Replace {
+		replaced[r.Old.Path] = true
+	}
+	var errors []source.Error
+	for _, req := range file.Require {
+		fork, ok := options.ModuleForks[req.Mod.Path]
+		if !ok || replaced[req.Mod.Path] || req.Syntax == nil || len(req.Syntax.Token) < 2 {
+			continue
+		}
+		rng, err := tokenRange(uri, m, req.Syntax, len(req.Syntax.Token)-2)
+		if err != nil {
+			return nil, err
+		}
+		path := req.Mod.Path
+		edits, err := rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+			return copied.AddReplace(path, "", fork.Path, fork.Version)
+		})
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: forkCategory,
+			Message:  fmt.Sprintf("%s is no longer maintained; %s is its maintained fork.", path, fork.Path),
+			Range:    rng,
+			URI:      uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Replace with %s %s", fork.Path, fork.Version),
+				Edits: map[span.URI][]protocol.TextEdit{uri: edits},
+			}},
+		})
+	}
+	return errors, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestModuleForks(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.15
+
+require (
+	example.com/abandoned v1.0.0
+	example.com/replaced v1.0.0
+	example.com/kept v1.0.0
+)
+
+replace example.com/replaced => ../replaced
+`
+	options := source.DefaultOptions()
+	for _, result := range source.SetOptions(&options, map[string]interface{}{
+		"moduleForks": map[string]interface{}{
+			"example.com/abandoned": "example.com/fork@v1.2.0",
+			"example.com/replaced":  "example.com/other@v1.0.1",
+		},
+	}) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := checkModuleForks(uri, m, file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if want := "example.com/abandoned is no longer maintained; example.com/fork is its maintained fork."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if got := rangeText(t, m, e.Range); got != "example.com/abandoned" {
+		t.Errorf("got range covering %q, want the module path", got)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `module example.com/m
+
+go 1.15
+
+require (
+	example.com/abandoned v1.0.0
+	example.com/replaced v1.0.0
+	example.com/kept v1.0.0
+)
+
+replace example.com/replaced => ../replaced
+
+replace example.com/abandoned => example.com/fork v1.2.0
+`
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, bad := range []interface{}{"example.com/fork", "example.com/fork@1.2.0", "@v1.2.0", 1} {
+		options := source.DefaultOptions()
+		results := source.SetOptions(&options, map[string]interface{}{
+			"moduleForks": map[string]interface{}{"example.com/abandoned": bad},
+		})
+		if len(results) != 1 || results[0].Error == nil {
+			t.Errorf("got no error for the invalid fork %v", bad)
+		}
+		if len(options.ModuleForks) != 0 {
+			t.Errorf("got forks %v from the invalid fork %v", options.ModuleForks, bad)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// syntaxCategory is the category of the errors of the go.mod parser, as
+// reported by the cache for go.mod files.
+const syntaxCategory = "syntax"
+
+// A Fragment is a go.mod file quoted in other text, such as a Markdown code
+// fence in an issue or in documentation.
+type Fragment struct {
+	Content []byte
+
+	// Line is the line of the surrounding text, counted from 0, on which
+	// the fragment begins.
+	Line int
+}
+
+// ExtractFragment returns the go.mod fragment of text: the contents of its
+// first code fence whose info string is go.mod, gomod, or mod, or else of
+// its first fence with a module directive, or else text itself if it has a
+// module directive. It reports false if there is no such fragment.
+func ExtractFragment(text []byte) (Fragment, bool) {
+	lines := bytes.SplitAfter(text, []byte("\n"))
+	var fences []Fragment
+	var infos []string
+	for i := 0; i < len(lines); i++ {
+		marker, info, ok := fenceStart(lines[i])
+		if !ok {
+			continue
+		}
+		start := i + 1
+		end := start
+		for end < len(lines) && !bytes.HasPrefix(bytes.TrimLeft(lines[end], " "), marker) {
+			end++
+		}
+		fences = append(fences, Fragment{Content: bytes.Join(lines[start:end], nil), Line: start})
+		infos = append(infos, info)
+		i = end
+	}
+	for i, info := range infos {
+		switch info {
+		case "go.mod", "gomod", "mod":
+			return fences[i], true
+		}
+	}
+	for _, f := range fences {
+		if hasModuleDirective(f.Content) {
+			return f, true
+		}
+	}
+	if len(fences) == 0 && hasModuleDirective(text) {
+		return Fragment{Content: text}, true
+	}
+	return Fragment{}, false
+}
+
+// fenceStart reports whether line opens a Markdown code fence, returning its
+// marker, such as ``` or ~~~, and its info string.
+func fenceStart(line []byte) (marker []byte, info string, ok bool) {
+	line = bytes.TrimLeft(bytes.TrimRight(line, "\r\n"), " ")
+	for _, c := range []byte{'`', '~'} {
+		n := 0
+		for n < len(line) && line[n] == c {
+			n++
+		}
+		if n >= 3 {
+			return line[:n], string(bytes.TrimSpace(line[n:])), true
+		}
+	}
+	return nil, "", false
+}
+
+func hasModuleDirective(content []byte) bool {
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if fields := bytes.Fields(line); len(fields) > 0 && string(fields[0]) == "module" {
+			return true
+		}
+	}
+	return false
+}
+
+// FragmentDiagnostics returns the diagnostics of the go.mod fragment that
+// can be computed without the go command: its parse errors, and, if it
+// parses, the result of the built-in checks and of the ModValidators of
+// options. Their positions are relative to the fragment, with uri as its
+// file; adding fragment.Line to their lines locates them in the surrounding
+// text.
+func FragmentDiagnostics(uri span.URI, fragment Fragment, options source.Options) ([]*source.Diagnostic, error) {
+	m := &protocol.ColumnMapper{
+		URI:       uri,
+		Converter: span.NewContentConverter(uri.Filename(), fragment.Content),
+		Content:   fragment.Content,
+	}
+	errors, err := fragmentErrors(uri, m, options)
+	if err != nil {
+		return nil, err
+	}
+	var diagnostics []*source.Diagnostic
+	for _, e := range errors {
+		diagnostics = append(diagnostics, toDiagnostic(e))
+	}
+	return diagnostics, nil
+}
+
+func fragmentErrors(uri span.URI, m *protocol.ColumnMapper, options source.Options) ([]source.Error, error) {
+	var errors []source.Error
+	file, err := modfile.Parse(uri.Filename(), m.Content, nil)
+	if err != nil {
+		errList, ok := err.(modfile.ErrorList)
+		if !ok {
+			return nil, err
+		}
+		var parseErrors []source.Error
+		for _, e := range errList {
+			rng, err := lineRange(uri, m, e.Pos)
+			if err != nil {
+				return nil, err
+			}
+			parseErrors = append(parseErrors, source.Error{
+				Category: syntaxCategory,
+				Message:  e.Err.Error(),
+				Range:    rng,
+				URI:      uri,
+			})
+		}
+		// As for go.mod files, the parse errors only describe the first
+		// invalid version, so report each one separately.
+		_, versionErrors, err := canonicalizeVersions(uri, m, options)
+		if err != nil {
+			return nil, err
+		}
+		errors = replaceLineErrors(parseErrors, versionErrors)
+	} else {
+		errors, err = runChecks(uri, m, file, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	endingErrors, err := lineEndingErrors(uri, m)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, endingErrors...)
+	spaceErrors, err := indentErrors(uri, m)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, spaceErrors...)
+	encErrors, err := encodingErrors(uri, m)
+	if err != nil {
+		return nil, err
+	}
+	return append(errors, encErrors...), nil
+}
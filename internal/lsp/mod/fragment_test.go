@@ -0,0 +1,97 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestExtractFragment(t *testing.T) {
+	for _, test := range []struct {
+		name, text, want string
+		line             int
+		ok               bool
+	}{
+		{
+			name: "info string",
+			text: "Build fails with:\n\n```\nmodule example.com/other\n```\n\n```go.mod\nmodule example.com/m\n```\n",
+			want: "module example.com/m\n",
+			line: 7,
+			ok:   true,
+		},
+		{
+			name: "module directive",
+			text: "```sh\n$ go build\n```\n~~~\nmodule example.com/m\n\ngo 1.14\n~~~\n",
+			want: "module example.com/m\n\ngo 1.14\n",
+			line: 4,
+			ok:   true,
+		},
+		{
+			name: "unfenced",
+			text: "module example.com/m\n",
+			want: "module example.com/m\n",
+			ok:   true,
+		},
+		{
+			name: "unterminated",
+			text: "  ```mod\nmodule example.com/m\n",
+			want: "module example.com/m\n",
+			line: 1,
+			ok:   true,
+		},
+		{
+			name: "none",
+			text: "```go\npackage main\n```\nmodule is not a directive here\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := ExtractFragment([]byte(test.text))
+			if ok != test.ok {
+				t.Fatalf("ExtractFragment reported %v, want %v", ok, test.ok)
+			}
+			if string(got.Content) != test.want || got.Line != test.line {
+				t.Errorf("got fragment %q on line %d, want %q on line %d", got.Content, got.Line, test.want, test.line)
+			}
+		})
+	}
+}
+
+func TestFragmentDiagnostics(t *testing.T) {
+	const issue = "go build reports:\n\n```go.mod\nmodule example.com/m\n\ngo 1.14\n\nrequire (\n\texample.com/a vX\n\texample.com/b v1.0.0\n    example.com/c v1.x\n)\n```\n"
+	fragment, ok := ExtractFragment([]byte(issue))
+	if !ok {
+		t.Fatal("no fragment found")
+	}
+	uri := span.URIFromPath("/issue/go.mod")
+	diagnostics, err := FragmentDiagnostics(uri, fragment, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both invalid versions are reported, along with the indentation with
+	// spaces, on the lines of the fragment.
+	lines := make(map[float64][]string)
+	for _, diag := range diagnostics {
+		lines[diag.Range.Start.Line] = append(lines[diag.Range.Start.Line], diag.Source)
+	}
+	if len(lines[5]) != 1 || len(lines[7]) != 2 || len(lines) != 2 {
+		t.Errorf("got diagnostics by line %v, want one on line 5 and two on line 7", lines)
+	}
+	if fragment.Line != 3 {
+		t.Errorf("got fragment on line %d, want 3", fragment.Line)
+	}
+
+	// A fragment that parses is checked.
+	fragment = Fragment{Content: []byte("module example.com/m\n\nexclude example.com/a v1.0.0\nexclude example.com/a v1.0.0\n")}
+	diagnostics, err = FragmentDiagnostics(uri, fragment, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diagnostics) != 1 || diagnostics[0].Source != duplicateCategory {
+		t.Errorf("got diagnostics %v, want one for the redundant exclude", diagnostics)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const goFlagsCategory = "go flags"
+
+// A goFlagsConflict checks that the state of a module does not conflict with
+// the value of a go command flag, set by GOFLAGS or the buildFlags setting.
+// The check returns a description of the conflict, or "" if there is none.
+type goFlagsConflict struct {
+	flag  string
+	check func(value, origin string, file *modfile.File, modDir string, exists func(path string) bool) string
+}
+
+// goFlagsConflicts are the conflicts between flags and module states that
+// are known to make the go command fail.
+var goFlagsConflicts = []goFlagsConflict{
+	{"mod", checkVendorMode},
+}
+
+// goFlagsErrors reports, on the module directive of the go.mod file, the
+// go command flags in goFlags, the view's GOFLAGS, or buildFlags, the
+// buildFlags setting, that conflict with the state of the module. A flag
+// set in both is taken from buildFlags, which the go command gives
+// precedence. exists reports whether a file exists.
+func goFlagsErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, goFlags, buildFlags []string, exists func(path string) bool) ([]source.Error, error) {
+	if file.Module == nil || file.Module.Syntax == nil {
+		return nil, nil
+	}
+	modDir := filepath.Dir(uri.Filename())
+	var errors []source.Error
+	for _, conflict := range goFlagsConflicts {
+		value, origin := flagValue(buildFlags, conflict.flag), "the buildFlags setting"
+		if value == "" {
+			value, origin = flagValue(goFlags, conflict.flag), "GOFLAGS"
+		}
+		if value == "" {
+			continue
+		}
+		msg := conflict.check(value, origin, file, modDir, exists)
+		if msg == "" {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, file.Module.Syntax.Start, file.Module.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: goFlagsCategory,
+			Message:  msg,
+			Range:    rng,
+			URI:      uri,
+		})
+	}
+	return errors, nil
+}
+
+// flagValue returns the value of the last setting of the flag with the
+// given name in flags, as in -name=value, --name=value, or -name value, or
+// "" if it is not set.
+func flagValue(flags []string, name string) string {
+	var value string
+	for i, arg := range flags {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		switch flag := strings.TrimPrefix(arg[1:], "-"); {
+		case strings.HasPrefix(flag, name+"="):
+			value = strings.TrimPrefix(flag, name+"=")
+		case flag == name && i+1 < len(flags):
+			value = flags[i+1]
+		}
+	}
+	return value
+}
+
+// checkVendorMode checks that a module built with -mod=vendor has a
+// vendor/modules.txt file, without which the go command cannot load its
+// requirements.
+func checkVendorMode(value, origin string, file *modfile.File, modDir string, exists func(path string) bool) string {
+	if value != "vendor" || len(file.Require) == 0 {
+		return ""
+	}
+	if exists(filepath.Join(modDir, "vendor", "modules.txt")) {
+		return ""
+	}
+	if exists(filepath.Join(modDir, "vendor")) {
+		return fmt.Sprintf("-mod=vendor is set by %s, but the vendor directory has no modules.txt file. Run `go mod vendor` to recreate it.", origin)
+	}
+	return fmt.Sprintf("-mod=vendor is set by %s, but the module has no vendor directory. Run `go mod vendor`, or remove the flag.", origin)
+}
+
+// fileExists reports whether a file exists on disk.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFlagValue(t *testing.T) {
+	for _, test := range []struct {
+		flags []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"-mod=vendor"}, "vendor"},
+		{[]string{"--mod=readonly"}, "readonly"},
+		{[]string{"-mod", "mod"}, "mod"},
+		{[]string{"-mod=vendor", "-tags=x", "-mod=mod"}, "mod"},
+		{[]string{"-modfile=other.mod"}, ""},
+		{[]string{"-tags", "mod"}, ""},
+	} {
+		if got := flagValue(test.flags, "mod"); got != test.want {
+			t.Errorf("flagValue(%q, mod) = %q, want %q", test.flags, got, test.want)
+		}
+	}
+}
+
+func TestGoFlagsErrors(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.14
+
+require example.com/dep v1.0.0
+`
+	for _, test := range []struct {
+		name                string
+		goFlags, buildFlags []string
+		exists              []string
+		want                string
+	}{
+		{
+			name:    "no vendor directory",
+			goFlags: []string{"-mod=vendor"},
+			want:    "-mod=vendor is set by GOFLAGS, but the module has no vendor directory. Run `go mod vendor`, or remove the flag.",
+		},
+		{
+			name:       "no modules.txt",
+			buildFlags: []string{"-mod=vendor"},
+			exists:     []string{"vendor"},
+			want:       "-mod=vendor is set by the buildFlags setting, but the vendor directory has no modules.txt file. Run `go mod vendor` to recreate it.",
+		},
+		{
+			name:    "vendored",
+			goFlags: []string{"-mod=vendor"},
+			exists:  []string{"vendor", filepath.Join("vendor", "modules.txt")},
+		},
+		{
+			name:       "overridden by buildFlags",
+			goFlags:    []string{"-mod=vendor"},
+			buildFlags: []string{"-mod=mod"},
+		},
+		{
+			name: "no flags",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			uri, m, file := parseTestMod(t, mod)
+			modDir := filepath.Dir(uri.Filename())
+			exists := func(path string) bool {
+				for _, rel := range test.exists {
+					if path == filepath.Join(modDir, rel) {
+						return true
+					}
+				}
+				return false
+			}
+			errors, err := goFlagsErrors(uri, m, file, test.goFlags, test.buildFlags, exists)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.want == "" {
+				if len(errors) != 0 {
+					t.Errorf("got %d errors, want none: %v", len(errors), errors)
+				}
+				return
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			if errors[0].Message != test.want {
+				t.Errorf("got message %q, want %q", errors[0].Message, test.want)
+			}
+			if got := rangeText(t, m, errors[0].Range); got != "module example.com/m" {
+				t.Errorf("got range covering %q, want the module directive", got)
+			}
+		})
+	}
+
+	// A module without requirements loads fine in vendor mode.
+	uri, m, file := parseTestMod(t, "module example.com/m\n")
+	errors, err := goFlagsErrors(uri, m, file, []string{"-mod=vendor"}, nil, func(string) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 0 {
+		t.Errorf("got %d errors for a module without requirements, want none", len(errors))
+	}
+}
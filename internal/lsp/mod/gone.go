@@ -0,0 +1,130 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const goneVersionCategory = "gone version"
+
+// goneVersionErrors reports the requirements in file on a version that the
+// module proxy answers with 410 Gone, which the go command cannot download.
+// Each version is looked up with `go list -m path@version`, which fetches
+// its .info file from the proxy unless it is in the module cache. If the go
+// command cannot be run, or the proxy cannot be reached, no errors are
+// reported.
+func goneVersionErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File) ([]source.Error, error) {
+	replaced := make(map[string]bool, len(file.Replace))
+	for _, r := range file.Replace {
+		replaced[r.Old.Path] = true
+	}
+	var queries []string
+	for _, req := range file.Require {
+		if !replaced[req.Mod.Path] {
+			queries = append(queries, req.Mod.Path+"@"+req.Mod.Version)
+		}
+	}
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	args := append([]string{"-e", "-m", "-json"}, queries...)
+	stdout, err := snapshot.RunGoCommand(ctx, "list", args)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "looking up required versions", err)
+		return nil, nil
+	}
+	gone := make(map[string]bool)
+	var paths []string
+	for dec := json.NewDecoder(stdout); dec.More(); {
+		var mod latestModule
+		if err := dec.Decode(&mod); err != nil {
+			return nil, err
+		}
+		if mod.Error != nil && isGoneError(mod.Error.Err) {
+			gone[mod.Path+"@"+mod.Version] = true
+			paths = append(paths, mod.Path)
+		}
+	}
+	if len(gone) == 0 {
+		return nil, nil
+	}
+	versions, err := listPublishedVersions(ctx, snapshot, paths)
+	if err != nil {
+		return nil, err
+	}
+	return goneErrors(uri, m, file, gone, versions)
+}
+
+// isGoneError reports whether msg, the error reported by the go command for
+// a module version, is the proxy's 410 Gone response.
+func isGoneError(msg string) bool {
+	return strings.Contains(msg, "410 Gone")
+}
+
+// goneErrors reports the requirements in file whose path@version is in gone,
+// with a fix to use the nearest of the module's other published versions, in
+// versions: the lowest higher one, or else the highest lower one.
+func goneErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, gone map[string]bool, versions map[string][]string) ([]source.Error, error) {
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Syntax == nil || !gone[req.Mod.Path+"@"+req.Mod.Version] {
+			continue
+		}
+		rng, err := tokenRange(uri, m, req.Syntax, len(req.Syntax.Token)-1)
+		if err != nil {
+			return nil, err
+		}
+		e := source.Error{
+			Category: goneVersionCategory,
+			Message: fmt.Sprintf("The module proxy no longer serves %s %s (410 Gone), so it cannot be downloaded. "+
+				"The proxy removed this version; it was not retracted by the module's author.", req.Mod.Path, req.Mod.Version),
+			Range: rng,
+			URI:   uri,
+		}
+		if nearest := nearestVersion(req.Mod.Version, versions[req.Mod.Path]); nearest != "" {
+			e.SuggestedFixes = []source.SuggestedFix{{
+				Title: fmt.Sprintf("Use %s %s", req.Mod.Path, nearest),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: rng, NewText: nearest}},
+				},
+			}}
+		}
+		errors = append(errors, e)
+	}
+	return errors, nil
+}
+
+// nearestVersion returns the lowest of the published versions above v, or
+// if there is none, the highest below it. It returns "" if no other version
+// is published.
+func nearestVersion(v string, published []string) string {
+	var above, below string
+	for _, p := range published {
+		switch c := semver.Compare(p, v); {
+		case c > 0 && (above == "" || semver.Compare(p, above) < 0):
+			above = p
+		case c < 0 && (below == "" || semver.Compare(p, below) > 0):
+			below = p
+		}
+	}
+	if above != "" {
+		return above
+	}
+	return below
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoneErrors(t *testing.T) {
+	const mod = `module example.com/m
+
+require (
+	example.com/a v1.2.0
+	example.com/b v1.5.0
+	example.com/c v1.0.0
+	example.com/d v1.0.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	gone := map[string]bool{
+		"example.com/a@v1.2.0": true,
+		"example.com/b@v1.5.0": true,
+		"example.com/c@v1.0.0": true,
+	}
+	versions := map[string][]string{
+		"example.com/a": {"v1.0.0", "v1.1.0", "v1.2.0", "v1.2.1", "v1.3.0"},
+		"example.com/b": {"v1.0.0", "v1.4.0", "v1.5.0"},
+		"example.com/c": {"v1.0.0"},
+		"example.com/d": {"v0.9.0"},
+	}
+	errors, err := goneErrors(uri, m, file, gone, versions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFixes := map[string]string{
+		"v1.2.0": "v1.2.1",
+		"v1.5.0": "v1.4.0",
+		"v1.0.0": "",
+	}
+	if len(errors) != len(wantFixes) {
+		t.Fatalf("got %d errors, want %d: %v", len(errors), len(wantFixes), errors)
+	}
+	for _, e := range errors {
+		version := rangeText(t, m, e.Range)
+		want, ok := wantFixes[version]
+		if !ok {
+			t.Errorf("got error on %q, want the version of a gone requirement", version)
+			continue
+		}
+		if !strings.Contains(e.Message, "410 Gone") || !strings.Contains(e.Message, "not retracted") {
+			t.Errorf("got message %q, want it to tell the gone version apart from a retraction", e.Message)
+		}
+		var got string
+		if len(e.SuggestedFixes) > 0 {
+			got = e.SuggestedFixes[0].Edits[uri][0].NewText
+		}
+		if got != want {
+			t.Errorf("got fix to %q for %s, want %q", got, version, want)
+		}
+	}
+}
+
+func TestIsGoneError(t *testing.T) {
+	for msg, want := range map[string]bool{
+		"example.com/a@v1.2.0: reading https://proxy.golang.org/example.com/a/@v/v1.2.0.info: 410 Gone":      true,
+		"example.com/a@v1.2.0: reading https://proxy.golang.org/example.com/a/@v/v1.2.0.info: 404 Not Found": false,
+		"example.com/a@v1.2.0: module lookup disabled by GOPROXY=off":                                        false,
+	} {
+		if got := isGoneError(msg); got != want {
+			t.Errorf("isGoneError(%q) = %v, want %v", msg, got, want)
+		}
+	}
+}
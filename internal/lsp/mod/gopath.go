@@ -0,0 +1,60 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const gopathLayoutCategory = "GOPATH layout"
+
+// gopathLayoutErrors reports, on the module directive, a go.mod file in the
+// src directory of one of the gopath entries whose module path is not the
+// import path of its directory in GOPATH mode. The packages of such a module
+// have different import paths with and without GO111MODULE=off.
+func gopathLayoutErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, gopath []string) ([]source.Error, error) {
+	if file.Module == nil || file.Module.Syntax == nil {
+		return nil, nil
+	}
+	dir := filepath.Dir(uri.Filename())
+	for _, entry := range gopath {
+		if entry == "" {
+			continue
+		}
+		src := filepath.Join(entry, "src")
+		rel, err := filepath.Rel(src, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		importPath := filepath.ToSlash(rel)
+		if importPath == file.Module.Mod.Path {
+			return nil, nil
+		}
+		var msg string
+		if importPath == "." {
+			msg = fmt.Sprintf("This go.mod file is at the root of the GOPATH src directory %s, whose packages GOPATH mode imports by their directory, not under the module path %s.", src, file.Module.Mod.Path)
+		} else {
+			msg = fmt.Sprintf("This module is in the GOPATH src directory %s, where GOPATH mode imports its packages under %s, not under the module path %s, so its imports only resolve with modules enabled.", src, importPath, file.Module.Mod.Path)
+		}
+		rng, err := positionsToRange(uri, m, file.Module.Syntax.Start, file.Module.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		return []source.Error{{
+			Category: gopathLayoutCategory,
+			Message:  msg,
+			Range:    rng,
+			URI:      uri,
+		}}, nil
+	}
+	return nil, nil
+}
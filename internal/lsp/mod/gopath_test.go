@@ -0,0 +1,58 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestGopathLayoutErrors(t *testing.T) {
+	gopath := filepath.FromSlash("/home/user/go")
+	for _, test := range []struct {
+		name, dir, module string
+		want              int
+	}{
+		{"matching", "/home/user/go/src/example.com/m", "example.com/m", 0},
+		{"mismatched", "/home/user/go/src/github.com/user/m", "example.com/m", 1},
+		{"root", "/home/user/go/src", "example.com/m", 1},
+		{"outside", "/home/user/work/m", "example.com/m", 0},
+		{"sibling", "/home/user/go/srcs/m", "m", 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			contents := "module " + test.module + "\n\ngo 1.14\n"
+			uri := span.URIFromPath(filepath.Join(filepath.FromSlash(test.dir), "go.mod"))
+			m := &protocol.ColumnMapper{
+				URI:       uri,
+				Converter: span.NewContentConverter(uri.Filename(), []byte(contents)),
+				Content:   []byte(contents),
+			}
+			file, err := modfile.Parse(uri.Filename(), []byte(contents), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			errors, err := gopathLayoutErrors(uri, m, file, []string{"", gopath})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(errors) != test.want {
+				t.Fatalf("got %d errors, want %d: %v", len(errors), test.want, errors)
+			}
+			if test.want == 0 {
+				return
+			}
+			if got := rangeText(t, m, errors[0].Range); got != "module "+test.module {
+				t.Errorf("got range covering %q, want the module directive", got)
+			}
+			if diag := toDiagnostic(errors[0]); diag.Severity != protocol.SeverityInformation {
+				t.Errorf("got severity %v, want information", diag.Severity)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// GoverningModFile returns the go.mod file of the module that contains the
+// file uri: the go.mod file in the file's directory or in the nearest of its
+// ancestors, up to the view's folder. In a repository with nested modules,
+// this may not be the view's go.mod file. Unsaved go.mod files count, as
+// they are what the user sees. It returns an error if the file is not in the
+// view's folder, or no go.mod file is found there.
+func GoverningModFile(ctx context.Context, snapshot source.Snapshot, uri span.URI) (span.URI, error) {
+	ctx, done := event.Start(ctx, "mod.GoverningModFile", tag.URI.Of(uri))
+	defer done()
+
+	exists := func(path string) bool {
+		fh, err := snapshot.GetFile(ctx, span.URIFromPath(path))
+		if err != nil {
+			return false
+		}
+		_, err = fh.Read()
+		return err == nil
+	}
+	folder := snapshot.View().Folder().Filename()
+	path := governingModFile(folder, uri.Filename(), exists)
+	if path == "" {
+		return "", errors.Errorf("no go.mod file governs %s in %s", uri.Filename(), folder)
+	}
+	return span.URIFromPath(path), nil
+}
+
+// governingModFile returns the path of the go.mod file in the directory of
+// filename or its nearest ancestor with one, without leaving folder, or "" if
+// there is none.
+func governingModFile(folder, filename string, exists func(path string) bool) string {
+	folder = filepath.Clean(folder)
+	for dir := filepath.Dir(filepath.Clean(filename)); inDir(folder, dir); {
+		if path := filepath.Join(dir, "go.mod"); exists(path) {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return ""
+}
+
+// inDir reports whether dir is the directory root or is inside it.
+func inDir(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
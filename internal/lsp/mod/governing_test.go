@@ -0,0 +1,44 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGoverningModFile(t *testing.T) {
+	mods := map[string]bool{
+		filepath.FromSlash("/repo/go.mod"):               true,
+		filepath.FromSlash("/repo/tools/go.mod"):         true,
+		filepath.FromSlash("/repo/tools/gen/sub/go.mod"): true,
+		filepath.FromSlash("/go.mod"):                    true,
+	}
+	exists := func(path string) bool { return mods[path] }
+	folder := filepath.FromSlash("/repo")
+	for _, tt := range []struct {
+		file, want string
+	}{
+		{"/repo/main.go", "/repo/go.mod"},
+		{"/repo/pkg/a/a.go", "/repo/go.mod"},
+		{"/repo/tools/tool.go", "/repo/tools/go.mod"},
+		{"/repo/tools/gen/gen.go", "/repo/tools/go.mod"},
+		{"/repo/tools/gen/sub/x/x.go", "/repo/tools/gen/sub/go.mod"},
+		// The go.mod file above the view's folder does not count.
+		{"/other/main.go", ""},
+		{"/repository/main.go", ""},
+	} {
+		var want string
+		if tt.want != "" {
+			want = filepath.FromSlash(tt.want)
+		}
+		if got := governingModFile(folder, filepath.FromSlash(tt.file), exists); got != want {
+			t.Errorf("governingModFile(%q) = %q, want %q", tt.file, got, want)
+		}
+	}
+	if got := governingModFile(filepath.FromSlash("/repo/pkg"), filepath.FromSlash("/repo/pkg/a/a.go"), exists); got != "" {
+		t.Errorf("got %q for a folder without a go.mod file, want none", got)
+	}
+}
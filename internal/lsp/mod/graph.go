@@ -0,0 +1,175 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// A DepNode is a module in the module dependency graph. A module required by
+// several others is a single shared node, rather than a copy per requirer.
+// Module requirements may form cycles, so walks of the graph must keep track
+// of the nodes they have visited.
+type DepNode struct {
+	Path    string
+	Version string // empty for the main module
+
+	// Indirect reports whether the module is not required directly by the
+	// main module's go.mod file, or is required with an indirect comment.
+	Indirect bool
+
+	// Requires are the modules required by this one, sorted by path and
+	// version.
+	Requires []*DepNode
+}
+
+// DependencyTree returns the module dependency graph of the view's main
+// module, as printed by `go mod graph`, rooted at the main module.
+func DependencyTree(ctx context.Context, snapshot source.Snapshot) (*DepNode, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.DependencyTree", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, _, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := snapshot.RunGoCommand(ctx, "mod", []string{"graph"})
+	if err != nil {
+		return nil, err
+	}
+	return parseModGraph(stdout, file)
+}
+
+// parseModGraph builds the graph printed by `go mod graph` from r, marking
+// the modules that the main module's go.mod file, file, does not require
+// directly as indirect.
+func parseModGraph(r io.Reader, file *modfile.File) (*DepNode, error) {
+	direct := make(map[string]bool)
+	for _, req := range file.Require {
+		if !req.Indirect {
+			direct[req.Mod.Path+"@"+req.Mod.Version] = true
+		}
+	}
+	nodes := make(map[string]*DepNode)
+	node := func(id string) *DepNode {
+		if n, ok := nodes[id]; ok {
+			return n
+		}
+		n := &DepNode{Path: id, Indirect: !direct[id]}
+		if i := strings.LastIndex(id, "@"); i >= 0 {
+			n.Path, n.Version = id[:i], id[i+1:]
+		}
+		nodes[id] = n
+		return n
+	}
+	var root *DepNode
+	for scanner := bufio.NewScanner(r); scanner.Scan(); {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("unexpected go mod graph line %q", scanner.Text())
+		}
+		from, to := node(fields[0]), node(fields[1])
+		if from.Version == "" {
+			// Only the main module is listed without a version.
+			from.Indirect = false
+			root = from
+		}
+		from.Requires = append(from.Requires, to)
+	}
+	if root == nil {
+		// A module without requirements is not listed at all.
+		if file.Module == nil {
+			return nil, fmt.Errorf("no main module in go mod graph output")
+		}
+		return &DepNode{Path: file.Module.Mod.Path}, nil
+	}
+	for _, n := range nodes {
+		sort.Slice(n.Requires, func(i, j int) bool {
+			a, b := n.Requires[i], n.Requires[j]
+			if a.Path != b.Path {
+				return a.Path < b.Path
+			}
+			return semver.Compare(a.Version, b.Version) < 0
+		})
+	}
+	return root, nil
+}
+
+const unusedExcludeCategory = "unused-exclude"
+
+// unusedExcludeErrors reports the exclude directives in file for modules that
+// do not appear in the module graph rooted at root at any version, since they
+// have no effect on the build. Excludes of other versions of modules in the
+// graph are kept, as they may be what keeps those versions out.
+func unusedExcludeErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, root *DepNode, options source.Options) ([]source.Error, error) {
+	inGraph := make(map[string]bool)
+	var visit func(n *DepNode)
+	visit = func(n *DepNode) {
+		if inGraph[n.Path+"@"+n.Version] {
+			return
+		}
+		inGraph[n.Path+"@"+n.Version] = true
+		inGraph[n.Path] = true
+		for _, req := range n.Requires {
+			visit(req)
+		}
+	}
+	visit(root)
+	var errors []source.Error
+	for _, x := range file.Exclude {
+		if x.Syntax == nil || inGraph[x.Mod.Path] {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, x.Syntax.Start, x.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		edits, err := dropExcludeEdits(uri, m, x, options)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: unusedExcludeCategory,
+			Message:  fmt.Sprintf("%s is not in the module graph, so excluding %s has no effect.", x.Mod.Path, x.Mod.Version),
+			Range:    rng,
+			URI:      uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Remove exclude %s %s", x.Mod.Path, x.Mod.Version),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: edits,
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
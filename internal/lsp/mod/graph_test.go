@@ -0,0 +1,125 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestParseModGraph(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/shared v1.1.0 // indirect
+)
+`
+	const graph = `mod.com example.com/b@v1.0.0
+mod.com example.com/a@v1.0.0
+mod.com example.com/shared@v1.1.0
+example.com/a@v1.0.0 example.com/shared@v1.1.0
+example.com/b@v1.0.0 example.com/shared@v1.1.0
+example.com/b@v1.0.0 example.com/shared@v1.0.0
+example.com/shared@v1.1.0 example.com/leaf@v1.0.0
+`
+	_, _, file := parseTestMod(t, mod)
+	root, err := parseModGraph(strings.NewReader(graph), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Path != "mod.com" || root.Version != "" || root.Indirect {
+		t.Fatalf("got root %+v, want the main module", root)
+	}
+	if len(root.Requires) != 3 {
+		t.Fatalf("got %d requirements of the main module, want 3", len(root.Requires))
+	}
+	a, b, shared := root.Requires[0], root.Requires[1], root.Requires[2]
+	if a.Path != "example.com/a" || b.Path != "example.com/b" || shared.Path != "example.com/shared" {
+		t.Fatalf("got requirements %s, %s, %s, want them sorted by path", a.Path, b.Path, shared.Path)
+	}
+	if a.Indirect || b.Indirect || !shared.Indirect {
+		t.Errorf("got indirect a=%v b=%v shared=%v, want only shared indirect", a.Indirect, b.Indirect, shared.Indirect)
+	}
+	// Shared dependencies are a single node.
+	if a.Requires[0] != shared || b.Requires[1] != shared {
+		t.Errorf("example.com/shared@v1.1.0 is not shared between its requirers")
+	}
+	if old := b.Requires[0]; old.Version != "v1.0.0" || !old.Indirect {
+		t.Errorf("got %+v, want the older, indirect example.com/shared@v1.0.0", old)
+	}
+	if leaf := shared.Requires[0]; leaf.Path != "example.com/leaf" || !leaf.Indirect {
+		t.Errorf("got %+v, want the indirect example.com/leaf", leaf)
+	}
+
+	// Modules without requirements do not appear in the graph.
+	_, _, file = parseTestMod(t, "module mod.com\n")
+	root, err = parseModGraph(strings.NewReader(""), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Path != "mod.com" || len(root.Requires) > 0 {
+		t.Errorf("got root %+v, want the main module alone", root)
+	}
+}
+
+func TestUnusedExcludeErrors(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/a v1.1.0
+
+exclude (
+	example.com/a v1.0.0
+	example.com/gone v1.0.0
+)
+`
+	const want = `module mod.com
+
+go 1.14
+
+require example.com/a v1.1.0
+
+exclude example.com/a v1.0.0
+`
+	uri, m, file := parseTestMod(t, mod)
+	root, err := parseModGraph(strings.NewReader("mod.com example.com/a@v1.1.0\n"), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errors, err := unusedExcludeErrors(uri, m, file, root, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// example.com/a is in the graph, at another version than the excluded
+	// one, so only the exclude of example.com/gone is unused.
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if diag := toDiagnostic(e); diag.Source != "unused-exclude" {
+		t.Errorf("got source %q, want unused-exclude", diag.Source)
+	}
+	if got := rangeText(t, m, e.Range); got != "example.com/gone v1.0.0" {
+		t.Errorf("got range covering %q, want the exclude of example.com/gone", got)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(m, e.SuggestedFixes[0].Edits[uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// A ModHealth summarizes the state of the dependencies of a go.mod file.
+type ModHealth struct {
+	File span.URI
+
+	// Tidied reports whether `go mod tidy` could be run for the module. If
+	// not, TidyClean, Missing, and Unused are unset.
+	Tidied bool
+
+	// TidyClean reports whether `go mod tidy` would leave the go.mod file
+	// unchanged, as far as its requirements are concerned.
+	TidyClean bool
+
+	// Missing is the number of requirements that `go mod tidy` would add,
+	// and Unused is the number that it would remove.
+	Missing, Unused int
+
+	// Online reports whether the module proxy was consulted. If not,
+	// Outdated and Gone are unset.
+	Online bool
+
+	// Outdated is the number of requirements with a newer version, and Gone
+	// is the number on a version that the proxy no longer serves.
+	Outdated, Gone int
+}
+
+// HealthSummary returns the health of the view's go.mod file, or nil if the
+// view has no go.mod file or it cannot be parsed. The counts come from the
+// same sources as the diagnostics: the tidied go.mod file, the available
+// upgrades, and the gone versions. The last two query the module proxy, so
+// they are only computed if online is set.
+func HealthSummary(ctx context.Context, snapshot source.Snapshot, online bool) (*ModHealth, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.HealthSummary", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	health := &ModHealth{File: uri}
+	mth, err := snapshot.ModTidyHandle(ctx)
+	switch {
+	case err == source.ErrTmpModfileUnsupported:
+	case err != nil:
+		return nil, err
+	default:
+		// Tidying can fail for reasons that are reported as diagnostics,
+		// so a failure only leaves the tidy counts unset.
+		ideal, err := mth.Ideal(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			event.Error(ctx, "tidying for the health summary", err)
+		} else if ideal != nil {
+			_, tidyErrors, err := mth.Tidy(ctx)
+			if err != nil {
+				return nil, err
+			}
+			health.Tidied = true
+			health.Missing, health.Unused = tidyCounts(file, ideal)
+			health.TidyClean = health.Missing == 0 && len(tidyErrors) == 0
+		}
+	}
+	if !online {
+		return health, nil
+	}
+	health.Online = true
+	muh, err := snapshot.ModUpgradeHandle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	upgrades, err := muh.Upgrades(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range file.Require {
+		if _, ok := upgrades[req.Mod.Path]; ok {
+			health.Outdated++
+		}
+	}
+	goneErrors, err := goneVersionErrors(ctx, snapshot, uri, m, file)
+	if err != nil {
+		return nil, err
+	}
+	health.Gone = len(goneErrors)
+	return health, nil
+}
+
+// tidyCounts returns the number of requirements that are in ideal but not in
+// original, and the number that are in original but not in ideal.
+func tidyCounts(original, ideal *modfile.File) (missing, unused int) {
+	required := make(map[string]bool, len(original.Require))
+	for _, req := range original.Require {
+		required[req.Mod.Path] = true
+	}
+	tidied := make(map[string]bool, len(ideal.Require))
+	for _, req := range ideal.Require {
+		tidied[req.Mod.Path] = true
+		if !required[req.Mod.Path] {
+			missing++
+		}
+	}
+	for path := range required {
+		if !tidied[path] {
+			unused++
+		}
+	}
+	return missing, unused
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+	"context"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// DocumentHighlight returns the ranges of every occurrence, in the require,
+// exclude, and replace directives of the go.mod file, of the module path of
+// the directive at position. If the cursor is on a version, or elsewhere in a
+// directive, the module path of that part of the directive is used.
+func DocumentHighlight(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, position protocol.Position) ([]protocol.Range, error) {
+	ctx, done := event.Start(ctx, "mod.DocumentHighlight", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	spn, err := m.PointSpan(position)
+	if err != nil {
+		return nil, err
+	}
+	return highlightRanges(fh.URI(), m, file, spn.Start().Offset())
+}
+
+// A pathRef is an occurrence of a module path in a directive.
+type pathRef struct {
+	path  string
+	line  *modfile.Line
+	token int // the index of the path in line.Token
+
+	// start and end are the offsets of the part of the directive that
+	// refers to the module, such as the left side of a replace.
+	start, end int
+}
+
+// modulePathRefs returns the occurrences of module paths in file, whose
+// contents are given.
+func modulePathRefs(file *modfile.File, content []byte) []pathRef {
+	var refs []pathRef
+	for _, r := range file.Require {
+		if r.Syntax != nil && len(r.Syntax.Token) >= 2 {
+			refs = append(refs, pathRef{r.Mod.Path, r.Syntax, len(r.Syntax.Token) - 2, r.Syntax.Start.Byte, r.Syntax.End.Byte})
+		}
+	}
+	for _, x := range file.Exclude {
+		if x.Syntax != nil && len(x.Syntax.Token) >= 2 {
+			refs = append(refs, pathRef{x.Mod.Path, x.Syntax, len(x.Syntax.Token) - 2, x.Syntax.Start.Byte, x.Syntax.End.Byte})
+		}
+	}
+	for _, r := range file.Replace {
+		arrow := -1
+		if r.Syntax != nil {
+			arrow = arrowIndex(r.Syntax)
+		}
+		if arrow < 1 || arrow+1 >= len(r.Syntax.Token) {
+			continue
+		}
+		// The arrow splits the directive between the module that is
+		// replaced and its replacement.
+		oldPath := arrow - 1
+		if r.Old.Version != "" {
+			oldPath--
+		}
+		split := bytes.Index(content[r.Syntax.Start.Byte:r.Syntax.End.Byte], []byte("=>"))
+		if split < 0 {
+			continue
+		}
+		split += r.Syntax.Start.Byte
+		refs = append(refs, pathRef{r.Old.Path, r.Syntax, oldPath, r.Syntax.Start.Byte, split})
+		if !modfile.IsDirectoryPath(r.New.Path) {
+			refs = append(refs, pathRef{r.New.Path, r.Syntax, arrow + 1, split, r.Syntax.End.Byte})
+		}
+	}
+	return refs
+}
+
+// highlightRanges returns the ranges of the occurrences of the module path
+// referred to at offset.
+func highlightRanges(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, offset int) ([]protocol.Range, error) {
+	refs := modulePathRefs(file, m.Content)
+	var path string
+	for _, ref := range refs {
+		if ref.start <= offset && offset <= ref.end {
+			path = ref.path
+			break
+		}
+	}
+	if path == "" {
+		return nil, nil
+	}
+	var rngs []protocol.Range
+	for _, ref := range refs {
+		if ref.path != path {
+			continue
+		}
+		rng, err := tokenRange(uri, m, ref.line, ref.token)
+		if err != nil {
+			return nil, err
+		}
+		rngs = append(rngs, rng)
+	}
+	return rngs, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightRanges(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+)
+
+exclude example.com/a v0.9.0
+
+replace example.com/b => example.com/a v1.1.0
+
+replace example.com/a => ../a
+`
+	uri, m, file := parseTestMod(t, mod)
+	// lines returns the 1-based lines of the highlights for a cursor at the
+	// first occurrence of text.
+	lines := func(text string) []float64 {
+		t.Helper()
+		offset := strings.Index(mod, text)
+		if offset < 0 {
+			t.Fatalf("%q does not occur in go.mod", text)
+		}
+		rngs, err := highlightRanges(uri, m, file, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []float64
+		for _, rng := range rngs {
+			if text := rangeText(t, m, rng); !strings.HasPrefix(text, "example.com/") {
+				t.Errorf("got highlight of %q, want a module path", text)
+			}
+			got = append(got, rng.Start.Line+1)
+		}
+		return got
+	}
+	for _, tt := range []struct {
+		text string
+		want []float64
+	}{
+		{"example.com/a v1.0.0", []float64{6, 10, 12, 14}},
+		// The version of a requirement highlights its module's path.
+		{"v1.0.0", []float64{6, 10, 12, 14}},
+		// On a replace, the side of the arrow determines the module.
+		{"example.com/b =>", []float64{7, 12}},
+		{"example.com/a v1.1.0", []float64{6, 10, 12, 14}},
+		{"go 1.14", nil},
+	} {
+		got := lines(tt.text)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: got highlights on lines %v, want %v", tt.text, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q: got highlights on lines %v, want %v", tt.text, got, tt.want)
+				break
+			}
+		}
+	}
+}
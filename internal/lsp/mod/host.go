@@ -0,0 +1,101 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const hostConventionCategory = "host convention"
+
+// A hostConvention checks the module paths and versions served by a code
+// host against the conventions of that host. The check returns a description
+// of the problem, or "" if the module follows the conventions.
+type hostConvention struct {
+	prefix string
+	check  func(mod module.Version) string
+}
+
+// hostConventions are the conventions of the hosts that gopls knows about.
+// Only violations that are certain to be mistakes on the host are reported.
+var hostConventions = []hostConvention{
+	{"gopkg.in/", checkGopkgIn},
+	{"github.com/", checkRepoHost},
+	{"bitbucket.org/", checkRepoHost},
+}
+
+// checkHostConventions reports the requirements in file whose module path or
+// version breaks the conventions of their code host. The go command accepts
+// these requirements, but the host cannot serve them, so they are reported
+// as hints. Replaced requirements are not checked, as they are not fetched
+// from the host.
+func checkHostConventions(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, _ source.Options) ([]source.Error, error) {
+	replaced := make(map[string]bool, len(file.Replace))
+	for _, r := range file.Replace {
+		replaced[r.Old.Path] = true
+	}
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Syntax == nil || replaced[req.Mod.Path] {
+			continue
+		}
+		for _, convention := range hostConventions {
+			if !strings.HasPrefix(req.Mod.Path, convention.prefix) {
+				continue
+			}
+			msg := convention.check(req.Mod)
+			if msg == "" {
+				break
+			}
+			rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: hostConventionCategory,
+				Message:  msg,
+				Range:    rng,
+				URI:      uri,
+			})
+			break
+		}
+	}
+	return errors, nil
+}
+
+// checkGopkgIn checks a module served by gopkg.in, which redirects
+// gopkg.in/pkg.vN to github.com/go-pkg/pkg and gopkg.in/user/pkg.vN to
+// github.com/user/pkg, for the major version N.
+func checkGopkgIn(mod module.Version) string {
+	elems := strings.Split(strings.TrimPrefix(mod.Path, "gopkg.in/"), "/")
+	if len(elems) > 2 {
+		return fmt.Sprintf("gopkg.in serves modules only at gopkg.in/pkg.vN or gopkg.in/user/pkg.vN, not %s.", mod.Path)
+	}
+	if semver.Build(mod.Version) == "+incompatible" {
+		return fmt.Sprintf("gopkg.in paths carry their major version, so %s should not be +incompatible.", mod.Version)
+	}
+	return ""
+}
+
+// checkRepoHost checks a module served by a host whose repositories are
+// named host/owner/repo.
+func checkRepoHost(mod module.Version) string {
+	elems := strings.Split(mod.Path, "/")
+	if len(elems) < 3 {
+		return fmt.Sprintf("modules on %s are in repositories named %s/owner/repo, so %s cannot be a module.", elems[0], elems[0], mod.Path)
+	}
+	if strings.HasSuffix(elems[2], ".git") {
+		return fmt.Sprintf("%s names the repository with its .git suffix, which %s does not serve.", mod.Path, elems[0])
+	}
+	return ""
+}
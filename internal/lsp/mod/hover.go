@@ -43,6 +43,14 @@ func Hover(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle,
 		return nil, fmt.Errorf("computing hover range: %w", err)
 	}
 
+	// The cursor may be on the go directive, which has its own hover.
+	if file.Go != nil && file.Go.Syntax != nil {
+		s, e := file.Go.Syntax.Start.Byte, file.Go.Syntax.End.Byte
+		if token.Pos(s) <= hoverRng.Start && hoverRng.Start <= token.Pos(e) {
+			return goDirectiveHover(fh.URI(), m, file.Go, snapshot.View().Options())
+		}
+	}
+
 	// Confirm that the cursor is at the position of a require statement.
 	var req *modfile.Require
 	var startPos, endPos int
@@ -172,3 +180,58 @@ func formatExplanation(text string, req *modfile.Require, options source.Options
 	b.WriteString("\n```")
 	return b.String()
 }
+
+// goVersionFeatures describes what each go version enables for the module
+// that declares it, keyed by minor version.
+var goVersionFeatures = []struct {
+	minor    int
+	features string
+}{
+	{13, "binary and octal integer literals, digit separators, and signed shift counts"},
+	{14, "the vendor directory is used by default when it is consistent with go.mod, and interfaces may embed overlapping methods"},
+	{17, "module graph pruning and lazy module loading; go.mod lists every dependency needed to build the module"},
+	{18, "generics (type parameters)"},
+	{21, "the go version is a minimum requirement enforced by the go command, and the min, max, and clear builtins"},
+	{22, "loop variables are created per iteration, and range over integers"},
+	{23, "range over iterator functions"},
+}
+
+// goDirectiveHover returns the hover for the go directive, which summarizes
+// what its version enables.
+func goDirectiveHover(uri span.URI, m *protocol.ColumnMapper, goStmt *modfile.Go, options source.Options) (*protocol.Hover, error) {
+	rng, err := positionsToRange(uri, m, goStmt.Syntax.Start, goStmt.Syntax.End)
+	if err != nil {
+		return nil, err
+	}
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{
+			Kind:  options.PreferredContentFormat,
+			Value: formatGoVersion(goStmt.Version, options),
+		},
+		Range: rng,
+	}, nil
+}
+
+func formatGoVersion(version string, options source.Options) string {
+	var minor int
+	if _, err := fmt.Sscanf(version, "1.%d", &minor); err != nil {
+		return fmt.Sprintf("This module requires go %s.", version)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "This module requires go %s, which enables:", version)
+	if options.PreferredContentFormat == protocol.Markdown {
+		b.WriteRune('\n')
+	}
+	var enabled bool
+	for _, v := range goVersionFeatures {
+		if v.minor > minor {
+			break
+		}
+		fmt.Fprintf(&b, "\n- go 1.%d+: %s", v.minor, v.features)
+		enabled = true
+	}
+	if !enabled {
+		return fmt.Sprintf("This module requires go %s.", version)
+	}
+	return b.String()
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestFormatGoVersion(t *testing.T) {
+	for _, tt := range []struct {
+		version, want string
+	}{
+		{"1.12", "This module requires go 1.12."},
+		{"1.17", `This module requires go 1.17, which enables:
+- go 1.13+: binary and octal integer literals, digit separators, and signed shift counts
+- go 1.14+: the vendor directory is used by default when it is consistent with go.mod, and interfaces may embed overlapping methods
+- go 1.17+: module graph pruning and lazy module loading; go.mod lists every dependency needed to build the module`},
+	} {
+		options := source.DefaultOptions()
+		options.PreferredContentFormat = protocol.PlainText
+		if got := formatGoVersion(tt.version, options); got != tt.want {
+			t.Errorf("formatGoVersion(%q):\ngot:\n%s\nwant:\n%s", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestGoDirectiveHover(t *testing.T) {
+	uri, m, file := parseTestMod(t, "module mod.com\n\ngo 1.18\n")
+	hover, err := goDirectiveHover(uri, m, file.Go, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rangeText(t, m, hover.Range); got != "go 1.18" {
+		t.Errorf("got hover range covering %q, want the go directive", got)
+	}
+	if want := formatGoVersion("1.18", source.DefaultOptions()); hover.Contents.Value != want {
+		t.Errorf("got hover %q, want %q", hover.Contents.Value, want)
+	}
+}
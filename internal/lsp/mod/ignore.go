@@ -0,0 +1,47 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// ignoredModFile reports whether the go.mod file at path is inside one of
+// the folders roots, below a directory whose name is one of dirs, such as
+// root/vendor/example.com/foo/go.mod. Such files belong to vendored code or
+// test fixtures rather than to modules of the workspace.
+func ignoredModFile(path string, roots, dirs []string) bool {
+	if len(dirs) == 0 {
+		return false
+	}
+	ignored := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		ignored[dir] = true
+	}
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, filepath.Dir(path))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+			if ignored[elem] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sessionFolders returns the folders of the views of the snapshot's session.
+func sessionFolders(snapshot source.Snapshot) []string {
+	var folders []string
+	for _, view := range snapshot.View().Session().Views() {
+		folders = append(folders, view.Folder().Filename())
+	}
+	return folders
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoredModFile(t *testing.T) {
+	dirs := []string{"vendor", "testdata", "node_modules"}
+	roots := []string{filepath.FromSlash("/work/app"), filepath.FromSlash("/work/app/testdata/fixture")}
+	for _, tt := range []struct {
+		path string
+		want bool
+	}{
+		{"/work/app/go.mod", false},
+		{"/work/app/sub/go.mod", false},
+		{"/work/app/testdata/fixture/go.mod", true},
+		{"/work/app/vendor/example.com/foo/go.mod", true},
+		{"/work/app/web/node_modules/pkg/go.mod", true},
+		{"/work/app/vendored/go.mod", false},
+		// Files outside every root are not ignored, even in an ignored
+		// directory, as the whole workspace may be below it.
+		{"/testdata/other/go.mod", false},
+	} {
+		if got := ignoredModFile(filepath.FromSlash(tt.path), roots, dirs); got != tt.want {
+			t.Errorf("ignoredModFile(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if ignoredModFile(filepath.FromSlash("/work/app/vendor/go.mod"), roots, nil) {
+		t.Errorf("ignoredModFile ignored a file with no ignored directories")
+	}
+}
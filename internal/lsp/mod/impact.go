@@ -0,0 +1,121 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// An ImpactReport describes how requiring a module version would change the
+// build list of the main module.
+type ImpactReport struct {
+	Path, Version string
+
+	// Added are the modules, other than Path, that would enter the build
+	// list as indirect dependencies, sorted by path.
+	Added []module.Version
+}
+
+// TransitiveImpact reports the modules that requiring path at version would
+// add to the build list of the view's main module. The require is added to
+// a temporary copy of the go.mod and go.sum files, whose build list is
+// compared with the current one, so neither file is modified. Listing the
+// new build list may download the go.mod files of the new dependencies.
+func TransitiveImpact(ctx context.Context, snapshot source.Snapshot, path, version string) (*ImpactReport, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, errors.Errorf("no go.mod file for %s", snapshot.View().Folder())
+	}
+	ctx, done := event.Start(ctx, "mod.TransitiveImpact", tag.URI.Of(uri))
+	defer done()
+
+	if !semver.IsValid(version) {
+		return nil, errors.Errorf("%s is not a valid version of %s", version, path)
+	}
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	content, err := fh.Read()
+	if err != nil {
+		return nil, err
+	}
+	file, err := modfile.Parse(uri.Filename(), content, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.AddRequire(path, version); err != nil {
+		return nil, err
+	}
+	file.Cleanup()
+	withRequire, err := file.Format()
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "gopls-impact")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmpMod := filepath.Join(dir, "go.mod")
+	if err := ioutil.WriteFile(tmpMod, withRequire, 0644); err != nil {
+		return nil, err
+	}
+	sumFH, err := snapshot.GetFile(ctx, span.URIFromPath(strings.TrimSuffix(uri.Filename(), ".mod")+".sum"))
+	if err != nil {
+		return nil, err
+	}
+	if sum, err := sumFH.Read(); err == nil {
+		if err := ioutil.WriteFile(filepath.Join(dir, "go.sum"), sum, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	listArgs := []string{"-m", "-f", "{{.Path}} {{.Version}}", "all"}
+	stdout, err := snapshot.RunGoCommand(ctx, "list", listArgs)
+	if err != nil {
+		return nil, errors.Errorf("computing the build list of %s: %w", uri.Filename(), err)
+	}
+	before, _ := parseBuildList(stdout.String())
+	// The flags come after those of the view, so the go command uses this
+	// go.mod file rather than the view's own temporary copy, and may record
+	// the checksums of the new dependencies in the accompanying go.sum file.
+	stdout, err = snapshot.RunGoCommand(ctx, "list", append([]string{"-mod=mod", "-modfile=" + tmpMod}, listArgs...))
+	if err != nil {
+		return nil, errors.Errorf("computing the build list with %s %s: %w", path, version, err)
+	}
+	after, _ := parseBuildList(stdout.String())
+	return impactReport(path, version, before, after), nil
+}
+
+// impactReport compares the build lists before and after requiring path at
+// version, both mapping module paths to their selected versions.
+func impactReport(path, version string, before, after map[string]string) *ImpactReport {
+	report := &ImpactReport{Path: path, Version: version, Added: []module.Version{}}
+	for p, v := range after {
+		if _, ok := before[p]; !ok && p != path {
+			report.Added = append(report.Added, module.Version{Path: p, Version: v})
+		}
+	}
+	sort.Slice(report.Added, func(i, j int) bool {
+		return report.Added[i].Path < report.Added[j].Path
+	})
+	return report
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestImpactReport(t *testing.T) {
+	before := map[string]string{
+		"example.com/shared": "v1.0.0",
+	}
+	after := map[string]string{
+		"example.com/new":    "v1.2.0",
+		"example.com/shared": "v1.1.0",
+		"example.com/z":      "v0.1.0",
+		"example.com/a":      "v1.0.0",
+	}
+	got := impactReport("example.com/new", "v1.2.0", before, after)
+	want := &ImpactReport{
+		Path:    "example.com/new",
+		Version: "v1.2.0",
+		Added: []module.Version{
+			{Path: "example.com/a", Version: "v1.0.0"},
+			{Path: "example.com/z", Version: "v0.1.0"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("impactReport() = %+v, want %+v", got, want)
+	}
+}
+
+func TestTransitiveImpact(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	// The dependencies are replaced with directories, so that their build
+	// list can be computed offline.
+	root, err := ioutil.TempDir("", "impact")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"main/go.mod": `module example.com/main
+
+go 1.14
+
+replace example.com/a => ../a
+
+replace example.com/b => ../b
+`,
+		"main/go.sum":  "",
+		"main/main.go": "package main\n\nfunc main() {}\n",
+		"a/go.mod":     "module example.com/a\n\ngo 1.14\n\nrequire example.com/b v1.0.0\n",
+		"b/go.mod":     "module example.com/b\n\ngo 1.14\n",
+	}
+	for name, content := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	folder := filepath.Join(root, "main")
+	before, err := ioutil.ReadFile(filepath.Join(folder, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOPROXY=off", "GOFLAGS=")
+	_, snapshot, err := session.NewView(ctx, "impact_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := TransitiveImpact(ctx, snapshot, "example.com/a", "v1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []module.Version{{Path: "example.com/b", Version: "v1.0.0"}}; !reflect.DeepEqual(report.Added, want) {
+		t.Errorf("got added modules %v, want %v", report.Added, want)
+	}
+	after, err := ioutil.ReadFile(filepath.Join(folder, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("TransitiveImpact changed go.mod to\n%s", after)
+	}
+
+	if _, err := TransitiveImpact(ctx, snapshot, "example.com/a", "latest"); err == nil {
+		t.Error("got no error for a non-canonical version")
+	}
+}
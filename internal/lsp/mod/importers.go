@@ -0,0 +1,193 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const singleImporterCategory = "single importer"
+
+// moduleImporters returns the non-test files of the workspace packages that
+// import each of the modules required by file, keyed by module path.
+func moduleImporters(ctx context.Context, snapshot source.Snapshot, file *modfile.File) (map[string]map[span.URI]bool, error) {
+	importers := make(map[string]map[span.URI]bool)
+	err := workspaceImports(ctx, snapshot, func(uri span.URI, importPath string) {
+		if isTestFile(uri) {
+			return
+		}
+		modPath := requiredModule(file, importPath)
+		if modPath == "" {
+			return
+		}
+		if importers[modPath] == nil {
+			importers[modPath] = make(map[span.URI]bool)
+		}
+		importers[modPath][uri] = true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return importers, nil
+}
+
+// An Importer is a source file that imports a package of a module.
+type Importer struct {
+	URI span.URI
+
+	// Test reports whether the file is a _test.go file, whose imports are
+	// only needed to test the workspace packages.
+	Test bool
+}
+
+// ImportersOf returns the files of the workspace packages, test files
+// included, that import a package provided by the module with the given
+// path, sorted by URI. They are the files that would no longer build if the
+// requirement on the module were dropped. Packages are attributed to modules
+// as the view's go.mod file requires them, so a package of a nested module
+// that is required separately does not count as a package of the module
+// that contains it.
+func ImportersOf(ctx context.Context, snapshot source.Snapshot, modulePath string) ([]Importer, error) {
+	ctx, done := event.Start(ctx, "mod.ImportersOf")
+	defer done()
+
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	file, _, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	seen := make(map[span.URI]bool)
+	err = workspaceImports(ctx, snapshot, func(uri span.URI, importPath string) {
+		if !seen[uri] && providedBy(file, modulePath, importPath) {
+			seen[uri] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sortedImporters(seen), nil
+}
+
+// providedBy reports whether the package with the given import path belongs
+// to the module modulePath, given the requirements of file. A module that is
+// not required provides the packages under its path.
+func providedBy(file *modfile.File, modulePath, importPath string) bool {
+	if modPath := requiredModule(file, importPath); modPath != "" {
+		return modPath == modulePath
+	}
+	return importPath == modulePath || strings.HasPrefix(importPath, modulePath+"/")
+}
+
+func sortedImporters(uris map[span.URI]bool) []Importer {
+	importers := make([]Importer, 0, len(uris))
+	for uri := range uris {
+		importers = append(importers, Importer{URI: uri, Test: isTestFile(uri)})
+	}
+	sort.Slice(importers, func(i, j int) bool {
+		return importers[i].URI < importers[j].URI
+	})
+	return importers
+}
+
+func isTestFile(uri span.URI) bool {
+	return strings.HasSuffix(uri.Filename(), "_test.go")
+}
+
+// workspaceImports calls fn with each import path imported by each file of
+// the workspace packages, including their test variants. A file that belongs
+// to several variants is visited once for each.
+func workspaceImports(ctx context.Context, snapshot source.Snapshot, fn func(uri span.URI, importPath string)) error {
+	wsPackages, err := snapshot.WorkspacePackages(ctx)
+	if err != nil {
+		return err
+	}
+	for _, ph := range wsPackages {
+		pkg, err := ph.Check(ctx)
+		if err != nil {
+			return err
+		}
+		for _, pgh := range pkg.CompiledGoFiles() {
+			f, _, _, _, err := pgh.Parse(ctx)
+			if err != nil {
+				return err
+			}
+			for _, spec := range f.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				fn(pgh.File().URI(), path)
+			}
+		}
+	}
+	return nil
+}
+
+// requiredModule returns the path of the module required by file that
+// provides the package with the given import path, or "" if there is none.
+// If the requirements include nested modules, the longest match is used.
+func requiredModule(file *modfile.File, importPath string) string {
+	var modPath string
+	for _, req := range file.Require {
+		path := req.Mod.Path
+		if (importPath == path || strings.HasPrefix(importPath, path+"/")) && len(path) > len(modPath) {
+			modPath = path
+		}
+	}
+	return modPath
+}
+
+// singleImporterErrors reports the direct requirements in file whose modules
+// are imported by exactly one non-test file, as they may have been added by
+// accident. importers is the result of moduleImporters.
+func singleImporterErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, importers map[string]map[span.URI]bool) ([]source.Error, error) {
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Indirect || req.Syntax == nil || len(importers[req.Mod.Path]) != 1 {
+			continue
+		}
+		var importer span.URI
+		for fileURI := range importers[req.Mod.Path] {
+			importer = fileURI
+		}
+		name := importer.Filename()
+		if rel, err := filepath.Rel(filepath.Dir(uri.Filename()), name); err == nil {
+			name = rel
+		}
+		rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: singleImporterCategory,
+			Message:  fmt.Sprintf("%s is only imported by %s, so it may have been added by accident.", req.Mod.Path, filepath.ToSlash(name)),
+			Range:    rng,
+			URI:      uri,
+		})
+	}
+	return errors, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestSingleImporterErrors(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/once v1.0.0
+	example.com/twice v1.0.0
+	example.com/twice/nested v1.0.0
+	example.com/indirect v1.0.0 // indirect
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	if got := requiredModule(file, "example.com/twice/nested/pkg"); got != "example.com/twice/nested" {
+		t.Errorf("got module %q for a nested module's package, want example.com/twice/nested", got)
+	}
+	if got := requiredModule(file, "example.com/oncemore"); got != "" {
+		t.Errorf("got module %q for an unrequired package, want none", got)
+	}
+	a, b := span.URIFromPath("/tmp/a.go"), span.URIFromPath("/tmp/sub/b.go")
+	importers := map[string]map[span.URI]bool{
+		"example.com/once":         {b: true},
+		"example.com/twice":        {a: true, b: true},
+		"example.com/twice/nested": {a: true, b: true},
+		"example.com/indirect":     {a: true},
+	}
+	errors, err := singleImporterErrors(uri, m, file, importers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	if want := "example.com/once is only imported by sub/b.go, so it may have been added by accident."; errors[0].Message != want {
+		t.Errorf("got message %q, want %q", errors[0].Message, want)
+	}
+	if got := rangeText(t, m, errors[0].Range); got != "example.com/once v1.0.0" {
+		t.Errorf("got range covering %q, want the requirement", got)
+	}
+}
+
+func TestImportersOf(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/outer v1.0.0
+	example.com/outer/nested v1.0.0
+)
+`
+	_, _, file := parseTestMod(t, mod)
+	for _, test := range []struct {
+		module, importPath string
+		want               bool
+	}{
+		{"example.com/outer", "example.com/outer", true},
+		{"example.com/outer", "example.com/outer/pkg", true},
+		{"example.com/outer", "example.com/outer/nested/pkg", false},
+		{"example.com/outer/nested", "example.com/outer/nested/pkg", true},
+		{"example.com/outer", "example.com/outerpkg", false},
+		{"example.com/other", "example.com/other/pkg", true},
+		{"example.com/other", "example.com/outer/pkg", false},
+	} {
+		if got := providedBy(file, test.module, test.importPath); got != test.want {
+			t.Errorf("providedBy(%q, %q) = %v, want %v", test.module, test.importPath, got, test.want)
+		}
+	}
+	a, aTest, b := span.URIFromPath("/tmp/a.go"), span.URIFromPath("/tmp/a_test.go"), span.URIFromPath("/tmp/b.go")
+	got := sortedImporters(map[span.URI]bool{b: true, aTest: true, a: true})
+	want := []Importer{{URI: a}, {URI: aTest, Test: true}, {URI: b}}
+	if len(got) != len(want) {
+		t.Fatalf("got importers %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got importer %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
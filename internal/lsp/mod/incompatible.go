@@ -0,0 +1,178 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// IncompatibleRequires returns the +incompatible requirements of the go.mod
+// file fh on a line within rng that could move to a versioned module path,
+// such as example.com/foo v2.1.0+incompatible, which could move to
+// example.com/foo/v2. Whether the versioned path has published versions is
+// only checked by MigrateIncompatible, as that may need network access.
+func IncompatibleRequires(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, rng protocol.Range) ([]module.Version, error) {
+	ctx, done := event.Start(ctx, "mod.IncompatibleRequires", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, _, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	inRange := make(map[*modfile.Require]bool)
+	for _, req := range requiresInRange(file, rng) {
+		inRange[req] = true
+	}
+	var reqs []module.Version
+	for _, req := range incompatibleCandidates(file) {
+		if inRange[req] {
+			reqs = append(reqs, req.Mod)
+		}
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].Path < reqs[j].Path
+	})
+	return reqs, nil
+}
+
+// MigrateIncompatible returns the versioned module version that replaces
+// old, a +incompatible requirement of the go.mod file fh, and the edit that
+// makes the migration: it rewrites the requirement, and the imports of the
+// old module's packages in the files of the main module. The version is the
+// same as old's if the versioned path published it, and otherwise its latest
+// version; listing them may need network access.
+func MigrateIncompatible(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, old module.Version) (module.Version, protocol.WorkspaceEdit, error) {
+	ctx, done := event.Start(ctx, "mod.MigrateIncompatible", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return module.Version{}, protocol.WorkspaceEdit{}, parseModError(err)
+	}
+	file, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return module.Version{}, protocol.WorkspaceEdit{}, parseModError(err)
+	}
+	var req *modfile.Require
+	var newPath string
+	for path, r := range incompatibleCandidates(file) {
+		if r.Mod == old {
+			req, newPath = r, path
+		}
+	}
+	if req == nil || file.Module == nil {
+		return module.Version{}, protocol.WorkspaceEdit{}, errors.Errorf("%s does not require %s that can be migrated", fh.URI().Filename(), old)
+	}
+	versions, err := listPublishedVersions(ctx, snapshot, []string{newPath})
+	if err != nil {
+		return module.Version{}, protocol.WorkspaceEdit{}, err
+	}
+	version := migrationVersion(old.Version, versions[newPath])
+	if version == "" {
+		return module.Version{}, protocol.WorkspaceEdit{}, errors.Errorf("found no published version of %s", newPath)
+	}
+	to := module.Version{Path: newPath, Version: version}
+	edits, err := incompatibleEdits(fh.URI(), m, req, to, snapshot.View().Options())
+	if err != nil {
+		return module.Version{}, protocol.WorkspaceEdit{}, err
+	}
+	imports, _, err := moduleImports(ctx, snapshot, file.Module.Mod.Path)
+	if err != nil {
+		return module.Version{}, protocol.WorkspaceEdit{}, err
+	}
+	var importers []moduleImport
+	for _, imp := range imports {
+		if requiredModule(file, imp.Path) == old.Path {
+			importers = append(importers, imp)
+		}
+	}
+	sortImports(importers)
+	changes, err := importChanges(ctx, snapshot, importers, func(path string) string {
+		return newPath + strings.TrimPrefix(path, old.Path)
+	})
+	if err != nil {
+		return module.Version{}, protocol.WorkspaceEdit{}, err
+	}
+	changes = append([]protocol.TextDocumentEdit{{
+		TextDocument: protocol.VersionedTextDocumentIdentifier{
+			Version: fh.Version(),
+			TextDocumentIdentifier: protocol.TextDocumentIdentifier{
+				URI: protocol.URIFromSpanURI(fh.URI()),
+			},
+		},
+		Edits: edits,
+	}}, changes...)
+	return to, protocol.WorkspaceEdit{DocumentChanges: changes}, nil
+}
+
+// incompatibleCandidates returns the +incompatible requirements in file,
+// keyed by the versioned module path that could replace each of them.
+func incompatibleCandidates(file *modfile.File) map[string]*modfile.Require {
+	candidates := make(map[string]*modfile.Require)
+	for _, req := range file.Require {
+		if req.Syntax == nil || !strings.HasSuffix(req.Mod.Version, "+incompatible") {
+			continue
+		}
+		if _, _, ok := module.SplitPathVersion(req.Mod.Path); !ok || strings.HasPrefix(req.Mod.Path, "gopkg.in/") {
+			continue
+		}
+		candidates[req.Mod.Path+"/"+semver.Major(req.Mod.Version)] = req
+	}
+	return candidates
+}
+
+// migrationVersion returns the version of the versioned module path to
+// require in place of the +incompatible version, given the versioned path's
+// published versions: the same version if it was published, and otherwise
+// the latest one. It returns "" if no versions were published.
+func migrationVersion(incompatible string, published []string) string {
+	if len(published) == 0 {
+		return ""
+	}
+	same := strings.TrimSuffix(incompatible, "+incompatible")
+	for _, v := range published {
+		if v == same {
+			return v
+		}
+	}
+	return published[len(published)-1]
+}
+
+// incompatibleEdits returns the edits that rewrite the requirement req to
+// the module version to.
+func incompatibleEdits(uri span.URI, m *protocol.ColumnMapper, req *modfile.Require, to module.Version, options source.Options) ([]protocol.TextEdit, error) {
+	return rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		// Rewrite the requirement's tokens in place, so that it keeps its
+		// position and comments.
+		for _, r := range copied.Require {
+			if r.Mod != req.Mod || r.Syntax == nil {
+				continue
+			}
+			n := len(r.Syntax.Token)
+			r.Syntax.Token[n-2], r.Syntax.Token[n-1] = modfile.AutoQuote(to.Path), to.Version
+			r.Mod = to
+		}
+		return nil
+	})
+}
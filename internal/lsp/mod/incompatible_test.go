@@ -0,0 +1,197 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestIncompatibleEdits(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/foo v2.1.0+incompatible
+	example.com/bar/v3 v3.0.0
+	gopkg.in/yaml.v2 v2.3.0
+)
+`
+	const want = `module mod.com
+
+go 1.14
+
+require (
+	example.com/foo/v2 v2.1.0
+	example.com/bar/v3 v3.0.0
+	gopkg.in/yaml.v2 v2.3.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	candidates := incompatibleCandidates(file)
+	if len(candidates) != 1 || candidates["example.com/foo/v2"] == nil {
+		t.Fatalf("got candidates %v, want only example.com/foo/v2", candidates)
+	}
+	req := candidates["example.com/foo/v2"]
+	for _, tt := range []struct {
+		published []string
+		want      string
+	}{
+		{nil, ""},
+		{[]string{"v2.0.0", "v2.1.0", "v2.2.0"}, "v2.1.0"},
+		{[]string{"v2.2.0", "v2.3.0"}, "v2.3.0"},
+	} {
+		if got := migrationVersion(req.Mod.Version, tt.published); got != tt.want {
+			t.Errorf("migrationVersion(%q, %v) = %q, want %q", req.Mod.Version, tt.published, got, tt.want)
+		}
+	}
+	protocolEdits, err := incompatibleEdits(uri, m, req, module.Version{Path: "example.com/foo/v2", Version: "v2.1.0"}, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	edits, err := source.FromProtocolEdits(m, protocolEdits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(mod, edits); got != want {
+		t.Errorf("migrated go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMigrateIncompatible(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	const app = `package main
+
+import (
+	"example.com/foo/bar"
+	"example.com/foobar"
+)
+
+func main() {
+	bar.Run()
+	foobar.Run()
+}
+`
+	dir, err := ioutil.TempDir("", "incompatible")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"proxy/example.com/foo/v2/@v/list":                 "v2.1.0\n",
+		"proxy/example.com/foo/v2/@v/v2.1.0.info":          `{"Version":"v2.1.0"}`,
+		"proxy/example.com/foo/v2/@v/v2.1.0.mod":           "module example.com/foo/v2\n",
+		"proxy/example.com/foo/@v/list":                    "v2.1.0+incompatible\n",
+		"proxy/example.com/foo/@v/v2.1.0+incompatible.mod": "module example.com/foo\n",
+		"mod/go.mod":  "module mod.com\n\ngo 1.14\n\nrequire example.com/foo v2.1.0+incompatible\n",
+		"mod/main.go": app,
+	}
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := tests.Context(t)
+	session := cache.New(ctx, nil).NewSession(ctx)
+	options := tests.DefaultOptions()
+	proxy := "file://" + filepath.ToSlash(filepath.Join(dir, "proxy"))
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOWORK=off", "GOPROXY="+proxy, "GOSUMDB=off", "GOFLAGS=-mod=mod")
+	_, snapshot, err := session.NewView(ctx, "incompatible_test", span.URIFromPath(filepath.Join(dir, "mod")), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh, err := snapshot.GetFile(ctx, snapshot.View().ModFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := module.Version{Path: "example.com/foo", Version: "v2.1.0+incompatible"}
+	reqs, err := IncompatibleRequires(ctx, snapshot, fh, protocol.Range{Start: protocol.Position{Line: 4}, End: protocol.Position{Line: 4}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 1 || reqs[0] != old {
+		t.Fatalf("got +incompatible requirements %v, want %v", reqs, old)
+	}
+	if reqs, err := IncompatibleRequires(ctx, snapshot, fh, protocol.Range{}); err != nil || len(reqs) != 0 {
+		t.Errorf("got +incompatible requirements %v, %v on the module line, want none", reqs, err)
+	}
+
+	to, edit, err := MigrateIncompatible(ctx, snapshot, fh, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (module.Version{Path: "example.com/foo/v2", Version: "v2.1.0"}); to != want {
+		t.Errorf("got migration to %v, want %v", to, want)
+	}
+	if len(edit.DocumentChanges) != 2 {
+		t.Fatalf("got changes to %d files, want the go.mod file and main.go", len(edit.DocumentChanges))
+	}
+	if uri := edit.DocumentChanges[0].TextDocument.URI.SpanURI(); uri != fh.URI() {
+		t.Errorf("got first change to %s, want the go.mod file", uri)
+	}
+	change := edit.DocumentChanges[1]
+	appURI := span.URIFromPath(filepath.Join(dir, "mod", "main.go"))
+	if change.TextDocument.URI.SpanURI() != appURI {
+		t.Fatalf("got changes to %s, want %s", change.TextDocument.URI, appURI)
+	}
+	appMapper := &protocol.ColumnMapper{
+		URI:       appURI,
+		Converter: span.NewContentConverter(appURI.Filename(), []byte(app)),
+		Content:   []byte(app),
+	}
+	edits, err := source.FromProtocolEdits(appMapper, change.Edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `package main
+
+import (
+	"example.com/foo/v2/bar"
+	"example.com/foobar"
+)
+
+func main() {
+	bar.Run()
+	foobar.Run()
+}
+`
+	if got := diff.ApplyEdits(app, edits); got != want {
+		t.Errorf("rewritten file:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMigrateIncompatibleArgs(t *testing.T) {
+	uri := span.URIFromPath("/a/go.mod")
+	cmd := MigrateIncompatibleCommand(uri, "example.com/foo", "v2.1.0+incompatible")
+	if cmd.Command != source.CommandMigrateIncompatible {
+		t.Fatalf("got command %q, want %q", cmd.Command, source.CommandMigrateIncompatible)
+	}
+	gotURI, path, version, err := MigrateIncompatibleArgs(cmd.Arguments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotURI != uri || path != "example.com/foo" || version != "v2.1.0+incompatible" {
+		t.Errorf("MigrateIncompatibleArgs(%v) = %s, %s, %s", cmd.Arguments, gotURI, path, version)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bytes"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const indentCategory = "indentation"
+
+// indentErrors reports the lines in the blocks of a go.mod file, such as
+// require ( ... ), whose indentation contains spaces, with a fix that
+// indents them with a tab, as the go.mod formatter does. Until they are
+// fixed, formatting the file rewrites them, so edits computed from a
+// formatted copy touch lines unrelated to the fix. Like lineEndingErrors,
+// the check only looks at the raw contents.
+func indentErrors(uri span.URI, m *protocol.ColumnMapper) ([]source.Error, error) {
+	var errors []source.Error
+	content := m.Content
+	inBlock := false
+	for start := 0; start < len(content); {
+		end := bytes.IndexByte(content[start:], '\n')
+		if end < 0 {
+			end = len(content)
+		} else {
+			end += start
+		}
+		line := bytes.TrimRight(content[start:end], "\r")
+		code := line
+		if i := bytes.Index(code, []byte("//")); i >= 0 {
+			code = code[:i]
+		}
+		code = bytes.TrimSpace(code)
+		switch {
+		case inBlock && bytes.HasPrefix(code, []byte(")")):
+			inBlock = false
+		case !inBlock && bytes.HasSuffix(code, []byte("(")):
+			inBlock = true
+		case inBlock:
+			text := bytes.TrimLeft(line, " \t")
+			indent := len(line) - len(text)
+			if len(text) == 0 || bytes.IndexByte(line[:indent], ' ') < 0 {
+				break
+			}
+			rng, err := positionsToRange(uri, m, modfile.Position{Byte: start + indent}, modfile.Position{Byte: start + len(line)})
+			if err != nil {
+				return nil, err
+			}
+			indentRng, err := positionsToRange(uri, m, modfile.Position{Byte: start}, modfile.Position{Byte: start + indent})
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: indentCategory,
+				Message:  "This line is indented with spaces, but go.mod blocks are indented with tabs.",
+				Range:    rng,
+				URI:      uri,
+				SuggestedFixes: []source.SuggestedFix{{
+					Title: "Indent with a tab",
+					Edits: map[span.URI][]protocol.TextEdit{
+						uri: {{Range: indentRng, NewText: "\t"}},
+					},
+				}},
+			})
+		}
+		start = end + 1
+	}
+	return errors, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestIndentErrors(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.14
+
+require (
+    example.com/a v1.0.0
+	example.com/b v1.0.0
+  	example.com/c v1.0.0 // indirect
+
+    // example.com/d is pinned.
+	example.com/d v1.0.0
+)
+
+replace example.com/e v1.0.0 => example.com/f v1.0.0 // (
+
+`
+	uri, m := testMapper(mod)
+	errors, err := indentErrors(uri, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range errors {
+		got = append(got, rangeText(t, m, e.Range))
+	}
+	want := []string{
+		"example.com/a v1.0.0",
+		"example.com/c v1.0.0 // indirect",
+		"// example.com/d is pinned.",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got errors on %q, want %q", got, want)
+	}
+
+	fixed := mod
+	for i := len(errors) - 1; i >= 0; i-- {
+		edits, err := source.FromProtocolEdits(m, errors[i].SuggestedFixes[0].Edits[uri])
+		if err != nil {
+			t.Fatal(err)
+		}
+		fixed = diff.ApplyEdits(fixed, edits)
+	}
+	// Once the block is indented with tabs, formatting the file leaves it
+	// alone.
+	file, err := modfile.Parse("go.mod", []byte(fixed), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	formatted, err := file.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const block = "require (\n\texample.com/a v1.0.0\n\texample.com/b v1.0.0\n\texample.com/c v1.0.0 // indirect\n\n\t// example.com/d is pinned.\n\texample.com/d v1.0.0\n)\n"
+	if !strings.Contains(fixed, block) || !strings.Contains(string(formatted), block) {
+		t.Errorf("fixed file is:\n%s\nformatted:\n%s\nwant a block of:\n%s", fixed, formatted, block)
+	}
+	if errors, _ := indentErrors(testMapper(fixed)); len(errors) > 0 {
+		t.Errorf("got errors after the fixes: %v", errors)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// A DirectnessChange marks the requirement of Path as indirect, by adding an
+// indirect comment, or if Indirect is false, as direct, by removing it.
+type DirectnessChange struct {
+	Path     string
+	Indirect bool
+	Edits    []protocol.TextEdit
+}
+
+// DirectnessChanges returns the change that toggles the indirect comment of
+// each requirement of the go.mod file fh on a line within rng. Unlike the
+// fixes computed by `go mod tidy`, the edits only touch the comment, so they
+// leave the rest of the file as it is.
+func DirectnessChanges(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, rng protocol.Range) ([]DirectnessChange, error) {
+	ctx, done := event.Start(ctx, "mod.DirectnessChanges", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return directnessChanges(fh.URI(), m, requiresInRange(file, rng))
+}
+
+// directnessChanges returns the changes that toggle the indirect comments of
+// reqs. A change is omitted if the edited file would not parse, or would not
+// have the requirement's directness toggled.
+func directnessChanges(uri span.URI, m *protocol.ColumnMapper, reqs []*modfile.Require) ([]DirectnessChange, error) {
+	var changes []DirectnessChange
+	for _, req := range reqs {
+		edit, err := directnessEdit(uri, m, req)
+		if err != nil {
+			return nil, err
+		}
+		edits := []protocol.TextEdit{edit}
+		if !togglesDirectness(uri, m, req, edits) {
+			continue
+		}
+		changes = append(changes, DirectnessChange{
+			Path:     req.Mod.Path,
+			Indirect: !req.Indirect,
+			Edits:    edits,
+		})
+	}
+	return changes, nil
+}
+
+// directnessEdit returns the edit of the line of req that toggles its
+// indirect comment, as the go command does: an indirect comment is added in
+// front of any other comment at the end of the line, as in
+// "// indirect; comment", and removing it keeps that comment.
+func directnessEdit(uri span.URI, m *protocol.ColumnMapper, req *modfile.Require) (protocol.TextEdit, error) {
+	line := req.Syntax
+	if len(line.Suffix) == 0 {
+		rng, err := positionsToRange(uri, m, line.End, line.End)
+		if err != nil {
+			return protocol.TextEdit{}, err
+		}
+		return protocol.TextEdit{Range: rng, NewText: " // indirect"}, nil
+	}
+	com := line.Suffix[0]
+	token := strings.TrimSpace(com.Token)
+	end := com.Start
+	end.Byte += len(token)
+	text := strings.TrimSpace(strings.TrimPrefix(token, "//"))
+	if !req.Indirect {
+		rng, err := positionsToRange(uri, m, com.Start, end)
+		if err != nil {
+			return protocol.TextEdit{}, err
+		}
+		return protocol.TextEdit{Range: rng, NewText: "// indirect; " + text}, nil
+	}
+	if text == "indirect" {
+		// Remove the comment along with the space in front of it.
+		rng, err := positionsToRange(uri, m, line.End, end)
+		if err != nil {
+			return protocol.TextEdit{}, err
+		}
+		return protocol.TextEdit{Range: rng}, nil
+	}
+	rng, err := positionsToRange(uri, m, com.Start, end)
+	if err != nil {
+		return protocol.TextEdit{}, err
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(text, "indirect;"))
+	return protocol.TextEdit{Range: rng, NewText: "// " + rest}, nil
+}
+
+// togglesDirectness reports whether applying edits to the go.mod file leaves
+// a file that parses, with the directness of req toggled.
+func togglesDirectness(uri span.URI, m *protocol.ColumnMapper, req *modfile.Require, edits []protocol.TextEdit) bool {
+	diffEdits, err := source.FromProtocolEdits(m, edits)
+	if err != nil {
+		return false
+	}
+	content := diff.ApplyEdits(string(m.Content), diffEdits)
+	edited, err := modfile.Parse(uri.Filename(), []byte(content), nil)
+	if err != nil {
+		return false
+	}
+	for _, r := range edited.Require {
+		if r.Mod == req.Mod {
+			return r.Indirect != req.Indirect
+		}
+	}
+	return false
+}
+
+// Title returns the title of the code action that makes the change.
+func (c DirectnessChange) Title() string {
+	if c.Indirect {
+		return fmt.Sprintf("Mark %s as indirect", c.Path)
+	}
+	return fmt.Sprintf("Mark %s as direct", c.Path)
+}
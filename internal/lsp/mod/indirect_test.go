@@ -0,0 +1,85 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestDirectnessChanges(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/single v1.0.0
+
+require (
+	example.com/direct v1.0.0
+	example.com/indirect v1.0.0 // indirect
+	example.com/commented v1.0.0 // pinned for a fix
+	example.com/both v1.0.0 // indirect; pinned for a fix
+)
+`
+	for _, test := range []struct {
+		path, title string
+		line, want  string
+	}{
+		{"example.com/single", "Mark example.com/single as indirect",
+			"require example.com/single v1.0.0\n",
+			"require example.com/single v1.0.0 // indirect\n"},
+		{"example.com/direct", "Mark example.com/direct as indirect",
+			"\texample.com/direct v1.0.0\n",
+			"\texample.com/direct v1.0.0 // indirect\n"},
+		{"example.com/indirect", "Mark example.com/indirect as direct",
+			"\texample.com/indirect v1.0.0 // indirect\n",
+			"\texample.com/indirect v1.0.0\n"},
+		{"example.com/commented", "Mark example.com/commented as indirect",
+			"\texample.com/commented v1.0.0 // pinned for a fix\n",
+			"\texample.com/commented v1.0.0 // indirect; pinned for a fix\n"},
+		{"example.com/both", "Mark example.com/both as direct",
+			"\texample.com/both v1.0.0 // indirect; pinned for a fix\n",
+			"\texample.com/both v1.0.0 // pinned for a fix\n"},
+	} {
+		t.Run(test.path, func(t *testing.T) {
+			uri, m, file := parseTestMod(t, mod)
+			var reqs []*modfile.Require
+			for _, req := range file.Require {
+				if req.Mod.Path == test.path {
+					reqs = append(reqs, req)
+				}
+			}
+			changes, err := directnessChanges(uri, m, reqs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != 1 {
+				t.Fatalf("got %d changes, want 1", len(changes))
+			}
+			change := changes[0]
+			if got := change.Title(); got != test.title {
+				t.Errorf("got title %q, want %q", got, test.title)
+			}
+			if len(change.Edits) != 1 {
+				t.Fatalf("got %d edits, want a single line edit", len(change.Edits))
+			}
+			if line := change.Edits[0].Range.Start.Line; line != change.Edits[0].Range.End.Line {
+				t.Errorf("edit spans lines %v to %v, want a single line", line, change.Edits[0].Range.End.Line)
+			}
+			edits, err := source.FromProtocolEdits(m, change.Edits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Replace(mod, test.line, test.want, 1)
+			if got := diff.ApplyEdits(mod, edits); got != want {
+				t.Errorf("edited go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// InitModFile returns the edit that creates a go.mod file in dir, declaring
+// a module path inferred from the origin remote of the enclosing git
+// repository, or else from dir's location in GOPATH or its name, the go
+// version of the view's go command, and an empty require block. It fails if
+// dir already has a go.mod file, or if no valid module path can be inferred.
+//
+// The edit inserts the contents into the new file, so the client must
+// support creating files by editing them.
+func InitModFile(ctx context.Context, snapshot source.Snapshot, dir span.URI) (protocol.WorkspaceEdit, error) {
+	uri := span.URIFromPath(filepath.Join(dir.Filename(), "go.mod"))
+	ctx, done := event.Start(ctx, "mod.InitModFile", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	if _, err := fh.Read(); err == nil {
+		return protocol.WorkspaceEdit{}, errors.Errorf("%s already exists", uri.Filename())
+	}
+	var gopath string
+	if stdout, err := snapshot.RunGoCommand(ctx, "env", []string{"GOPATH"}); err != nil {
+		event.Error(ctx, "reading GOPATH", err)
+	} else {
+		gopath = strings.TrimSpace(stdout.String())
+	}
+	modPath, err := inferModulePath(dir.Filename(), gopath)
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	content, err := initModContent(modPath, snapshot.View().GoVersion())
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	return protocol.WorkspaceEdit{
+		DocumentChanges: []protocol.TextDocumentEdit{{
+			TextDocument: protocol.VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: protocol.TextDocumentIdentifier{
+					URI: protocol.URIFromSpanURI(uri),
+				},
+			},
+			Edits: []protocol.TextEdit{{NewText: string(content)}},
+		}},
+	}, nil
+}
+
+// initModContent returns the contents of a new go.mod file for the module
+// path, with a go directive for the go minor version goMinor, if it is known,
+// and an empty require block.
+func initModContent(modPath string, goMinor int) ([]byte, error) {
+	file, err := modfile.Parse("go.mod", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.AddModuleStmt(modPath); err != nil {
+		return nil, err
+	}
+	if goMinor > 0 {
+		if err := file.AddGoStmt(fmt.Sprintf("1.%d", goMinor)); err != nil {
+			return nil, err
+		}
+	}
+	file.Syntax.Stmt = append(file.Syntax.Stmt, &modfile.LineBlock{Token: []string{"require"}})
+	return file.Format()
+}
+
+// inferModulePath returns the module path for a module rooted at dir. The
+// path is taken from the origin remote of the git repository containing dir,
+// joined with dir's location in the repository, or else from dir's location
+// in the first GOPATH entry, or else from dir's name. The first candidate is
+// used, and the reason it is invalid, if it is, is reported.
+func inferModulePath(dir, gopath string) (string, error) {
+	var candidate string
+	if root, remote := gitRemote(dir); remote != "" {
+		if base := remoteModulePath(remote); base != "" {
+			candidate = base
+			if rel, err := filepath.Rel(root, dir); err == nil && rel != "." {
+				candidate = path.Join(base, filepath.ToSlash(rel))
+			}
+		}
+	}
+	if candidate == "" && gopath != "" {
+		src := filepath.Join(filepath.SplitList(gopath)[0], "src")
+		if rel, err := filepath.Rel(src, dir); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			candidate = filepath.ToSlash(rel)
+		}
+	}
+	if candidate == "" {
+		candidate = filepath.Base(dir)
+	}
+	if err := module.CheckPath(candidate); err != nil {
+		return "", errors.Errorf("cannot infer a module path for %s: %w", dir, err)
+	}
+	return candidate, nil
+}
+
+// gitRemote returns the root of the git repository containing dir, and the
+// URL of its origin remote, as read from .git/config. It returns empty
+// strings if there is no such repository or remote.
+func gitRemote(dir string) (root, remote string) {
+	for root = dir; ; {
+		fi, err := os.Stat(filepath.Join(root, ".git"))
+		if err == nil {
+			if !fi.IsDir() {
+				// Worktrees and submodules point elsewhere; their
+				// remotes are not worth chasing.
+				return "", ""
+			}
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", ""
+		}
+		root = parent
+	}
+	data, err := ioutil.ReadFile(filepath.Join(root, ".git", "config"))
+	if err != nil {
+		return "", ""
+	}
+	var inOrigin bool
+	for scanner := bufio.NewScanner(bytes.NewReader(data)); scanner.Scan(); {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inOrigin = line == `[remote "origin"]`
+			continue
+		}
+		if !inOrigin {
+			continue
+		}
+		if i := strings.Index(line, "="); i >= 0 && strings.TrimSpace(line[:i]) == "url" {
+			return root, strings.TrimSpace(line[i+1:])
+		}
+	}
+	return "", ""
+}
+
+// remoteModulePath returns the module path corresponding to a git remote
+// URL, such as github.com/foo/bar for https://github.com/foo/bar.git or
+// git@github.com:foo/bar.git. It returns "" if the URL is not understood.
+func remoteModulePath(remote string) string {
+	remote = strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+	var host, p string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return ""
+		}
+		host, p = u.Hostname(), u.Path
+	} else if i := strings.Index(remote, ":"); i >= 0 {
+		// An scp-like address, such as user@host:path.
+		host, p = remote[:i], remote[i+1:]
+		if j := strings.LastIndex(host, "@"); j >= 0 {
+			host = host[j+1:]
+		}
+	}
+	p = strings.Trim(p, "/")
+	if host == "" || p == "" {
+		return ""
+	}
+	return host + "/" + p
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestRemoteModulePath(t *testing.T) {
+	for _, tt := range []struct {
+		remote, want string
+	}{
+		{"https://github.com/foo/bar.git", "github.com/foo/bar"},
+		{"https://github.com/foo/bar/", "github.com/foo/bar"},
+		{"ssh://git@example.com:2222/foo/bar.git", "example.com/foo/bar"},
+		{"git@github.com:foo/bar.git", "github.com/foo/bar"},
+		{"/local/path/to/repo", ""},
+		{"https://example.com", ""},
+	} {
+		if got := remoteModulePath(tt.remote); got != tt.want {
+			t.Errorf("remoteModulePath(%q) = %q, want %q", tt.remote, got, tt.want)
+		}
+	}
+}
+
+func TestInferModulePath(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "modinit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	repo := filepath.Join(tmp, "repo")
+	sub := filepath.Join(repo, "cmd", "tool")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `[core]
+	bare = false
+[remote "upstream"]
+	url = https://example.com/other/repo.git
+[remote "origin"]
+	url = git@github.com:foo/bar.git
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`
+	if err := ioutil.WriteFile(filepath.Join(repo, ".git", "config"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gopath := filepath.Join(tmp, "gopath")
+	inGopath := filepath.Join(gopath, "src", "example.com", "proj")
+	if err := os.MkdirAll(inGopath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	dotted := filepath.Join(tmp, "example.org")
+	plain := filepath.Join(tmp, "plain")
+	for _, dir := range []string{dotted, plain} {
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		dir, want string
+	}{
+		{repo, "github.com/foo/bar"},
+		{sub, "github.com/foo/bar/cmd/tool"},
+		{inGopath, "example.com/proj"},
+		{dotted, "example.org"},
+		{plain, ""},
+	} {
+		got, err := inferModulePath(tt.dir, gopath)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("inferModulePath(%q) = %q, want an error", tt.dir, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("inferModulePath(%q): %v", tt.dir, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("inferModulePath(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestInitModContent(t *testing.T) {
+	got, err := initModContent("example.com/foo", 14)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "module example.com/foo\n\ngo 1.14\n\nrequire (\n)\n"
+	if string(got) != want {
+		t.Errorf("initModContent = %q, want %q", got, want)
+	}
+	got, err = initModContent("example.com/foo", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "module example.com/foo\n\nrequire (\n)\n"
+	if string(got) != want {
+		t.Errorf("initModContent with an unknown go version = %q, want %q", got, want)
+	}
+}
+
+func TestInitModFileExists(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOPROXY=off")
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	_, snapshot, err := session.NewView(ctx, "init_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InitModFile(ctx, snapshot, span.URIFromPath(folder)); err == nil {
+		t.Errorf("InitModFile succeeded in a directory that has a go.mod file")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const licenseCategory = "license"
+
+// licenseErrors reports the requirements in file whose module's license, as
+// detected by options.LicenseResolver, is not one of options.AllowedLicenses.
+// A replaced requirement is checked against the license of its replacement,
+// and one replaced by a directory is not checked, as it is not downloaded.
+// Licenses are compared case-insensitively. If a license cannot be
+// resolved, the failure is logged and the requirement is not reported.
+func licenseErrors(ctx context.Context, uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	if len(options.AllowedLicenses) == 0 || options.LicenseResolver == nil {
+		return nil, nil
+	}
+	allowed := make(map[string]bool, len(options.AllowedLicenses))
+	for _, license := range options.AllowedLicenses {
+		allowed[strings.ToLower(license)] = true
+	}
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Syntax == nil {
+			continue
+		}
+		mod := replacement(file, req.Mod)
+		if modfile.IsDirectoryPath(mod.Path) {
+			continue
+		}
+		license, err := options.LicenseResolver.License(ctx, mod)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			event.Error(ctx, "resolving the license of "+mod.String(), err)
+			continue
+		}
+		if allowed[strings.ToLower(license)] {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		msg := fmt.Sprintf("%s is licensed under %s, which is not an allowed license.", mod.Path, license)
+		if license == "" {
+			msg = fmt.Sprintf("No license was detected for %s, so it is not known to be allowed.", mod.Path)
+		}
+		if mod.Path != req.Mod.Path {
+			msg += fmt.Sprintf(" It replaces %s.", req.Mod.Path)
+		}
+		errors = append(errors, source.Error{
+			Category: licenseCategory,
+			Message:  msg,
+			Range:    rng,
+			URI:      uri,
+		})
+	}
+	return errors, nil
+}
+
+// replacement returns the module that the replace directives of file
+// substitute for mod, or mod itself if it is not replaced. A replacement of
+// the specific version takes precedence over one of every version.
+func replacement(file *modfile.File, mod module.Version) module.Version {
+	result := mod
+	for _, r := range file.Replace {
+		if r.Old.Path != mod.Path {
+			continue
+		}
+		if r.Old.Version == mod.Version {
+			return r.New
+		}
+		if r.Old.Version == "" {
+			result = r.New
+		}
+	}
+	return result
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// fakeLicenses resolves the licenses of the module versions it maps, and
+// fails for the others.
+type fakeLicenses map[module.Version]string
+
+func (f fakeLicenses) License(ctx context.Context, mod module.Version) (string, error) {
+	license, ok := f[mod]
+	if !ok {
+		return "", fmt.Errorf("no license information for %s", mod)
+	}
+	return license, nil
+}
+
+func TestLicenseErrors(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/bsd v1.0.0
+	example.com/gpl v1.0.0
+	example.com/unlicensed v1.0.0
+	example.com/unknown v1.0.0
+	example.com/forked v1.0.0
+	example.com/local v1.0.0
+)
+
+replace example.com/forked => example.com/fork v1.1.0
+
+replace example.com/local => ../local
+`
+	uri, m, file := parseTestMod(t, mod)
+	options := source.DefaultOptions()
+	options.LicenseResolver = fakeLicenses{
+		{Path: "example.com/bsd", Version: "v1.0.0"}:        "BSD-3-Clause",
+		{Path: "example.com/gpl", Version: "v1.0.0"}:        "GPL-3.0",
+		{Path: "example.com/unlicensed", Version: "v1.0.0"}: "",
+		{Path: "example.com/forked", Version: "v1.0.0"}:     "MIT",
+		{Path: "example.com/fork", Version: "v1.1.0"}:       "AGPL-3.0",
+	}
+	ctx := context.Background()
+	if errors, err := licenseErrors(ctx, uri, m, file, options); err != nil || len(errors) > 0 {
+		t.Fatalf("got errors %v, %v without allowed licenses", errors, err)
+	}
+	options.AllowedLicenses = []string{"bsd-3-clause", "MIT"}
+	errors, err := licenseErrors(ctx, uri, m, file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"example.com/gpl v1.0.0":        "example.com/gpl is licensed under GPL-3.0, which is not an allowed license.",
+		"example.com/unlicensed v1.0.0": "No license was detected for example.com/unlicensed, so it is not known to be allowed.",
+		"example.com/forked v1.0.0":     "example.com/fork is licensed under AGPL-3.0, which is not an allowed license. It replaces example.com/forked.",
+	}
+	if len(errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errors), len(want), errors)
+	}
+	for _, e := range errors {
+		text := rangeText(t, m, e.Range)
+		if msg, ok := want[text]; !ok || e.Message != msg {
+			t.Errorf("got error %q on %q, want %q", e.Message, text, msg)
+		}
+		if diag := toDiagnostic(e); diag.Severity != protocol.SeverityWarning {
+			t.Errorf("got severity %v, want a warning", diag.Severity)
+		}
+	}
+
+	options.LicenseResolver = nil
+	if errors, err := licenseErrors(ctx, uri, m, file, options); err != nil || len(errors) > 0 {
+		t.Errorf("got errors %v, %v without a license resolver", errors, err)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const mainOnlyCategory = "main-only module"
+
+// listedPackage is the subset of the output of `go list -json` that names a
+// package and its module.
+type listedPackage struct {
+	ImportPath string
+	Name       string
+	Module     *struct{ Path string }
+}
+
+// mainOnlyRequireErrors reports the direct requirements in file whose module
+// only has main packages, as listed by `go list`, and that no tools.go file
+// of the main module imports. Modules that the go command cannot list, for
+// example because they have not been downloaded, are not reported.
+func mainOnlyRequireErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File) ([]source.Error, error) {
+	var patterns []string
+	for _, req := range file.Require {
+		if !req.Indirect {
+			patterns = append(patterns, req.Mod.Path+"/...")
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	args := append([]string{"-e", "-json"}, patterns...)
+	stdout, err := snapshot.RunGoCommand(ctx, "list", args)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "listing the packages of dependencies", err)
+		return nil, nil
+	}
+	names := make(map[string][]string)
+	for dec := json.NewDecoder(stdout); dec.More(); {
+		var pkg listedPackage
+		if err := dec.Decode(&pkg); err != nil {
+			return nil, err
+		}
+		if pkg.Module != nil && pkg.Name != "" {
+			names[pkg.Module.Path] = append(names[pkg.Module.Path], pkg.Name)
+		}
+	}
+	tools, err := toolImports(filepath.Dir(uri.Filename()))
+	if err != nil {
+		return nil, err
+	}
+	return mainOnlyErrors(uri, m, file, names, tools)
+}
+
+// mainOnlyErrors reports the direct requirements in file whose module's
+// packages, whose names are in names, are all main packages, unless a
+// package under the module's path is in tools.
+func mainOnlyErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, names map[string][]string, tools map[string]bool) ([]source.Error, error) {
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Indirect || req.Syntax == nil || !allMain(names[req.Mod.Path]) || importsModule(tools, req.Mod.Path) {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: mainOnlyCategory,
+			Message: fmt.Sprintf("%s only has main packages, which cannot be imported. "+
+				"If it is required to run its commands, import them from a tools.go file with a tools build constraint.", req.Mod.Path),
+			Range: rng,
+			URI:   uri,
+		})
+	}
+	return errors, nil
+}
+
+func allMain(names []string) bool {
+	for _, name := range names {
+		if name != "main" {
+			return false
+		}
+	}
+	return len(names) > 0
+}
+
+func importsModule(imports map[string]bool, modPath string) bool {
+	for path := range imports {
+		if path == modPath || strings.HasPrefix(path, modPath+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// toolImports returns the import paths imported by the tools.go files of
+// the module in modDir: the Go files constrained by the tools build tag,
+// which track the commands that the module depends on. Nested modules,
+// vendor and testdata directories, and hidden directories are skipped.
+func toolImports(modDir string) (map[string]bool, error) {
+	imports := make(map[string]bool)
+	fset := token.NewFileSet()
+	err := filepath.Walk(modDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if path == modDir {
+				return nil
+			}
+			name := info.Name()
+			if name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ImportsOnly|parser.ParseComments)
+		if err != nil || !hasToolsConstraint(f) {
+			return nil
+		}
+		for _, spec := range f.Imports {
+			if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+				imports[path] = true
+			}
+		}
+		return nil
+	})
+	return imports, err
+}
+
+// hasToolsConstraint reports whether f has a build constraint that requires
+// the tools tag, such as "// +build tools" or "//go:build tools". Like the go
+// command, it only looks at the comments before the package clause that are
+// followed by a blank line, so the package's doc comment is ignored.
+func hasToolsConstraint(f *ast.File) bool {
+	for _, group := range f.Comments {
+		if group.Pos() >= f.Package {
+			break
+		}
+		if group == f.Doc {
+			continue
+		}
+		for _, c := range group.List {
+			var expr string
+			switch {
+			case strings.HasPrefix(c.Text, "// +build "):
+				expr = strings.TrimPrefix(c.Text, "// +build ")
+			case strings.HasPrefix(c.Text, "//go:build "):
+				expr = strings.TrimPrefix(c.Text, "//go:build ")
+			default:
+				continue
+			}
+			terms := strings.FieldsFunc(expr, func(r rune) bool {
+				return r == ' ' || r == ',' || r == '&' || r == '(' || r == ')'
+			})
+			for _, term := range terms {
+				if term == "tools" {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMainOnlyErrors(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/cmd v1.0.0
+	example.com/tracked v1.0.0
+	example.com/lib v1.0.0
+	example.com/mixed v1.0.0
+	example.com/indirect v1.0.0 // indirect
+	example.com/unlisted v1.0.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	names := map[string][]string{
+		"example.com/cmd":      {"main", "main"},
+		"example.com/tracked":  {"main"},
+		"example.com/lib":      {"lib"},
+		"example.com/mixed":    {"main", "lib"},
+		"example.com/indirect": {"main"},
+	}
+	tools := map[string]bool{"example.com/tracked/cmd/gen": true}
+	errors, err := mainOnlyErrors(uri, m, file, names, tools)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	if got := rangeText(t, m, errors[0].Range); got != "example.com/cmd v1.0.0" {
+		t.Errorf("got range covering %q, want the requirement on example.com/cmd", got)
+	}
+}
+
+func TestToolImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "toolimports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"tools.go":            "// +build tools\n\npackage tools\n\nimport _ \"example.com/old\"\n",
+		"tools/tools.go":      "//go:build tools\n// +build tools\n\npackage tools\n\nimport _ \"example.com/new/cmd\"\n",
+		"main.go":             "package main\n\nimport \"example.com/lib\"\n",
+		"other.go":            "// +build !tools\n\npackage main\n\nimport \"example.com/negated\"\n",
+		"nested/go.mod":       "module mod.com/nested\n",
+		"nested/tools.go":     "// +build tools\n\npackage tools\n\nimport _ \"example.com/nested\"\n",
+		"testdata/tools.go":   "// +build tools\n\npackage tools\n\nimport _ \"example.com/testdata\"\n",
+		"tools/tools_test.go": "// +build tools\n\npackage tools\n\nimport _ \"example.com/test\"\n",
+		"tools/comment.go":    "// Package tools does not track anything.\n// +build tools is not a constraint here\npackage tools\n\nimport _ \"example.com/comment\"\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	imports, err := toolImports(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{"example.com/old": true, "example.com/new/cmd": true}
+	if len(imports) != len(want) {
+		t.Errorf("got tool imports %v, want %v", imports, want)
+	}
+	for path := range want {
+		if !imports[path] {
+			t.Errorf("tool import %s is missing from %v", path, imports)
+		}
+	}
+}
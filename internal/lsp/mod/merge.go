@@ -0,0 +1,140 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// MergeRequireBlocks returns the edits that merge the require directives of
+// the go.mod file, whether blocks or single lines, into one sorted block
+// where the first of them is. It returns no edits if there is at most one
+// require directive, or if the file cannot be parsed.
+func MergeRequireBlocks(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "mod.MergeRequireBlocks", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	_, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mergeRequireEdits(fh.URI(), m, snapshot.View().Options())
+}
+
+func mergeRequireEdits(uri span.URI, m *protocol.ColumnMapper, options source.Options) ([]protocol.TextEdit, error) {
+	file, err := modfile.Parse(uri.Filename(), m.Content, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(requireStmts(file.Syntax)) < 2 {
+		return nil, nil
+	}
+	return rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		mergeRequires(copied.Syntax)
+		return nil
+	})
+}
+
+// requireStmts returns the indexes of the require directives in syntax.
+func requireStmts(syntax *modfile.FileSyntax) []int {
+	var indexes []int
+	for i, stmt := range syntax.Stmt {
+		switch stmt := stmt.(type) {
+		case *modfile.Line:
+			if len(stmt.Token) > 0 && stmt.Token[0] == "require" {
+				indexes = append(indexes, i)
+			}
+		case *modfile.LineBlock:
+			if len(stmt.Token) == 1 && stmt.Token[0] == "require" {
+				indexes = append(indexes, i)
+			}
+		}
+	}
+	return indexes
+}
+
+// mergeRequires replaces the require directives of syntax with a single
+// block, sorted by module path and version, in place of the first one. The
+// comments of the merged directives are kept: those above the first one, or
+// after the last one, stay there, while those on the other directives move
+// above their first line, and those at the end of a block move to the end
+// of the merged block.
+func mergeRequires(syntax *modfile.FileSyntax) {
+	indexes := requireStmts(syntax)
+	merged := &modfile.LineBlock{Token: []string{"require"}}
+	for n, i := range indexes {
+		var lines []*modfile.Line
+		var com *modfile.Comments
+		switch stmt := syntax.Stmt[i].(type) {
+		case *modfile.Line:
+			line := &modfile.Line{
+				Comments: modfile.Comments{Suffix: stmt.Suffix},
+				Token:    stmt.Token[1:],
+				InBlock:  true,
+			}
+			lines = []*modfile.Line{line}
+			com = &stmt.Comments
+		case *modfile.LineBlock:
+			lines = stmt.Line
+			com = &stmt.Comments
+			merged.RParen.Before = append(merged.RParen.Before, stmt.RParen.Before...)
+			// Comments after "require (" are kept above the first line.
+			com.Before = append(com.Before, stmt.LParen.Suffix...)
+			com.Before = append(com.Before, stmt.Suffix...)
+		}
+		// Comments above the first directive stay above the block.
+		if n == 0 {
+			merged.Before = com.Before
+		} else if len(lines) > 0 {
+			lines[0].Before = append(com.Before, lines[0].Before...)
+		} else {
+			merged.RParen.Before = append(merged.RParen.Before, com.Before...)
+		}
+		merged.After = append(merged.After, com.After...)
+		merged.Line = append(merged.Line, lines...)
+	}
+	sort.SliceStable(merged.Line, func(i, j int) bool {
+		a, b := merged.Line[i].Token, merged.Line[j].Token
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return semver.Compare(a[1], b[1]) < 0
+	})
+	var stmts []modfile.Expr
+	for i, stmt := range syntax.Stmt {
+		switch {
+		case i == indexes[0]:
+			stmts = append(stmts, merged)
+		case !contains(indexes, i):
+			stmts = append(stmts, stmt)
+		}
+	}
+	syntax.Stmt = stmts
+}
+
+func contains(indexes []int, i int) bool {
+	for _, j := range indexes {
+		if i == j {
+			return true
+		}
+	}
+	return false
+}
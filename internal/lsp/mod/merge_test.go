@@ -0,0 +1,95 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestMergeRequireBlocks(t *testing.T) {
+	for _, test := range []struct {
+		name, mod, want string
+	}{
+		{
+			name: "single block",
+			mod: `module mod.com
+
+go 1.14
+
+require (
+	example.com/b v1.0.0
+	example.com/a v1.0.0
+)
+`,
+		},
+		{
+			name: "blocks and lines",
+			mod: `module mod.com
+
+go 1.14
+
+// Direct dependencies.
+require (
+	example.com/c v1.0.0
+	example.com/a v1.0.0 // pinned for #123
+)
+
+replace example.com/a => example.com/fork v1.0.0
+
+// Added by go get.
+require example.com/d v1.0.0 // indirect
+
+require (
+	// Test-only dependencies.
+	example.com/b v1.2.0
+	example.com/b/v2 v2.0.0 // indirect
+	// Keep this block.
+)
+`,
+			want: `module mod.com
+
+go 1.14
+
+// Direct dependencies.
+require (
+	example.com/a v1.0.0 // pinned for #123
+	// Test-only dependencies.
+	example.com/b v1.2.0
+	example.com/b/v2 v2.0.0 // indirect
+	example.com/c v1.0.0
+	// Added by go get.
+	example.com/d v1.0.0 // indirect
+// Keep this block.
+)
+
+replace example.com/a => example.com/fork v1.0.0
+`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			uri, m := testMapper(test.mod)
+			edits, err := mergeRequireEdits(uri, m, source.DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.want == "" {
+				if len(edits) > 0 {
+					t.Fatalf("got edits %v, want none", edits)
+				}
+				return
+			}
+			spanEdits, err := source.FromProtocolEdits(m, edits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.ApplyEdits(test.mod, spanEdits); got != test.want {
+				t.Errorf("merged file is\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
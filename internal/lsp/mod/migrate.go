@@ -0,0 +1,252 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// A WorkMigration reports the result of moving the directory replacements
+// of a go.mod file to the use directives of a go.work file.
+type WorkMigration struct {
+	// WorkFile is the go.work file that uses the migrated directories, and
+	// Created reports whether it is created by the migration.
+	WorkFile span.URI
+	Created  bool
+
+	// Migrated are the replace directives that are removed from the go.mod
+	// file, as the go.work file uses their directories.
+	Migrated []MigratedReplace
+
+	// Skipped are the directory replacements that are kept, with the
+	// reason for each.
+	Skipped []SkippedReplace
+}
+
+// A MigratedReplace is a replacement of Old with the module in Dir, which
+// is written as it is in the go.work file.
+type MigratedReplace struct {
+	Old module.Version
+	Dir string
+}
+
+// A SkippedReplace is a replacement of Old with Dir that cannot be migrated.
+type SkippedReplace struct {
+	Old    module.Version
+	Dir    string
+	Reason string
+}
+
+// MigrateReplacesToWork returns the edits that replace every directory
+// replacement in the view's go.mod file with a use directive for the
+// directory in the go.work file, along with a report of the migration. The
+// go.work file is the one in the module's directory or its nearest ancestor
+// with one; if there is none, one is created next to the go.mod file, using
+// the main module and its go version. Replacements whose directory does not
+// hold the replaced module are left alone, as a use directive cannot stand
+// in for them.
+func MigrateReplacesToWork(ctx context.Context, snapshot source.Snapshot) (*WorkMigration, map[span.URI][]protocol.TextEdit, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.MigrateReplacesToWork", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, nil, parseModError(err)
+	}
+	file, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, nil, parseModError(err)
+	}
+	read := func(path string) ([]byte, bool) {
+		fh, err := snapshot.GetFile(ctx, span.URIFromPath(path))
+		if err != nil {
+			return nil, false
+		}
+		content, err := fh.Read()
+		return content, err == nil
+	}
+	modDir := filepath.Dir(uri.Filename())
+	workPath := findWorkFile(modDir, func(path string) bool {
+		_, ok := read(path)
+		return ok
+	})
+	var workContent []byte
+	if workPath == "" {
+		workPath = filepath.Join(modDir, "go.work")
+	} else {
+		workContent, _ = read(workPath)
+	}
+	modulePath := func(dir string) (string, error) {
+		content, ok := read(filepath.Join(dir, "go.mod"))
+		if !ok {
+			return "", fmt.Errorf("%s has no go.mod file", dir)
+		}
+		if path := modfile.ModulePath(content); path != "" {
+			return path, nil
+		}
+		return "", fmt.Errorf("%s has no module directive", filepath.Join(dir, "go.mod"))
+	}
+	return migrateReplaces(uri, m, file, workPath, workContent, modulePath, snapshot.View().Options())
+}
+
+// migrateReplaces returns the migration of the directory replacements of
+// file to the go.work file at workPath, whose contents are workContent, or
+// nil if it does not exist yet. modulePath returns the path of the module in
+// a directory.
+func migrateReplaces(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, workPath string, workContent []byte, modulePath func(dir string) (string, error), options source.Options) (*WorkMigration, map[span.URI][]protocol.TextEdit, error) {
+	workURI := span.URIFromPath(workPath)
+	workDir := filepath.Dir(workPath)
+	migration := &WorkMigration{WorkFile: workURI, Created: workContent == nil}
+
+	used := make(map[string]bool)
+	if workContent != nil {
+		workFile, err := modfile.ParseLax(workPath, workContent, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, dir := range workUseDirs(workPath, workFile) {
+			used[realDir(filepath.Clean(dir))] = true
+		}
+	}
+	// The main module must be in the workspace too, or its own packages
+	// would no longer be built with the migrated modules.
+	modDir := filepath.Dir(uri.Filename())
+	var uses []string
+	addUse := func(dir string) {
+		if real := realDir(dir); !used[real] {
+			used[real] = true
+			uses = append(uses, workRelativeDir(workDir, dir))
+		}
+	}
+	addUse(modDir)
+	var dropped []*modfile.Replace
+	for _, r := range file.Replace {
+		if !modfile.IsDirectoryPath(r.New.Path) {
+			continue
+		}
+		dir := filepath.FromSlash(r.New.Path)
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(modDir, dir)
+		}
+		path, err := modulePath(dir)
+		if err != nil {
+			migration.Skipped = append(migration.Skipped, SkippedReplace{Old: r.Old, Dir: r.New.Path, Reason: err.Error()})
+			continue
+		}
+		if path != r.Old.Path {
+			migration.Skipped = append(migration.Skipped, SkippedReplace{
+				Old:    r.Old,
+				Dir:    r.New.Path,
+				Reason: fmt.Sprintf("%s holds module %s, not %s", r.New.Path, path, r.Old.Path),
+			})
+			continue
+		}
+		addUse(dir)
+		dropped = append(dropped, r)
+		migration.Migrated = append(migration.Migrated, MigratedReplace{Old: r.Old, Dir: workRelativeDir(workDir, dir)})
+	}
+	if len(dropped) == 0 {
+		return migration, nil, nil
+	}
+	modEdits, err := rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		for _, r := range dropped {
+			if err := copied.DropReplace(r.Old.Path, r.Old.Version); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	edits := map[span.URI][]protocol.TextEdit{uri: modEdits}
+	if len(uses) == 0 {
+		return migration, edits, nil
+	}
+	if workContent == nil {
+		var b strings.Builder
+		if file.Go != nil {
+			fmt.Fprintf(&b, "go %s\n\n", file.Go.Version)
+		}
+		b.WriteString("use (\n")
+		for _, dir := range uses {
+			fmt.Fprintf(&b, "\t%s\n", modfile.AutoQuote(dir))
+		}
+		b.WriteString(")\n")
+		edits[workURI] = []protocol.TextEdit{{NewText: b.String()}}
+		return migration, edits, nil
+	}
+	workEdits, err := addUseEdits(workURI, workContent, uses, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	edits[workURI] = workEdits
+	return migration, edits, nil
+}
+
+// addUseEdits returns the edits that add use directives for dirs to the
+// go.work file uri, whose contents are given. They are added to its first use
+// block, or to a new block at the end of the file if there is none.
+func addUseEdits(uri span.URI, content []byte, dirs []string, options source.Options) ([]protocol.TextEdit, error) {
+	file, err := modfile.ParseLax(uri.Filename(), content, nil)
+	if err != nil {
+		return nil, err
+	}
+	var block *modfile.LineBlock
+	for _, stmt := range file.Syntax.Stmt {
+		if b, ok := stmt.(*modfile.LineBlock); ok && len(b.Token) == 1 && b.Token[0] == "use" {
+			block = b
+			break
+		}
+	}
+	if block == nil {
+		block = &modfile.LineBlock{Token: []string{"use"}}
+		file.Syntax.Stmt = append(file.Syntax.Stmt, block)
+	}
+	for _, dir := range dirs {
+		block.Line = append(block.Line, &modfile.Line{Token: []string{modfile.AutoQuote(dir)}})
+	}
+	m := &protocol.ColumnMapper{
+		URI:       uri,
+		Converter: span.NewContentConverter(uri.Filename(), content),
+		Content:   content,
+	}
+	diff := options.ComputeEdits(uri, string(content), string(modfile.Format(file.Syntax)))
+	return source.ToProtocolEdits(m, diff)
+}
+
+// workRelativeDir returns dir as it is written in a use directive of a
+// go.work file in workDir: relative to workDir and starting with ./ or ../
+// if possible, and absolute otherwise.
+func workRelativeDir(workDir, dir string) string {
+	rel, err := filepath.Rel(workDir, dir)
+	if err != nil {
+		return filepath.ToSlash(dir)
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || strings.HasPrefix(rel, "../") || rel == ".." {
+		return rel
+	}
+	return "./" + rel
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestMigrateReplaces(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+	example.com/d v1.0.0
+)
+
+replace (
+	example.com/a => ./a
+	example.com/b => ../b
+	example.com/c => ./c
+	example.com/d => example.com/fork v1.0.0
+)
+`
+	modules := map[string]string{
+		filepath.FromSlash("/tmp/a"): "example.com/a",
+		filepath.FromSlash("/b"):     "example.com/other",
+	}
+	modulePath := func(dir string) (string, error) {
+		if path, ok := modules[dir]; ok {
+			return path, nil
+		}
+		return "", fmt.Errorf("%s has no go.mod file", dir)
+	}
+	apply := func(m *protocol.ColumnMapper, edits []protocol.TextEdit) string {
+		t.Helper()
+		diffEdits, err := source.FromProtocolEdits(m, edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return diff.ApplyEdits(string(m.Content), diffEdits)
+	}
+	const wantMod = `module example.com/m
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+	example.com/d v1.0.0
+)
+
+replace (
+	example.com/b => ../b
+	example.com/c => ./c
+	example.com/d => example.com/fork v1.0.0
+)
+`
+	for _, tt := range []struct {
+		name              string
+		workPath, work    string
+		wantWork, wantDir string
+	}{
+		{
+			name:     "new go.work",
+			workPath: "/tmp/go.work",
+			wantWork: "go 1.14\n\nuse (\n\t.\n\t./a\n)\n",
+			wantDir:  "./a",
+		},
+		{
+			name:     "existing go.work",
+			workPath: "/go.work",
+			work:     "go 1.14\n\nuse (\n\t./tmp\n)\n",
+			wantWork: "go 1.14\n\nuse (\n\t./tmp\n\t./tmp/a\n)\n",
+			wantDir:  "./tmp/a",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, m, file := parseTestMod(t, mod)
+			var work []byte
+			if tt.work != "" {
+				work = []byte(tt.work)
+			}
+			workPath := filepath.FromSlash(tt.workPath)
+			migration, edits, err := migrateReplaces(uri, m, file, workPath, work, modulePath, source.DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			workURI := span.URIFromPath(workPath)
+			if migration.WorkFile != workURI || migration.Created != (work == nil) {
+				t.Errorf("got go.work file %s (created: %v), want %s", migration.WorkFile, migration.Created, workURI)
+			}
+			wantMigrated := []MigratedReplace{{Old: module.Version{Path: "example.com/a"}, Dir: tt.wantDir}}
+			if !reflect.DeepEqual(migration.Migrated, wantMigrated) {
+				t.Errorf("got migrated %+v, want %+v", migration.Migrated, wantMigrated)
+			}
+			var skipped []string
+			for _, s := range migration.Skipped {
+				skipped = append(skipped, s.Old.Path)
+			}
+			if want := []string{"example.com/b", "example.com/c"}; !reflect.DeepEqual(skipped, want) {
+				t.Errorf("got skipped %v, want %v", skipped, want)
+			}
+			if got := apply(m, edits[uri]); got != wantMod {
+				t.Errorf("got go.mod\n%s\nwant\n%s", got, wantMod)
+			}
+			workMapper := &protocol.ColumnMapper{
+				URI:       workURI,
+				Converter: span.NewContentConverter(workPath, work),
+				Content:   work,
+			}
+			if got := apply(workMapper, edits[workURI]); got != tt.wantWork {
+				t.Errorf("got go.work\n%s\nwant\n%s", got, tt.wantWork)
+			}
+		})
+	}
+
+	// Nothing is left to migrate after a migration.
+	uri, m, file := parseTestMod(t, wantMod)
+	migration, edits, err := migrateReplaces(uri, m, file, filepath.FromSlash("/tmp/go.work"), nil, modulePath, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migration.Migrated) > 0 || len(edits) > 0 {
+		t.Errorf("got migration %+v with edits %v, want nothing migrated", migration, edits)
+	}
+}
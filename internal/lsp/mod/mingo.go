@@ -0,0 +1,195 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// minGoMinor is the minor version of the first go command that supports
+// modules, below which no go directive is meaningful.
+const minGoMinor = 11
+
+// MinimalGoDirective returns the lowest go version, such as "1.13", that
+// the go directive of the view's go.mod file can be set to while the
+// module still builds: the highest of the go directives of its
+// dependencies, and of the language versions needed by the features that
+// its packages, including their tests, use. Only the language changes that
+// the go directive gates are considered, namely the number literals and
+// signed shift counts of Go 1.13 and the //go:embed directives of Go 1.16;
+// the standard library does not depend on the go directive.
+func MinimalGoDirective(ctx context.Context, snapshot source.Snapshot) (string, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return "", fmt.Errorf("no go.mod file for %s", snapshot.View().Folder().Filename())
+	}
+	ctx, done := event.Start(ctx, "mod.MinimalGoDirective", tag.URI.Of(uri))
+	defer done()
+
+	minor := minGoMinor
+	stdout, err := snapshot.RunGoCommand(ctx, "list", []string{"-m", "-json", "all"})
+	if err != nil {
+		return "", err
+	}
+	for dec := json.NewDecoder(stdout); dec.More(); {
+		var mod struct {
+			Main      bool
+			GoVersion string
+		}
+		if err := dec.Decode(&mod); err != nil {
+			return "", err
+		}
+		if mod.Main {
+			continue
+		}
+		if v := goMinor(mod.GoVersion); v > minor {
+			minor = v
+		}
+	}
+	wsPackages, err := snapshot.WorkspacePackages(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, ph := range wsPackages {
+		pkg, err := ph.Check(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, f := range pkg.GetSyntax() {
+			if v := languageMinor(f, pkg.GetTypesInfo()); v > minor {
+				minor = v
+			}
+		}
+	}
+	return fmt.Sprintf("1.%d", minor), nil
+}
+
+// goMinor returns the minor version of the go version v, such as 13 for
+// "1.13", or 0 if v is not of the form 1.N.
+func goMinor(v string) int {
+	if !strings.HasPrefix(v, "1.") {
+		return 0
+	}
+	minor, err := strconv.Atoi(strings.TrimPrefix(v, "1."))
+	if err != nil {
+		return 0
+	}
+	return minor
+}
+
+// languageMinor returns the minor version of the lowest language version
+// under which f compiles, as far as the features described for
+// MinimalGoDirective are concerned. info may be nil, in which case signed
+// shift counts are not detected.
+func languageMinor(f *ast.File, info *types.Info) int {
+	minor := 0
+	use := func(v int) {
+		if v > minor {
+			minor = v
+		}
+	}
+	for _, group := range f.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "//go:embed ") {
+				use(16)
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BasicLit:
+			if n.Kind == token.INT || n.Kind == token.FLOAT || n.Kind == token.IMAG {
+				if newNumberLiteral(n.Value) {
+					use(13)
+				}
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.SHL || n.Op == token.SHR {
+				if signedShiftCount(info, n.Y) {
+					use(13)
+				}
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.SHL_ASSIGN || n.Tok == token.SHR_ASSIGN {
+				if signedShiftCount(info, n.Rhs[0]) {
+					use(13)
+				}
+			}
+		}
+		return true
+	})
+	return minor
+}
+
+// newNumberLiteral reports whether the number literal lit uses the syntax
+// added in Go 1.13: binary and 0o-prefixed octal literals, hexadecimal
+// floating-point literals, and digit separators.
+func newNumberLiteral(lit string) bool {
+	if strings.Contains(lit, "_") {
+		return true
+	}
+	if len(lit) < 2 || lit[0] != '0' {
+		return false
+	}
+	switch lit[1] {
+	case 'b', 'B', 'o', 'O':
+		return true
+	case 'x', 'X':
+		return strings.ContainsAny(lit, "pP")
+	}
+	return false
+}
+
+// signedShiftCount reports whether the shift count x has a signed integer
+// type, which Go 1.13 was the first to allow. Untyped constants were always
+// allowed.
+func signedShiftCount(info *types.Info, x ast.Expr) bool {
+	if info == nil {
+		return false
+	}
+	tv, ok := info.Types[x]
+	if !ok || tv.Type == nil {
+		return false
+	}
+	basic, ok := tv.Type.Underlying().(*types.Basic)
+	if !ok || basic.Info()&types.IsUntyped != 0 {
+		return false
+	}
+	return basic.Info()&types.IsInteger != 0 && basic.Info()&types.IsUnsigned == 0
+}
+
+// GoDirectiveEdits returns the edits that set the go directive of the go.mod
+// file to version, adding one if there is none.
+func GoDirectiveEdits(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle, version string) ([]protocol.TextEdit, error) {
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	_, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return goDirectiveEdits(fh.URI(), m, version, snapshot.View().Options())
+}
+
+func goDirectiveEdits(uri span.URI, m *protocol.ColumnMapper, version string, options source.Options) ([]protocol.TextEdit, error) {
+	return rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		return copied.AddGoStmt(version)
+	})
+}
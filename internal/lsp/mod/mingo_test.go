@@ -0,0 +1,81 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestLanguageMinor(t *testing.T) {
+	for _, tt := range []struct {
+		name, src string
+		want      int
+	}{
+		{"plain", "var x = 0755 + 0x1F + 1.5e3", 0},
+		{"binary", "var x = 0b101", 13},
+		{"octal", "var x = 0o755", 13},
+		{"separator", "var x = 1_000_000", 13},
+		{"hex float", "var x = 0x1p-2", 13},
+		{"unsigned shift", "func f(x int, n uint) int { return x << n }", 0},
+		{"constant shift", "func f(x int) int { return x << 2 }", 0},
+		{"signed shift", "func f(x, n int) int { return x << n }", 13},
+		{"signed shift assign", "func f(x, n int) { x >>= n }", 13},
+		{"embed", "import _ \"embed\"\n\n//go:embed hello.txt\nvar s string", 16},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "p.go", "package p\n\n"+tt.src+"\n", parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := &types.Info{Types: make(map[ast.Expr]types.TypeAndValue)}
+			conf := types.Config{Importer: importer.Default(), Error: func(error) {}}
+			conf.Check("p", fset, []*ast.File{f}, info)
+			if got := languageMinor(f, info); got != tt.want {
+				t.Errorf("languageMinor(%q) = %d, want %d", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGoDirectiveEdits(t *testing.T) {
+	for _, tt := range []struct {
+		name, mod, want string
+	}{
+		{
+			name: "lower",
+			mod:  "module mod.com\n\ngo 1.14\n",
+			want: "module mod.com\n\ngo 1.13\n",
+		},
+		{
+			name: "missing",
+			mod:  "module mod.com\n",
+			want: "module mod.com\n\ngo 1.13\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			uri, m := testMapper(tt.mod)
+			edits, err := goDirectiveEdits(uri, m, "1.13", source.DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			diffEdits, err := source.FromProtocolEdits(m, edits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.ApplyEdits(tt.mod, diffEdits); got != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
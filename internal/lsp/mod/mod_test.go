@@ -5,12 +5,20 @@
 package mod
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
 	"golang.org/x/tools/internal/lsp/tests"
 	"golang.org/x/tools/internal/span"
 	"golang.org/x/tools/internal/testenv"
@@ -54,3 +62,727 @@ func TestModfileRemainsUnchanged(t *testing.T) {
 		t.Errorf("the real go.mod file was changed even when tempModfile=true")
 	}
 }
+
+func TestTidyTimeout(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+	// Hang when running `go mod tidy`.
+	defer fakeGoMod(t, "tidy", "exec sleep 60")()
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.TidyTimeout = 100 * time.Millisecond
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=")
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	// The checks that do not run `go mod tidy` are not subject to the
+	// timeout, so the redundant exclude is still reported.
+	const mod = "module unchanged\n\nexclude example.com/a v1.0.0\nexclude example.com/a v1.0.0\n"
+	if err := ioutil.WriteFile(filepath.Join(folder, "go.mod"), []byte(mod), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, snapshot, err := session.NewView(ctx, "diagnostics_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Finish loading the workspace, so that only `go mod tidy` can time out.
+	if _, err := snapshot.WorkspacePackages(ctx); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	reports, _, err := Diagnostics(ctx, snapshot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 30*time.Second {
+		t.Errorf("Diagnostics took %v, despite the timeout", elapsed)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	for _, diags := range reports {
+		var timeout, duplicate int
+		for _, diag := range diags {
+			switch diag.Source {
+			case tidyTimeoutCategory:
+				timeout++
+				if diag.Message != "go mod tidy timed out" {
+					t.Errorf("got message %q for the timeout", diag.Message)
+				}
+				if diag.Severity != protocol.SeverityInformation {
+					t.Errorf("got severity %v, want %v", diag.Severity, protocol.SeverityInformation)
+				}
+			case duplicateCategory:
+				duplicate++
+			}
+		}
+		if timeout != 1 || duplicate != 1 {
+			t.Fatalf("got diagnostics %v, want a timeout diagnostic and the redundant exclude", diags)
+		}
+	}
+}
+
+func TestTidyGoCommandError(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+	const stderr = "go: example.com/dep@v1.0.0: reading https://proxy.example.com/example.com/dep/@v/v1.0.0.mod: 401 Unauthorized"
+	defer fakeGoMod(t, "tidy", fmt.Sprintf("echo %q >&2\n\texit 1", stderr))()
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=")
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	_, snapshot, err := session.NewView(ctx, "diagnostics_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, _, err := Diagnostics(ctx, snapshot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	for _, diags := range reports {
+		if len(diags) != 1 {
+			t.Fatalf("got diagnostics %v, want a single go command diagnostic", diags)
+		}
+		diag := diags[0]
+		if want := "go mod tidy failed:\n" + stderr; diag.Message != want {
+			t.Errorf("got message %q, want %q", diag.Message, want)
+		}
+		if diag.Source != "go-command" {
+			t.Errorf("got source %q, want %q", diag.Source, "go-command")
+		}
+		if diag.Severity != protocol.SeverityError {
+			t.Errorf("got severity %v, want %v", diag.Severity, protocol.SeverityError)
+		}
+		if diag.Range.Start.Line != 0 {
+			t.Errorf("got diagnostic on line %v, want the module directive", diag.Range.Start.Line)
+		}
+	}
+}
+
+// fakeGoMod puts a fake go command on the PATH that runs the shell commands
+// script for `go mod subcommand`, and otherwise defers to the real go
+// command. It returns a function that restores the PATH.
+func fakeGoMod(t *testing.T, subcommand, script string) func() {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake go command is a shell script")
+	}
+	goCmd, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	bin, err := ioutil.TempDir("", "fakego")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := fmt.Sprintf(`#!/bin/sh
+if [ "$1" = mod ] && [ "$2" = %s ]; then
+	%s
+fi
+exec %q "$@"
+`, subcommand, script, goCmd)
+	if err := ioutil.WriteFile(filepath.Join(bin, "go"), []byte(fake), 0755); err != nil {
+		os.RemoveAll(bin)
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+	return func() {
+		os.Setenv("PATH", path)
+		os.RemoveAll(bin)
+	}
+}
+
+func TestModCacheOverride(t *testing.T) {
+	testenv.NeedsGo1Point(t, 15) // GOMODCACHE was added in Go 1.15
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	modCache, err := ioutil.TempDir("", "modcache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(modCache)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOMODCACHE="+os.TempDir())
+	options.ModCache = modCache
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	_, snapshot, err := session.NewView(ctx, "diagnostics_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The go commands run for go.mod diagnostics must use the override.
+	stdout, err := snapshot.RunGoCommand(ctx, "env", []string{"GOMODCACHE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(stdout.String()); got != modCache {
+		t.Errorf("got GOMODCACHE %q, want %q", got, modCache)
+	}
+}
+
+func TestDiagnosticsSeq(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOPROXY=off")
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	// Each unused requirement is diagnosed separately. The required modules
+	// are replaced with local directories, so that no network access is
+	// needed.
+	const mod = `module unchanged
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+)
+
+replace (
+	example.com/a => ./a
+	example.com/b => ./b
+	example.com/c => ./c
+)
+`
+	files := map[string]string{"go.mod": mod}
+	for _, dep := range []string{"a", "b", "c"} {
+		files[filepath.Join(dep, "go.mod")] = fmt.Sprintf("module example.com/%s\n", dep)
+	}
+	for name, contents := range files {
+		path := filepath.Join(folder, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, snapshot, err := session.NewView(ctx, "diagnostics_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, _, err := Diagnostics(ctx, snapshot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	var want []*source.Diagnostic
+	for _, diags := range reports {
+		want = diags
+	}
+	if len(want) != 3 {
+		t.Fatalf("got %d diagnostics, want 3: %v", len(want), want)
+	}
+
+	var got []*source.Diagnostic
+	id, _, err := DiagnosticsSeq(ctx, snapshot, func(diag *source.Diagnostic) bool {
+		got = append(got, diag)
+		return len(got) < 2
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.URI != span.URIFromPath(filepath.Join(folder, "go.mod")) {
+		t.Errorf("got diagnostics for %s, want the go.mod file", id.URI)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d diagnostics after stopping at the second, want 2", len(got))
+	}
+	messages := make(map[string]bool)
+	for _, diag := range want {
+		messages[diag.Message] = true
+	}
+	for _, diag := range got {
+		if !messages[diag.Message] {
+			t.Errorf("got unexpected diagnostic %q", diag.Message)
+		}
+	}
+}
+
+func TestRangeDiagnostics(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOPROXY=off")
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	// The unused requirement is diagnosed by tidy, and the duplicate
+	// exclude by inspecting the file.
+	const mod = `module unchanged
+
+go 1.14
+
+require example.com/a v1.0.0
+
+replace example.com/a => ./a
+
+exclude (
+	example.com/x v1.0.0
+	example.com/x v1.0.0
+)
+`
+	files := map[string]string{
+		"go.mod":                     mod,
+		filepath.Join("a", "go.mod"): "module example.com/a\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(folder, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, snapshot, err := session.NewView(ctx, "diagnostics_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources := func(reports map[source.FileIdentity][]*source.Diagnostic) map[string]bool {
+		got := make(map[string]bool)
+		for _, diags := range reports {
+			for _, diag := range diags {
+				got[diag.Source] = true
+			}
+		}
+		return got
+	}
+	reports, _, err := Diagnostics(ctx, snapshot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all := sources(reports); !all["go mod tidy"] || !all[duplicateCategory] {
+		t.Fatalf("got diagnostics from %v, want tidy and duplicate exclude diagnostics", all)
+	}
+
+	// The first lines only hold the module and go directives, but the tidy
+	// diagnostics are returned wherever they are.
+	top := protocol.Range{End: protocol.Position{Line: 2}}
+	reports, _, err = RangeDiagnostics(ctx, snapshot, top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sources(reports); !got["go mod tidy"] || got[duplicateCategory] {
+		t.Errorf("got diagnostics from %v in the first lines, want only tidy diagnostics", got)
+	}
+
+	excludes := protocol.Range{Start: protocol.Position{Line: 8}, End: protocol.Position{Line: 11}}
+	reports, _, err = RangeDiagnostics(ctx, snapshot, excludes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sources(reports); !got[duplicateCategory] {
+		t.Errorf("got diagnostics from %v in the exclude block, want the duplicate exclude", got)
+	}
+}
+
+func TestCheckTidy(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	for _, test := range []struct {
+		name             string
+		mod, sum         string
+		wantMod, wantSum bool
+	}{
+		{
+			name: "clean",
+			mod:  "module unchanged\n\ngo 1.14\n",
+		},
+		{
+			name:    "unused require",
+			mod:     "module unchanged\n\ngo 1.14\n\nrequire example.com/a v1.0.0\n\nreplace example.com/a => ./a\n",
+			wantMod: true,
+		},
+		{
+			name:    "stale go.sum",
+			mod:     "module unchanged\n\ngo 1.14\n",
+			sum:     "example.com/x v1.0.0/go.mod h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=\n",
+			wantSum: true,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := tests.Context(t)
+			cache := cache.New(ctx, nil)
+			session := cache.NewSession(ctx)
+			options := tests.DefaultOptions()
+			options.TempModfile = true
+			options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOPROXY=off")
+
+			folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(folder)
+			files := map[string]string{
+				"go.mod":                     test.mod,
+				filepath.Join("a", "go.mod"): "module example.com/a\n",
+			}
+			if test.sum != "" {
+				files["go.sum"] = test.sum
+			}
+			for name, contents := range files {
+				path := filepath.Join(folder, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, snapshot, err := session.NewView(ctx, "check_tidy_test", span.URIFromPath(folder), options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clean, modDiff, sumDiff, err := CheckTidy(ctx, snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := !test.wantMod && !test.wantSum; clean != want {
+				t.Errorf("got clean %v, want %v", clean, want)
+			}
+			if got := len(modDiff) > 0; got != test.wantMod {
+				t.Errorf("got go.mod diff %q, want a diff: %v", modDiff, test.wantMod)
+			}
+			if got := len(sumDiff) > 0; got != test.wantSum {
+				t.Errorf("got go.sum diff %q, want a diff: %v", sumDiff, test.wantSum)
+			}
+			if test.wantMod && !strings.Contains(string(modDiff), "-require example.com/a v1.0.0") {
+				t.Errorf("go.mod diff does not remove the unused requirement:\n%s", modDiff)
+			}
+		})
+	}
+}
+
+func TestDiagnosticsFixableOnly(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.IndirectRequireThreshold = 1
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOPROXY=off")
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	// Both requirements are unused, which tidy fixes, and there are more
+	// indirect requirements than the threshold, which has no fix.
+	const mod = `module unchanged
+
+go 1.14
+
+require (
+	example.com/a v1.0.0 // indirect
+	example.com/b v1.0.0 // indirect
+)
+
+replace (
+	example.com/a => ./a
+	example.com/b => ./b
+)
+`
+	files := map[string]string{"go.mod": mod}
+	for _, dep := range []string{"a", "b"} {
+		files[filepath.Join(dep, "go.mod")] = fmt.Sprintf("module example.com/%s\n", dep)
+	}
+	for name, contents := range files {
+		path := filepath.Join(folder, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, snapshot, err := session.NewView(ctx, "diagnostics_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := func(fixableOnly bool) (int, map[string]int) {
+		reports, _, err := Diagnostics(ctx, snapshot, fixableOnly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources := make(map[string]int)
+		var n int
+		for _, diags := range reports {
+			for _, diag := range diags {
+				sources[diag.Source]++
+				n++
+			}
+		}
+		return n, sources
+	}
+	if n, sources := count(false); n != 3 || sources[indirectCategory] != 1 {
+		t.Fatalf("got %d diagnostics from %v, want 3, one of them for the indirect requirements", n, sources)
+	}
+	if n, sources := count(true); n != 2 || sources["go mod tidy"] != 2 {
+		t.Errorf("got %d fixable diagnostics from %v, want the 2 from go mod tidy", n, sources)
+	}
+}
+
+func TestHealthSummary(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	for _, test := range []struct {
+		name string
+		mod  string
+		want ModHealth
+	}{
+		{
+			name: "clean",
+			mod:  "module unchanged\n\ngo 1.14\n",
+			want: ModHealth{Tidied: true, TidyClean: true},
+		},
+		{
+			name: "unused require",
+			mod:  "module unchanged\n\ngo 1.14\n\nrequire example.com/a v1.0.0\n\nreplace example.com/a => ./a\n",
+			want: ModHealth{Tidied: true, Unused: 1},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := tests.Context(t)
+			cache := cache.New(ctx, nil)
+			session := cache.NewSession(ctx)
+			options := tests.DefaultOptions()
+			options.TempModfile = true
+			options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOPROXY=off")
+
+			folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(folder)
+			files := map[string]string{
+				"go.mod":                     test.mod,
+				filepath.Join("a", "go.mod"): "module example.com/a\n",
+			}
+			for name, contents := range files {
+				path := filepath.Join(folder, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			_, snapshot, err := session.NewView(ctx, "health_summary_test", span.URIFromPath(folder), options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			health, err := HealthSummary(ctx, snapshot, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if health == nil {
+				t.Fatal("got no health summary")
+			}
+			test.want.File = snapshot.View().ModFile()
+			if *health != test.want {
+				t.Errorf("got health %+v, want %+v", *health, test.want)
+			}
+		})
+	}
+}
+
+func TestTidyCounts(t *testing.T) {
+	_, _, original := parseTestMod(t, "module mod.com\n\nrequire (\n\texample.com/a v1.0.0\n\texample.com/b v1.0.0 // indirect\n)\n")
+	_, _, ideal := parseTestMod(t, "module mod.com\n\nrequire (\n\texample.com/b v1.0.0\n\texample.com/c v1.0.0\n\texample.com/d v1.0.0 // indirect\n)\n")
+	if missing, unused := tidyCounts(original, ideal); missing != 2 || unused != 1 {
+		t.Errorf("got %d missing and %d unused requirements, want 2 and 1", missing, unused)
+	}
+}
+
+func TestTidyChangeEdits(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/old v1.0.0
+	example.com/kept v1.0.0 // indirect
+)
+`
+	uri, m, original := parseTestMod(t, mod)
+	_, _, ideal := parseTestMod(t, `module mod.com
+
+go 1.14
+
+require (
+	example.com/kept v1.1.0
+	example.com/new v1.0.0
+	example.com/newindirect v1.0.0 // indirect
+)
+`)
+	for _, test := range []struct {
+		kind ChangeKind
+		want string
+	}{
+		{Added, `module mod.com
+
+go 1.14
+
+require (
+	example.com/old v1.0.0
+	example.com/kept v1.0.0 // indirect
+	example.com/new v1.0.0
+	example.com/newindirect v1.0.0 // indirect
+)
+`},
+		{Removed, `module mod.com
+
+go 1.14
+
+require example.com/kept v1.0.0 // indirect
+`},
+	} {
+		t.Run(test.kind.String(), func(t *testing.T) {
+			edits, err := tidyChangeEdits(uri, m, original, ideal, test.kind, source.DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			spanEdits, err := source.FromProtocolEdits(m, edits)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.ApplyEdits(mod, spanEdits); got != test.want {
+				t.Errorf("got\n%s\nwant\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSuggestedFixesDiagnosed(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=", "GOPROXY=off")
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	const mod = `module unchanged
+
+go 1.14
+
+exclude example.com/a v1.0.0
+
+exclude example.com/a v1.0.0
+`
+	if err := ioutil.WriteFile(filepath.Join(folder, "go.mod"), []byte(mod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, snapshot, err := session.NewView(ctx, "diagnostics_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, _, err := Diagnostics(ctx, snapshot, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var diags []protocol.Diagnostic
+	for _, report := range reports {
+		for _, diag := range report {
+			if diag.Source == duplicateCategory {
+				diags = append(diags, protocol.Diagnostic{Range: diag.Range, Message: diag.Message, Source: diag.Source})
+			}
+		}
+	}
+	if len(diags) != 1 {
+		t.Fatalf("got %d redundant exclude diagnostics, want 1", len(diags))
+	}
+	fh, err := snapshot.GetFile(ctx, snapshot.View().ModFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fixes are taken from the diagnosed errors, not computed again.
+	fake := source.Error{
+		Category:       duplicateCategory,
+		Message:        diags[0].Message,
+		Range:          diags[0].Range,
+		URI:            fh.URI(),
+		SuggestedFixes: []source.SuggestedFix{{Title: "Diagnosed fix", Edits: map[span.URI][]protocol.TextEdit{fh.URI(): nil}}},
+	}
+	storeDiagnosed(fh.Identity(), []source.Error{fake})
+	actions, err := SuggestedFixes(ctx, snapshot, fh, diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Title != "Diagnosed fix" {
+		t.Errorf("got actions %v, want the diagnosed fix", actions)
+	}
+
+	// If this version of the file was not diagnosed, the checks that do not
+	// need the go command are run.
+	storeDiagnosed(source.FileIdentity{URI: fh.URI()}, nil)
+	actions, err = SuggestedFixes(ctx, snapshot, fh, diags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 1 || actions[0].Title == "Diagnosed fix" {
+		t.Errorf("got actions %v, want the fix of the redundant exclude", actions)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// Normalize returns the edits that make only cosmetic changes to the go.mod
+// file: sorting the lines of each block, canonicalizing versions, spelling
+// every indirect comment as "// indirect", and formatting the file. The
+// build is unaffected, and applying the edits a second time changes nothing.
+// Files that cannot be parsed are left alone.
+func Normalize(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "mod.Normalize", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	_, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return normalizeEdits(fh.URI(), m, snapshot.View().Options())
+}
+
+func normalizeEdits(uri span.URI, m *protocol.ColumnMapper, options source.Options) ([]protocol.TextEdit, error) {
+	// The go.mod parser canonicalizes versions as it parses them, and the
+	// remaining transforms are applied in a fixed order, so that the result
+	// is deterministic.
+	return rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		copied.SortBlocks()
+		for _, req := range copied.Require {
+			if req.Indirect && req.Syntax != nil {
+				normalizeIndirectComment(req.Syntax)
+			}
+		}
+		return nil
+	})
+}
+
+// normalizeIndirectComment rewrites the indirect comment of line, which may
+// be written as "//indirect" or with extra spaces, as "// indirect", keeping
+// any text that follows it.
+func normalizeIndirectComment(line *modfile.Line) {
+	if len(line.Suffix) == 0 {
+		return
+	}
+	com := &line.Suffix[0]
+	text := strings.TrimSpace(strings.TrimPrefix(com.Token, "//"))
+	switch {
+	case text == "indirect":
+		com.Token = "// indirect"
+	case strings.HasPrefix(text, "indirect;"):
+		rest := strings.TrimSpace(strings.TrimPrefix(text, "indirect;"))
+		if rest == "" {
+			com.Token = "// indirect"
+		} else {
+			com.Token = "// indirect; " + rest
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestNormalize(t *testing.T) {
+	const before = `module mod.com
+
+go 1.14
+
+require (
+	example.com/c v1.2
+	example.com/a v1.0.0 //indirect
+	example.com/b v1.1.0   //   indirect; used by tools
+)
+
+exclude (
+	example.com/z v1.0.0
+	example.com/y v1.0.0
+)
+`
+	const want = `module mod.com
+
+go 1.14
+
+require (
+	example.com/a v1.0.0 // indirect
+	example.com/b v1.1.0 // indirect; used by tools
+	example.com/c v1.2.0
+)
+
+exclude (
+	example.com/y v1.0.0
+	example.com/z v1.0.0
+)
+`
+	options := source.DefaultOptions()
+	normalize := func(content string) string {
+		t.Helper()
+		uri, m := testMapper(content)
+		edits, err := normalizeEdits(uri, m, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffEdits, err := source.FromProtocolEdits(m, edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return diff.ApplyEdits(content, diffEdits)
+	}
+	got := normalize(before)
+	if got != want {
+		t.Fatalf("normalized go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if again := normalize(got); again != got {
+		t.Errorf("normalizing twice changed the file:\n%s", again)
+	}
+}
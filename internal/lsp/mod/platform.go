@@ -0,0 +1,102 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const (
+	platformCategory    = "unused on platform"
+	constrainedCategory = "constrained on platform"
+)
+
+// platformErrors reports the direct requirements in file that provide no
+// package to the build, including tests, of the main module's packages for
+// platform, a GOOS/GOARCH pair. Requirements whose imported packages are all
+// excluded by build constraints on the platform are told apart from those
+// that are not imported at all. `go mod tidy` keeps the requirements needed
+// on any platform, so these are not reported otherwise. If the go command
+// fails, no errors are reported.
+func platformErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File, platform string) ([]source.Error, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid platform %q", platform)
+	}
+	env := []string{"GOOS=" + parts[0], "GOARCH=" + parts[1]}
+	// Packages whose files are all excluded are still listed, with no Go
+	// files, but their imports are not followed.
+	args := []string{"-e", "-deps", "-test", "-f", "{{with .Module}}{{.Path}} {{len $.GoFiles}} {{len $.CgoFiles}}{{end}}", "./..."}
+	stdout, err := snapshot.RunGoCommandEnv(ctx, env, "list", args)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "listing dependencies for "+platform, err)
+		return nil, nil
+	}
+	used, constrained := platformModules(stdout)
+	return unusedOnPlatformErrors(uri, m, file, platform, used, constrained)
+}
+
+// platformModules returns the modules listed in r, the output of the go list
+// command run by platformErrors, that provide a package with Go files to the
+// build, and those whose listed packages all have none, because their files
+// are excluded by build constraints.
+func platformModules(r io.Reader) (used, constrained map[string]bool) {
+	used, constrained = make(map[string]bool), make(map[string]bool)
+	for scanner := bufio.NewScanner(r); scanner.Scan(); {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		if path := fields[0]; fields[1] != "0" || fields[2] != "0" {
+			used[path] = true
+			delete(constrained, path)
+		} else if !used[path] {
+			constrained[path] = true
+		}
+	}
+	return used, constrained
+}
+
+// unusedOnPlatformErrors reports the direct requirements in file whose
+// modules are not in used, the set of modules providing packages to the
+// build for platform. Those in constrained, whose imported packages are all
+// excluded by build constraints, are reported as such.
+func unusedOnPlatformErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, platform string, used, constrained map[string]bool) ([]source.Error, error) {
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Indirect || req.Syntax == nil || used[req.Mod.Path] {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		e := source.Error{
+			Category: platformCategory,
+			Message:  fmt.Sprintf("%s is not used when building for %s.", req.Mod.Path, platform),
+			Range:    rng,
+			URI:      uri,
+		}
+		if constrained[req.Mod.Path] {
+			e.Category = constrainedCategory
+			e.Message = fmt.Sprintf("%s is imported, but build constraints exclude all of its imported packages' files when building for %s, so it is only needed on other platforms.", req.Mod.Path, platform)
+		}
+		errors = append(errors, e)
+	}
+	return errors, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlatformModules(t *testing.T) {
+	const listed = `mod.com 2 0
+example.com/everywhere 1 0
+example.com/winonly 0 0
+example.com/cgo 0 1
+example.com/mixed 0 0
+example.com/mixed 3 0
+`
+	used, constrained := platformModules(strings.NewReader(listed))
+	if want := map[string]bool{"mod.com": true, "example.com/everywhere": true, "example.com/cgo": true, "example.com/mixed": true}; !reflect.DeepEqual(used, want) {
+		t.Errorf("got used modules %v, want %v", used, want)
+	}
+	if want := map[string]bool{"example.com/winonly": true}; !reflect.DeepEqual(constrained, want) {
+		t.Errorf("got constrained modules %v, want %v", constrained, want)
+	}
+}
+
+func TestUnusedOnPlatformErrors(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/everywhere v1.0.0
+	example.com/windowsonly v1.0.0
+	example.com/constrained v1.0.0
+	example.com/indirect v1.0.0 // indirect
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	used := map[string]bool{"mod.com": true, "example.com/everywhere": true}
+	constrained := map[string]bool{"example.com/constrained": true}
+	errors, err := unusedOnPlatformErrors(uri, m, file, "linux/arm64", used, constrained)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 2 {
+		t.Fatalf("got %d errors, want 2: %v", len(errors), errors)
+	}
+	if want := "example.com/windowsonly is not used when building for linux/arm64."; errors[0].Message != want {
+		t.Errorf("got message %q, want %q", errors[0].Message, want)
+	}
+	if got := rangeText(t, m, errors[0].Range); got != "example.com/windowsonly v1.0.0" {
+		t.Errorf("got range covering %q, want the requirement", got)
+	}
+	if errors[1].Category != constrainedCategory {
+		t.Errorf("got category %q for the constrained requirement, want %q", errors[1].Category, constrainedCategory)
+	}
+	if want := "example.com/constrained is imported, but build constraints exclude all of its imported packages' files when building for linux/arm64, so it is only needed on other platforms."; errors[1].Message != want {
+		t.Errorf("got message %q, want %q", errors[1].Message, want)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const policyCategory = "module policy"
+
+// checkModulePolicy reports the module paths in the require and replace
+// directives that options.DeniedModules forbids, or that none of
+// options.AllowedModules permits, if it is set. Directory replacements are
+// not checked, as they do not name a module to download. If
+// options.ResolveVanityPaths is set, the paths are checked by
+// vanityPolicyErrors instead.
+func checkModulePolicy(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options) ([]source.Error, error) {
+	if options.ResolveVanityPaths {
+		return nil, nil
+	}
+	return modulePolicyErrors(uri, m, file, options, nil)
+}
+
+// modulePolicyErrors reports the module paths in file that the policy of
+// options does not permit, as described for checkModulePolicy. If repos is
+// not nil, a path that is only reported because AllowedModules does not
+// permit it is judged by the repository that repos maps it to instead, and
+// is not reported if it has none.
+func modulePolicyErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, options source.Options, repos map[string]string) ([]source.Error, error) {
+	if len(options.AllowedModules) == 0 && len(options.DeniedModules) == 0 {
+		return nil, nil
+	}
+	var errors []source.Error
+	report := func(line *modfile.Line, tok int, path string) error {
+		msg := policyViolation(path, options.AllowedModules, options.DeniedModules)
+		if msg == "" {
+			return nil
+		}
+		if repos != nil && onlyNotAllowed(path, options) {
+			if repo, ok := repos[path]; !ok || policyViolation(repo, options.AllowedModules, nil) == "" {
+				return nil
+			}
+		}
+		rng, err := tokenRange(uri, m, line, tok)
+		if err != nil {
+			return err
+		}
+		errors = append(errors, source.Error{
+			Category: policyCategory,
+			Message:  msg,
+			Range:    rng,
+			URI:      uri,
+		})
+		return nil
+	}
+	for _, req := range file.Require {
+		if req.Syntax == nil {
+			continue
+		}
+		if err := report(req.Syntax, len(req.Syntax.Token)-2, req.Mod.Path); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range file.Replace {
+		if r.Syntax == nil {
+			continue
+		}
+		arrow := arrowIndex(r.Syntax)
+		if arrow < 0 {
+			continue
+		}
+		old := arrow - 1
+		if r.Old.Version != "" {
+			old--
+		}
+		if err := report(r.Syntax, old, r.Old.Path); err != nil {
+			return nil, err
+		}
+		if modfile.IsDirectoryPath(r.New.Path) {
+			continue
+		}
+		if err := report(r.Syntax, arrow+1, r.New.Path); err != nil {
+			return nil, err
+		}
+	}
+	return errors, nil
+}
+
+// policyViolation returns the reason that the module path is not permitted
+// by the allowed and denied prefixes, or "" if it is. Denied prefixes take
+// precedence over allowed ones.
+func policyViolation(path string, allowed, denied []string) string {
+	for _, prefix := range denied {
+		if hasPathPrefix(path, prefix) {
+			return fmt.Sprintf("%s is denied by the deniedModules setting (%s).", path, prefix)
+		}
+	}
+	if len(allowed) == 0 {
+		return ""
+	}
+	for _, prefix := range allowed {
+		if hasPathPrefix(path, prefix) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("%s is not permitted by the allowedModules setting.", path)
+}
+
+// onlyNotAllowed reports whether the module path violates the policy of
+// options only because AllowedModules does not permit it.
+func onlyNotAllowed(path string, options source.Options) bool {
+	return policyViolation(path, nil, options.DeniedModules) == "" &&
+		policyViolation(path, options.AllowedModules, nil) != ""
+}
+
+// hasPathPrefix reports whether the module path is prefix or is inside it,
+// element by element, so that example.com/a does not match example.com/ab.
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return false
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
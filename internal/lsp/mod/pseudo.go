@@ -0,0 +1,127 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const stalePseudoCategory = "stale pseudo-version"
+
+// latestModule is the subset of the output of `go list -m -json path@latest`
+// that describes the latest version of a module.
+type latestModule struct {
+	Path    string
+	Version string
+	Time    *time.Time
+	Error   *struct{ Err string }
+}
+
+// stalePseudoVersionErrors reports requirements on a pseudo-version whose
+// commit is more than age older than the latest version of the module. A
+// pseudo-version does not record the branch it was taken from, so it is
+// compared with the module's latest version, as resolved by the go command:
+// the latest release if there is one, and otherwise the latest commit on the
+// default branch. Resolving it may require network access, so if the go
+// command fails, no errors are reported.
+func stalePseudoVersionErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File, age time.Duration) ([]source.Error, error) {
+	var queries []string
+	for _, req := range file.Require {
+		if isPseudoVersion(req.Mod.Version) {
+			queries = append(queries, req.Mod.Path+"@latest")
+		}
+	}
+	if len(queries) == 0 {
+		return nil, nil
+	}
+	args := append([]string{"-e", "-m", "-json"}, queries...)
+	stdout, err := snapshot.RunGoCommand(ctx, "list", args)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "listing latest versions", err)
+		return nil, nil
+	}
+	latest := make(map[string]latestModule)
+	for dec := json.NewDecoder(stdout); dec.More(); {
+		var mod latestModule
+		if err := dec.Decode(&mod); err != nil {
+			return nil, err
+		}
+		if mod.Error == nil && mod.Time != nil {
+			latest[mod.Path] = mod
+		}
+	}
+	return staleErrors(uri, m, file, latest, age)
+}
+
+// staleErrors reports the pseudo-version requirements in file whose
+// timestamp is more than age older than the time of their module's latest
+// version.
+func staleErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, latest map[string]latestModule, age time.Duration) ([]source.Error, error) {
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Syntax == nil || !isPseudoVersion(req.Mod.Version) {
+			continue
+		}
+		mod, ok := latest[req.Mod.Path]
+		if !ok || mod.Version == req.Mod.Version {
+			continue
+		}
+		pinned, err := pseudoVersionTime(req.Mod.Version)
+		if err != nil {
+			continue
+		}
+		if mod.Time.Sub(pinned) <= age {
+			continue
+		}
+		rng, err := tokenRange(uri, m, req.Syntax, len(req.Syntax.Token)-1)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: stalePseudoCategory,
+			Message: fmt.Sprintf("%s %s is a commit from %s, but the latest version, %s, is from %s.",
+				req.Mod.Path, req.Mod.Version, pinned.Format("2006-01-02"), mod.Version, mod.Time.UTC().Format("2006-01-02")),
+			Range: rng,
+			URI:   uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Update to %s %s", req.Mod.Path, mod.Version),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: rng, NewText: mod.Version}},
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// pseudoVersionTime returns the commit time recorded in the pseudo-version v,
+// such as 2020-06-01 12:00:00 UTC for v0.0.0-20200601120000-abcdefabcdef.
+func pseudoVersionTime(v string) (time.Time, error) {
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	// The timestamp is the last dot- or dash-separated element before the
+	// revision.
+	j := strings.LastIndex(v, "-")
+	if j < 0 {
+		return time.Time{}, fmt.Errorf("%s is not a pseudo-version", v)
+	}
+	rest := v[:j]
+	i := strings.LastIndexAny(rest, ".-")
+	return time.Parse("20060102150405", rest[i+1:])
+}
@@ -0,0 +1,71 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPseudoVersionTime(t *testing.T) {
+	want := time.Date(2020, 6, 1, 12, 30, 0, 0, time.UTC)
+	for _, v := range []string{
+		"v0.0.0-20200601123000-abcdefabcdef",
+		"v1.2.4-0.20200601123000-abcdefabcdef",
+		"v1.2.3-pre.0.20200601123000-abcdefabcdef",
+		"v2.0.0-20200601123000-abcdefabcdef+incompatible",
+	} {
+		got, err := pseudoVersionTime(v)
+		if err != nil {
+			t.Errorf("pseudoVersionTime(%q): %v", v, err)
+			continue
+		}
+		if !got.Equal(want) {
+			t.Errorf("pseudoVersionTime(%q) = %v, want %v", v, got, want)
+		}
+	}
+}
+
+func TestStaleErrors(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/stale v0.0.0-20200101000000-abcdefabcdef
+	example.com/recent v0.0.0-20200501000000-abcdefabcdef
+	example.com/current v0.0.0-20200101000000-abcdefabcdef
+	example.com/release v1.0.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	at := func(year int, month time.Month) *time.Time {
+		t := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		return &t
+	}
+	latest := map[string]latestModule{
+		"example.com/stale":   {Path: "example.com/stale", Version: "v1.1.0", Time: at(2020, 6)},
+		"example.com/recent":  {Path: "example.com/recent", Version: "v0.0.0-20200601000000-123456123456", Time: at(2020, 6)},
+		"example.com/current": {Path: "example.com/current", Version: "v0.0.0-20200101000000-abcdefabcdef", Time: at(2020, 1)},
+		"example.com/release": {Path: "example.com/release", Version: "v1.2.0", Time: at(2020, 6)},
+	}
+	errors, err := staleErrors(uri, m, file, latest, 90*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if got := rangeText(t, m, e.Range); got != "v0.0.0-20200101000000-abcdefabcdef" {
+		t.Errorf("got range covering %q, want the stale version", got)
+	}
+	if want := "example.com/stale v0.0.0-20200101000000-abcdefabcdef is a commit from 2020-01-01, but the latest version, v1.1.0, is from 2020-06-01."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if len(e.SuggestedFixes) != 1 || e.SuggestedFixes[0].Title != "Update to example.com/stale v1.1.0" {
+		t.Errorf("got fixes %v, want an update to v1.1.0", e.SuggestedFixes)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// RegenerateSumCommand returns the command that rebuilds the go.sum file of
+// the go.mod file uri from scratch. Its title warns that every line of the
+// go.sum file is replaced.
+func RegenerateSumCommand(uri span.URI) *protocol.Command {
+	return &protocol.Command{
+		Title:     "Regenerate go.sum (rebuilds the whole file)",
+		Command:   source.CommandRegenerateSum,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri)},
+	}
+}
+
+// RegenerateSum returns the edit that replaces the go.sum file of the view's
+// go.mod file with the one `go mod download all` writes, starting from no
+// go.sum file at all. This recovers from a corrupted go.sum file, at the cost
+// of downloading every module in the build list, and of dropping the
+// checksums that no longer apply, which may make the go command download
+// them again later. The command runs on copies of go.mod and go.sum, so
+// neither file is modified on disk.
+func RegenerateSum(ctx context.Context, snapshot source.Snapshot) (protocol.WorkspaceEdit, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return protocol.WorkspaceEdit{}, errors.Errorf("no go.mod file for %s", snapshot.View().Folder())
+	}
+	ctx, done := event.Start(ctx, "mod.RegenerateSum", tag.URI.Of(uri))
+	defer done()
+
+	modFH, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	content, err := modFH.Read()
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	dir, err := ioutil.TempDir("", "gopls-sum")
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	defer os.RemoveAll(dir)
+	tmpMod := filepath.Join(dir, "go.mod")
+	if err := ioutil.WriteFile(tmpMod, content, 0644); err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	if err := snapshot.RunGoCommandDirect(ctx, "mod", []string{"download", "-modfile=" + tmpMod, "all"}); err != nil {
+		return protocol.WorkspaceEdit{}, errors.Errorf("regenerating go.sum: %w", err)
+	}
+	sum, err := ioutil.ReadFile(filepath.Join(dir, "go.sum"))
+	if err != nil && !os.IsNotExist(err) {
+		return protocol.WorkspaceEdit{}, err
+	}
+
+	sumURI := span.URIFromPath(strings.TrimSuffix(uri.Filename(), ".mod") + ".sum")
+	sumFH, err := snapshot.GetFile(ctx, sumURI)
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	// A missing go.sum file is created by editing it, as InitModFile does.
+	old, _ := sumFH.Read()
+	m := &protocol.ColumnMapper{
+		URI:       sumURI,
+		Converter: span.NewContentConverter(sumURI.Filename(), old),
+		Content:   old,
+	}
+	diff := snapshot.View().Options().ComputeEdits(sumURI, string(old), string(sum))
+	edits, err := source.ToProtocolEdits(m, diff)
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	return protocol.WorkspaceEdit{
+		DocumentChanges: []protocol.TextDocumentEdit{{
+			TextDocument: protocol.VersionedTextDocumentIdentifier{
+				Version: sumFH.Version(),
+				TextDocumentIdentifier: protocol.TextDocumentIdentifier{
+					URI: protocol.URIFromSpanURI(sumURI),
+				},
+			},
+			Edits: edits,
+		}},
+	}, nil
+}
+
+// RegenerateSumArgs returns the go.mod file of a command returned by
+// RegenerateSumCommand, as sent back by the client.
+func RegenerateSumArgs(args []interface{}) (span.URI, error) {
+	return uriArgs(args)
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestRegenerateSum(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+	const (
+		stale       = "example.com/stale v1.0.0 h1:c3RhbGU=\nexample.com/dep v1.0.0/go.mod h1:Y29ycnVwdA==\n"
+		regenerated = "example.com/dep v1.0.0 h1:ZGVw\nexample.com/dep v1.0.0/go.mod h1:Z29tb2Q=\n"
+	)
+	// Write the go.sum file next to the -modfile, as the go command does.
+	defer fakeGoMod(t, "download", `for arg; do
+		case "$arg" in -modfile=*) modfile="${arg#-modfile=}";; esac
+	done
+	printf '`+regenerated+`' > "${modfile%.mod}.sum"
+	exit 0`)()
+
+	ctx := tests.Context(t)
+	cache := cache.New(ctx, nil)
+	session := cache.NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.TempModfile = true
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOROOT=")
+
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	sumFile := filepath.Join(folder, "go.sum")
+	if err := ioutil.WriteFile(sumFile, []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, snapshot, err := session.NewView(ctx, "regenerate_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit, err := RegenerateSum(ctx, snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edit.DocumentChanges) != 1 {
+		t.Fatalf("got %d document changes, want 1", len(edit.DocumentChanges))
+	}
+	change := edit.DocumentChanges[0]
+	if got := change.TextDocument.URI.SpanURI(); got != span.URIFromPath(sumFile) {
+		t.Errorf("got edit of %s, want %s", got, sumFile)
+	}
+	m := &protocol.ColumnMapper{
+		URI:       span.URIFromPath(sumFile),
+		Converter: span.NewContentConverter(sumFile, []byte(stale)),
+		Content:   []byte(stale),
+	}
+	edits, err := source.FromProtocolEdits(m, change.Edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(stale, edits); got != regenerated {
+		t.Errorf("got go.sum\n%s\nwant\n%s", got, regenerated)
+	}
+	if onDisk, err := ioutil.ReadFile(sumFile); err != nil || string(onDisk) != stale {
+		t.Errorf("the go.sum file on disk was changed to %q (%v)", onDisk, err)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+const staleImportCategory = "stale import"
+
+// A moduleImport is an import spec of a file of the main module.
+type moduleImport struct {
+	URI   span.URI
+	Range protocol.Range
+	Path  string
+
+	// Resolved reports whether the imported package was found.
+	Resolved bool
+}
+
+// moduleImports returns the imports of the files of the workspace packages
+// of the main module, each listed once, along with the directories of the
+// packages, relative to the module root, as "" or "/dir".
+func moduleImports(ctx context.Context, snapshot source.Snapshot, modulePath string) ([]moduleImport, map[string]bool, error) {
+	wsPackages, err := snapshot.WorkspacePackages(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	fset := snapshot.View().Session().Cache().FileSet()
+	dirs := make(map[string]bool)
+	seen := make(map[span.URI]bool)
+	var imports []moduleImport
+	for _, ph := range wsPackages {
+		pkg, err := ph.Check(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		// The packages of external tests have the path of the tested
+		// package, followed by _test.
+		pkgPath := strings.TrimSuffix(pkg.PkgPath(), "_test")
+		if pkgPath != modulePath && !strings.HasPrefix(pkgPath, modulePath+"/") {
+			continue
+		}
+		dirs[strings.TrimPrefix(pkgPath, modulePath)] = true
+		for _, pgh := range pkg.CompiledGoFiles() {
+			uri := pgh.File().URI()
+			if seen[uri] {
+				continue
+			}
+			seen[uri] = true
+			f, _, m, _, err := pgh.Parse(ctx)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, spec := range f.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				spn, err := span.NewRange(fset, spec.Path.Pos(), spec.Path.End()).Span()
+				if err != nil {
+					return nil, nil, err
+				}
+				rng, err := m.Range(spn)
+				if err != nil {
+					return nil, nil, err
+				}
+				imp, err := pkg.GetImport(path)
+				imports = append(imports, moduleImport{
+					URI:      uri,
+					Range:    rng,
+					Path:     path,
+					Resolved: err == nil && len(imp.CompiledGoFiles()) > 0,
+				})
+			}
+		}
+	}
+	return imports, dirs, nil
+}
+
+// staleImports returns the imports that still use oldPath, a previous path
+// of the main module: the unresolved imports of oldPath itself or of oldPath
+// followed by the directory of one of the module's packages.
+func staleImports(file *modfile.File, oldPath string, imports []moduleImport, dirs map[string]bool) []moduleImport {
+	modulePath := file.Module.Mod.Path
+	var unresolved []moduleImport
+	for _, imp := range imports {
+		if imp.Resolved || requiredModule(file, imp.Path) != "" {
+			continue
+		}
+		if imp.Path == modulePath || strings.HasPrefix(imp.Path, modulePath+"/") {
+			continue
+		}
+		unresolved = append(unresolved, imp)
+	}
+	var stale []moduleImport
+	for _, imp := range unresolved {
+		if imp.Path == oldPath || strings.HasPrefix(imp.Path, oldPath+"/") && dirs[strings.TrimPrefix(imp.Path, oldPath)] {
+			stale = append(stale, imp)
+		}
+	}
+	sortImports(stale)
+	return stale
+}
+
+// sortImports sorts imports by file, and by position within each file.
+func sortImports(imports []moduleImport) {
+	sort.Slice(imports, func(i, j int) bool {
+		if imports[i].URI != imports[j].URI {
+			return imports[i].URI < imports[j].URI
+		}
+		return protocol.ComparePosition(imports[i].Range.Start, imports[j].Range.Start) < 0
+	})
+}
+
+// staleImportErrors reports, on the module directive, the imports that use
+// oldPath, the previous path of the main module, with a fix that rewrites
+// them. Each import is given as related information.
+func staleImportErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, oldPath string, stale []moduleImport) ([]source.Error, error) {
+	if len(stale) == 0 || file.Module.Syntax == nil {
+		return nil, nil
+	}
+	rng, err := positionsToRange(uri, m, file.Module.Syntax.Start, file.Module.Syntax.End)
+	if err != nil {
+		return nil, err
+	}
+	var related []source.RelatedInformation
+	files := make(map[span.URI]bool)
+	for _, imp := range stale {
+		files[imp.URI] = true
+		related = append(related, source.RelatedInformation{
+			URI:     imp.URI,
+			Range:   imp.Range,
+			Message: fmt.Sprintf("%s is imported under the old module path.", imp.Path),
+		})
+	}
+	imports, use := "1 import", "uses"
+	if len(stale) > 1 {
+		imports, use = fmt.Sprintf("%d imports", len(stale)), "use"
+	}
+	in := "1 file"
+	if len(files) > 1 {
+		in = fmt.Sprintf("%d files", len(files))
+	}
+	return []source.Error{{
+		Category: staleImportCategory,
+		Message:  fmt.Sprintf("%s in %s still %s %s, the old path of module %s.", imports, in, use, oldPath, file.Module.Mod.Path),
+		Range:    rng,
+		URI:      uri,
+		Related:  related,
+		SuggestedFixes: []source.SuggestedFix{{
+			Title:   fmt.Sprintf("Rewrite the imports of %s to %s", oldPath, file.Module.Mod.Path),
+			Command: RewriteImportsCommand(uri, oldPath),
+		}},
+	}}, nil
+}
+
+// moduleStaleImports returns the imports of the workspace files of the main
+// module that still use each of oldPaths, previous paths of the module, as
+// described for staleImports. It type-checks the workspace packages.
+func moduleStaleImports(ctx context.Context, snapshot source.Snapshot, file *modfile.File, oldPaths []string) (map[string][]moduleImport, error) {
+	imports, dirs, err := moduleImports(ctx, snapshot, file.Module.Mod.Path)
+	if err != nil {
+		return nil, err
+	}
+	stale := make(map[string][]moduleImport, len(oldPaths))
+	for _, oldPath := range oldPaths {
+		if oldPath != "" && oldPath != file.Module.Mod.Path {
+			stale[oldPath] = staleImports(file, oldPath, imports, dirs)
+		}
+	}
+	return stale, nil
+}
+
+// RewriteImportsCommand returns the command that rewrites the imports of
+// oldPath, the previous path of the module of the go.mod file uri, to its
+// current path.
+func RewriteImportsCommand(uri span.URI, oldPath string) *protocol.Command {
+	return &protocol.Command{
+		Title:     fmt.Sprintf("Rewrite the imports of %s", oldPath),
+		Command:   source.CommandRewriteImports,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri), oldPath},
+	}
+}
+
+// RewriteImportsArgs returns the go.mod file and old module path of a
+// command returned by RewriteImportsCommand, as sent back by the client.
+func RewriteImportsArgs(args []interface{}) (span.URI, string, error) {
+	if len(args) != 2 {
+		return "", "", errors.Errorf("expected 2 arguments, got %v", args)
+	}
+	var strs [2]string
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			strs[i] = arg
+		case protocol.DocumentURI:
+			strs[i] = string(arg)
+		default:
+			return "", "", errors.Errorf("expected argument %d to be a string but got %T", i, arg)
+		}
+	}
+	if strs[1] == "" {
+		return "", "", errors.Errorf("missing module path in %v", args)
+	}
+	return protocol.DocumentURI(strs[0]).SpanURI(), strs[1], nil
+}
+
+// RewriteImports returns the edit that rewrites the imports of oldPath in
+// the files of the main module to the path of its module directive. It
+// spans every file that has such an import.
+func RewriteImports(ctx context.Context, snapshot source.Snapshot, oldPath string) (protocol.WorkspaceEdit, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return protocol.WorkspaceEdit{}, errors.Errorf("no go.mod file for %s", snapshot.View().Folder())
+	}
+	ctx, done := event.Start(ctx, "mod.RewriteImports", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return protocol.WorkspaceEdit{}, parseModError(err)
+	}
+	file, _, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return protocol.WorkspaceEdit{}, parseModError(err)
+	}
+	if file.Module == nil {
+		return protocol.WorkspaceEdit{}, errors.Errorf("%s has no module directive", uri.Filename())
+	}
+	stale, err := moduleStaleImports(ctx, snapshot, file, []string{oldPath})
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	changes, err := importChanges(ctx, snapshot, stale[oldPath], func(path string) string {
+		return file.Module.Mod.Path + strings.TrimPrefix(path, oldPath)
+	})
+	if err != nil {
+		return protocol.WorkspaceEdit{}, err
+	}
+	return protocol.WorkspaceEdit{DocumentChanges: changes}, nil
+}
+
+// importChanges returns the changes that rewrite each of imports, sorted by
+// file, to the path that rewrite returns for its current one, with one
+// change per file.
+func importChanges(ctx context.Context, snapshot source.Snapshot, imports []moduleImport, rewrite func(path string) string) ([]protocol.TextDocumentEdit, error) {
+	var changes []protocol.TextDocumentEdit
+	for _, imp := range imports {
+		if len(changes) == 0 || changes[len(changes)-1].TextDocument.URI.SpanURI() != imp.URI {
+			goFH, err := snapshot.GetFile(ctx, imp.URI)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, protocol.TextDocumentEdit{
+				TextDocument: protocol.VersionedTextDocumentIdentifier{
+					Version: goFH.Version(),
+					TextDocumentIdentifier: protocol.TextDocumentIdentifier{
+						URI: protocol.URIFromSpanURI(imp.URI),
+					},
+				},
+			})
+		}
+		change := &changes[len(changes)-1]
+		change.Edits = append(change.Edits, protocol.TextEdit{
+			Range:   imp.Range,
+			NewText: strconv.Quote(rewrite(imp.Path)),
+		})
+	}
+	return changes, nil
+}
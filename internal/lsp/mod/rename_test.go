@@ -0,0 +1,172 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/span"
+	"golang.org/x/tools/internal/testenv"
+)
+
+func TestStaleImports(t *testing.T) {
+	testenv.NeedsGo1Point(t, 14)
+
+	// The module was renamed from example.com/old, but the command still
+	// imports its packages under the old path.
+	const app = `package main
+
+import (
+	"fmt"
+
+	"example.com/old"
+	"example.com/old/util"
+	"example.com/legacy/util"
+)
+
+func main() {
+	fmt.Println(renamed.Name, util.Name)
+}
+`
+	files := map[string]string{
+		"go.mod":          "module example.com/renamed\n\ngo 1.14\n",
+		"renamed.go":      "package renamed\n\nconst Name = \"renamed\"\n",
+		"util/util.go":    "package util\n\nconst Name = \"util\"\n",
+		"cmd/app/main.go": app,
+	}
+	folder, err := ioutil.TempDir("", "stale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	for name, content := range files {
+		name = filepath.Join(folder, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := tests.Context(t)
+	session := cache.New(ctx, nil).NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOPROXY=off", "GOFLAGS=")
+	_, snapshot, err := session.NewView(ctx, "stale_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fh, err := snapshot.GetFile(ctx, snapshot.View().ModFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const oldPath = "example.com/old"
+	byPath, err := moduleStaleImports(ctx, snapshot, file, []string{oldPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// example.com/legacy/util is a dependency that is not required yet,
+	// not a stale import, as example.com/legacy is not a previous path.
+	stale := byPath[oldPath]
+	var paths []string
+	for _, imp := range stale {
+		paths = append(paths, imp.Path)
+	}
+	if len(paths) != 2 || paths[0] != "example.com/old" || paths[1] != "example.com/old/util" {
+		t.Fatalf("got stale imports %v, want those of example.com/old", paths)
+	}
+
+	errors, err := staleImportErrors(fh.URI(), m, file, oldPath, stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if want := "2 imports in 1 file still use example.com/old, the old path of module example.com/renamed."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if got := rangeText(t, m, e.Range); got != "module example.com/renamed" {
+		t.Errorf("got range covering %q, want the module directive", got)
+	}
+	if len(e.Related) != 2 {
+		t.Errorf("got %d related locations, want one per stale import", len(e.Related))
+	}
+	if len(e.SuggestedFixes) != 1 || e.SuggestedFixes[0].Command == nil {
+		t.Fatalf("got fixes %v, want a command", e.SuggestedFixes)
+	}
+	cmd := e.SuggestedFixes[0].Command
+	if cmd.Command != source.CommandRewriteImports {
+		t.Errorf("got command %q, want %q", cmd.Command, source.CommandRewriteImports)
+	}
+	one, err := staleImportErrors(fh.URI(), m, file, oldPath, stale[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1 import in 1 file still uses example.com/old, the old path of module example.com/renamed."; len(one) != 1 || one[0].Message != want {
+		t.Errorf("got errors %v for a single import, want %q", one, want)
+	}
+	if gotURI, gotPath, err := RewriteImportsArgs(cmd.Arguments); err != nil || gotURI != fh.URI() || gotPath != oldPath {
+		t.Errorf("RewriteImportsArgs(%v) = %s, %s, %v", cmd.Arguments, gotURI, gotPath, err)
+	}
+
+	edit, err := RewriteImports(ctx, snapshot, oldPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edit.DocumentChanges) != 1 {
+		t.Fatalf("got changes to %d files, want 1", len(edit.DocumentChanges))
+	}
+	change := edit.DocumentChanges[0]
+	appURI := span.URIFromPath(filepath.Join(folder, "cmd", "app", "main.go"))
+	if change.TextDocument.URI.SpanURI() != appURI {
+		t.Fatalf("got changes to %s, want %s", change.TextDocument.URI, appURI)
+	}
+	appMapper := &protocol.ColumnMapper{
+		URI:       appURI,
+		Converter: span.NewContentConverter(appURI.Filename(), []byte(app)),
+		Content:   []byte(app),
+	}
+	edits, err := source.FromProtocolEdits(appMapper, change.Edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `package main
+
+import (
+	"fmt"
+
+	"example.com/renamed"
+	"example.com/renamed/util"
+	"example.com/legacy/util"
+)
+
+func main() {
+	fmt.Println(renamed.Name, util.Name)
+}
+`
+	if got := diff.ApplyEdits(app, edits); got != want {
+		t.Errorf("rewritten file:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+// Replacements returns the replace directives that are in effect for the
+// view's module, keyed by the path of the module they replace. If a module
+// has both a replacement of a single version and one of all of its versions,
+// the former takes precedence, and if it has replacements of several
+// versions, that of the version the module requires does.
+//
+// The replace directives of the go.work file found in the view's folder or
+// one of its ancestors, if any, override those of the go.mod file for the
+// same module, as they do for the go command. Their relative directories are
+// made absolute, as they are relative to the go.work file rather than to the
+// go.mod file. Replacements returns nil if the view is not in module mode.
+func Replacements(ctx context.Context, snapshot source.Snapshot) (map[string]modfile.Replace, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.Replacements", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, _, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	work, err := readParsedWork(ctx, []source.Snapshot{snapshot})
+	if err != nil {
+		return nil, err
+	}
+	if work == nil {
+		return replacements(file, "", nil), nil
+	}
+	if work.err != nil {
+		return nil, work.err
+	}
+	return replacements(file, work.uri.Filename(), workReplaces(work.file)), nil
+}
+
+// replacements returns the replacements in effect for the module of file,
+// with the replacements work of the go.work file at workFile, as described
+// for Replacements.
+func replacements(file *modfile.File, workFile string, work []workReplace) map[string]modfile.Replace {
+	required := make(map[string]string, len(file.Require))
+	for _, req := range file.Require {
+		required[req.Mod.Path] = req.Mod.Version
+	}
+	result := effectiveReplaces(file.Replace, required)
+	var workReplaces []*modfile.Replace
+	for _, w := range work {
+		r := &modfile.Replace{Old: w.old, New: w.new}
+		if modfile.IsDirectoryPath(r.New.Path) && !filepath.IsAbs(filepath.FromSlash(r.New.Path)) {
+			r.New.Path = filepath.Join(filepath.Dir(workFile), filepath.FromSlash(r.New.Path))
+		}
+		workReplaces = append(workReplaces, r)
+	}
+	for path, r := range effectiveReplaces(workReplaces, required) {
+		result[path] = r
+	}
+	return result
+}
+
+// effectiveReplaces returns the replacement in effect for each module
+// replaced by replaces, given the versions of the modules that are required,
+// keyed by module path.
+func effectiveReplaces(replaces []*modfile.Replace, required map[string]string) map[string]modfile.Replace {
+	result := make(map[string]modfile.Replace, len(replaces))
+	for _, r := range replaces {
+		if prev, ok := result[r.Old.Path]; ok && prev.Old.Version != "" {
+			// A replacement of a single version takes precedence over one
+			// of all versions, and that of the required version over those
+			// of the others.
+			if r.Old.Version == "" || prev.Old.Version == required[r.Old.Path] && r.Old.Version != prev.Old.Version {
+				continue
+			}
+		}
+		// The go command rejects conflicting replacements, so if there
+		// are several for the same module version, the last one wins, as
+		// it does when the directives are edited with go mod edit.
+		result[r.Old.Path] = *r
+	}
+	return result
+}
+
+// errEscapesRoot is returned by resolveReplaceDir for relative replacement
+// directories that climb above the root of the filesystem.
+var errEscapesRoot = errors.New("directory escapes the filesystem root")
+
+// resolveReplaceDir returns the clean, absolute directory that the
+// replacement directory dir, such as ../other, refers to, relative to modDir,
+// the directory of the go.mod file. Like the go command, it resolves ".."
+// lexically, but it reports errEscapesRoot rather than silently stopping at
+// the root of the filesystem. Symbolic links are then resolved, so that a
+// directory reached by several paths is always identified by the same one;
+// directories that do not exist are returned as they are.
+func resolveReplaceDir(modDir, dir string) (string, error) {
+	dir = filepath.FromSlash(dir)
+	if filepath.IsAbs(dir) {
+		return realDir(filepath.Clean(dir)), nil
+	}
+	cur := filepath.Clean(modDir)
+	for _, elem := range strings.Split(dir, string(filepath.Separator)) {
+		switch elem {
+		case "", ".":
+		case "..":
+			parent := filepath.Dir(cur)
+			if parent == cur {
+				return "", errEscapesRoot
+			}
+			cur = parent
+		default:
+			cur = filepath.Join(cur, elem)
+		}
+	}
+	return realDir(cur), nil
+}
+
+// realDir returns dir with its symbolic links resolved, or dir itself if
+// they cannot be, for example because it does not exist.
+func realDir(dir string) string {
+	if _, err := os.Lstat(dir); err != nil {
+		return dir
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		return real
+	}
+	return dir
+}
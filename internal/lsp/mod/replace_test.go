@@ -0,0 +1,106 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/mod/module"
+)
+
+func TestReplacements(t *testing.T) {
+	_, _, file := parseTestMod(t, `module mod.com
+
+require (
+	example.com/b v1.0.0
+	example.com/d v1.3.0
+)
+
+replace (
+	example.com/a => ../a
+	example.com/b v1.0.0 => example.com/c v1.1.0
+	example.com/b => example.com/c v1.2.0
+	example.com/d v1.3.0 => example.com/e v1.3.1
+	example.com/d v1.0.0 => example.com/e v1.0.1
+	example.com/f => example.com/g v1.0.0
+)
+`)
+	work := []workReplace{
+		{old: module.Version{Path: "example.com/f"}, new: module.Version{Path: "./g"}},
+		{old: module.Version{Path: "example.com/h", Version: "v1.0.0"}, new: module.Version{Path: "example.com/i", Version: "v1.0.0"}},
+	}
+	workFile := filepath.Join(string(filepath.Separator)+"work", "go.work")
+	got := replacements(file, workFile, work)
+	want := map[string]string{
+		"example.com/a": "../a",
+		"example.com/b": "example.com/c@v1.1.0",
+		"example.com/d": "example.com/e@v1.3.1",
+		"example.com/f": filepath.Join(string(filepath.Separator)+"work", "g"),
+		"example.com/h": "example.com/i@v1.0.0",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d replacements, want %d: %v", len(got), len(want), got)
+	}
+	for old, new := range want {
+		r, ok := got[old]
+		if !ok {
+			t.Errorf("missing replacement for %s", old)
+			continue
+		}
+		if r.New.String() != new {
+			t.Errorf("%s is replaced by %s, want %s", old, r.New, new)
+		}
+	}
+}
+
+func TestResolveReplaceDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "gopls-replace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		t.Fatal(err)
+	}
+	mod := filepath.Join(root, "work", "mod")
+	other := filepath.Join(root, "other")
+	for _, dir := range []string{mod, other} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(root, "work", "link")
+	if err := os.Symlink(other, link); err != nil {
+		t.Skipf("cannot create symlinks: %v", err)
+	}
+	for _, tt := range []struct {
+		dir, want string
+	}{
+		{"../../other", other},
+		{"./../../other/", other},
+		{"../link", other},
+		{"../missing", filepath.Join(root, "work", "missing")},
+		{filepath.ToSlash(other), other},
+	} {
+		got, err := resolveReplaceDir(mod, tt.dir)
+		if err != nil {
+			t.Errorf("resolveReplaceDir(%q): %v", tt.dir, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("resolveReplaceDir(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+	escape := ".."
+	for dir := mod; filepath.Dir(dir) != dir; dir = filepath.Dir(dir) {
+		escape += "/.."
+	}
+	if _, err := resolveReplaceDir(mod, escape); err != errEscapesRoot {
+		t.Errorf("resolveReplaceDir(%q): got error %v, want %v", escape, err, errEscapesRoot)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// ReportVersion is the version of the JSON schema of a Report. It is
+// incremented whenever a field is removed or changes meaning; new fields may
+// be added without changing the version.
+const ReportVersion = 1
+
+// A Report describes the diagnostics for a go.mod file and the fixes for
+// them, independently of the LSP protocol types.
+type Report struct {
+	Version     int                `json:"version"`
+	File        string             `json:"file"`
+	Diagnostics []ReportDiagnostic `json:"diagnostics"`
+
+	// MissingRequires are the requirements that must be added to the
+	// go.mod file to satisfy the imports of the module's packages.
+	MissingRequires []ReportMissingRequire `json:"missingRequires"`
+}
+
+// A ReportDiagnostic is a single diagnostic for a go.mod file.
+type ReportDiagnostic struct {
+	Category string      `json:"category"`
+	Severity string      `json:"severity"`
+	Message  string      `json:"message"`
+	Range    ReportRange `json:"range"`
+	Fixes    []ReportFix `json:"fixes"`
+}
+
+// A ReportMissingRequire is a requirement missing from a go.mod file, along
+// with the fix that adds it.
+type ReportMissingRequire struct {
+	Path    string    `json:"path"`
+	Version string    `json:"version"`
+	Fix     ReportFix `json:"fix"`
+}
+
+// A ReportFix is a named set of edits that fixes a diagnostic. Fixes that
+// must run the go command have a command instead of edits.
+type ReportFix struct {
+	Title   string         `json:"title"`
+	Edits   []ReportEdit   `json:"edits"`
+	Command *ReportCommand `json:"command,omitempty"`
+}
+
+// A ReportCommand is a gopls command, such as add_dependency, along with its
+// arguments.
+type ReportCommand struct {
+	Name      string        `json:"name"`
+	Arguments []interface{} `json:"arguments"`
+}
+
+// A ReportEdit replaces the text of a range in a file.
+type ReportEdit struct {
+	File    string      `json:"file"`
+	Range   ReportRange `json:"range"`
+	NewText string      `json:"newText"`
+}
+
+// A ReportRange is a range of a file. Lines and columns are zero-based, and
+// columns are measured in UTF-16 code units, as in the LSP.
+type ReportRange struct {
+	Start ReportPosition `json:"start"`
+	End   ReportPosition `json:"end"`
+}
+
+// A ReportPosition is a position in a file.
+type ReportPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// JSONReport returns the diagnostics and fixes for the view's go.mod file,
+// encoded as the JSON form of a Report. It returns nil if the view has no
+// go.mod file.
+func JSONReport(ctx context.Context, snapshot source.Snapshot) ([]byte, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.JSONReport", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	missingDeps, errors, err := modErrors(ctx, snapshot, fh)
+	if err == source.ErrTmpModfileUnsupported {
+		missingDeps, errors = nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	goFixes, err := SuggestedGoFixes(ctx, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	options := snapshot.View().Options()
+	report := newReport(uri, errors, options)
+	for dep, req := range missingDeps {
+		fix, ok := goFixes[dep]
+		if !ok {
+			continue
+		}
+		report.MissingRequires = append(report.MissingRequires, ReportMissingRequire{
+			Path:    req.Mod.Path,
+			Version: req.Mod.Version,
+			Fix: ReportFix{
+				Title: options.FixTitle(fmt.Sprintf("Add %s to go.mod", dep)),
+				Edits: reportEdits(fix.TextDocument.URI.SpanURI(), fix.Edits),
+			},
+		})
+	}
+	sort.Slice(report.MissingRequires, func(i, j int) bool {
+		return report.MissingRequires[i].Path < report.MissingRequires[j].Path
+	})
+	return json.MarshalIndent(report, "", "\t")
+}
+
+// newReport returns a report of the given errors for the go.mod file uri.
+// The titles of their fixes are rewritten as the options specify.
+func newReport(uri span.URI, errors []source.Error, options source.Options) *Report {
+	report := &Report{
+		Version:         ReportVersion,
+		File:            uri.Filename(),
+		Diagnostics:     []ReportDiagnostic{},
+		MissingRequires: []ReportMissingRequire{},
+	}
+	for _, e := range errors {
+		diag := ReportDiagnostic{
+			Category: e.Category,
+			Severity: severityNames[toDiagnostic(e).Severity],
+			Message:  e.Message,
+			Range:    reportRange(e.Range),
+			Fixes:    []ReportFix{},
+		}
+		for _, fix := range e.SuggestedFixes {
+			reportFix := ReportFix{Title: options.FixTitle(fix.Title), Edits: []ReportEdit{}}
+			if fix.Command != nil {
+				reportFix.Command = &ReportCommand{
+					Name:      fix.Command.Command,
+					Arguments: fix.Command.Arguments,
+				}
+			}
+			// Sort the edited files, so that the report is deterministic.
+			var uris []span.URI
+			for uri := range fix.Edits {
+				uris = append(uris, uri)
+			}
+			sort.Slice(uris, func(i, j int) bool {
+				return uris[i] < uris[j]
+			})
+			for _, uri := range uris {
+				reportFix.Edits = append(reportFix.Edits, reportEdits(uri, fix.Edits[uri])...)
+			}
+			diag.Fixes = append(diag.Fixes, reportFix)
+		}
+		report.Diagnostics = append(report.Diagnostics, diag)
+	}
+	return report
+}
+
+var severityNames = map[protocol.DiagnosticSeverity]string{
+	protocol.SeverityError:       "error",
+	protocol.SeverityWarning:     "warning",
+	protocol.SeverityInformation: "information",
+	protocol.SeverityHint:        "hint",
+}
+
+func reportEdits(uri span.URI, edits []protocol.TextEdit) []ReportEdit {
+	var result []ReportEdit
+	for _, edit := range edits {
+		result = append(result, ReportEdit{
+			File:    uri.Filename(),
+			Range:   reportRange(edit.Range),
+			NewText: edit.NewText,
+		})
+	}
+	return result
+}
+
+func reportRange(rng protocol.Range) ReportRange {
+	return ReportRange{
+		Start: ReportPosition{Line: int(rng.Start.Line), Column: int(rng.Start.Character)},
+		End:   ReportPosition{Line: int(rng.End.Line), Column: int(rng.End.Character)},
+	}
+}
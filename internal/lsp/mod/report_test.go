@@ -0,0 +1,149 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"encoding/json"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestReport(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/foo v1.2.0
+
+exclude example.com/foo v1.1.0
+`
+	const want = `{
+	"version": 1,
+	"file": "/tmp/go.mod",
+	"diagnostics": [
+		{
+			"category": "ineffective exclude",
+			"severity": "hint",
+			"message": "example.com/foo@v1.1.0 is excluded, but v1.2.0 is required, so the exclude has no effect.",
+			"range": {
+				"start": {
+					"line": 6,
+					"column": 0
+				},
+				"end": {
+					"line": 6,
+					"column": 30
+				}
+			},
+			"fixes": [
+				{
+					"title": "Remove exclude example.com/foo v1.1.0",
+					"edits": [
+						{
+							"file": "/tmp/go.mod",
+							"range": {
+								"start": {
+									"line": 5,
+									"column": 0
+								},
+								"end": {
+									"line": 6,
+									"column": 0
+								}
+							},
+							"newText": ""
+						},
+						{
+							"file": "/tmp/go.mod",
+							"range": {
+								"start": {
+									"line": 6,
+									"column": 0
+								},
+								"end": {
+									"line": 7,
+									"column": 0
+								}
+							},
+							"newText": ""
+						}
+					]
+				}
+			]
+		}
+	],
+	"missingRequires": []
+}`
+	uri, m, file := parseTestMod(t, mod)
+	options := source.DefaultOptions()
+	errors, err := checkExcludes(uri, m, file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(newReport(uri, errors, options), "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("got report:\n%s\nwant:\n%s", got, want)
+	}
+	var decoded Report
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != ReportVersion || len(decoded.Diagnostics) != 1 {
+		t.Errorf("decoded report %+v does not match the original", decoded)
+	}
+}
+
+func TestFixTitleTemplate(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/foo v1.2.0
+
+exclude example.com/foo v1.1.0
+`
+	uri, m, file := parseTestMod(t, mod)
+	options := source.DefaultOptions()
+	errors, err := checkExcludes(uri, m, file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without a template, titles are unchanged.
+	const title = "Remove exclude example.com/foo v1.1.0"
+	if got := newReport(uri, errors, options).Diagnostics[0].Fixes[0].Title; got != title {
+		t.Errorf("got title %q, want %q", got, title)
+	}
+	for _, result := range source.SetOptions(&options, map[string]interface{}{
+		"fixTitleTemplate": "go.mod: {{.}}",
+	}) {
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+	if got, want := newReport(uri, errors, options).Diagnostics[0].Fixes[0].Title, "go.mod: "+title; got != want {
+		t.Errorf("got title %q, want %q", got, want)
+	}
+	for _, result := range source.SetOptions(&options, map[string]interface{}{
+		"fixTitleTemplate": "{{.",
+	}) {
+		if result.Error == nil {
+			t.Error("got no error for an invalid template")
+		}
+	}
+	// An invalid template leaves the previous one in place.
+	if got, want := newReport(uri, errors, options).Diagnostics[0].Fixes[0].Title, "go.mod: "+title; got != want {
+		t.Errorf("after an invalid template, got title %q, want %q", got, want)
+	}
+	source.SetOptions(&options, map[string]interface{}{
+		"fixTitleTemplate": "",
+	})
+	if got := newReport(uri, errors, options).Diagnostics[0].Fixes[0].Title; got != title {
+		t.Errorf("after clearing the template, got title %q, want %q", got, title)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+	errors "golang.org/x/xerrors"
+)
+
+// A Reproducer is the main module's go.mod file, reduced to the requirements
+// needed to select the same version of a module.
+type Reproducer struct {
+	Path    string // the module whose selection is reproduced
+	Version string // the version of Path selected by both go.mod files
+	Content string // the reduced go.mod file
+}
+
+// MinimalReproducer returns the smallest subset of the requirements of the
+// view's go.mod file that still selects the same version of modulePath,
+// which helps to report a problem with the version of a dependency. The
+// replace and exclude directives of the modules that are left in the module
+// graph are kept as they are.
+func MinimalReproducer(ctx context.Context, snapshot source.Snapshot, modulePath string) (*Reproducer, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, errors.Errorf("no go.mod file for %s", snapshot.View().Folder())
+	}
+	ctx, done := event.Start(ctx, "mod.MinimalReproducer", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	content, err := fh.Read()
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, _, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := snapshot.RunGoCommand(ctx, "mod", []string{"graph"})
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseModGraph(stdout, file)
+	if err != nil {
+		return nil, err
+	}
+	kept, version, err := minimizeRequires(root, modulePath)
+	if err != nil {
+		return nil, err
+	}
+	reduced, err := reproducerContent(uri, content, kept)
+	if err != nil {
+		return nil, err
+	}
+	return &Reproducer{
+		Path:    modulePath,
+		Version: version,
+		Content: string(reduced),
+	}, nil
+}
+
+// minimizeRequires returns the smallest subset of the requirements of root
+// that selects the same version of path, and that version. A requirement is
+// dropped whenever the rest still select the version. Since dropping
+// requirements can only lower the selected version, a requirement that is
+// needed once is needed in every smaller subset, so a single pass suffices.
+func minimizeRequires(root *DepNode, path string) ([]*DepNode, string, error) {
+	if path == root.Path {
+		return nil, "", errors.Errorf("%s is the main module", path)
+	}
+	version := selectedVersion(root.Requires, path)
+	if version == "" {
+		return nil, "", errors.Errorf("%s is not in the module graph", path)
+	}
+	kept := append([]*DepNode(nil), root.Requires...)
+	for i := 0; i < len(kept); {
+		rest := append(append([]*DepNode(nil), kept[:i]...), kept[i+1:]...)
+		if selectedVersion(rest, path) == version {
+			kept = rest
+			continue
+		}
+		i++
+	}
+	return kept, version, nil
+}
+
+// selectedVersion returns the highest version of path in the module graph
+// below reqs, which is the version minimal version selection picks, or "" if
+// path is not in the graph.
+func selectedVersion(reqs []*DepNode, path string) string {
+	var version string
+	walkGraph(reqs, func(n *DepNode) {
+		if n.Path == path && semver.Compare(n.Version, version) > 0 {
+			version = n.Version
+		}
+	})
+	return version
+}
+
+// walkGraph calls visit once for each module in the graph below reqs.
+func walkGraph(reqs []*DepNode, visit func(n *DepNode)) {
+	seen := make(map[*DepNode]bool)
+	var walk func(n *DepNode)
+	walk = func(n *DepNode) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		visit(n)
+		for _, req := range n.Requires {
+			walk(req)
+		}
+	}
+	for _, req := range reqs {
+		walk(req)
+	}
+}
+
+// reproducerContent returns the go.mod file content, with only the kept
+// requirements, and the replace and exclude directives of the modules in
+// the graph below them.
+func reproducerContent(uri span.URI, content []byte, kept []*DepNode) ([]byte, error) {
+	file, err := modfile.Parse(uri.Filename(), content, nil)
+	if err != nil {
+		return nil, err
+	}
+	keep := make(map[string]bool)
+	for _, req := range kept {
+		keep[req.Path+"@"+req.Version] = true
+	}
+	inGraph := make(map[string]bool)
+	walkGraph(kept, func(n *DepNode) {
+		inGraph[n.Path] = true
+	})
+	for _, req := range append([]*modfile.Require(nil), file.Require...) {
+		if !keep[req.Mod.Path+"@"+req.Mod.Version] {
+			if err := file.DropRequire(req.Mod.Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, r := range append([]*modfile.Replace(nil), file.Replace...) {
+		if !inGraph[r.Old.Path] {
+			if err := file.DropReplace(r.Old.Path, r.Old.Version); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, x := range append([]*modfile.Exclude(nil), file.Exclude...) {
+		if !inGraph[x.Mod.Path] {
+			if err := file.DropExclude(x.Mod.Path, x.Mod.Version); err != nil {
+				return nil, err
+			}
+		}
+	}
+	file.Cleanup()
+	return file.Format()
+}
+
+// MinimalReproducerCommand returns the command that reduces the go.mod file
+// uri to reproduce the selected version of the module path.
+func MinimalReproducerCommand(title string, uri span.URI, path string) *protocol.Command {
+	return &protocol.Command{
+		Title:     title,
+		Command:   source.CommandMinimalReproducer,
+		Arguments: []interface{}{protocol.URIFromSpanURI(uri), path},
+	}
+}
+
+// MinimalReproducerArgs returns the go.mod file and module path of a command
+// returned by MinimalReproducerCommand, as sent back by the client.
+func MinimalReproducerArgs(args []interface{}) (span.URI, string, error) {
+	if len(args) != 2 {
+		return "", "", errors.Errorf("expected 2 arguments, got %v", args)
+	}
+	var strs [2]string
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			strs[i] = arg
+		case protocol.DocumentURI:
+			strs[i] = string(arg)
+		default:
+			return "", "", errors.Errorf("expected argument %d to be a string but got %T", i, arg)
+		}
+	}
+	if strs[1] == "" {
+		return "", "", errors.Errorf("missing module path in %v", args)
+	}
+	return protocol.DocumentURI(strs[0]).SpanURI(), strs[1], nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMinimalReproducer(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.14
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0 // indirect
+	example.com/t v1.1.0
+)
+
+replace example.com/a => ../a
+
+replace example.com/c => ../c
+
+exclude example.com/t v1.3.0
+`
+	const graph = `example.com/m example.com/a@v1.0.0
+example.com/m example.com/b@v1.0.0
+example.com/m example.com/c@v1.0.0
+example.com/m example.com/t@v1.1.0
+example.com/a@v1.0.0 example.com/t@v1.2.0
+example.com/b@v1.0.0 example.com/t@v1.0.0
+example.com/b@v1.0.0 example.com/a@v1.0.0
+example.com/c@v1.0.0 example.com/d@v1.0.0
+`
+	uri, _, file := parseTestMod(t, mod)
+	root, err := parseModGraph(strings.NewReader(graph), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, version, err := minimizeRequires(root, "example.com/t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "v1.2.0" {
+		t.Errorf("got version %s, want v1.2.0", version)
+	}
+	var got []string
+	for _, n := range kept {
+		got = append(got, n.Path)
+	}
+	// b requires a, which requires t v1.2.0, so a is not needed once b is kept.
+	if want := []string{"example.com/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got kept requirements %v, want %v", got, want)
+	}
+
+	content, err := reproducerContent(uri, []byte(mod), kept)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `module example.com/m
+
+go 1.14
+
+require example.com/b v1.0.0
+
+replace example.com/a => ../a
+
+exclude example.com/t v1.3.0
+`
+	if string(content) != want {
+		t.Errorf("got reproducer:\n%s\nwant:\n%s", content, want)
+	}
+
+	for _, path := range []string{"example.com/m", "example.com/missing"} {
+		if _, _, err := minimizeRequires(root, path); err == nil {
+			t.Errorf("minimizeRequires(%s) succeeded, want an error", path)
+		}
+	}
+}
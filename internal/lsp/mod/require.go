@@ -0,0 +1,177 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const upgradeCategory = "upgrade available"
+
+// DiagnoseRequire runs the checks that concern the requirement on the
+// module path in the view's go.mod file, without running `go mod tidy`, and
+// returns the most severe of the problems it finds, or nil if there are
+// none. The checks are, in order: the validity of the required version, and,
+// using a single go command that lists only that module, whether the version
+// is older than the earliest published one, whether it is a stale
+// pseudo-version, if StalePseudoVersionAge is set, and whether an upgrade is
+// available. If the go command fails, for example because the module proxy
+// cannot be reached, only the first check is run. If ModDirectOnly is set,
+// indirect requirements are not checked.
+//
+// gopls does not know about retracted versions or vulnerabilities yet, so
+// DiagnoseRequire does not check for them. Modules to move away from can be
+// listed with their forks in the ModuleForks option; see checkModuleForks.
+func DiagnoseRequire(ctx context.Context, snapshot source.Snapshot, path string) (*source.Diagnostic, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.DiagnoseRequire", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	file, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		// Only the validity of the version can be checked, since the file
+		// cannot be parsed.
+		_, versionErrors, err := canonicalizeVersions(uri, m, snapshot.View().Options())
+		if err != nil {
+			return nil, err
+		}
+		var errors []source.Error
+		for _, e := range versionErrors {
+			if lineHasField(m.Content, int(e.Range.Start.Line), path) {
+				errors = append(errors, e)
+			}
+		}
+		return mostSevere(errors), nil
+	}
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	var req *modfile.Require
+	for _, r := range file.Require {
+		if r.Mod.Path == path && r.Syntax != nil {
+			req = r
+		}
+	}
+	if req == nil {
+		return nil, fmt.Errorf("%s does not require %s", uri.Filename(), path)
+	}
+	if req.Indirect && snapshot.View().Options().ModDirectOnly {
+		return nil, nil
+	}
+	// Limit the checks to the one requirement.
+	only := &modfile.File{Module: file.Module, Require: []*modfile.Require{req}}
+
+	stdout, err := snapshot.RunGoCommand(ctx, "list", []string{"-e", "-m", "-u", "-versions", "-json", path})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "listing "+path, err)
+		return nil, nil
+	}
+	var mod struct {
+		Path     string
+		Versions []string
+		Update   *latestModule
+		Error    *struct{ Err string }
+	}
+	if err := json.NewDecoder(stdout).Decode(&mod); err != nil {
+		return nil, err
+	}
+	if mod.Error != nil {
+		return nil, nil
+	}
+	errors, err := belowEarliestErrors(uri, m, only, map[string][]string{path: mod.Versions})
+	if err != nil {
+		return nil, err
+	}
+	if mod.Update != nil {
+		if age := snapshot.View().Options().StalePseudoVersionAge; age > 0 && mod.Update.Time != nil {
+			latest := *mod.Update
+			latest.Path = path
+			staleErrors, err := staleErrors(uri, m, only, map[string]latestModule{path: latest}, age)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, staleErrors...)
+		}
+		upgradeError, err := upgradeAvailableError(uri, m, req, mod.Update.Version)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, upgradeError)
+	}
+	return mostSevere(errors), nil
+}
+
+// upgradeAvailableError reports that req can be upgraded to version.
+func upgradeAvailableError(uri span.URI, m *protocol.ColumnMapper, req *modfile.Require, version string) (source.Error, error) {
+	rng, err := tokenRange(uri, m, req.Syntax, len(req.Syntax.Token)-1)
+	if err != nil {
+		return source.Error{}, err
+	}
+	return source.Error{
+		Category: upgradeCategory,
+		Message:  fmt.Sprintf("%s can be upgraded to %s.", req.Mod.Path, version),
+		Range:    rng,
+		URI:      uri,
+		SuggestedFixes: []source.SuggestedFix{{
+			Title: fmt.Sprintf("Upgrade to %s %s", req.Mod.Path, version),
+			Edits: map[span.URI][]protocol.TextEdit{
+				uri: {{Range: rng, NewText: version}},
+			},
+		}},
+	}, nil
+}
+
+// mostSevere returns the diagnostic for the most severe of errors, or for
+// the first of the most severe ones if there are several, or nil if there
+// are none.
+func mostSevere(errors []source.Error) *source.Diagnostic {
+	var worst *source.Diagnostic
+	for _, e := range errors {
+		// Lower values are more severe.
+		if diag := toDiagnostic(e); worst == nil || diag.Severity < worst.Severity {
+			worst = diag
+		}
+	}
+	return worst
+}
+
+// lineHasField reports whether the given zero-based line of content has a
+// whitespace-separated field equal to s.
+func lineHasField(content []byte, line int, s string) bool {
+	lines := strings.Split(string(content), "\n")
+	if line < 0 || line >= len(lines) {
+		return false
+	}
+	for _, field := range strings.Fields(lines[line]) {
+		if field == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestUpgradeAvailableError(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require example.com/dep v1.0.0
+`
+	uri, m, file := parseTestMod(t, mod)
+	e, err := upgradeAvailableError(uri, m, file.Require[0], "v1.2.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rangeText(t, m, e.Range); got != "v1.0.0" {
+		t.Errorf("got range covering %q, want the version", got)
+	}
+	if want := "example.com/dep can be upgraded to v1.2.0."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if diag := toDiagnostic(e); diag.Severity != protocol.SeverityInformation {
+		t.Errorf("got severity %v, want information", diag.Severity)
+	}
+}
+
+func TestMostSevere(t *testing.T) {
+	if diag := mostSevere(nil); diag != nil {
+		t.Errorf("got %v for no errors, want nil", diag)
+	}
+	errors := []source.Error{
+		{Category: upgradeCategory, Message: "upgrade"},
+		{Category: earliestVersionCategory, Message: "first error"},
+		{Category: versionCategory, Message: "second error"},
+	}
+	if diag := mostSevere(errors); diag == nil || diag.Message != "first error" {
+		t.Errorf("got %v, want the first error", diag)
+	}
+}
+
+func TestLineHasField(t *testing.T) {
+	content := []byte("module mod.com\n\nrequire example.com/dep v1.0.0\n")
+	if !lineHasField(content, 2, "example.com/dep") {
+		t.Errorf("lineHasField did not find the module path on its line")
+	}
+	if lineHasField(content, 2, "example.com") || lineHasField(content, 0, "example.com/dep") || lineHasField(content, 9, "module") {
+		t.Errorf("lineHasField matched a partial field or the wrong line")
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// SharedRequire describes a module that is in the build list of more than one
+// module in the workspace.
+type SharedRequire struct {
+	// Path is the module path of the shared dependency.
+	Path string
+
+	// Versions maps the go.mod file of each workspace module that depends on
+	// the shared dependency to the version selected for it.
+	Versions map[span.URI]string
+
+	// Version is the highest of the selected versions, to which the
+	// workspace modules can be aligned.
+	Version string
+}
+
+// Aligned reports whether every workspace module selects the same version of
+// the shared dependency.
+func (r *SharedRequire) Aligned() bool {
+	for _, v := range r.Versions {
+		if v != r.Version {
+			return false
+		}
+	}
+	return true
+}
+
+// SharedRequires analyzes the build lists of the given snapshots, one for each
+// module in the workspace, and reports the dependencies that are shared by
+// more than one of them. The results are sorted by module path. If align is
+// true, it also returns the edits that make each go.mod file require the
+// highest selected version of every shared dependency.
+func SharedRequires(ctx context.Context, snapshots []source.Snapshot, align bool) ([]*SharedRequire, map[span.URI][]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "mod.SharedRequires")
+	defer done()
+
+	buildLists := make(map[span.URI]map[string]string)
+	mainModules := make(map[string]bool)
+	for _, snapshot := range snapshots {
+		uri := snapshot.View().ModFile()
+		if uri == "" {
+			continue
+		}
+		stdout, err := snapshot.RunGoCommand(ctx, "list", []string{"-m", "-f", "{{.Path}} {{.Version}}", "all"})
+		if err != nil {
+			return nil, nil, fmt.Errorf("computing the build list of %s: %w", uri.Filename(), err)
+		}
+		buildList, main := parseBuildList(stdout.String())
+		buildLists[uri] = buildList
+		mainModules[main] = true
+	}
+	shared := sharedRequires(buildLists, mainModules)
+	if !align {
+		return shared, nil, nil
+	}
+	edits := make(map[span.URI][]protocol.TextEdit)
+	for _, snapshot := range snapshots {
+		uri := snapshot.View().ModFile()
+		if _, ok := buildLists[uri]; !ok {
+			continue
+		}
+		fh, err := snapshot.GetFile(ctx, uri)
+		if err != nil {
+			return nil, nil, err
+		}
+		pmh, err := snapshot.ParseModHandle(ctx, fh)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, m, _, err := pmh.Parse(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		fileEdits, err := alignEdits(uri, m, shared, snapshot.View().Options())
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(fileEdits) > 0 {
+			edits[uri] = fileEdits
+		}
+	}
+	return shared, edits, nil
+}
+
+// parseBuildList parses the output of `go list -m -f "{{.Path}} {{.Version}}"
+// all`, returning the version selected for each dependency along with the
+// path of the main module, which has no version.
+func parseBuildList(out string) (map[string]string, string) {
+	buildList := make(map[string]string)
+	var main string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 1:
+			if main == "" {
+				main = fields[0]
+			}
+		case 2:
+			buildList[fields[0]] = fields[1]
+		}
+	}
+	return buildList, main
+}
+
+// sharedRequires returns the dependencies that appear in more than one of the
+// given build lists, excluding the workspace modules themselves.
+func sharedRequires(buildLists map[span.URI]map[string]string, mainModules map[string]bool) []*SharedRequire {
+	byPath := make(map[string]*SharedRequire)
+	for uri, buildList := range buildLists {
+		for path, version := range buildList {
+			if mainModules[path] {
+				continue
+			}
+			r, ok := byPath[path]
+			if !ok {
+				r = &SharedRequire{Path: path, Versions: make(map[span.URI]string)}
+				byPath[path] = r
+			}
+			r.Versions[uri] = version
+			if r.Version == "" || semver.Compare(version, r.Version) > 0 {
+				r.Version = version
+			}
+		}
+	}
+	var shared []*SharedRequire
+	for _, r := range byPath {
+		if len(r.Versions) > 1 {
+			shared = append(shared, r)
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool {
+		return shared[i].Path < shared[j].Path
+	})
+	return shared
+}
+
+// alignEdits returns the edits to the given go.mod file that make it require
+// the highest selected version of each shared dependency that it depends on
+// at a lower version.
+func alignEdits(uri span.URI, m *protocol.ColumnMapper, shared []*SharedRequire, options source.Options) ([]protocol.TextEdit, error) {
+	// We need a private copy of the parsed go.mod file, since we're going to
+	// modify it.
+	copied, err := modfile.Parse("", m.Content, nil)
+	if err != nil {
+		return nil, err
+	}
+	required := make(map[string]bool, len(copied.Require))
+	for _, req := range copied.Require {
+		required[req.Mod.Path] = true
+	}
+	var changed bool
+	for _, r := range shared {
+		version, ok := r.Versions[uri]
+		if !ok || version == r.Version {
+			continue
+		}
+		if required[r.Path] {
+			if err := copied.AddRequire(r.Path, r.Version); err != nil {
+				return nil, err
+			}
+		} else {
+			// The dependency is only required indirectly, so align it with
+			// a new indirect requirement.
+			copied.AddNewRequire(r.Path, r.Version, true)
+		}
+		changed = true
+	}
+	if !changed {
+		return nil, nil
+	}
+	copied.SortBlocks()
+	copied.Cleanup()
+	newContents, err := copied.Format()
+	if err != nil {
+		return nil, err
+	}
+	// Calculate the edits to be made due to the change.
+	diff := options.ComputeEdits(uri, string(m.Content), string(newContents))
+	return source.ToProtocolEdits(m, diff)
+}
@@ -0,0 +1,74 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestSharedRequires(t *testing.T) {
+	const before = `module example.com/a
+
+go 1.14
+
+require example.com/shared v1.1.0
+`
+	const want = `module example.com/a
+
+go 1.14
+
+require example.com/shared v1.2.0
+`
+	a, m := testMapper(before)
+	b := span.URIFromPath("/b/go.mod")
+	listA, mainA := parseBuildList(`example.com/a
+example.com/b v0.0.0
+example.com/shared v1.1.0
+example.com/onlya v1.0.0
+`)
+	listB, mainB := parseBuildList(`example.com/b
+example.com/shared v1.2.0
+`)
+	if mainA != "example.com/a" || mainB != "example.com/b" {
+		t.Fatalf("got main modules %q and %q", mainA, mainB)
+	}
+	shared := sharedRequires(map[span.URI]map[string]string{
+		a: listA,
+		b: listB,
+	}, map[string]bool{mainA: true, mainB: true})
+	if len(shared) != 1 {
+		t.Fatalf("got %d shared requires, want 1: %v", len(shared), shared)
+	}
+	r := shared[0]
+	if r.Path != "example.com/shared" || r.Version != "v1.2.0" || r.Aligned() {
+		t.Fatalf("got %+v, want unaligned example.com/shared at v1.2.0", r)
+	}
+
+	options := source.DefaultOptions()
+	edits, err := alignEdits(a, m, shared, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffEdits, err := source.FromProtocolEdits(m, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := diff.ApplyEdits(before, diffEdits); got != want {
+		t.Errorf("aligned go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The module that already requires the highest version needs no edits.
+	edits, err = alignEdits(b, m, shared, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits) > 0 {
+		t.Errorf("got edits for an aligned module: %v", edits)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// SuggestReplaceTargets returns the directories in the folders of the
+// snapshot's session whose go.mod file declares modulePath, which are the
+// candidates for replacing the module with a local copy. They are ranked by
+// proximity to the view's go.mod file: the fewer directories there are
+// between the two, the earlier the candidate. The main module itself, and
+// go.mod files in vendor, testdata, and hidden directories, or in the
+// IgnoredModDirs, are not candidates. It returns an empty slice if no
+// directory matches.
+func SuggestReplaceTargets(ctx context.Context, snapshot source.Snapshot, modulePath string) ([]span.URI, error) {
+	uri := snapshot.View().ModFile()
+	ctx, done := event.Start(ctx, "mod.SuggestReplaceTargets", tag.URI.Of(uri))
+	defer done()
+
+	var modDir string
+	if uri != "" {
+		modDir = filepath.Dir(uri.Filename())
+	}
+	read := func(path string) []byte {
+		fh, err := snapshot.GetFile(ctx, span.URIFromPath(path))
+		if err != nil {
+			return nil
+		}
+		content, err := fh.Read()
+		if err != nil {
+			return nil
+		}
+		return content
+	}
+	dirs := localModuleDirs(sessionFolders(snapshot), snapshot.View().Options().IgnoredModDirs, modulePath, read)
+	return rankReplaceTargets(modDir, dirs), nil
+}
+
+// localModuleDirs returns the directories below roots with a go.mod file that
+// declares modulePath, according to read, which returns the contents of a
+// file. The directories named by ignored, in addition to vendor, testdata,
+// and hidden directories, are skipped.
+func localModuleDirs(roots, ignored []string, modulePath string, read func(path string) []byte) []string {
+	skip := make(map[string]bool, len(ignored))
+	for _, dir := range ignored {
+		skip[dir] = true
+	}
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, root := range roots {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				name := info.Name()
+				if path != root && (skip[name] || name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.Name() != "go.mod" {
+				return nil
+			}
+			dir := filepath.Dir(path)
+			if real := realDir(dir); !seen[real] && modfile.ModulePath(read(path)) == modulePath {
+				seen[real] = true
+				dirs = append(dirs, dir)
+			}
+			return nil
+		})
+	}
+	return dirs
+}
+
+// rankReplaceTargets returns dirs, except for modDir, as URIs, ordered by
+// the number of directories between them and modDir, then by path.
+func rankReplaceTargets(modDir string, dirs []string) []span.URI {
+	distance := func(dir string) int {
+		rel, err := filepath.Rel(modDir, dir)
+		if err != nil || modDir == "" {
+			// Candidates on another volume come last.
+			return int(^uint(0) >> 1)
+		}
+		return len(strings.Split(rel, string(filepath.Separator)))
+	}
+	var candidates []string
+	for _, dir := range dirs {
+		if modDir == "" || realDir(dir) != realDir(modDir) {
+			candidates = append(candidates, dir)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		di, dj := distance(candidates[i]), distance(candidates[j])
+		if di != dj {
+			return di < dj
+		}
+		return candidates[i] < candidates[j]
+	})
+	targets := []span.URI{}
+	for _, dir := range candidates {
+		targets = append(targets, span.URIFromPath(dir))
+	}
+	return targets
+}
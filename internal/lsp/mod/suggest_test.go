@@ -0,0 +1,68 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/span"
+)
+
+func TestSuggestReplaceTargets(t *testing.T) {
+	root, err := ioutil.TempDir("", "suggest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	modules := map[string]string{
+		"app":                    "example.com/app",
+		"app/lib":                "example.com/lib",
+		"lib":                    "example.com/lib",
+		"forks/deep/lib":         "example.com/lib",
+		"vendor/example.com/lib": "example.com/lib",
+		"fixtures/lib":           "example.com/lib",
+		".cache/lib":             "example.com/lib",
+		"other":                  "example.com/other",
+	}
+	for dir, path := range modules {
+		dir = filepath.Join(root, filepath.FromSlash(dir))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(fmt.Sprintf("module %s\n", path)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(path string) []byte {
+		content, _ := ioutil.ReadFile(path)
+		return content
+	}
+	modDir := filepath.Join(root, "app")
+
+	dirs := localModuleDirs([]string{root}, []string{"fixtures"}, "example.com/lib", read)
+	got := rankReplaceTargets(modDir, dirs)
+	var want []span.URI
+	for _, dir := range []string{"app/lib", "lib", "forks/deep/lib"} {
+		want = append(want, span.URIFromPath(filepath.Join(root, filepath.FromSlash(dir))))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got targets %v, want %v", got, want)
+	}
+
+	// The main module is not a candidate for replacing itself.
+	dirs = localModuleDirs([]string{root}, nil, "example.com/app", read)
+	if got := rankReplaceTargets(modDir, dirs); got == nil || len(got) != 0 {
+		t.Errorf("got targets %#v for the main module, want an empty slice", got)
+	}
+	dirs = localModuleDirs([]string{root}, nil, "example.com/missing", read)
+	if got := rankReplaceTargets(modDir, dirs); got == nil || len(got) != 0 {
+		t.Errorf("got targets %#v for a missing module, want an empty slice", got)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const missingSumCategory = "missing go.sum entry"
+
+// missingSumErrors reports the requirements in file that have no go.mod
+// checksum in sum, the contents of the go.sum file. A missing go.sum file is
+// left to `go mod tidy`, which creates it. Replaced modules are not checked, as their checksums are those of
+// the replacement, if any, and neither are modules for which skip, such as
+// View.SkipsSumCheck, returns true.
+//
+// The targets of module replacements are checked instead, with a fix that
+// downloads them, which records their checksums. Directory replacements
+// need no checksum.
+func missingSumErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, sum []byte, skip func(path string) bool) ([]source.Error, error) {
+	summed := make(map[string]bool)
+	for _, line := range strings.Split(string(sum), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		summed[fields[0]+" "+strings.TrimSuffix(fields[1], "/go.mod")] = true
+	}
+	replaced := make(map[string]bool, len(file.Replace))
+	for _, r := range file.Replace {
+		replaced[r.Old.Path] = true
+	}
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Syntax == nil || replaced[req.Mod.Path] || skip(req.Mod.Path) {
+			continue
+		}
+		if summed[req.Mod.Path+" "+req.Mod.Version] {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, req.Syntax.Start, req.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: missingSumCategory,
+			Message:  fmt.Sprintf("go.sum has no checksum for %s %s.", req.Mod.Path, req.Mod.Version),
+			Range:    rng,
+			URI:      uri,
+		})
+	}
+	for _, r := range file.Replace {
+		if r.Syntax == nil || r.New.Version == "" || skip(r.New.Path) {
+			continue
+		}
+		if summed[r.New.Path+" "+r.New.Version] {
+			continue
+		}
+		rng, err := positionsToRange(uri, m, r.Syntax.Start, r.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: missingSumCategory,
+			Message:  fmt.Sprintf("go.sum has no checksum for %s %s, the replacement of %s.", r.New.Path, r.New.Version, r.Old.Path),
+			Range:    rng,
+			URI:      uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title:   fmt.Sprintf("Download %s %s", r.New.Path, r.New.Version),
+				Command: DownloadModuleCommand(uri, r.New.Path, r.New.Version),
+			}},
+		})
+	}
+	return errors, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/span"
+)
+
+func TestMissingSumErrors(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/summed v1.0.0
+	example.com/missing v1.0.0
+	example.com/replaced v1.0.0
+	example.com/forked v1.0.0
+	example.com/resummed v1.0.0
+	corp.example.com/private/lib v1.0.0
+)
+
+replace example.com/replaced => ../replaced
+
+replace example.com/forked => example.com/fork v1.1.0
+
+replace example.com/resummed => example.com/summed v1.0.0
+`
+	const sum = `example.com/summed v1.0.0 h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+example.com/summed v1.0.0/go.mod h1:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=
+`
+	// The view computes the effective GONOSUMDB from the go command.
+	ctx := tests.Context(t)
+	session := cache.New(ctx, nil).NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOPROXY=off", "GOPRIVATE=", "GONOSUMDB=corp.example.com/private")
+	folder, err := tests.CopyFolderToTempDir(filepath.Join("testdata", "unchanged"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+	view, _, err := session.NewView(ctx, "sum_test", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !view.SkipsSumCheck("corp.example.com/private/lib") || view.SkipsSumCheck("example.com/missing") {
+		t.Fatalf("SkipsSumCheck does not follow GONOSUMDB")
+	}
+
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := missingSumErrors(uri, m, file, []byte(sum), view.SkipsSumCheck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 2 {
+		t.Fatalf("got %d errors, want 2: %v", len(errors), errors)
+	}
+	if got := rangeText(t, m, errors[0].Range); got != "example.com/missing v1.0.0" {
+		t.Errorf("got range covering %q, want the requirement without a checksum", got)
+	}
+	if want := "go.sum has no checksum for example.com/missing v1.0.0."; errors[0].Message != want {
+		t.Errorf("got message %q, want %q", errors[0].Message, want)
+	}
+
+	// The module replacement without a checksum is fixed by downloading it.
+	if got := rangeText(t, m, errors[1].Range); got != "replace example.com/forked => example.com/fork v1.1.0" {
+		t.Errorf("got range covering %q, want the replacement without a checksum", got)
+	}
+	if want := "go.sum has no checksum for example.com/fork v1.1.0, the replacement of example.com/forked."; errors[1].Message != want {
+		t.Errorf("got message %q, want %q", errors[1].Message, want)
+	}
+	if fixes := errors[1].SuggestedFixes; len(fixes) != 1 || fixes[0].Command == nil {
+		t.Fatalf("got fixes %v, want a download command", fixes)
+	}
+	cmd := errors[1].SuggestedFixes[0].Command
+	if cmd.Command != source.CommandDownloadModule {
+		t.Errorf("got command %q, want %q", cmd.Command, source.CommandDownloadModule)
+	}
+	if gotURI, path, version, err := DownloadModuleArgs(cmd.Arguments); err != nil || gotURI != uri || path != "example.com/fork" || version != "v1.1.0" {
+		t.Errorf("DownloadModuleArgs(%v) = %s, %s, %s, %v", cmd.Arguments, gotURI, path, version, err)
+	}
+
+	// GONOSUMCHECK=1 disables the checks for every module.
+	options.Env = append(options.Env, "GONOSUMCHECK=1")
+	view, _, err = session.NewView(ctx, "sum_test_nocheck", span.URIFromPath(folder), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errors, err = missingSumErrors(uri, m, file, []byte(sum), view.SkipsSumCheck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 0 {
+		t.Errorf("got %d errors with GONOSUMCHECK=1, want none: %v", len(errors), errors)
+	}
+}
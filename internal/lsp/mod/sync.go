@@ -0,0 +1,165 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const syncCategory = "out of sync"
+
+// syncErrors reports the drift between the go.mod file and the external
+// sources of the given synchronizers. A synchronizer that fails is logged
+// and skipped, so that a broken external source does not hide the rest of
+// the file's diagnostics.
+func syncErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File, synchronizers []source.ModSynchronizer) ([]source.Error, error) {
+	var errors []source.Error
+	for _, s := range synchronizers {
+		reqs, err := s.Requirements(ctx, snapshot, uri)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			event.Error(ctx, "synchronizing go.mod with "+s.Name(), err)
+			continue
+		}
+		driftErrors, err := driftErrors(uri, m, file, s.Name(), reqs)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, driftErrors...)
+	}
+	return errors, nil
+}
+
+// driftErrors reports the requirements listed by the external source name
+// that file does not satisfy: those that no requirement provides, on the
+// module directive, and those required at another version, on the version,
+// with a fix to require the listed one.
+func driftErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, name string, reqs []source.ExternalRequirement) ([]source.Error, error) {
+	var errors []source.Error
+	for _, ext := range reqs {
+		req := providingRequire(file, ext.Path)
+		if req == nil {
+			if file.Module == nil || file.Module.Syntax == nil {
+				continue
+			}
+			rng, err := positionsToRange(uri, m, file.Module.Syntax.Start, file.Module.Syntax.End)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: syncCategory,
+				Message:  fmt.Sprintf("%s lists %s, but no requirement in go.mod provides it.", name, ext.Path),
+				Range:    rng,
+				URI:      uri,
+			})
+			continue
+		}
+		if ext.Version == "" || ext.Version == req.Mod.Version {
+			continue
+		}
+		rng, err := tokenRange(uri, m, req.Syntax, len(req.Syntax.Token)-1)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: syncCategory,
+			Message:  fmt.Sprintf("%s lists %s at %s, but go.mod requires %s.", name, ext.Path, ext.Version, req.Mod.Version),
+			Range:    rng,
+			URI:      uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Require %s %s", req.Mod.Path, ext.Version),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: rng, NewText: ext.Version}},
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// providingRequire returns the requirement of file on the module that
+// provides path, which is either a module path or a package path, or nil if
+// there is none. If several modules could provide it, the longest path wins,
+// as it does for the go command.
+func providingRequire(file *modfile.File, path string) *modfile.Require {
+	var best *modfile.Require
+	for _, req := range file.Require {
+		if req.Syntax == nil {
+			continue
+		}
+		if p := req.Mod.Path; p == path || strings.HasPrefix(path, p+"/") {
+			if best == nil || len(p) > len(best.Mod.Path) {
+				best = req
+			}
+		}
+	}
+	return best
+}
+
+// ToolsGoSynchronizer is a sample source.ModSynchronizer for the tools.go
+// convention: a file, next to go.mod and excluded from builds by a build
+// tag, that imports the packages of the tools a module depends on, such as
+// code generators, so that their versions are tracked by go.mod. Each
+// package it imports must be provided by a requirement of go.mod.
+type ToolsGoSynchronizer struct{}
+
+// Name implements source.ModSynchronizer.
+func (ToolsGoSynchronizer) Name() string {
+	return "tools.go"
+}
+
+// Requirements implements source.ModSynchronizer. A module without a
+// tools.go file has no external requirements.
+func (ToolsGoSynchronizer) Requirements(ctx context.Context, snapshot source.Snapshot, uri span.URI) ([]source.ExternalRequirement, error) {
+	path := filepath.Join(filepath.Dir(uri.Filename()), "tools.go")
+	fh, err := snapshot.GetFile(ctx, span.URIFromPath(path))
+	if err != nil {
+		return nil, err
+	}
+	content, err := fh.Read()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toolsGoRequirements(path, content)
+}
+
+// toolsGoRequirements returns the packages imported by the tools.go file
+// content, except for those of the standard library.
+func toolsGoRequirements(filename string, content []byte) ([]source.ExternalRequirement, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, content, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+	var reqs []source.ExternalRequirement
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		if elem := strings.SplitN(path, "/", 2)[0]; !strings.Contains(elem, ".") {
+			continue
+		}
+		reqs = append(reqs, source.ExternalRequirement{Path: path})
+	}
+	return reqs, nil
+}
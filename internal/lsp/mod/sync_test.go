@@ -0,0 +1,94 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+type fakeSynchronizer struct {
+	reqs []source.ExternalRequirement
+	err  error
+}
+
+func (fakeSynchronizer) Name() string { return "deps.txt" }
+
+func (s fakeSynchronizer) Requirements(context.Context, source.Snapshot, span.URI) ([]source.ExternalRequirement, error) {
+	return s.reqs, s.err
+}
+
+func TestSyncErrors(t *testing.T) {
+	const mod = `module example.com/m
+
+go 1.14
+
+require (
+	example.com/tools v1.0.0
+	example.com/tools/gen v1.2.0
+	example.com/lib v1.0.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	synchronizers := []source.ModSynchronizer{
+		fakeSynchronizer{reqs: []source.ExternalRequirement{
+			{Path: "example.com/tools/gen/cmd/gen", Version: "v1.2.0"},
+			{Path: "example.com/tools/cmd/lint"},
+			{Path: "example.com/lib", Version: "v1.1.0"},
+			{Path: "example.com/missing"},
+		}},
+		fakeSynchronizer{err: fmt.Errorf("deps.txt is unreadable")},
+	}
+	errors, err := syncErrors(context.Background(), nil, uri, m, file, synchronizers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type result struct {
+		msg, text string
+	}
+	var got []result
+	for _, e := range errors {
+		got = append(got, result{e.Message, rangeText(t, m, e.Range)})
+	}
+	want := []result{
+		{"deps.txt lists example.com/lib at v1.1.0, but go.mod requires v1.0.0.", "v1.0.0"},
+		{"deps.txt lists example.com/missing, but no requirement in go.mod provides it.", "module example.com/m"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got errors %v, want %v", got, want)
+	}
+	if fixes := errors[0].SuggestedFixes; len(fixes) != 1 || fixes[0].Edits[uri][0].NewText != "v1.1.0" {
+		t.Errorf("got fixes %v, want one that requires v1.1.0", fixes)
+	}
+}
+
+func TestToolsGoRequirements(t *testing.T) {
+	const tools = `// +build tools
+
+package tools
+
+import (
+	_ "fmt"
+	_ "golang.org/x/tools/cmd/stringer"
+	_ "github.com/golang/mock/mockgen"
+)
+`
+	got, err := toolsGoRequirements("tools.go", []byte(tools))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []source.ExternalRequirement{
+		{Path: "golang.org/x/tools/cmd/stringer"},
+		{Path: "github.com/golang/mock/mockgen"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("toolsGoRequirements() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const replaceTargetCategory = "replace target"
+
+// replaceTargetErrors reports the replace directives in file that substitute
+// a module version that is not published for another module. Unlike the
+// checks of directory replacements, which look at the disk, this one looks
+// up the target's published versions with the go command, which may access
+// the network; if it fails, no errors are reported.
+func replaceTargetErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File) ([]source.Error, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, r := range file.Replace {
+		if isModuleTarget(r) && !seen[r.New.Path] {
+			seen[r.New.Path] = true
+			paths = append(paths, r.New.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	versions, err := listPublishedVersions(ctx, snapshot, paths)
+	if err != nil {
+		return nil, err
+	}
+	return missingTargetErrors(uri, m, file, versions)
+}
+
+// isModuleTarget reports whether r replaces a module with a released
+// version of another module. Pseudo-versions are not listed among the
+// published versions, so they are not checked.
+func isModuleTarget(r *modfile.Replace) bool {
+	return !modfile.IsDirectoryPath(r.New.Path) && r.New.Version != "" && !isPseudoVersion(r.New.Version)
+}
+
+// missingTargetErrors reports the replace directives in file whose target
+// version is not among the published versions of its module, in versions.
+// Modules whose versions are unknown are not checked. The fix uses the
+// nearest published version, as for a version that is gone.
+func missingTargetErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, versions map[string][]string) ([]source.Error, error) {
+	var errors []source.Error
+	for _, r := range file.Replace {
+		if r.Syntax == nil || !isModuleTarget(r) {
+			continue
+		}
+		published, ok := versions[r.New.Path]
+		if !ok || containsVersion(published, r.New.Version) {
+			continue
+		}
+		rng, err := tokenRange(uri, m, r.Syntax, len(r.Syntax.Token)-1)
+		if err != nil {
+			return nil, err
+		}
+		e := source.Error{
+			Category: replaceTargetCategory,
+			Message:  fmt.Sprintf("%s %s is not a published version, so %s cannot be replaced with it.", r.New.Path, r.New.Version, r.Old.Path),
+			Range:    rng,
+			URI:      uri,
+		}
+		if nearest := nearestVersion(r.New.Version, published); nearest != "" {
+			e.SuggestedFixes = []source.SuggestedFix{{
+				Title: fmt.Sprintf("Replace with %s %s", r.New.Path, nearest),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: rng, NewText: nearest}},
+				},
+			}}
+		}
+		errors = append(errors, e)
+	}
+	return errors, nil
+}
+
+func containsVersion(versions []string, v string) bool {
+	for _, version := range versions {
+		if version == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+)
+
+func TestMissingTargetErrors(t *testing.T) {
+	const mod = `module example.com/m
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/c v1.0.0
+	example.com/d v1.0.0
+	example.com/e v1.0.0
+	example.com/f v1.0.0
+)
+
+replace example.com/a => example.com/fork v1.2.0
+
+replace example.com/b => example.com/fork v1.1.0
+
+replace example.com/c => example.com/only v0.1.0
+
+replace example.com/d => example.com/fork v1.1.1-0.20200101000000-abcdefabcdef
+
+replace example.com/e => ../e
+
+replace example.com/f => example.com/unknown v1.0.0
+`
+	uri, m, file := parseTestMod(t, mod)
+	versions := map[string][]string{
+		"example.com/fork": {"v1.0.0", "v1.1.0", "v1.3.0"},
+		"example.com/only": {"v0.2.0"},
+	}
+	errors, err := missingTargetErrors(uri, m, file, versions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"v1.2.0": "v1.3.0",
+		"v0.1.0": "v0.2.0",
+	}
+	if len(errors) != len(want) {
+		t.Fatalf("got %d errors, want %d: %v", len(errors), len(want), errors)
+	}
+	for _, e := range errors {
+		version := rangeText(t, m, e.Range)
+		fix, ok := want[version]
+		if !ok {
+			t.Errorf("got error %q on %q, want none", e.Message, version)
+			continue
+		}
+		if len(e.SuggestedFixes) != 1 {
+			t.Errorf("got %d fixes for %s, want 1", len(e.SuggestedFixes), version)
+			continue
+		}
+		if got := e.SuggestedFixes[0].Edits[uri][0].NewText; got != fix {
+			t.Errorf("got fix to %s for %s, want %s", got, version, fix)
+		}
+	}
+	if want := "example.com/fork v1.2.0 is not a published version, so example.com/a cannot be replaced with it."; errors[0].Message != want {
+		t.Errorf("got message %q, want %q", errors[0].Message, want)
+	}
+}
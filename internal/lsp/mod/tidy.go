@@ -0,0 +1,166 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// CheckTidy reports whether the view's go.mod and go.sum files are those
+// that `go mod tidy` would produce, for use in CI, where the files must
+// match the committed versions. If they are not, modDiff and sumDiff are
+// the unified diffs that tidying would apply to each file; a diff is empty
+// if the file would not change. A missing go.sum file is compared as if it
+// were empty.
+func CheckTidy(ctx context.Context, snapshot source.Snapshot) (clean bool, modDiff, sumDiff []byte, err error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return true, nil, nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.CheckTidy", tag.URI.Of(uri))
+	defer done()
+
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	mth, err := snapshot.ModTidyHandle(ctx)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	ideal, err := mth.Ideal(ctx)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	if ideal == nil {
+		// The go.mod file has parse errors, so it cannot be tidied.
+		return false, nil, nil, fmt.Errorf("%s cannot be tidied", uri.Filename())
+	}
+	idealMod, err := ideal.Format()
+	if err != nil {
+		return false, nil, nil, err
+	}
+	idealSum, err := mth.IdealSum(ctx)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	mod, err := fh.Read()
+	if err != nil {
+		return false, nil, nil, err
+	}
+	var sum []byte
+	if sumFH := pmh.Sum(); sumFH != nil {
+		if sum, err = sumFH.Read(); err != nil {
+			return false, nil, nil, err
+		}
+	}
+	options := snapshot.View().Options()
+	modDiff = unifiedDiff(uri, mod, idealMod, options)
+	sumDiff = unifiedDiff(span.URIFromPath(uri.Filename()[:len(uri.Filename())-len("mod")]+"sum"), sum, idealSum, options)
+	return len(modDiff) == 0 && len(sumDiff) == 0, modDiff, sumDiff, nil
+}
+
+// unifiedDiff returns the unified diff from before to after of the file at
+// uri, or nil if they are the same.
+func unifiedDiff(uri span.URI, before, after []byte, options source.Options) []byte {
+	if string(before) == string(after) {
+		return nil
+	}
+	edits := options.ComputeEdits(uri, string(before), string(after))
+	name := uri.Filename()
+	return []byte(fmt.Sprint(diff.ToUnified(name+".orig", name, string(before), edits)))
+}
+
+// AddMissingOnly returns the edits that add the requirements that `go mod
+// tidy` would add to the view's go.mod file, without making any of its other
+// changes: no requirement is removed, and no version or indirect comment
+// changes.
+func AddMissingOnly(ctx context.Context, snapshot source.Snapshot) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "mod.AddMissingOnly")
+	defer done()
+
+	return selectiveTidyEdits(ctx, snapshot, Added)
+}
+
+// RemoveUnusedOnly returns the edits that remove the requirements that `go
+// mod tidy` would remove from the view's go.mod file, without making any of
+// its other changes.
+func RemoveUnusedOnly(ctx context.Context, snapshot source.Snapshot) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "mod.RemoveUnusedOnly")
+	defer done()
+
+	return selectiveTidyEdits(ctx, snapshot, Removed)
+}
+
+func selectiveTidyEdits(ctx context.Context, snapshot source.Snapshot, kind ChangeKind) ([]protocol.TextEdit, error) {
+	uri := snapshot.View().ModFile()
+	if uri == "" {
+		return nil, nil
+	}
+	fh, err := snapshot.GetFile(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	original, m, _, err := pmh.Parse(ctx)
+	if err != nil {
+		return nil, parseModError(err)
+	}
+	mth, err := snapshot.ModTidyHandle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ideal, err := mth.Ideal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if ideal == nil {
+		return nil, fmt.Errorf("%s cannot be tidied", uri.Filename())
+	}
+	return tidyChangeEdits(uri, m, original, ideal, kind, snapshot.View().Options())
+}
+
+// tidyChangeEdits returns the edits that make the requirement changes of the
+// given kind, Added or Removed, from original to its tidied form, ideal.
+func tidyChangeEdits(uri span.URI, m *protocol.ColumnMapper, original, ideal *modfile.File, kind ChangeKind, options source.Options) ([]protocol.TextEdit, error) {
+	var changes []RequireChange
+	for _, change := range requireChanges(original.Require, ideal.Require) {
+		if change.Kind == kind {
+			changes = append(changes, change)
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		for _, change := range changes {
+			switch kind {
+			case Added:
+				copied.AddNewRequire(change.Path, change.NewVersion, change.NewIndirect)
+			case Removed:
+				if err := copied.DropRequire(change.Path); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
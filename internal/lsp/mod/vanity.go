@@ -0,0 +1,150 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// vanityRepos caches the repository that each module path resolves to. A
+// vanity import path is not expected to move to another repository, so the
+// resolutions are kept for the life of the process. Failed resolutions are
+// not cached, so that they are retried, for example once the network is
+// back.
+var vanityRepos = struct {
+	mu    sync.Mutex
+	repos map[string]string
+}{repos: make(map[string]string)}
+
+// listedOrigin is the subset of the output of `go list -m -json` that
+// describes where a module version comes from.
+type listedOrigin struct {
+	Path   string
+	Error  *struct{ Err string }
+	Origin *struct {
+		URL    string
+		Subdir string
+	}
+}
+
+// vanityPolicyErrors reports the module paths in file that the policy of the
+// view's options does not permit, after resolving the paths that
+// AllowedModules does not permit to their repositories, which the go command
+// finds by following the go-import meta tags served for vanity import paths.
+// Paths that cannot be resolved are not reported, so that the check does not
+// flag every vanity path when offline.
+func vanityPolicyErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File) ([]source.Error, error) {
+	options := snapshot.View().Options()
+	var mods []module.Version
+	add := func(mod module.Version) {
+		if onlyNotAllowed(mod.Path, options) {
+			mods = append(mods, mod)
+		}
+	}
+	for _, req := range file.Require {
+		add(req.Mod)
+	}
+	for _, r := range file.Replace {
+		add(r.Old)
+		if !modfile.IsDirectoryPath(r.New.Path) {
+			add(r.New)
+		}
+	}
+	repos, err := resolveRepos(ctx, snapshot, mods)
+	if err != nil {
+		return nil, err
+	}
+	return modulePolicyErrors(uri, m, file, options, repos)
+}
+
+// resolveRepos returns the repository of each of the modules that can be
+// resolved, keyed by module path, such as github.com/rsc/quote for
+// rsc.io/quote. Modules without a version are looked up at their latest
+// version. If the go command fails, only the cached resolutions are
+// returned.
+func resolveRepos(ctx context.Context, snapshot source.Snapshot, mods []module.Version) (map[string]string, error) {
+	repos := make(map[string]string)
+	var queries []string
+	vanityRepos.mu.Lock()
+	for _, mod := range mods {
+		if repo, ok := vanityRepos.repos[mod.Path]; ok {
+			repos[mod.Path] = repo
+			continue
+		}
+		version := mod.Version
+		if version == "" {
+			version = "latest"
+		}
+		queries = append(queries, mod.Path+"@"+version)
+	}
+	vanityRepos.mu.Unlock()
+	if len(queries) == 0 {
+		return repos, nil
+	}
+	args := append([]string{"-e", "-m", "-json"}, queries...)
+	stdout, err := snapshot.RunGoCommand(ctx, "list", args)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "resolving vanity import paths", err)
+		return repos, nil
+	}
+	resolved, err := parseOrigins(stdout)
+	if err != nil {
+		return nil, err
+	}
+	vanityRepos.mu.Lock()
+	defer vanityRepos.mu.Unlock()
+	for path, repo := range resolved {
+		vanityRepos.repos[path] = repo
+		repos[path] = repo
+	}
+	return repos, nil
+}
+
+// parseOrigins returns the repository of each module in the output of
+// `go list -m -json`, r, that reports where it comes from.
+func parseOrigins(r io.Reader) (map[string]string, error) {
+	repos := make(map[string]string)
+	for dec := json.NewDecoder(r); dec.More(); {
+		var mod listedOrigin
+		if err := dec.Decode(&mod); err != nil {
+			return nil, err
+		}
+		if mod.Error != nil || mod.Origin == nil {
+			continue
+		}
+		if repo := repoPath(mod.Origin.URL, mod.Origin.Subdir); repo != "" {
+			repos[mod.Path] = repo
+		}
+	}
+	return repos, nil
+}
+
+// repoPath returns the repository URL rawURL, in the subdirectory subdir, as
+// a module path: without its scheme and .git suffix. It returns "" if the
+// URL cannot be parsed.
+func repoPath(rawURL, subdir string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	p := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	return path.Join(u.Host, p, subdir)
+}
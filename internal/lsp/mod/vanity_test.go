@@ -0,0 +1,96 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestRepoPath(t *testing.T) {
+	for _, test := range []struct {
+		url, subdir, want string
+	}{
+		{"https://github.com/rsc/quote", "", "github.com/rsc/quote"},
+		{"https://go.googlesource.com/tools.git", "", "go.googlesource.com/tools"},
+		{"https://github.com/corp/mono/", "lib", "github.com/corp/mono/lib"},
+		{"not a url", "", ""},
+	} {
+		if got := repoPath(test.url, test.subdir); got != test.want {
+			t.Errorf("repoPath(%q, %q) = %q, want %q", test.url, test.subdir, got, test.want)
+		}
+	}
+}
+
+func TestParseOrigins(t *testing.T) {
+	const listed = `{
+	"Path": "rsc.io/quote",
+	"Version": "v1.5.2",
+	"Origin": {"VCS": "git", "URL": "https://github.com/rsc/quote"}
+}
+{
+	"Path": "go.corp.com/offline",
+	"Version": "v1.0.0",
+	"Error": {"Err": "module lookup disabled by GOPROXY=off"}
+}
+{
+	"Path": "example.com/cached",
+	"Version": "v1.0.0"
+}
+`
+	got, err := parseOrigins(strings.NewReader(listed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"rsc.io/quote": "github.com/rsc/quote"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseOrigins() = %v, want %v", got, want)
+	}
+}
+
+func TestModulePolicyResolvedRepos(t *testing.T) {
+	const mod = `module example.com/m
+
+require (
+	go.corp.com/lib v1.0.0
+	go.corp.com/bad v1.0.0
+	go.corp.com/offline v1.0.0
+	github.com/evil/pkg v1.0.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	options := source.DefaultOptions()
+	options.AllowedModules = []string{"github.com"}
+	options.DeniedModules = []string{"github.com/evil"}
+	repos := map[string]string{
+		"go.corp.com/lib": "github.com/corp/lib",
+		"go.corp.com/bad": "gitlab.com/other/bad",
+	}
+	errors, err := modulePolicyErrors(uri, m, file, options, repos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// go.corp.com/lib resolves to an allowed repository, and
+	// go.corp.com/offline cannot be resolved, so neither is reported.
+	var got []string
+	for _, e := range errors {
+		got = append(got, rangeText(t, m, e.Range))
+	}
+	if want := []string{"go.corp.com/bad", "github.com/evil/pkg"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors on %q, want %q", got, want)
+	}
+
+	// Without resolution, the vanity paths are judged as they are written.
+	errors, err = checkModulePolicy(uri, m, file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 4 {
+		t.Errorf("got %d errors without resolution, want 4", len(errors))
+	}
+}
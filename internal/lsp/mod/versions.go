@@ -0,0 +1,141 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const earliestVersionCategory = "unpublished version"
+
+// publishedVersions is the subset of the output of `go list -m -versions
+// -json` that lists the published versions of a module.
+type publishedVersions struct {
+	Path     string
+	Versions []string
+	Error    *struct{ Err string }
+}
+
+// earliestVersionErrors reports requirements on a version older than the
+// earliest published version of the module, as listed by the go command.
+// Requirements that a replace directive covers are not checked, as their
+// version need not be published. Listing the versions may require network
+// access, so if the go command fails, no errors are reported.
+func earliestVersionErrors(ctx context.Context, snapshot source.Snapshot, uri span.URI, m *protocol.ColumnMapper, file *modfile.File) ([]source.Error, error) {
+	paths := unreplacedRequirePaths(file)
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	versions, err := listPublishedVersions(ctx, snapshot, paths)
+	if err != nil {
+		return nil, err
+	}
+	return belowEarliestErrors(uri, m, file, versions)
+}
+
+// unreplacedRequirePaths returns the paths of the modules required by file
+// that none of its replace directives cover.
+func unreplacedRequirePaths(file *modfile.File) []string {
+	replaced := make(map[string]bool, len(file.Replace))
+	for _, r := range file.Replace {
+		replaced[r.Old.Path] = true
+	}
+	var paths []string
+	for _, req := range file.Require {
+		if !replaced[req.Mod.Path] {
+			paths = append(paths, req.Mod.Path)
+		}
+	}
+	return paths
+}
+
+// listPublishedVersions returns the published versions of each of the given
+// modules, in semver order, as listed by the go command. Modules that cannot
+// be found are omitted. Listing the versions may require network access, so
+// if the go command fails, nil is returned without an error.
+func listPublishedVersions(ctx context.Context, snapshot source.Snapshot, paths []string) (map[string][]string, error) {
+	args := append([]string{"-e", "-m", "-versions", "-json"}, paths...)
+	stdout, err := snapshot.RunGoCommand(ctx, "list", args)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		event.Error(ctx, "listing published versions", err)
+		return nil, nil
+	}
+	versions := make(map[string][]string)
+	for dec := json.NewDecoder(stdout); dec.More(); {
+		var mod publishedVersions
+		if err := dec.Decode(&mod); err != nil {
+			return nil, err
+		}
+		if mod.Error == nil {
+			versions[mod.Path] = mod.Versions
+		}
+	}
+	return versions, nil
+}
+
+// belowEarliestErrors reports the requirements in file that are below the
+// earliest of their module's published versions. Pseudo-versions, and
+// modules without any published versions, are not checked, as they refer to
+// commits rather than releases.
+func belowEarliestErrors(uri span.URI, m *protocol.ColumnMapper, file *modfile.File, versions map[string][]string) ([]source.Error, error) {
+	var errors []source.Error
+	for _, req := range file.Require {
+		if req.Syntax == nil || isPseudoVersion(req.Mod.Version) {
+			continue
+		}
+		published := versions[req.Mod.Path]
+		if len(published) == 0 {
+			continue
+		}
+		earliest := published[0]
+		for _, v := range published[1:] {
+			if semver.Compare(v, earliest) < 0 {
+				earliest = v
+			}
+		}
+		if semver.Compare(req.Mod.Version, earliest) >= 0 {
+			continue
+		}
+		rng, err := tokenRange(uri, m, req.Syntax, len(req.Syntax.Token)-1)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: earliestVersionCategory,
+			Message:  fmt.Sprintf("%s %s is older than the earliest published version, %s.", req.Mod.Path, req.Mod.Version, earliest),
+			Range:    rng,
+			URI:      uri,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Use %s %s", req.Mod.Path, earliest),
+				Edits: map[span.URI][]protocol.TextEdit{
+					uri: {{Range: rng, NewText: earliest}},
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// pseudoVersionRE matches pseudo-versions, as in cmd/go.
+var pseudoVersionRE = regexp.MustCompile(`^v[0-9]+\.(0\.0-|\d+\.\d+-([^+]*\.)?0\.)\d{14}-[A-Za-z0-9]+(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// isPseudoVersion reports whether v is a pseudo-version.
+func isPseudoVersion(v string) bool {
+	return strings.Count(v, "-") >= 2 && semver.IsValid(v) && pseudoVersionRE.MatchString(v)
+}
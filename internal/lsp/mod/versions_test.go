@@ -0,0 +1,70 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBelowEarliestErrors(t *testing.T) {
+	const mod = `module mod.com
+
+require (
+	example.com/typo v0.0.1
+	example.com/ok v1.1.0
+	example.com/pseudo v0.0.0-20200101000000-abcdefabcdef
+	example.com/unpublished v0.1.0
+	example.com/unknown v0.1.0
+)
+`
+	uri, m, file := parseTestMod(t, mod)
+	errors, err := belowEarliestErrors(uri, m, file, map[string][]string{
+		"example.com/typo":   {"v1.0.0", "v1.1.0"},
+		"example.com/ok":     {"v1.0.0", "v1.1.0"},
+		"example.com/pseudo": {"v1.0.0"},
+		// Modules with only pseudo-versions have no published versions.
+		"example.com/unpublished": {},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if want := "example.com/typo v0.0.1 is older than the earliest published version, v1.0.0."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if got := rangeText(t, m, e.Range); got != "v0.0.1" {
+		t.Errorf("got range covering %q, want the version", got)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	if edits := e.SuggestedFixes[0].Edits[uri]; len(edits) != 1 || edits[0].NewText != "v1.0.0" {
+		t.Errorf("got edits %v, want the version replaced by v1.0.0", edits)
+	}
+}
+
+func TestUnreplacedRequirePaths(t *testing.T) {
+	const mod = `module mod.com
+
+require (
+	example.com/a v1.0.0
+	example.com/b v0.0.1
+	example.com/c v0.0.1
+)
+
+replace example.com/b => ../b
+
+replace example.com/c v0.0.1 => example.com/fork v1.0.0
+`
+	_, _, file := parseTestMod(t, mod)
+	want := []string{"example.com/a"}
+	if got := unreplacedRequirePaths(file); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/debug/tag"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+// viaPrefix starts the text that an indirect comment carries after
+// "indirect;" to name the direct dependency that needs the module.
+const viaPrefix = "via "
+
+// ViaComments returns the edits that annotate each indirect requirement of
+// the go.mod file with the direct dependency that imports it, according to
+// `go mod why`, as in "// indirect; via example.com/foo". Indirect comments
+// that carry other text are left alone. StripViaComments undoes the edits.
+func ViaComments(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "mod.ViaComments", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	file, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	mwh, err := snapshot.ModWhyHandle(ctx)
+	if err != nil {
+		return nil, err
+	}
+	why, err := mwh.Why(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("running go mod why: %w", err)
+	}
+	return viaEdits(fh.URI(), m, viaModules(file, why), snapshot.View().Options())
+}
+
+// StripViaComments returns the edits that remove the annotations added by
+// ViaComments, leaving plain "// indirect" comments.
+func StripViaComments(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]protocol.TextEdit, error) {
+	ctx, done := event.Start(ctx, "mod.StripViaComments", tag.URI.Of(fh.URI()))
+	defer done()
+
+	pmh, err := snapshot.ParseModHandle(ctx, fh)
+	if err != nil {
+		return nil, err
+	}
+	_, m, parseErrors, err := pmh.Parse(ctx)
+	if len(parseErrors) > 0 {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return viaEdits(fh.URI(), m, nil, snapshot.View().Options())
+}
+
+// viaModules returns the direct dependency that needs each indirect
+// requirement of file, keyed by the indirect module's path, given the output
+// of `go mod why -m` for each requirement. The direct dependency is the
+// module of the first package outside the main module in the chain of
+// imports that `go mod why` prints.
+func viaModules(file *modfile.File, why map[string]string) map[string]string {
+	if file.Module == nil {
+		return nil
+	}
+	main := file.Module.Mod.Path
+	direct := make(map[string]bool)
+	for _, req := range file.Require {
+		if !req.Indirect {
+			direct[req.Mod.Path] = true
+		}
+	}
+	via := make(map[string]string)
+	for _, req := range file.Require {
+		if !req.Indirect {
+			continue
+		}
+		for _, pkg := range strings.Split(why[req.Mod.Path], "\n") {
+			pkg = strings.TrimSpace(pkg)
+			if pkg == "" || strings.HasPrefix(pkg, "#") || strings.HasPrefix(pkg, "(") {
+				continue
+			}
+			if pkg == main || strings.HasPrefix(pkg, main+"/") {
+				continue
+			}
+			if modPath := requiredModule(file, pkg); direct[modPath] && modPath != req.Mod.Path {
+				via[req.Mod.Path] = modPath
+			}
+			break
+		}
+	}
+	return via
+}
+
+// viaEdits returns the edits that make the indirect comment of each indirect
+// requirement in the file name the module that via maps it to, or, for
+// modules not in via, that remove the module named by the comment.
+func viaEdits(uri span.URI, m *protocol.ColumnMapper, via map[string]string, options source.Options) ([]protocol.TextEdit, error) {
+	return rewriteEdits(uri, m, options, func(copied *modfile.File) error {
+		for _, req := range copied.Require {
+			if !req.Indirect || req.Syntax == nil || len(req.Syntax.Suffix) == 0 {
+				continue
+			}
+			com := &req.Syntax.Suffix[0]
+			text := strings.TrimSpace(strings.TrimPrefix(com.Token, "//"))
+			if text != "indirect" && !strings.HasPrefix(text, "indirect; "+viaPrefix) {
+				continue
+			}
+			if mod, ok := via[req.Mod.Path]; ok {
+				com.Token = "// indirect; " + viaPrefix + mod
+			} else {
+				com.Token = "// indirect"
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"testing"
+
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/source"
+)
+
+func TestViaComments(t *testing.T) {
+	const mod = `module mod.com
+
+go 1.14
+
+require (
+	example.com/direct v1.0.0
+	example.com/dep v1.0.0 // indirect
+	example.com/used v1.0.0 // indirect
+	example.com/noted v1.0.0 // indirect; pinned for a bug fix
+)
+`
+	const annotated = `module mod.com
+
+go 1.14
+
+require (
+	example.com/direct v1.0.0
+	example.com/dep v1.0.0 // indirect; via example.com/direct
+	example.com/used v1.0.0 // indirect
+	example.com/noted v1.0.0 // indirect; pinned for a bug fix
+)
+`
+	why := map[string]string{
+		"example.com/direct": "# example.com/direct\nmod.com\nexample.com/direct\n",
+		"example.com/dep":    "# example.com/dep\nmod.com/sub\nexample.com/direct/pkg\nexample.com/dep\n",
+		// The main module imports example.com/used itself, so no other
+		// module is to blame.
+		"example.com/used":  "# example.com/used\nmod.com\nexample.com/used\n",
+		"example.com/noted": "# example.com/noted\nmod.com\nexample.com/direct\nexample.com/noted\n",
+	}
+	apply := func(content string, via map[string]string) string {
+		t.Helper()
+		uri, m := testMapper(content)
+		edits, err := viaEdits(uri, m, via, source.DefaultOptions())
+		if err != nil {
+			t.Fatal(err)
+		}
+		diffEdits, err := source.FromProtocolEdits(m, edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return diff.ApplyEdits(content, diffEdits)
+	}
+	_, _, file := parseTestMod(t, mod)
+	if got := apply(mod, viaModules(file, why)); got != annotated {
+		t.Errorf("annotated go.mod:\ngot:\n%s\nwant:\n%s", got, annotated)
+	}
+	if got := apply(annotated, nil); got != mod {
+		t.Errorf("stripped go.mod:\ngot:\n%s\nwant:\n%s", got, mod)
+	}
+}
@@ -0,0 +1,829 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/internal/event"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/span"
+)
+
+const (
+	workGoDirectiveCategory     = "workspace go directive"
+	workReplaceCycleCategory    = "workspace replace cycle"
+	workReplaceOverrideCategory = "overridden replace"
+	workSecurityReplaceCategory = "missing security replace"
+	workGoVersionCategory       = "go.work go version"
+	workMissingGoCategory       = "missing go.work go directive"
+)
+
+// workFileGoVersion is the go version of the first go command that supports
+// go.work files, suggested for a go.work file without a go directive when
+// the version of the go command in use is unknown.
+const workFileGoVersion = 18
+
+// prunedGoVersion is the first go version whose modules have a pruned module
+// graph. Modules on either side of it load their dependencies differently.
+const prunedGoVersion = "v1.17"
+
+// workMember is the parsed go.mod file of a module in the workspace.
+type workMember struct {
+	uri  span.URI
+	m    *protocol.ColumnMapper
+	file *modfile.File
+}
+
+// WorkDiagnostics compares the go directives of the modules in the
+// workspace and warns when some of them have a pruned module graph and others
+// do not. It also warns about cycles of directory replacements between the
+// modules, about their replace directives that a go.work file overrides,
+// and about modules that lack a security replace that another module has.
+// Modules whose go directive is above the go.work file's, which the go
+// command refuses to load, are reported as errors.
+//
+// If a go.work file is found in the folder of the first snapshot's view or
+// in one of its ancestors, the workspace consists of the modules it uses.
+// Otherwise, it consists of the modules of the given snapshots' views. The
+// mix of pruned and unpruned module graphs is reported on the use directive
+// of each module in the go.work file, if there is one, and on the go
+// directive of each module otherwise.
+//
+// The result has an entry, possibly empty, for the go.mod file of every
+// module in the workspace and for the go.work file, so that callers can
+// clear the diagnostics that no longer apply.
+//
+// The go directive of the go.work file itself is validated as that of a
+// go.mod file is, and reported if it is missing.
+func WorkDiagnostics(ctx context.Context, snapshots []source.Snapshot) (map[source.FileIdentity][]*source.Diagnostic, error) {
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+	ctx, done := event.Start(ctx, "mod.WorkDiagnostics")
+	defer done()
+
+	work, err := readParsedWork(ctx, snapshots)
+	if err != nil {
+		return nil, err
+	}
+	members, ids, err := workMembers(ctx, snapshots, work)
+	if err != nil {
+		return nil, err
+	}
+	if work != nil {
+		ids[work.uri] = work.fh.Identity()
+	}
+	errors, err := workGoDirectiveErrors(members, work)
+	if err != nil {
+		return nil, err
+	}
+	cycleErrors, err := workReplaceCycleErrors(members)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, cycleErrors...)
+	options := snapshots[0].View().Options()
+	securityErrors, err := workSecurityReplaceErrors(members, options.SecurityReplaceMarker, options)
+	if err != nil {
+		return nil, err
+	}
+	errors = append(errors, securityErrors...)
+	if work != nil {
+		if work.file != nil {
+			overrideErrors, err := workReplaceOverrideErrors(work.uri.Filename(), workReplaces(work.file), members, options)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, overrideErrors...)
+		}
+		goVersionErrors, err := workGoVersionErrors(work, members)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, goVersionErrors...)
+		workGoErrors, err := workFileGoErrors(work, snapshots[0].View().GoVersion(), options)
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, workGoErrors...)
+	}
+	reports := make(map[source.FileIdentity][]*source.Diagnostic, len(ids))
+	for _, id := range ids {
+		reports[id] = []*source.Diagnostic{}
+	}
+	for _, e := range errors {
+		id := ids[e.URI]
+		reports[id] = append(reports[id], toDiagnostic(e))
+	}
+	return reports, nil
+}
+
+// workMembers returns the parsed go.mod files of the modules in the
+// workspace, as described for WorkDiagnostics, along with their file
+// identities. work is the go.work file of the workspace, or nil if there is
+// none. go.mod files that cannot be parsed are skipped, as are the go.mod
+// files of views in the IgnoredModDirs of another view's folder. If the
+// go.work file cannot be parsed, no modules are returned; its parse errors
+// are reported on their own.
+func workMembers(ctx context.Context, snapshots []source.Snapshot, work *parsedWork) ([]workMember, map[span.URI]source.FileIdentity, error) {
+	var members []workMember
+	ids := make(map[span.URI]source.FileIdentity)
+	addMember := func(snapshot source.Snapshot, uri span.URI) error {
+		fh, err := snapshot.GetFile(ctx, uri)
+		if err != nil {
+			return err
+		}
+		pmh, err := snapshot.ParseModHandle(ctx, fh)
+		if err != nil {
+			return err
+		}
+		file, m, _, err := pmh.Parse(ctx)
+		if err != nil {
+			// Unparseable go.mod files are diagnosed on their own.
+			return nil
+		}
+		members = append(members, workMember{uri: uri, m: m, file: file})
+		ids[uri] = fh.Identity()
+		return nil
+	}
+	if work != nil {
+		if work.file == nil {
+			return nil, ids, nil
+		}
+		for _, dir := range workUseDirs(work.uri.Filename(), work.file) {
+			if err := addMember(snapshots[0], span.URIFromPath(filepath.Join(dir, "go.mod"))); err != nil {
+				return nil, nil, err
+			}
+		}
+	} else {
+		var folders []string
+		for _, snapshot := range snapshots {
+			folders = append(folders, snapshot.View().Folder().Filename())
+		}
+		for _, snapshot := range snapshots {
+			uri := snapshot.View().ModFile()
+			if uri == "" || ignoredModFile(uri.Filename(), folders, snapshot.View().Options().IgnoredModDirs) {
+				continue
+			}
+			if err := addMember(snapshot, uri); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return members, ids, nil
+}
+
+// readWorkFile returns the go.work file found in the folder of the first
+// snapshot's view or in one of its ancestors, as described for
+// WorkDiagnostics, and its contents, or nil if there is none.
+func readWorkFile(ctx context.Context, snapshots []source.Snapshot) (source.FileHandle, []byte, error) {
+	if len(snapshots) == 0 {
+		return nil, nil, nil
+	}
+	exists := func(path string) bool {
+		fh, err := snapshots[0].GetFile(ctx, span.URIFromPath(path))
+		if err != nil {
+			return false
+		}
+		_, err = fh.Read()
+		return err == nil
+	}
+	workFile := findWorkFile(snapshots[0].View().Folder().Filename(), exists)
+	if workFile == "" {
+		return nil, nil, nil
+	}
+	fh, err := snapshots[0].GetFile(ctx, span.URIFromPath(workFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := fh.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	return fh, content, nil
+}
+
+// A parsedWork is a go.work file, read and parsed once for all the checks of
+// WorkDiagnostics.
+type parsedWork struct {
+	fh  source.FileHandle
+	uri span.URI
+	m   *protocol.ColumnMapper
+
+	// file is the go.work file as parsed by the go.mod parser in lax mode,
+	// or nil if it cannot be parsed, in which case err is the parse error.
+	file *modfile.File
+	err  error
+}
+
+// readParsedWork reads and parses the go.work file that readWorkFile
+// returns, or returns nil if there is none.
+func readParsedWork(ctx context.Context, snapshots []source.Snapshot) (*parsedWork, error) {
+	fh, content, err := readWorkFile(ctx, snapshots)
+	if err != nil || fh == nil {
+		return nil, err
+	}
+	work := parseWork(fh.URI(), content)
+	work.fh = fh
+	return work, nil
+}
+
+// parseWork parses the go.work file uri, whose contents are given.
+func parseWork(uri span.URI, content []byte) *parsedWork {
+	file, err := modfile.ParseLax(uri.Filename(), content, nil)
+	return &parsedWork{uri: uri, m: workMapper(uri, content), file: file, err: err}
+}
+
+// workspaceRoots returns the directories that make up the workspace of the
+// go.mod file fh in snapshot: the view folder, the directory of fh, and the
+// directory of the go.work file that applies to the view, if any.
+func workspaceRoots(ctx context.Context, snapshot source.Snapshot, fh source.FileHandle) ([]string, error) {
+	roots := []string{snapshot.View().Folder().Filename(), filepath.Dir(fh.URI().Filename())}
+	workFH, _, err := readWorkFile(ctx, []source.Snapshot{snapshot})
+	if err != nil {
+		return nil, err
+	}
+	if workFH != nil {
+		roots = append(roots, filepath.Dir(workFH.URI().Filename()))
+	}
+	return roots, nil
+}
+
+// findWorkFile returns the path of the go.work file in dir or in the
+// nearest of its ancestors that has one, or "" if there is none.
+func findWorkFile(dir string, exists func(path string) bool) string {
+	for dir = filepath.Clean(dir); ; {
+		if path := filepath.Join(dir, "go.work"); exists(path) {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// workUseDirs returns the absolute directories of the modules listed in the
+// use directives of the parsed go.work file at path.
+func workUseDirs(path string, file *modfile.File) []string {
+	var dirs []string
+	for _, tokens := range laxDirectives(file, "use") {
+		if dir, ok := workUseDir(path, tokens); ok {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// workUseLines returns the use directives of the parsed go.work file at
+// path, keyed by the absolute directory of the module they list.
+func workUseLines(path string, file *modfile.File) map[string]*modfile.Line {
+	lines := make(map[string]*modfile.Line)
+	directives, args := laxDirectiveLines(file, "use")
+	for i, line := range directives {
+		if dir, ok := workUseDir(path, args[i]); ok {
+			lines[dir] = line
+		}
+	}
+	return lines
+}
+
+// workUseDir returns the absolute directory listed by a use directive of the
+// go.work file at path with the given arguments, and whether it lists one.
+func workUseDir(path string, tokens []string) (string, bool) {
+	if len(tokens) != 1 {
+		return "", false
+	}
+	dir := filepath.FromSlash(unquoteToken(tokens[0]))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(path), dir)
+	}
+	return filepath.Clean(dir), true
+}
+
+// A workReplace is a replace directive of a go.work file.
+type workReplace struct {
+	old, new module.Version
+}
+
+// workReplaces returns the replace directives of the parsed go.work file.
+// Directories are left as they are written.
+func workReplaces(file *modfile.File) []workReplace {
+	var replaces []workReplace
+	for _, tokens := range laxDirectives(file, "replace") {
+		arrow := -1
+		for i, tok := range tokens {
+			if tok == "=>" {
+				arrow = i
+			}
+		}
+		if arrow < 0 {
+			continue
+		}
+		old, new := tokens[:arrow], tokens[arrow+1:]
+		if len(old) < 1 || len(old) > 2 || len(new) < 1 || len(new) > 2 {
+			continue
+		}
+		var r workReplace
+		r.old.Path, r.new.Path = unquoteToken(old[0]), unquoteToken(new[0])
+		if len(old) == 2 {
+			r.old.Version = old[1]
+		}
+		if len(new) == 2 {
+			r.new.Version = new[1]
+		}
+		replaces = append(replaces, r)
+	}
+	return replaces
+}
+
+// laxDirectives returns the arguments of each of the directives with the
+// given verb in the file, whether they are on a line of their own or in a
+// block. The go.mod parser does not know the directives of go.work files,
+// and ignores replace directives in lax mode, but it keeps them in the
+// syntax tree, so they are read from there.
+func laxDirectives(file *modfile.File, verb string) [][]string {
+	_, args := laxDirectiveLines(file, verb)
+	return args
+}
+
+// laxDirectiveLines is like laxDirectives, but also returns the line of each
+// directive.
+func laxDirectiveLines(file *modfile.File, verb string) ([]*modfile.Line, [][]string) {
+	var lines []*modfile.Line
+	var args [][]string
+	for _, stmt := range file.Syntax.Stmt {
+		switch stmt := stmt.(type) {
+		case *modfile.Line:
+			if len(stmt.Token) > 0 && stmt.Token[0] == verb {
+				lines = append(lines, stmt)
+				args = append(args, stmt.Token[1:])
+			}
+		case *modfile.LineBlock:
+			if len(stmt.Token) == 1 && stmt.Token[0] == verb {
+				for _, line := range stmt.Line {
+					lines = append(lines, line)
+					args = append(args, line.Token)
+				}
+			}
+		}
+	}
+	return lines, args
+}
+
+// unquoteToken returns tok without the quotes that the go.mod syntax allows
+// around paths.
+func unquoteToken(tok string) string {
+	if unquoted, err := strconv.Unquote(tok); err == nil {
+		return unquoted
+	}
+	return tok
+}
+
+// workGoDirectiveErrors reports each workspace member if the members' go
+// versions span prunedGoVersion. If there is a go.work file, work, that can
+// be parsed, the members are reported on the use directives that list them,
+// or on its go directive, with the go directive of the member as related
+// information. Otherwise, they are reported on their go directive.
+func workGoDirectiveErrors(members []workMember, work *parsedWork) ([]source.Error, error) {
+	var withGo []workMember
+	for _, member := range members {
+		if member.file.Module != nil && member.file.Go != nil && member.file.Go.Syntax != nil {
+			withGo = append(withGo, member)
+		}
+	}
+	if len(withGo) < 2 {
+		return nil, nil
+	}
+	sort.Slice(withGo, func(i, j int) bool {
+		return semver.Compare(goSemver(withGo[i]), goSemver(withGo[j])) < 0
+	})
+	lowest, highest := withGo[0], withGo[len(withGo)-1]
+	if semver.Compare(goSemver(lowest), prunedGoVersion) >= 0 || semver.Compare(goSemver(highest), prunedGoVersion) < 0 {
+		return nil, nil
+	}
+	var versions []string
+	for _, member := range withGo {
+		versions = append(versions, fmt.Sprintf("%s (go %s)", member.file.Module.Mod.Path, member.file.Go.Version))
+	}
+	msg := fmt.Sprintf("The workspace modules mix go versions before and after go %s, so only some of them have a pruned module graph: %s. Consider aligning their go directives.",
+		strings.TrimPrefix(prunedGoVersion, "v"), strings.Join(versions, ", "))
+
+	var workGo *modfile.Line
+	var useLines map[string]*modfile.Line
+	if work != nil && work.file != nil {
+		useLines = workUseLines(work.uri.Filename(), work.file)
+		if work.file.Go != nil {
+			workGo = work.file.Go.Syntax
+		}
+	}
+	var errors []source.Error
+	for _, member := range withGo {
+		line := useLines[filepath.Dir(member.uri.Filename())]
+		if line == nil {
+			line = workGo
+		}
+		if line == nil {
+			rng, err := positionsToRange(member.uri, member.m, member.file.Go.Syntax.Start, member.file.Go.Syntax.End)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: workGoDirectiveCategory,
+				Message:  msg,
+				Range:    rng,
+				URI:      member.uri,
+			})
+			continue
+		}
+		rng, err := positionsToRange(work.uri, work.m, line.Start, line.End)
+		if err != nil {
+			return nil, err
+		}
+		related, err := relatedLine(member.uri, member.m, member.file.Go.Syntax, fmt.Sprintf("The go directive of %s is here.", member.file.Module.Mod.Path))
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: workGoDirectiveCategory,
+			Message:  msg,
+			Range:    rng,
+			URI:      work.uri,
+			Related:  related,
+		})
+	}
+	return errors, nil
+}
+
+// workGoVersionErrors reports the go directive of each workspace member
+// whose go version is above that of the go.work file work. The fix raises
+// the go.work file's go directive to the member's version. No errors are
+// reported if the go.work file cannot be parsed or has no go directive.
+func workGoVersionErrors(work *parsedWork, members []workMember) ([]source.Error, error) {
+	file := work.file
+	if file == nil || file.Go == nil || file.Go.Syntax == nil {
+		return nil, nil
+	}
+	workURI, workFile, workVersion, m := work.uri, work.uri.Filename(), file.Go.Version, work.m
+	workRng, err := tokenRange(workURI, m, file.Go.Syntax, 1)
+	if err != nil {
+		return nil, err
+	}
+	var errors []source.Error
+	for _, member := range members {
+		if member.file.Go == nil || member.file.Go.Syntax == nil {
+			continue
+		}
+		version := member.file.Go.Version
+		if semver.Compare("v"+version, "v"+workVersion) <= 0 {
+			continue
+		}
+		rng, err := positionsToRange(member.uri, member.m, member.file.Go.Syntax.Start, member.file.Go.Syntax.End)
+		if err != nil {
+			return nil, err
+		}
+		related, err := relatedLine(workURI, m, file.Go.Syntax, "The go.work go directive is here.")
+		if err != nil {
+			return nil, err
+		}
+		errors = append(errors, source.Error{
+			Category: workGoVersionCategory,
+			Message: fmt.Sprintf("This module requires go %s, but %s only lists go %s, so the go command cannot load the workspace. Consider aligning the go directives.",
+				version, workFile, workVersion),
+			Range:   rng,
+			URI:     member.uri,
+			Related: related,
+			SuggestedFixes: []source.SuggestedFix{{
+				Title: fmt.Sprintf("Update the go.work go directive to %s", version),
+				Edits: map[span.URI][]protocol.TextEdit{
+					workURI: {{Range: workRng, NewText: version}},
+				},
+			}},
+		})
+	}
+	return errors, nil
+}
+
+// workFileGoErrors reports the problems with the go directive of the
+// go.work file work: the go versions that goDirectiveErrors reports for a
+// go.mod file, the other errors of the go.mod parser, which rejects invalid
+// go versions, and a missing go directive, with a fix that adds one for
+// goversion, the minor version of the go command in use, if it is known.
+func workFileGoErrors(work *parsedWork, goversion int, options source.Options) ([]source.Error, error) {
+	uri, m, file := work.uri, work.m, work.file
+	errors, err := goDirectiveErrors(uri, m, goversion)
+	if err != nil || len(errors) > 0 {
+		return errors, err
+	}
+	if err := work.err; err != nil {
+		errList, ok := err.(modfile.ErrorList)
+		if !ok {
+			return nil, err
+		}
+		for _, e := range errList {
+			rng, err := lineRange(uri, m, e.Pos)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: syntaxCategory,
+				Message:  e.Err.Error(),
+				Range:    rng,
+				URI:      uri,
+			})
+		}
+		return errors, nil
+	}
+	if file.Go != nil {
+		return nil, nil
+	}
+	if goversion < workFileGoVersion {
+		goversion = workFileGoVersion
+	}
+	version := fmt.Sprintf("1.%d", goversion)
+	if err := file.AddGoStmt(version); err != nil {
+		return nil, err
+	}
+	newContent, err := file.Format()
+	if err != nil {
+		return nil, err
+	}
+	edits, err := source.ToProtocolEdits(m, options.ComputeEdits(uri, string(m.Content), string(newContent)))
+	if err != nil {
+		return nil, err
+	}
+	return []source.Error{{
+		Category: workMissingGoCategory,
+		Message:  "The go.work file has no go directive, so the go version of the workspace is unspecified.",
+		URI:      uri,
+		SuggestedFixes: []source.SuggestedFix{{
+			Title: fmt.Sprintf("Add go %s", version),
+			Edits: map[span.URI][]protocol.TextEdit{
+				uri: edits,
+			},
+		}},
+	}}, nil
+}
+
+// workMapper returns the column mapper of the go.work file uri, whose
+// contents are given.
+func workMapper(uri span.URI, content []byte) *protocol.ColumnMapper {
+	return &protocol.ColumnMapper{
+		URI:       uri,
+		Converter: span.NewContentConverter(uri.Filename(), content),
+		Content:   content,
+	}
+}
+
+func goSemver(member workMember) string {
+	return "v" + member.file.Go.Version
+}
+
+// workReplaceCycleErrors reports the replace directives that replace a module
+// with the directory of another workspace member, when following such
+// replacements leads back to the member that made the first one.
+func workReplaceCycleErrors(members []workMember) ([]source.Error, error) {
+	// The nodes of the graph are the members, identified by their
+	// directories, and its edges are their directory replacements.
+	type edge struct {
+		to      string
+		replace *modfile.Replace
+	}
+	byDir := make(map[string]workMember, len(members))
+	for _, member := range members {
+		byDir[realDir(filepath.Dir(member.uri.Filename()))] = member
+	}
+	edges := make(map[string][]edge)
+	for dir, member := range byDir {
+		for _, r := range member.file.Replace {
+			if r.Syntax == nil || !modfile.IsDirectoryPath(r.New.Path) {
+				continue
+			}
+			to, err := resolveReplaceDir(dir, r.New.Path)
+			if err != nil {
+				continue
+			}
+			if _, ok := byDir[to]; ok {
+				edges[dir] = append(edges[dir], edge{to: to, replace: r})
+			}
+		}
+	}
+	// Find the edges that are part of a cycle: an edge from a to b is in a
+	// cycle if a is reachable from b.
+	reachable := func(from, target string) bool {
+		seen := make(map[string]bool)
+		var visit func(dir string) bool
+		visit = func(dir string) bool {
+			if dir == target {
+				return true
+			}
+			if seen[dir] {
+				return false
+			}
+			seen[dir] = true
+			for _, e := range edges[dir] {
+				if visit(e.to) {
+					return true
+				}
+			}
+			return false
+		}
+		return visit(from)
+	}
+	var dirs []string
+	for dir := range edges {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	var errors []source.Error
+	for _, dir := range dirs {
+		member := byDir[dir]
+		for _, e := range edges[dir] {
+			if !reachable(e.to, dir) {
+				continue
+			}
+			rng, err := positionsToRange(member.uri, member.m, e.replace.Syntax.Start, e.replace.Syntax.End)
+			if err != nil {
+				return nil, err
+			}
+			errors = append(errors, source.Error{
+				Category: workReplaceCycleCategory,
+				Message: fmt.Sprintf("%s is replaced with workspace module %s, whose replacements lead back to %s.",
+					e.replace.Old.Path, byDir[e.to].uri.Filename(), member.uri.Filename()),
+				Range: rng,
+				URI:   member.uri,
+			})
+		}
+	}
+	return errors, nil
+}
+
+// workReplaceOverrideErrors reports the replace directives of the workspace
+// members that the replaces of the go.work file at workFile override with a
+// different replacement. The go command only uses the go.work file's
+// replacement, so the member's is misleading, and the fix removes it.
+func workReplaceOverrideErrors(workFile string, replaces []workReplace, members []workMember, options source.Options) ([]source.Error, error) {
+	workDir := filepath.Dir(workFile)
+	var errors []source.Error
+	for _, member := range members {
+		memberDir := filepath.Dir(member.uri.Filename())
+		for _, r := range member.file.Replace {
+			if r.Syntax == nil {
+				continue
+			}
+			for _, w := range replaces {
+				if w.old.Path != r.Old.Path || (w.old.Version != "" && w.old.Version != r.Old.Version) {
+					continue
+				}
+				if sameReplacement(workDir, w.new, memberDir, r.New) {
+					continue
+				}
+				rng, err := positionsToRange(member.uri, member.m, r.Syntax.Start, r.Syntax.End)
+				if err != nil {
+					return nil, err
+				}
+				old := r.Old
+				edits, err := rewriteEdits(member.uri, member.m, options, func(copied *modfile.File) error {
+					return copied.DropReplace(old.Path, old.Version)
+				})
+				if err != nil {
+					return nil, err
+				}
+				errors = append(errors, source.Error{
+					Category: workReplaceOverrideCategory,
+					Message: fmt.Sprintf("%s is replaced with %s in %s, which overrides this replacement, so it can be removed.",
+						r.Old.Path, strings.TrimSpace(w.new.Path+" "+w.new.Version), workFile),
+					Range: rng,
+					URI:   member.uri,
+					SuggestedFixes: []source.SuggestedFix{{
+						Title: fmt.Sprintf("Remove replace %s", r.Old.Path),
+						Edits: map[span.URI][]protocol.TextEdit{
+							member.uri: edits,
+						},
+					}},
+				})
+				break
+			}
+		}
+	}
+	return errors, nil
+}
+
+// workSecurityReplaceErrors reports the requirements of the workspace
+// members on a module that another member replaces with a directive marked
+// as a security patch, by a comment containing marker, when the requiring
+// member does not replace the module itself. For replacements with another
+// module version, the fix adds the same replacement. The error points at the
+// requirement, and its related information at the marked directive. An
+// empty marker disables the check.
+func workSecurityReplaceErrors(members []workMember, marker string, options source.Options) ([]source.Error, error) {
+	if marker == "" {
+		return nil, nil
+	}
+	type securityReplace struct {
+		member workMember
+		r      *modfile.Replace
+	}
+	// Members are visited in order of their URIs, so that the first marked
+	// replacement of each module is the one that is reported.
+	sorted := append([]workMember(nil), members...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].uri < sorted[j].uri
+	})
+	marked := make(map[string]securityReplace)
+	for _, member := range sorted {
+		for _, r := range member.file.Replace {
+			if _, ok := marked[r.Old.Path]; !ok && r.Syntax != nil && hasMarker(r.Syntax, marker) {
+				marked[r.Old.Path] = securityReplace{member, r}
+			}
+		}
+	}
+	var errors []source.Error
+	for _, member := range sorted {
+		replaced := make(map[string]bool, len(member.file.Replace))
+		for _, r := range member.file.Replace {
+			replaced[r.Old.Path] = true
+		}
+		for _, req := range member.file.Require {
+			sec, ok := marked[req.Mod.Path]
+			if !ok || replaced[req.Mod.Path] || req.Syntax == nil || sec.member.uri == member.uri {
+				continue
+			}
+			if sec.r.Old.Version != "" && sec.r.Old.Version != req.Mod.Version {
+				continue
+			}
+			rng, err := positionsToRange(member.uri, member.m, req.Syntax.Start, req.Syntax.End)
+			if err != nil {
+				return nil, err
+			}
+			related, err := relatedLine(sec.member.uri, sec.member.m, sec.r.Syntax, "The security replacement is here.")
+			if err != nil {
+				return nil, err
+			}
+			e := source.Error{
+				Category: workSecurityReplaceCategory,
+				Message: fmt.Sprintf("%s replaces %s with %s as a security patch, but this module requires it without the replacement.",
+					sec.member.uri.Filename(), req.Mod.Path, strings.TrimSpace(sec.r.New.Path+" "+sec.r.New.Version)),
+				Range:   rng,
+				URI:     member.uri,
+				Related: related,
+			}
+			if !modfile.IsDirectoryPath(sec.r.New.Path) {
+				r := sec.r
+				edits, err := rewriteEdits(member.uri, member.m, options, func(copied *modfile.File) error {
+					return copied.AddReplace(r.Old.Path, r.Old.Version, r.New.Path, r.New.Version)
+				})
+				if err != nil {
+					return nil, err
+				}
+				e.SuggestedFixes = []source.SuggestedFix{{
+					Title: fmt.Sprintf("Replace %s with %s %s", r.Old.Path, r.New.Path, r.New.Version),
+					Edits: map[span.URI][]protocol.TextEdit{
+						member.uri: edits,
+					},
+				}}
+			}
+			errors = append(errors, e)
+		}
+	}
+	return errors, nil
+}
+
+// hasMarker reports whether a comment on line, at its end or on the lines
+// above it, contains marker.
+func hasMarker(line *modfile.Line, marker string) bool {
+	for _, com := range append(line.Before, line.Suffix...) {
+		if strings.Contains(com.Token, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameReplacement reports whether the replacements a and b, from files in
+// the directories aDir and bDir, refer to the same module version or
+// directory.
+func sameReplacement(aDir string, a module.Version, bDir string, b module.Version) bool {
+	if modfile.IsDirectoryPath(a.Path) != modfile.IsDirectoryPath(b.Path) {
+		return false
+	}
+	if !modfile.IsDirectoryPath(a.Path) {
+		return a == b
+	}
+	aResolved, aErr := resolveReplaceDir(aDir, a.Path)
+	bResolved, bErr := resolveReplaceDir(bDir, b.Path)
+	return aErr == nil && bErr == nil && aResolved == bResolved
+}
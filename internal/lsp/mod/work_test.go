@@ -0,0 +1,578 @@
+// Copyright 2020 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package mod
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/internal/lsp/cache"
+	"golang.org/x/tools/internal/lsp/diff"
+	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/lsp/source"
+	"golang.org/x/tools/internal/lsp/tests"
+	"golang.org/x/tools/internal/span"
+)
+
+func testWorkMember(t *testing.T, name, goVersion string) workMember {
+	t.Helper()
+	return parseWorkMember(t, name, fmt.Sprintf("module example.com/%s\n\ngo %s\n", name, goVersion))
+}
+
+// parseWorkMember parses the go.mod file of the workspace module in the
+// directory /name.
+func parseWorkMember(t *testing.T, name, contents string) workMember {
+	t.Helper()
+	uri := span.URIFromPath(fmt.Sprintf("/%s/go.mod", name))
+	file, err := modfile.Parse(uri.Filename(), []byte(contents), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return workMember{
+		uri: uri,
+		m: &protocol.ColumnMapper{
+			URI:       uri,
+			Converter: span.NewContentConverter(uri.Filename(), []byte(contents)),
+			Content:   []byte(contents),
+		},
+		file: file,
+	}
+}
+
+func TestWorkGoDirectiveErrors(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want bool
+	}{
+		{"1.16", "1.22", true},
+		{"1.17", "1.22", false},
+		{"1.14", "1.16", false},
+	} {
+		t.Run(tt.a+"-"+tt.b, func(t *testing.T) {
+			a, b := testWorkMember(t, "a", tt.a), testWorkMember(t, "b", tt.b)
+			errors, err := workGoDirectiveErrors([]workMember{b, a}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.want {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 2 {
+				t.Fatalf("got %d errors, want one for each member: %v", len(errors), errors)
+			}
+			want := fmt.Sprintf("The workspace modules mix go versions before and after go 1.17, so only some of them have a pruned module graph: example.com/a (go %s), example.com/b (go %s). Consider aligning their go directives.", tt.a, tt.b)
+			for i, member := range []workMember{a, b} {
+				e := errors[i]
+				if e.URI != member.uri {
+					t.Errorf("error %d: got URI %s, want %s", i, e.URI, member.uri)
+				}
+				if e.Message != want {
+					t.Errorf("error %d: got message %q, want %q", i, e.Message, want)
+				}
+				if got := rangeText(t, member.m, e.Range); got != "go "+member.file.Go.Version {
+					t.Errorf("error %d: got range covering %q, want the go directive", i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkGoDirectiveErrorsWorkFile(t *testing.T) {
+	// Member a is listed in a block and b on a line of its own; c is not
+	// listed, so it is reported on the go directive of the go.work file.
+	const work = "go 1.22\n\nuse (\n\t./a\n)\n\nuse \"./b\"\n"
+	a, b, c := testWorkMember(t, "a", "1.16"), testWorkMember(t, "b", "1.22"), testWorkMember(t, "c", "1.20")
+	errors, err := workGoDirectiveErrors([]workMember{a, b, c}, parseWork(span.URIFromPath("/go.work"), []byte(work)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	workURI := span.URIFromPath("/go.work")
+	_, m := testMapper(work)
+	for i, want := range []struct {
+		member workMember
+		line   string
+	}{
+		{a, "./a"},
+		{c, "go 1.22"},
+		{b, `use "./b"`},
+	} {
+		if i >= len(errors) {
+			t.Fatalf("got %d errors, want one for each member: %v", len(errors), errors)
+		}
+		e := errors[i]
+		if e.URI != workURI {
+			t.Errorf("error %d: got URI %s, want %s", i, e.URI, workURI)
+		}
+		if got := rangeText(t, m, e.Range); got != want.line {
+			t.Errorf("error %d: got range covering %q, want %q", i, got, want.line)
+		}
+		if len(e.Related) != 1 || e.Related[0].URI != want.member.uri {
+			t.Errorf("error %d: got related information %v, want the go directive of %s", i, e.Related, want.member.uri)
+			continue
+		}
+		if got := rangeText(t, want.member.m, e.Related[0].Range); got != "go "+want.member.file.Go.Version {
+			t.Errorf("error %d: got related range covering %q, want the go directive", i, got)
+		}
+	}
+}
+
+func TestWorkReplaceCycleErrors(t *testing.T) {
+	a := parseWorkMember(t, "a", `module example.com/a
+
+require example.com/b v1.0.0
+
+replace example.com/b => ../b
+`)
+	b := parseWorkMember(t, "b", `module example.com/b
+
+require example.com/a v1.0.0
+
+replace example.com/a => ../a
+`)
+	c := parseWorkMember(t, "c", `module example.com/c
+
+replace example.com/a => ../a
+`)
+	errors, err := workReplaceCycleErrors([]workMember{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 2 {
+		t.Fatalf("got %d errors, want one for each replacement in the cycle: %v", len(errors), errors)
+	}
+	for i, want := range []struct {
+		member workMember
+		text   string
+	}{
+		{a, "replace example.com/b => ../b"},
+		{b, "replace example.com/a => ../a"},
+	} {
+		e := errors[i]
+		if e.URI != want.member.uri {
+			t.Errorf("error %d: got URI %s, want %s", i, e.URI, want.member.uri)
+			continue
+		}
+		if got := rangeText(t, want.member.m, e.Range); got != want.text {
+			t.Errorf("error %d: got range covering %q, want %q", i, got, want.text)
+		}
+	}
+}
+
+func TestWorkFileInAncestor(t *testing.T) {
+	root, err := ioutil.TempDir("", "gopls-work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	const work = `go 1.18
+
+use (
+	./a
+	"./b"
+)
+
+use ./tools
+`
+	workFile := filepath.Join(root, "go.work")
+	if err := ioutil.WriteFile(workFile, []byte(work), 0644); err != nil {
+		t.Fatal(err)
+	}
+	folder := filepath.Join(root, "a", "cmd")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	exists := func(path string) bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}
+	// The opened folder is a subdirectory of the workspace root.
+	if got := findWorkFile(folder, exists); got != workFile {
+		t.Fatalf("got go.work file %q, want %q", got, workFile)
+	}
+	if got := findWorkFile(filepath.Dir(root), func(path string) bool { return path == workFile }); got != "" {
+		t.Errorf("got go.work file %q in a subdirectory of the folder, want none", got)
+	}
+	file, err := modfile.ParseLax(workFile, []byte(work), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs := workUseDirs(workFile, file)
+	want := []string{filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "tools")}
+	if !reflect.DeepEqual(dirs, want) {
+		t.Errorf("got use directories %v, want %v", dirs, want)
+	}
+}
+
+func TestWorkMembersUnparsed(t *testing.T) {
+	// The members of a go.work file that cannot be parsed are unknown; its
+	// parse errors are reported by workFileGoErrors.
+	work := parseWork(span.URIFromPath("/go.work"), []byte("go one\n\nuse ./a\n"))
+	if work.err == nil {
+		t.Fatal("parsed an invalid go directive")
+	}
+	members, _, err := workMembers(tests.Context(t), nil, work)
+	if err != nil || len(members) != 0 {
+		t.Errorf("workMembers = %v, %v, want no members", members, err)
+	}
+}
+
+func TestWorkDiagnostics(t *testing.T) {
+	ctx := tests.Context(t)
+	if reports, err := WorkDiagnostics(ctx, nil); err != nil || reports != nil {
+		t.Fatalf("WorkDiagnostics without snapshots = %v, %v, want nothing", reports, err)
+	}
+
+	root, err := ioutil.TempDir("", "gopls-work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"go.work":  "go 1.22\n\nuse (\n\t./a\n\t./b\n)\n",
+		"a/go.mod": "module example.com/a\n\ngo 1.16\n",
+		"b/go.mod": "module example.com/b\n\ngo 1.22\n",
+	}
+	for name, content := range files {
+		name = filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	session := cache.New(ctx, nil).NewSession(ctx)
+	options := tests.DefaultOptions()
+	options.Env = append(os.Environ(), "GOPACKAGESDRIVER=off", "GOWORK=off", "GOPROXY=off", "GOFLAGS=")
+	_, snapshot, err := session.NewView(ctx, "work_test", span.URIFromPath(root), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, err := WorkDiagnostics(ctx, []source.Snapshot{snapshot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every file of the workspace has a report, even without diagnostics.
+	got := make(map[string]int)
+	for id, diags := range reports {
+		rel, err := filepath.Rel(root, id.URI.Filename())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[filepath.ToSlash(rel)] = len(diags)
+	}
+	want := map[string]int{"go.work": 2, "a/go.mod": 0, "b/go.mod": 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got diagnostics per file %v, want %v", got, want)
+	}
+}
+
+func TestAffectedMembers(t *testing.T) {
+	members := []workMember{
+		parseWorkMember(t, "c", "module example.com/c\n\nrequire example.com/dep v1.0.0\n"),
+		parseWorkMember(t, "a", "module example.com/a\n\nrequire example.com/dep v1.1.0\n"),
+		parseWorkMember(t, "b", "module example.com/b\n\nrequire example.com/dep v1.2.0\n"),
+		parseWorkMember(t, "d", "module example.com/d\n\nrequire example.com/other v1.0.0\n"),
+	}
+	got := affectedMembers(members, "example.com/dep", "v1.2.0")
+	want := []span.URI{span.URIFromPath("/a/go.mod"), span.URIFromPath("/c/go.mod")}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got affected files %v, want %v", got, want)
+	}
+}
+
+func TestWorkReplaceOverrideErrors(t *testing.T) {
+	const work = `go 1.18
+
+use (
+	./a
+	./b
+)
+
+replace example.com/dep => ./fork
+
+replace (
+	example.com/other v1.0.0 => example.com/other v1.1.0
+	"example.com/same" => ./same
+)
+`
+	file, err := modfile.ParseLax("/go.work", []byte(work), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replaces := workReplaces(file)
+	if len(replaces) != 3 {
+		t.Fatalf("got %d go.work replaces, want 3: %v", len(replaces), replaces)
+	}
+	a := parseWorkMember(t, "a", `module example.com/a
+
+replace example.com/dep => ../dep
+
+replace example.com/same => ../same
+`)
+	b := parseWorkMember(t, "b", `module example.com/b
+
+replace example.com/other => example.com/other v1.2.0
+`)
+	errors, err := workReplaceOverrideErrors("/go.work", replaces, []workMember{a, b}, source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The replace of example.com/same agrees with the go.work file, and the
+	// go.work file only replaces one version of example.com/other.
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if e.URI != a.uri {
+		t.Fatalf("got error for %s, want %s", e.URI, a.uri)
+	}
+	if got := rangeText(t, a.m, e.Range); got != "replace example.com/dep => ../dep" {
+		t.Errorf("got range covering %q, want the overridden replace", got)
+	}
+	if want := "example.com/dep is replaced with ./fork in /go.work, which overrides this replacement, so it can be removed."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(a.m, e.SuggestedFixes[0].Edits[a.uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "module example.com/a\n\nreplace example.com/same => ../same\n"
+	if got := diff.ApplyEdits(string(a.m.Content), edits); got != want {
+		t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWorkSecurityReplaceErrors(t *testing.T) {
+	a := parseWorkMember(t, "a", `module example.com/a
+
+require (
+	example.com/dep v1.0.0
+	example.com/local v1.0.0
+)
+
+// security: CVE-2020-0001
+replace example.com/dep v1.0.0 => example.com/dep v1.0.1
+
+replace example.com/local => ../local // security
+`)
+	b := parseWorkMember(t, "b", `module example.com/b
+
+require (
+	example.com/dep v1.0.0
+	example.com/local v1.0.0
+)
+`)
+	c := parseWorkMember(t, "c", `module example.com/c
+
+require example.com/dep v1.1.0
+
+require example.com/local v1.0.0
+
+replace example.com/local => ../local
+`)
+	errors, err := workSecurityReplaceErrors([]workMember{c, b, a}, "security", source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// c requires another version of example.com/dep than the one that a
+	// replaces, and has its own replacement of example.com/local.
+	if len(errors) != 2 {
+		t.Fatalf("got %d errors, want 2: %v", len(errors), errors)
+	}
+	for _, e := range errors {
+		if e.URI != b.uri {
+			t.Errorf("got error for %s, want %s", e.URI, b.uri)
+		}
+	}
+	dep, local := errors[0], errors[1]
+	if got := rangeText(t, b.m, dep.Range); got != "example.com/dep v1.0.0" {
+		t.Errorf("got range covering %q, want the requirement", got)
+	}
+	if want := "/a/go.mod replaces example.com/dep with example.com/dep v1.0.1 as a security patch, but this module requires it without the replacement."; dep.Message != want {
+		t.Errorf("got message %q, want %q", dep.Message, want)
+	}
+	if len(dep.Related) != 1 || dep.Related[0].URI != a.uri {
+		t.Errorf("got related information %v, want the replace in %s", dep.Related, a.uri)
+	}
+	if len(dep.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(dep.SuggestedFixes))
+	}
+	edits, err := source.FromProtocolEdits(b.m, dep.SuggestedFixes[0].Edits[b.uri])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `module example.com/b
+
+require (
+	example.com/dep v1.0.0
+	example.com/local v1.0.0
+)
+
+replace example.com/dep v1.0.0 => example.com/dep v1.0.1
+`
+	if got := diff.ApplyEdits(string(b.m.Content), edits); got != want {
+		t.Errorf("fixed go.mod:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	// A directory replacement is relative to the module that has it, so it
+	// is not copied.
+	if len(local.SuggestedFixes) != 0 {
+		t.Errorf("got %d fixes for directory replacement, want none", len(local.SuggestedFixes))
+	}
+
+	errors, err = workSecurityReplaceErrors([]workMember{a, b, c}, "", source.DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 0 {
+		t.Errorf("got %d errors with no marker, want none", len(errors))
+	}
+}
+
+func TestWorkGoVersionErrors(t *testing.T) {
+	const work = `go 1.18
+
+use (
+	./a
+	./b
+	./c
+)
+`
+	a, b := testWorkMember(t, "a", "1.17"), testWorkMember(t, "b", "1.20")
+	c := parseWorkMember(t, "c", "module example.com/c\n")
+	errors, err := workGoVersionErrors(parseWork(span.URIFromPath("/go.work"), []byte(work)), []workMember{a, b, c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 1 {
+		t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+	}
+	e := errors[0]
+	if e.URI != b.uri {
+		t.Fatalf("got error for %s, want %s", e.URI, b.uri)
+	}
+	if got := rangeText(t, b.m, e.Range); got != "go 1.20" {
+		t.Errorf("got range covering %q, want the go directive", got)
+	}
+	if want := "This module requires go 1.20, but /go.work only lists go 1.18, so the go command cannot load the workspace. Consider aligning the go directives."; e.Message != want {
+		t.Errorf("got message %q, want %q", e.Message, want)
+	}
+	if len(e.SuggestedFixes) != 1 {
+		t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+	}
+	workURI := span.URIFromPath("/go.work")
+	_, workMapper := testMapper(work)
+	edits, err := source.FromProtocolEdits(workMapper, e.SuggestedFixes[0].Edits[workURI])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(work, "go 1.18", "go 1.20", 1)
+	if got := diff.ApplyEdits(work, edits); got != want {
+		t.Errorf("fixed go.work:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Without a go directive in the go.work file, there is nothing to
+	// compare with.
+	errors, err = workGoVersionErrors(parseWork(span.URIFromPath("/go.work"), []byte("use ./b\n")), []workMember{b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errors) != 0 {
+		t.Errorf("got %d errors without a go.work go directive, want none", len(errors))
+	}
+}
+
+func TestWorkFileGoErrors(t *testing.T) {
+	uri := span.URIFromPath("/work/go.work")
+	for _, tt := range []struct {
+		name, work    string
+		goversion     int
+		category, fix string
+	}{
+		{
+			name:      "missing",
+			work:      "use (\n\t./a\n\t./b\n)\n",
+			goversion: 22,
+			category:  workMissingGoCategory,
+			fix:       "use (\n\t./a\n\t./b\n)\n\ngo 1.22\n",
+		},
+		{
+			name:     "missing, unknown go command",
+			work:     "use ./a\n",
+			category: workMissingGoCategory,
+			fix:      "use ./a\n\ngo 1.18\n",
+		},
+		{
+			name:      "patch version",
+			work:      "go 1.22.1\n\nuse ./a\n",
+			goversion: 22,
+			category:  goPatchCategory,
+		},
+		{
+			name:     "patch version, unknown go command",
+			work:     "go 1.22.1\n\nuse ./a\n",
+			category: goDirectiveCategory,
+			fix:      "go 1.22\n\nuse ./a\n",
+		},
+		{
+			name:     "invalid",
+			work:     "go one\n\nuse ./a\n",
+			category: syntaxCategory,
+		},
+		{
+			name: "valid",
+			work: "go 1.22\n\nuse ./a\n",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			work := parseWork(uri, []byte(tt.work))
+			errors, err := workFileGoErrors(work, tt.goversion, source.DefaultOptions())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.category == "" {
+				if len(errors) > 0 {
+					t.Fatalf("unexpected errors: %v", errors)
+				}
+				return
+			}
+			if len(errors) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(errors), errors)
+			}
+			e := errors[0]
+			if e.Category != tt.category {
+				t.Errorf("got category %q, want %q", e.Category, tt.category)
+			}
+			if tt.fix == "" {
+				if len(e.SuggestedFixes) != 0 {
+					t.Errorf("got fixes %v, want none", e.SuggestedFixes)
+				}
+				return
+			}
+			if len(e.SuggestedFixes) != 1 {
+				t.Fatalf("got %d fixes, want 1", len(e.SuggestedFixes))
+			}
+			edits, err := source.FromProtocolEdits(work.m, e.SuggestedFixes[0].Edits[uri])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff.ApplyEdits(tt.work, edits); got != tt.fix {
+				t.Errorf("fixed go.work:\ngot:\n%s\nwant:\n%s", got, tt.fix)
+			}
+		})
+	}
+}
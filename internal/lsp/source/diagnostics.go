@@ -27,12 +27,21 @@ type Diagnostic struct {
 	Severity protocol.DiagnosticSeverity
 	Tags     []protocol.DiagnosticTag
 
+	// CodeHref, if set, links to the documentation of the diagnostic's
+	// source, which editors display along with it.
+	CodeHref string
+
 	Related []RelatedInformation
 }
 
 type SuggestedFix struct {
 	Title string
 	Edits map[span.URI][]protocol.TextEdit
+
+	// Command, if set, is executed to apply the fix instead of Edits. It is
+	// used for fixes that must run the go command, such as those that
+	// update go.sum or the module cache.
+	Command *protocol.Command
 }
 
 type RelatedInformation struct {
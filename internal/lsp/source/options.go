@@ -7,10 +7,16 @@ package source
 import (
 	"context"
 	"fmt"
+	"net/url"
 	"os"
 	"regexp"
+	"strings"
+	"text/template"
 	"time"
 
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 	"golang.org/x/tools/go/analysis"
 	"golang.org/x/tools/go/analysis/passes/asmdecl"
 	"golang.org/x/tools/go/analysis/passes/assign"
@@ -51,6 +57,7 @@ import (
 	"golang.org/x/tools/internal/lsp/diff"
 	"golang.org/x/tools/internal/lsp/diff/myers"
 	"golang.org/x/tools/internal/lsp/protocol"
+	"golang.org/x/tools/internal/span"
 	errors "golang.org/x/xerrors"
 )
 
@@ -67,11 +74,67 @@ const (
 	// CommandVendor is a gopls command to run `go mod vendor` for a module.
 	CommandVendor = "vendor"
 
-	// CommandUpgradeDependency is a gopls command to upgrade a dependency.
+	// CommandUpgradeDependency is a gopls command to upgrade a dependency, or
+	// to move it to a given version with `go get`.
 	CommandUpgradeDependency = "upgrade_dependency"
 
 	// CommandRegenerateCfgo is a gopls command to regenerate cgo definitions.
 	CommandRegenerateCgo = "regenerate_cgo"
+
+	// CommandSharedRequires is a gopls command to report the dependencies
+	// shared by the modules in the workspace, optionally aligning their
+	// versions.
+	CommandSharedRequires = "shared_requires"
+
+	// CommandAddDependency is a gopls command to add a dependency at a given
+	// version with `go get`, which also updates go.sum.
+	CommandAddDependency = "add_dependency"
+
+	// CommandInitModFile is a gopls command to create a go.mod file, with an
+	// inferred module path, for a directory that has none.
+	CommandInitModFile = "init_mod_file"
+
+	// CommandMigrateReplaces is a gopls command to move the directory
+	// replacements of a go.mod file to the use directives of a go.work file.
+	CommandMigrateReplaces = "migrate_replaces"
+
+	// CommandChangelog is a gopls command to return the changes of a module
+	// between two versions, as provided by the ChangelogSource hook.
+	CommandChangelog = "changelog"
+
+	// CommandMinimalReproducer is a gopls command to reduce a go.mod file to
+	// the requirements that select the same version of a module.
+	CommandMinimalReproducer = "minimal_reproducer"
+
+	// CommandRegenerateSum is a gopls command to rebuild a go.sum file from
+	// scratch with `go mod download all`, replacing all of its contents.
+	CommandRegenerateSum = "regenerate_sum"
+
+	// CommandDownloadModule is a gopls command to download a module version
+	// with `go mod download`, which records its checksums in go.sum.
+	CommandDownloadModule = "download_module"
+
+	// CommandRewriteImports is a gopls command to rewrite the imports that
+	// use the previous path of a renamed module to its current path.
+	CommandRewriteImports = "rewrite_imports"
+
+	// CommandMinimalGoDirective is a gopls command to set the go directive of
+	// a go.mod file to the lowest version under which the module builds.
+	CommandMinimalGoDirective = "minimal_go_directive"
+
+	// CommandAnnotateIndirect is a gopls command to annotate the indirect
+	// requirements of a go.mod file with the direct dependencies that need
+	// them, according to `go mod why`.
+	CommandAnnotateIndirect = "annotate_indirect"
+
+	// CommandDowngradeDependency is a gopls command to downgrade a dependency
+	// to the highest published version below its current one.
+	CommandDowngradeDependency = "downgrade_dependency"
+
+	// CommandMigrateIncompatible is a gopls command to move a +incompatible
+	// requirement, and the imports of its packages, to the versioned module
+	// path published for its major version.
+	CommandMigrateIncompatible = "migrate_incompatible"
 )
 
 // DefaultOptions is the options that are used for Gopls execution independent
@@ -98,13 +161,28 @@ func DefaultOptions() Options {
 					protocol.RefactorExtract:       true,
 				},
 				Mod: {
+					protocol.SourceFixAll:          true,
 					protocol.SourceOrganizeImports: true,
+					protocol.RefactorRewrite:       true,
 				},
 				Sum: {},
 			},
 			SupportedCommands: []string{
+				CommandAddDependency,
 				CommandGenerate,
+				CommandInitModFile,
+				CommandMigrateReplaces,
+				CommandChangelog,
+				CommandMinimalReproducer,
+				CommandRegenerateSum,
+				CommandDownloadModule,
+				CommandRewriteImports,
+				CommandMinimalGoDirective,
+				CommandAnnotateIndirect,
+				CommandDowngradeDependency,
+				CommandMigrateIncompatible,
 				CommandRegenerateCgo,
+				CommandSharedRequires,
 				CommandTest,
 				CommandTidy,
 				CommandUpgradeDependency,
@@ -120,6 +198,7 @@ func DefaultOptions() Options {
 			DeepCompletion:          true,
 			UnimportedCompletion:    true,
 			CompletionDocumentation: true,
+			IgnoredModDirs:          []string{"vendor", "testdata", "node_modules"},
 			EnabledCodeLens: map[string]bool{
 				CommandGenerate:          true,
 				CommandUpgradeDependency: true,
@@ -183,6 +262,63 @@ type UserOptions struct {
 	// BuildFlags is used to adjust the build flags applied to the view.
 	BuildFlags []string
 
+	// ModCache, if set, overrides the GOMODCACHE of every go command run
+	// for the view.
+	ModCache string
+
+	// FixTitleTemplate, if set, is a text/template that rewrites the titles
+	// of the suggested fixes for go.mod files. The template is executed with
+	// the original title as its data, so "go.mod: {{.}}" prefixes each
+	// title. It is parsed when the option is set.
+	FixTitleTemplate *template.Template
+
+	// IgnoredModDirs are the names of directories, such as vendor, whose
+	// go.mod files are not diagnosed when they are found inside another
+	// folder of the workspace, as they are not meant to be modules of it.
+	IgnoredModDirs []string
+
+	// AllowedModules, if set, are the module path prefixes that go.mod files
+	// may require or replace; other modules are reported as errors.
+	AllowedModules []string
+
+	// DeniedModules are the module path prefixes that go.mod files may not
+	// require or replace. They take precedence over AllowedModules.
+	DeniedModules []string
+
+	// ResolveVanityPaths enables resolving the modules that AllowedModules
+	// does not permit to the repositories that their vanity import paths
+	// redirect to, which are permitted if the repository is. It queries the
+	// go command, which may fetch from the network, so it is disabled by
+	// default.
+	ResolveVanityPaths bool
+
+	// SecurityReplaceMarker, if set, marks the replace directives that apply
+	// security patches: those with a comment containing it. A workspace
+	// module that requires a module that another workspace module replaces
+	// with such a directive, but does not replace it itself, is reported.
+	SecurityReplaceMarker string
+
+	// AllowedLicenses, if set, are the SPDX identifiers of the licenses that
+	// the modules required by go.mod files may have; other requirements are
+	// reported as warnings. Licenses are detected by the LicenseResolver hook.
+	AllowedLicenses []string
+
+	// KnownBreakages are the module versions known not to build with some
+	// go versions. Requirements on them are reported as warnings when the
+	// go directive selects such a go version, with a fix that upgrades them.
+	KnownBreakages []KnownBreakage
+
+	// ModuleForks maps the paths of modules that are no longer maintained to
+	// the maintained forks that replace them. Requirements on such modules
+	// are reported, with a fix that adds a replace directive for the fork.
+	ModuleForks map[string]module.Version
+
+	// PreviousModulePaths are the paths that the main module had before it
+	// was renamed. Imports that still use them are reported on the module
+	// directive, with a fix that rewrites them. Finding them type-checks the
+	// workspace packages, so nothing is checked if the list is empty.
+	PreviousModulePaths []string
+
 	// HoverKind specifies the format of the content for hover requests.
 	HoverKind HoverKind
 
@@ -283,6 +419,83 @@ type Hooks struct {
 	TypeErrorAnalyzers   map[string]Analyzer
 	ConvenienceAnalyzers map[string]Analyzer
 	GofumptFormat        func(ctx context.Context, src []byte) ([]byte, error)
+
+	// ModValidators are run over each parsed go.mod file after the
+	// built-in checks, and the errors they return are reported with the
+	// file's other diagnostics. They allow custom builds of gopls to
+	// enforce their own policies, such as forbidding replace directives.
+	ModValidators []ModValidator
+
+	// LicenseResolver detects the licenses of the modules required by
+	// go.mod files, for the AllowedLicenses check. Without one, the check
+	// does not run, as gopls has no built-in way to detect licenses.
+	LicenseResolver LicenseResolver
+
+	// ChangelogSource provides the changelogs returned by the changelog
+	// command. Without one, no changelog is available.
+	ChangelogSource ChangelogSource
+
+	// ModSynchronizers compare the requirements of each go.mod file with
+	// external sources that must be kept in sync with it, such as a tools.go
+	// file or a custom dependency manifest, and their drift from go.mod is
+	// reported with the file's other diagnostics.
+	ModSynchronizers []ModSynchronizer
+}
+
+// A ModValidator checks the parsed go.mod file uri, whose contents are those
+// of m, and returns the errors it finds. The errors' categories determine
+// their diagnostics' sources; categories that gopls does not know are
+// reported as warnings.
+type ModValidator func(uri span.URI, m *protocol.ColumnMapper, file *modfile.File) ([]Error, error)
+
+// A KnownBreakage records that the versions of Module below Fixed do not
+// build with go versions Go and later, for example because they depend on
+// internals of the standard library that changed. Reason, if set, explains
+// the breakage in diagnostics.
+type KnownBreakage struct {
+	Module, Fixed, Go, Reason string
+}
+
+// A LicenseResolver detects the license of a module version. License returns
+// its SPDX identifier, such as "BSD-3-Clause", or "" if no license is found.
+type LicenseResolver interface {
+	License(ctx context.Context, mod module.Version) (string, error)
+}
+
+// A ChangelogSource provides the release notes of a module between two
+// versions, for example by comparing the tags of its repository. Changelog
+// returns "" if there are none.
+type ChangelogSource interface {
+	Changelog(ctx context.Context, path, from, to string) (string, error)
+}
+
+// A ModSynchronizer lists the dependencies that an external source, such as
+// a tools.go file, expects of the go.mod file uri. Name names the source in
+// diagnostics.
+type ModSynchronizer interface {
+	Name() string
+	Requirements(ctx context.Context, snapshot Snapshot, uri span.URI) ([]ExternalRequirement, error)
+}
+
+// An ExternalRequirement is a dependency listed outside of go.mod. Path is a
+// module path, or the path of a package that a required module must provide.
+// Version, if set, is the version that go.mod must require.
+type ExternalRequirement struct {
+	Path, Version string
+}
+
+// FixTitle returns the title of a suggested fix, rewritten by the
+// FixTitleTemplate option if it is set. If the template fails, the title is
+// returned unchanged.
+func (o Options) FixTitle(title string) string {
+	if o.FixTitleTemplate == nil {
+		return title
+	}
+	var buf strings.Builder
+	if err := o.FixTitleTemplate.Execute(&buf, title); err != nil {
+		return title
+	}
+	return buf.String()
 }
 
 func (o Options) AddDefaultAnalyzer(a *analysis.Analyzer) {
@@ -299,6 +512,84 @@ type ExperimentalOptions struct {
 	// VerboseWorkDoneProgress controls whether the LSP server should send
 	// progress reports for all work done outside the scope of an RPC.
 	VerboseWorkDoneProgress bool
+
+	// TidyTimeout limits how long the `go mod tidy` diagnostics for a go.mod
+	// file may run. Zero means unlimited.
+	TidyTimeout time.Duration
+
+	// TidyCompat is the Go version passed to `go mod tidy` as its -compat
+	// flag when computing the tidy diagnostics, so that they keep the
+	// indirect requirements needed by that version. Empty means the flag is
+	// not passed.
+	TidyCompat string
+
+	// IndirectRequireThreshold is the number of indirect requirements in a
+	// go.mod file above which an informational diagnostic suggests reviewing
+	// the module's dependencies. Zero disables the diagnostic.
+	IndirectRequireThreshold int
+
+	// MajorVersionLayout enables a diagnostic for go.mod files whose module
+	// path has a major version suffix that does not match the directory
+	// containing the file.
+	MajorVersionLayout bool
+
+	// CheckEarliestVersions enables a diagnostic for requirements on a
+	// version that is older than the module's earliest published version.
+	// It queries the module proxy, so it is disabled by default.
+	CheckEarliestVersions bool
+
+	// CheckGoneVersions enables a diagnostic for requirements on a version
+	// that the module proxy no longer serves. It queries the module proxy,
+	// so it is disabled by default.
+	CheckGoneVersions bool
+
+	// CheckReplaceTargets enables a diagnostic for module replacements whose
+	// target version is not published. It queries the module proxy, so it
+	// is disabled by default.
+	CheckReplaceTargets bool
+
+	// SingleImporterRequires enables an informational diagnostic for direct
+	// requirements whose module is imported by only one non-test file,
+	// which may have been added by accident.
+	SingleImporterRequires bool
+
+	// TargetPlatform, if set to a GOOS/GOARCH pair such as linux/arm64,
+	// enables a diagnostic for direct requirements that are not used by any
+	// package built for that platform, even if they are used on others, or
+	// whose imported packages are all excluded by build constraints on it.
+	TargetPlatform string
+
+	// StalePseudoVersionAge, if positive, enables an informational
+	// diagnostic for requirements on a pseudo-version whose commit is more
+	// than this much older than the latest version of the module.
+	StalePseudoVersionAge time.Duration
+
+	// DependencyReplaces enables an informational diagnostic for direct
+	// requirements whose own go.mod file has replace directives, which do
+	// not apply to the main module.
+	DependencyReplaces bool
+
+	// MainOnlyRequires enables an informational diagnostic for direct
+	// requirements whose module only has main packages, which cannot be
+	// imported, unless a tools.go file tracks them.
+	MainOnlyRequires bool
+
+	// ModuleCycles enables an informational diagnostic for requirements
+	// that are part of, or lead to, a cycle in the module graph.
+	ModuleCycles bool
+
+	// ModDirectOnly limits the go.mod diagnostics, and the checks that query
+	// the module proxy, to the direct requirements.
+	ModDirectOnly bool
+
+	// CheckGopathLayout enables an informational diagnostic for go.mod files
+	// in a GOPATH src directory whose module path differs from the import
+	// path that GOPATH mode gives their packages.
+	CheckGopathLayout bool
+
+	// ModDiagnosticDocs, if set, is the base URL of the documentation of the
+	// go.mod diagnostic categories, which diagnostics link to.
+	ModDiagnosticDocs string
 }
 
 // DebuggingOptions should not affect the logical execution of Gopls, but may
@@ -431,6 +722,138 @@ func (o *Options) set(name string, value interface{}) OptionResult {
 			o.Env = append(o.Env, fmt.Sprintf("%s=%s", k, v))
 		}
 
+	case "modCache":
+		result.setString(&o.ModCache)
+
+	case "fixTitleTemplate":
+		if v, ok := result.asString(); ok {
+			if v == "" {
+				o.FixTitleTemplate = nil
+				break
+			}
+			tmpl, err := template.New("fixTitle").Parse(v)
+			if err != nil {
+				result.errorf("invalid fix title template %q: %v", v, err)
+				break
+			}
+			o.FixTitleTemplate = tmpl
+		}
+
+	case "ignoredModDirs":
+		idirs, ok := value.([]interface{})
+		if !ok {
+			result.errorf("invalid config gopls.ignoredModDirs type %T", value)
+			break
+		}
+		dirs := make([]string, 0, len(idirs))
+		for _, dir := range idirs {
+			dirs = append(dirs, fmt.Sprintf("%s", dir))
+		}
+		o.IgnoredModDirs = dirs
+
+	case "allowedModules", "deniedModules":
+		iprefixes, ok := value.([]interface{})
+		if !ok {
+			result.errorf("invalid config gopls.%s type %T", name, value)
+			break
+		}
+		prefixes := make([]string, 0, len(iprefixes))
+		for _, prefix := range iprefixes {
+			prefixes = append(prefixes, fmt.Sprintf("%s", prefix))
+		}
+		if name == "allowedModules" {
+			o.AllowedModules = prefixes
+		} else {
+			o.DeniedModules = prefixes
+		}
+
+	case "previousModulePaths":
+		ipaths, ok := value.([]interface{})
+		if !ok {
+			result.errorf("invalid config gopls.previousModulePaths type %T", value)
+			break
+		}
+		paths := make([]string, 0, len(ipaths))
+		for _, path := range ipaths {
+			paths = append(paths, fmt.Sprintf("%s", path))
+		}
+		o.PreviousModulePaths = paths
+
+	case "resolveVanityPaths":
+		result.setBool(&o.ResolveVanityPaths)
+
+	case "securityReplaceMarker":
+		if v, ok := result.asString(); ok {
+			o.SecurityReplaceMarker = v
+		}
+
+	case "allowedLicenses":
+		ilicenses, ok := value.([]interface{})
+		if !ok {
+			result.errorf("invalid config gopls.allowedLicenses type %T", value)
+			break
+		}
+		licenses := make([]string, 0, len(ilicenses))
+		for _, license := range ilicenses {
+			licenses = append(licenses, fmt.Sprintf("%s", license))
+		}
+		o.AllowedLicenses = licenses
+
+	case "knownBreakages":
+		ientries, ok := value.([]interface{})
+		if !ok {
+			result.errorf("invalid config gopls.knownBreakages type %T", value)
+			break
+		}
+		breakages := make([]KnownBreakage, 0, len(ientries))
+		for _, ientry := range ientries {
+			entry, ok := ientry.(map[string]interface{})
+			if !ok {
+				result.errorf("invalid gopls.knownBreakages entry type %T", ientry)
+				break
+			}
+			field := func(key string) string {
+				if v, ok := entry[key].(string); ok {
+					return v
+				}
+				return ""
+			}
+			b := KnownBreakage{
+				Module: field("module"),
+				Fixed:  field("fixed"),
+				Go:     field("go"),
+				Reason: field("reason"),
+			}
+			if b.Module == "" || !semver.IsValid(b.Fixed) || !strings.HasPrefix(b.Go, "1.") {
+				result.errorf("invalid gopls.knownBreakages entry %v: want a module, a fixed semantic version, and a go version such as 1.15", entry)
+				break
+			}
+			breakages = append(breakages, b)
+		}
+		if result.Error == nil {
+			o.KnownBreakages = breakages
+		}
+
+	case "moduleForks":
+		iforks, ok := value.(map[string]interface{})
+		if !ok {
+			result.errorf("invalid config gopls.moduleForks type %T", value)
+			break
+		}
+		forks := make(map[string]module.Version, len(iforks))
+		for path, ifork := range iforks {
+			fork, _ := ifork.(string)
+			i := strings.LastIndex(fork, "@")
+			if path == "" || i <= 0 || !semver.IsValid(fork[i+1:]) {
+				result.errorf("invalid gopls.moduleForks entry %q: %v: want a module path and version such as example.com/fork@v1.2.0", path, ifork)
+				break
+			}
+			forks[path] = module.Version{Path: fork[:i], Version: fork[i+1:]}
+		}
+		if result.Error == nil {
+			o.ModuleForks = forks
+		}
+
 	case "buildFlags":
 		iflags, ok := value.([]interface{})
 		if !ok {
@@ -568,6 +991,91 @@ func (o *Options) set(name string, value interface{}) OptionResult {
 	case "tempModfile":
 		result.setBool(&o.TempModfile)
 
+	case "tidyTimeout":
+		if v, ok := result.asString(); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				result.errorf("failed to parse duration %q: %v", v, err)
+				break
+			}
+			o.TidyTimeout = d
+		}
+
+	case "tidyCompat":
+		if v, ok := result.asString(); ok {
+			if v != "" && !modfile.GoVersionRE.MatchString(v) {
+				result.errorf("invalid Go version %q for option %q, expected a version such as \"1.17\"", v, result.Name)
+				break
+			}
+			o.TidyCompat = v
+		}
+
+	case "indirectRequireThreshold":
+		v, ok := result.Value.(float64)
+		if !ok {
+			result.errorf("Invalid type %T for int option %q", result.Value, result.Name)
+			break
+		}
+		o.IndirectRequireThreshold = int(v)
+
+	case "majorVersionLayout":
+		result.setBool(&o.MajorVersionLayout)
+
+	case "checkEarliestVersions":
+		result.setBool(&o.CheckEarliestVersions)
+
+	case "checkGoneVersions":
+		result.setBool(&o.CheckGoneVersions)
+
+	case "checkReplaceTargets":
+		result.setBool(&o.CheckReplaceTargets)
+
+	case "singleImporterRequires":
+		result.setBool(&o.SingleImporterRequires)
+
+	case "dependencyReplaces":
+		result.setBool(&o.DependencyReplaces)
+
+	case "mainOnlyRequires":
+		result.setBool(&o.MainOnlyRequires)
+
+	case "moduleCycles":
+		result.setBool(&o.ModuleCycles)
+
+	case "modDirectOnly":
+		result.setBool(&o.ModDirectOnly)
+
+	case "checkGopathLayout":
+		result.setBool(&o.CheckGopathLayout)
+
+	case "modDiagnosticDocs":
+		if v, ok := result.asString(); ok {
+			if u, err := url.Parse(v); v != "" && (err != nil || !u.IsAbs()) {
+				result.errorf("invalid documentation URL %q, want an absolute URL", v)
+				break
+			}
+			o.ModDiagnosticDocs = v
+		}
+
+	case "targetPlatform":
+		if v, ok := result.asString(); ok {
+			if parts := strings.Split(v, "/"); v != "" && (len(parts) != 2 || parts[0] == "" || parts[1] == "") {
+				result.errorf("invalid platform %q, want GOOS/GOARCH", v)
+				break
+			}
+			o.TargetPlatform = v
+		}
+
+	case "stalePseudoVersionAge":
+		if v, ok := result.asString(); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				result.errorf("failed to parse duration %q: %v", v, err)
+				break
+			}
+			o.StalePseudoVersionAge = d
+		}
+
 	case "gofumpt":
 		result.setBool(&o.Gofumpt)
 
@@ -54,6 +54,10 @@ type Snapshot interface {
 	// -modfile flag, if possible.
 	RunGoCommand(ctx context.Context, verb string, args []string) (*bytes.Buffer, error)
 
+	// RunGoCommandEnv is like RunGoCommand, but adds the given environment
+	// variables, such as GOOS=windows, to the view's environment.
+	RunGoCommandEnv(ctx context.Context, env []string, verb string, args []string) (*bytes.Buffer, error)
+
 	// RunGoCommandDirect runs the given `go` command, never using the
 	// -modfile flag.
 	RunGoCommandDirect(ctx context.Context, verb string, args []string) error
@@ -133,6 +137,10 @@ type View interface {
 	// ModFile is the go.mod file at the root of this view. It may not exist.
 	ModFile() span.URI
 
+	// GoVersion returns the minor version of the go command used by this
+	// view, e.g. 14 for go1.14. It returns 0 if the version is unknown.
+	GoVersion() int
+
 	// BuiltinPackage returns the go/ast.Object for the given name in the builtin package.
 	BuiltinPackage(ctx context.Context) (BuiltinPackage, error)
 
@@ -174,6 +182,19 @@ type View interface {
 	// by the GOPRIVATE environment variable.
 	IsGoPrivatePath(path string) bool
 
+	// SkipsSumCheck reports whether the go.sum entries of the module path
+	// need not be checked, because it matches GONOSUMDB, which defaults to
+	// GOPRIVATE, or because GONOSUMCHECK=1 disables the checks for all
+	// modules.
+	SkipsSumCheck(path string) bool
+
+	// GoFlags returns the flags set by the view's GOFLAGS environment
+	// variable.
+	GoFlags() []string
+
+	// GOPATH returns the directories of the view's GOPATH.
+	GOPATH() []string
+
 	// IgnoredFile reports if a file would be ignored by a `go list` of the whole
 	// workspace.
 	IgnoredFile(uri span.URI) bool
@@ -343,10 +364,46 @@ type ModWhyHandle interface {
 type ModTidyHandle interface {
 	// Tidy returns the results of `go mod tidy` for the module.
 	Tidy(ctx context.Context) (map[string]*modfile.Require, []Error, error)
+
+	// TidyFile returns the results of `go mod tidy` for the module, as if its
+	// go.mod file had been replaced by the given parsed file. It reuses the
+	// tidied go.mod file computed for Tidy, rather than running the go
+	// command again.
+	TidyFile(ctx context.Context, file *modfile.File, m *protocol.ColumnMapper) (map[string]*modfile.Require, []Error, error)
+
+	// Ideal returns the go.mod file that `go mod tidy` would produce for the
+	// module, or nil if it could not be computed.
+	Ideal(ctx context.Context) (*modfile.File, error)
+
+	// IdealSum returns the contents of the go.sum file that `go mod tidy`
+	// would produce for the module, or nil if it would produce none.
+	IdealSum(ctx context.Context) ([]byte, error)
 }
 
 var ErrTmpModfileUnsupported = errors.New("-modfile is unsupported for this Go version")
 
+// A GoCommandError is the error returned when the go command fails. Stderr is
+// what the command wrote to its standard error, which usually describes the
+// cause, such as a module that cannot be downloaded.
+type GoCommandError struct {
+	// Command is the go command that was run, without the go, such as
+	// "mod tidy".
+	Command string
+	Stderr  string
+	Err     error
+}
+
+func (e *GoCommandError) Error() string {
+	if e.Stderr == "" {
+		return fmt.Sprintf("go %s: %v", e.Command, e.Err)
+	}
+	return fmt.Sprintf("go %s: %v: %s", e.Command, e.Err, e.Stderr)
+}
+
+func (e *GoCommandError) Unwrap() error {
+	return e.Err
+}
+
 // ParseMode controls the content of the AST produced when parsing a source file.
 type ParseMode int
 
@@ -43,6 +43,10 @@ const (
 	// the cgo sources for the workspace.
 	FromRegenerateCgo
 
+	// FromRegenerateSum refers to file modifications caused by rebuilding
+	// a go.sum file with the regenerate_sum command.
+	FromRegenerateSum
+
 	// FromInitialWorkspaceLoad refers to the loading of all packages in the
 	// workspace when the view is first created.
 	FromInitialWorkspaceLoad
@@ -62,6 +66,8 @@ func (m ModificationSource) String() string {
 		return "close files"
 	case FromRegenerateCgo:
 		return "regenerate cgo"
+	case FromRegenerateSum:
+		return "regenerate go.sum"
 	case FromInitialWorkspaceLoad:
 		return "initial workspace load"
 	default: